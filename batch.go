@@ -0,0 +1,42 @@
+package speedmap
+
+// Batcher is an optional interface for stores that can get or put many keys
+// in a single operation more efficiently than one key at a time, e.g. by
+// acquiring a lock only once for the entire batch.
+type Batcher interface {
+	MultiGet(keys []string) (values [][]byte, err error)
+	MultiPut(pairs map[string][]byte) (err error)
+}
+
+// MultiGet returns the values for the specified keys in the same order as
+// the keys. If a key is not in the store its value is nil; misses are not
+// treated as errors. If the store implements Batcher its MultiGet method is
+// used, otherwise each key is fetched with Get in turn.
+func MultiGet(store Store, keys []string) (values [][]byte, err error) {
+	if batcher, ok := Unwrap(store).(Batcher); ok {
+		return batcher.MultiGet(keys)
+	}
+
+	values = make([][]byte, len(keys))
+	for i, key := range keys {
+		// Not found errors are treated as nil values
+		values[i], _ = store.Get(key)
+	}
+	return values, nil
+}
+
+// MultiPut stores all of the specified key/value pairs. If the store
+// implements Batcher its MultiPut method is used, otherwise each pair is
+// stored with Put in turn, stopping at the first error.
+func MultiPut(store Store, pairs map[string][]byte) (err error) {
+	if batcher, ok := Unwrap(store).(Batcher); ok {
+		return batcher.MultiPut(pairs)
+	}
+
+	for key, value := range pairs {
+		if err = store.Put(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
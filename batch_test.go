@@ -0,0 +1,41 @@
+package speedmap_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bbengfort/speedmap"
+	"github.com/bbengfort/speedmap/store"
+)
+
+var _ = Describe("Batch", func() {
+
+	It("should get and put batches on stores that are not batchers", func() {
+		kv, err := store.NewSyncMap()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var batcher interface{} = kv
+		_, ok := batcher.(Batcher)
+		Ω(ok).Should(BeFalse())
+
+		pairs := map[string][]byte{"foo": []byte("bar"), "baz": []byte("qux")}
+		Ω(MultiPut(kv, pairs)).Should(Succeed())
+
+		vals, err := MultiGet(kv, []string{"baz", "missing", "foo"})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(vals).Should(Equal([][]byte{[]byte("qux"), nil, []byte("bar")}))
+	})
+
+	It("should detect batchers wrapped by a context store", func() {
+		kv, err := store.NewBasic()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		pairs := map[string][]byte{"foo": []byte("bar")}
+		Ω(MultiPut(WithContext(kv), pairs)).Should(Succeed())
+
+		vals, err := kv.MultiGet([]string{"foo"})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(vals).Should(Equal([][]byte{[]byte("bar")}))
+	})
+
+})
@@ -2,7 +2,10 @@ package speedmap
 
 import (
 	"errors"
+	"io"
 	"os"
+	"runtime"
+	"time"
 )
 
 // New returns a Benchmark object ready to evaluate Stores. If maxthreads is
@@ -22,16 +25,41 @@ func New(workload Workload, maxthreads int) *Benchmark {
 type Benchmark struct {
 	Workload       Workload
 	MaxConcurrency int
+	MeasureGC      bool // Force and time a full GC after each run of the workload
 	Results        []*Result
 }
 
-// Run the benchmark against the specified Store.
+// Run the benchmark against the specified Store, recording the garbage
+// collection statistics of each run of the workload in its result. If
+// MeasureGC is set, a full collection is forced and timed after each run,
+// which measures how long the GC takes to scan the store. If the store is
+// Timed, the latency percentiles of each run are recorded as well.
 func (b *Benchmark) Run(store Store) (err error) {
+	var before, after runtime.MemStats
+	timed, _ := Unwrap(store).(Timed)
 	for i := 1; i <= b.MaxConcurrency; i++ {
+		if timed != nil {
+			// Discard the latencies of operations before the run, e.g. a prefill
+			timed.Latencies()
+		}
+		runtime.ReadMemStats(&before)
+
 		var result *Result
 		if result, err = b.Workload.Run(store, i); err != nil {
 			return err
 		}
+
+		runtime.ReadMemStats(&after)
+		result.setMemStats(&before, &after)
+		if timed != nil {
+			result.setLatencies(timed.Latencies())
+		}
+
+		if b.MeasureGC {
+			start := time.Now()
+			runtime.GC()
+			result.ForcedGC = time.Since(start)
+		}
 		b.Results = append(b.Results, result)
 	}
 	return nil
@@ -49,15 +77,24 @@ func (b *Benchmark) Save(path string) (err error) {
 	}
 	defer file.Close()
 
+	return b.Write(file)
+}
+
+// Write the benchmarks as CSV to the writer, in the format saved to disk.
+func (b *Benchmark) Write(w io.Writer) (err error) {
+	if len(b.Results) < 1 {
+		return errors.New("no results to write")
+	}
+
 	// Write the header of the CSV file.
-	header := "store,workload,concurrency,operations,duration (ns),throughput\n"
-	if _, err = file.Write([]byte(header)); err != nil {
+	header := "store,workload,concurrency,operations,duration (ns),throughput,aborts,abort rate,hit ratio,evictions,gc cycles,gc pause (ns),max gc pause (ns),forced gc (ns),heap objects,p50 latency (ns),p90 latency (ns),p99 latency (ns),max latency (ns)\n"
+	if _, err = w.Write([]byte(header)); err != nil {
 		return err
 	}
 
 	// Write each of the result rows
 	for _, result := range b.Results {
-		if _, err = file.Write([]byte(result.String())); err != nil {
+		if _, err = w.Write([]byte(result.String())); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,27 @@
+package speedmap
+
+// Bounded is an optional interface for stores whose size is bounded, e.g.
+// caches that evict values to stay within a maximum number of entries or
+// bytes. Stats reports the effectiveness of the cache since it was created.
+type Bounded interface {
+	Stats() CacheStats
+}
+
+// CacheStats describes the hits, misses, and evictions of a bounded store
+// along with its current size.
+type CacheStats struct {
+	Hits      uint64 // The number of reads that found the key
+	Misses    uint64 // The number of reads that did not find the key
+	Evictions uint64 // The number of keys evicted to make room for others
+	Entries   int    // The number of keys currently stored
+	Bytes     int64  // The number of bytes of keys and values currently stored
+}
+
+// HitRatio returns the fraction of reads that found the key.
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits == 0 {
+		return 0.0
+	}
+
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
@@ -0,0 +1,193 @@
+/*
+Package cluster implements the consistent-hash ring that partitions the keys
+of a speedmap cluster between the servers that are its members, so that each
+server only stores the keys it owns and clients can route requests to them.
+*/
+package cluster
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultVirtualNodes is the number of points each member has on the ring if
+// the number is not specified. More points spread the keys more evenly
+// between the members at the cost of a larger ring.
+const DefaultVirtualNodes = 128
+
+// Member of a cluster, identified by its unique name and served on its address.
+type Member struct {
+	Name string
+	Addr string
+}
+
+// Ring assigns every key to the member of the cluster that owns it. Each
+// member has a number of virtual nodes, points on a ring of 64 bit hashes, and
+// a key is owned by the member of the first point at or after the hash of the
+// key. Adding or removing a member only moves the keys of the points next to
+// its own, and every ring built with the same members and number of virtual
+// nodes assigns the keys in the same way, so servers and clients can each
+// build the ring from the membership. A Ring is immutable and safe for
+// concurrent use.
+type Ring struct {
+	members map[string]Member
+	vnodes  int
+	points  []point // sorted by hash
+}
+
+// A virtual node of a member on the ring.
+type point struct {
+	hash   uint64
+	member string
+}
+
+// NewRing creates a ring of the members with the specified number of virtual
+// nodes for each, DefaultVirtualNodes if vnodes is zero or less. Member names
+// must be unique and not empty.
+func NewRing(members []Member, vnodes int) (ring *Ring, err error) {
+	if len(members) == 0 {
+		return nil, errors.New("a ring requires at least one member")
+	}
+
+	if vnodes <= 0 {
+		vnodes = DefaultVirtualNodes
+	}
+
+	ring = &Ring{
+		members: make(map[string]Member, len(members)),
+		vnodes:  vnodes,
+		points:  make([]point, 0, len(members)*vnodes),
+	}
+
+	for _, member := range members {
+		if member.Name == "" {
+			return nil, errors.New("members of a ring must have a name")
+		}
+
+		if _, ok := ring.members[member.Name]; ok {
+			return nil, fmt.Errorf("duplicate member %q", member.Name)
+		}
+		ring.members[member.Name] = member
+
+		for i := 0; i < vnodes; i++ {
+			ring.points = append(ring.points, point{hash: hash(member.Name + "#" + strconv.Itoa(i)), member: member.Name})
+		}
+	}
+
+	// Ties are broken by name so that the order does not depend on the members
+	sort.Slice(ring.points, func(i, j int) bool {
+		if ring.points[i].hash == ring.points[j].hash {
+			return ring.points[i].member < ring.points[j].member
+		}
+		return ring.points[i].hash < ring.points[j].hash
+	})
+	return ring, nil
+}
+
+// Load the members of a cluster from a membership file (see LoadMembers) and
+// create their ring with the specified number of virtual nodes.
+func Load(path string, vnodes int) (ring *Ring, err error) {
+	var members []Member
+	if members, err = LoadMembers(path); err != nil {
+		return nil, err
+	}
+
+	if ring, err = NewRing(members, vnodes); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return ring, nil
+}
+
+// LoadMembers reads the members of a cluster from a membership file. Each line
+// of the file is the name of a member followed by its address; blank lines
+// and lines that begin with # are ignored:
+//
+//	# name  address
+//	alpha   10.0.0.1:3264
+//	bravo   10.0.0.2:3264
+func LoadMembers(path string) (members []Member, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a name and an address", path, line)
+		}
+
+		if prev, ok := names[fields[0]]; ok {
+			return nil, fmt.Errorf("%s:%d: %q is already a member on line %d", path, line, fields[0], prev)
+		}
+
+		names[fields[0]] = line
+		members = append(members, Member{Name: fields[0], Addr: fields[1]})
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// Owner returns the member that owns the key.
+func (r *Ring) Owner(key string) Member {
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.members[r.points[i].member]
+}
+
+// Member returns the member with the name, if it is a member of the ring.
+func (r *Ring) Member(name string) (member Member, ok bool) {
+	member, ok = r.members[name]
+	return member, ok
+}
+
+// Members returns the members of the ring sorted by name.
+func (r *Ring) Members() []Member {
+	members := make([]Member, 0, len(r.members))
+	for _, member := range r.members {
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members
+}
+
+// VirtualNodes returns the number of points each member has on the ring.
+func (r *Ring) VirtualNodes() int {
+	return r.vnodes
+}
+
+// Hashes the key with FNV-1a, then mixes the bits of the hash with the
+// finalizer of MurmurHash3 since FNV alone spreads similar keys (such as the
+// names of the virtual nodes of a member) poorly around the ring.
+func hash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,132 @@
+package cluster_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bbengfort/speedmap/cluster"
+)
+
+var _ = Describe("Ring", func() {
+
+	members := []Member{
+		{Name: "alpha", Addr: "127.0.0.1:3264"},
+		{Name: "bravo", Addr: "127.0.0.1:3265"},
+		{Name: "charlie", Addr: "127.0.0.1:3266"},
+	}
+
+	It("should spread keys evenly between the members", func() {
+		ring, err := NewRing(members, 0)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(ring.VirtualNodes()).Should(Equal(DefaultVirtualNodes))
+
+		counts := make(map[string]int)
+		for i := 0; i < 30000; i++ {
+			counts[ring.Owner(fmt.Sprintf("key-%d", i)).Name]++
+		}
+
+		Ω(counts).Should(HaveLen(3))
+		for _, count := range counts {
+			Ω(count).Should(BeNumerically("~", 10000, 2000))
+		}
+	})
+
+	It("should assign keys independently of the order of the members", func() {
+		ring, err := NewRing(members, 16)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		other, err := NewRing([]Member{members[2], members[0], members[1]}, 16)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			Ω(other.Owner(key)).Should(Equal(ring.Owner(key)))
+		}
+	})
+
+	It("should only move the keys of a removed member", func() {
+		ring, err := NewRing(members, 64)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		smaller, err := NewRing(members[:2], 64)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			if owner := ring.Owner(key); owner.Name != "charlie" {
+				Ω(smaller.Owner(key)).Should(Equal(owner))
+			}
+		}
+	})
+
+	It("should look up members by name", func() {
+		ring, err := NewRing(members, 8)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(ring.Members()).Should(Equal(members))
+
+		member, ok := ring.Member("bravo")
+		Ω(ok).Should(BeTrue())
+		Ω(member.Addr).Should(Equal("127.0.0.1:3265"))
+
+		_, ok = ring.Member("delta")
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("should reject invalid members", func() {
+		_, err := NewRing(nil, 8)
+		Ω(err).Should(HaveOccurred())
+
+		_, err = NewRing([]Member{{Addr: "localhost:3264"}}, 8)
+		Ω(err).Should(HaveOccurred())
+
+		_, err = NewRing([]Member{members[0], members[0]}, 8)
+		Ω(err).Should(MatchError(`duplicate member "alpha"`))
+	})
+
+	Describe("membership files", func() {
+
+		var tmpDir string
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "speedmap-cluster")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpDir)
+		})
+
+		It("should load the members from a file", func() {
+			path := filepath.Join(tmpDir, "members.txt")
+			data := "# name address\nalpha 127.0.0.1:3264\n\nbravo   127.0.0.1:3265\ncharlie\t127.0.0.1:3266\n"
+			Ω(ioutil.WriteFile(path, []byte(data), 0644)).Should(Succeed())
+
+			ring, err := Load(path, 32)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(ring.Members()).Should(Equal(members))
+			Ω(ring.VirtualNodes()).Should(Equal(32))
+		})
+
+		It("should report the line of a malformed member", func() {
+			path := filepath.Join(tmpDir, "members.txt")
+			Ω(ioutil.WriteFile(path, []byte("alpha 127.0.0.1:3264\nbravo\n"), 0644)).Should(Succeed())
+
+			_, err := Load(path, 0)
+			Ω(err).Should(MatchError(path + ":2: expected a name and an address"))
+		})
+
+		It("should reject members with the same name", func() {
+			path := filepath.Join(tmpDir, "members.txt")
+			Ω(ioutil.WriteFile(path, []byte("alpha 127.0.0.1:3264\n# spare\nalpha 127.0.0.1:3265\n"), 0644)).Should(Succeed())
+
+			_, err := LoadMembers(path)
+			Ω(err).Should(MatchError(path + `:3: "alpha" is already a member on line 1`))
+		})
+	})
+})
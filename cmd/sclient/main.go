@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/bbengfort/speedmap"
 	"github.com/bbengfort/speedmap/server"
 	"github.com/bbengfort/speedmap/server/pb"
+	"github.com/bbengfort/speedmap/workload"
 	"github.com/urfave/cli"
 )
 
@@ -30,6 +34,27 @@ func main() {
 			Name:  "i, identity",
 			Usage: "unique identity of the client",
 		},
+		cli.BoolFlag{
+			Name:  "tls",
+			Usage: "connect over TLS, verifying the server with the system roots",
+		},
+		cli.StringFlag{
+			Name:  "tls-ca",
+			Usage: "connect over TLS, verifying the server with this CA (PEM)",
+		},
+		cli.StringFlag{
+			Name:  "tls-cert",
+			Usage: "client certificate to present to the server for mutual TLS (PEM)",
+		},
+		cli.StringFlag{
+			Name:  "tls-key",
+			Usage: "key of the client certificate (PEM)",
+		},
+		cli.StringFlag{
+			Name:   "token",
+			Usage:  "token to authenticate to the server with (requires tls)",
+			EnvVar: "SPEEDMAP_TOKEN",
+		},
 	}
 
 	// Define commands available to application
@@ -58,6 +83,10 @@ func main() {
 					Name:  "v, val",
 					Usage: "value to put to the key",
 				},
+				cli.DurationFlag{
+					Name:  "t, ttl",
+					Usage: "time until the key expires (if supported by the store)",
+				},
 			},
 		},
 		{
@@ -75,6 +104,121 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "watch",
+			Usage:  "print changes to a key or prefix on the speedmap server",
+			Action: watch,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "k, key",
+					Usage: "specify the key to watch",
+				},
+				cli.StringFlag{
+					Name:  "p, prefix",
+					Usage: "watch all keys with the specified prefix",
+				},
+			},
+		},
+		{
+			Name:   "snapshot",
+			Usage:  "write the contents of the server's store to its snapshot file",
+			Action: snapshot,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "z, compress",
+					Usage: "compress the snapshot with gzip",
+				},
+			},
+		},
+		{
+			Name:   "stats",
+			Usage:  "print the requests served to each client, the number of keys and the memory of the server",
+			Action: stats,
+		},
+		{
+			Name:   "flush",
+			Usage:  "delete every key in the server's store",
+			Action: flush,
+		},
+		{
+			Name:      "swap",
+			Usage:     "migrate the server's store to another store implementation while it serves requests",
+			ArgsUsage: "store",
+			Action:    swap,
+		},
+		{
+			Name:   "replication",
+			Usage:  "print the replication status of a primary or backup server",
+			Action: replication,
+		},
+		{
+			Name:   "promote",
+			Usage:  "promote a backup server to the primary of its store",
+			Action: promote,
+		},
+		{
+			Name:   "cluster",
+			Usage:  "print the members of the cluster the server belongs to",
+			Action: members,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "k, key",
+					Usage: "also print the member that owns the key",
+				},
+			},
+		},
+		{
+			Name:   "bench",
+			Usage:  "run a workload benchmark against the speedmap server",
+			Action: bench,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "n, rounds",
+					Usage: "number of benchmarking rounds",
+					Value: 1,
+				},
+				cli.IntFlag{
+					Name:  "t, threads",
+					Usage: "maximum number of concurrent clients",
+					Value: 10,
+				},
+				cli.StringFlag{
+					Name:  "o, outpath",
+					Usage: "path to write the results csv to (stdout if not specified)",
+				},
+				cli.Float64Flag{
+					Name:  "p, prob",
+					Usage: "conflict probability in workload",
+					Value: 0.5,
+				},
+				cli.Float64Flag{
+					Name:  "r, readratio",
+					Usage: "percent of reads in workload (0 for all writes)",
+					Value: 0.5,
+				},
+				cli.IntFlag{
+					Name:  "b, batch",
+					Usage: "number of keys per batched get or put (1 disables batching)",
+					Value: 1,
+				},
+				cli.Float64Flag{
+					Name:  "z, zipf",
+					Usage: "run the skewed cache workload with the specified zipf exponent (> 1)",
+				},
+				cli.IntFlag{
+					Name:  "prefill",
+					Usage: "number of keys to put to the server before the benchmark",
+				},
+				cli.BoolFlag{
+					Name:  "c, connections",
+					Usage: "give each client its own connection rather than sharing one",
+				},
+				cli.BoolFlag{
+					Name:  "pipeline",
+					Usage: "make requests on a pipelined stream per client rather than with unary rpcs",
+				},
+			},
+		},
 	}
 
 	// Run the CLI program
@@ -82,15 +226,36 @@ func main() {
 
 }
 
-func initClient(c *cli.Context) error {
-	client = server.NewClient(c.String("identity"))
-	if err := client.Connect(c.String("addr")); err != nil {
+func initClient(c *cli.Context) (err error) {
+	if client, err = dial(c, c.String("identity")); err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
-
 	return nil
 }
 
+// Connects a client with the identity to the server using the global flags.
+func dial(c *cli.Context, identity string) (*server.Client, error) {
+	client := server.NewClient(identity)
+
+	if c.GlobalBool("tls") || c.GlobalString("tls-ca") != "" || c.GlobalString("tls-cert") != "" || c.GlobalString("tls-key") != "" {
+		conf, err := server.ClientTLS(c.GlobalString("tls-ca"), c.GlobalString("tls-cert"), c.GlobalString("tls-key"))
+		if err != nil {
+			return nil, err
+		}
+		client.SetTLS(conf)
+	}
+
+	if token := c.GlobalString("token"); token != "" {
+		client.SetToken(token)
+	}
+
+	if err := client.Connect(c.GlobalString("addr")); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
 //===========================================================================
 // Client Methods
 //===========================================================================
@@ -111,7 +276,7 @@ func get(c *cli.Context) (err error) {
 
 func put(c *cli.Context) (err error) {
 	var rep *pb.ClientReply
-	if rep, err = client.Put(c.String("key"), []byte(c.String("val"))); err != nil {
+	if rep, err = client.PutWithTTL(c.String("key"), []byte(c.String("val")), c.Duration("ttl")); err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
 
@@ -134,3 +299,263 @@ func del(c *cli.Context) (err error) {
 
 	return nil
 }
+
+func watch(c *cli.Context) (err error) {
+	key, prefix := c.String("key"), false
+	if c.IsSet("prefix") {
+		if key != "" {
+			return cli.NewExitError("specify either a key or a prefix to watch", 1)
+		}
+		key, prefix = c.String("prefix"), true
+	}
+
+	var stream pb.KV_WatchClient
+	if stream, err = client.Watch(context.Background(), key, prefix); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	for {
+		var event *pb.WatchEvent
+		if event, err = stream.Recv(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return cli.NewExitError(err.Error(), 1)
+		}
+
+		fmt.Printf("%s %s\n", event.Type, event.Pair)
+	}
+}
+
+func snapshot(c *cli.Context) (err error) {
+	var rep *pb.SnapshotReply
+	if rep, err = client.Snapshot(c.Bool("compress")); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if !rep.Success {
+		return cli.NewExitError(rep.Error, 2)
+	}
+
+	fmt.Printf("wrote %d keys (%d bytes) to %s\n", rep.Keys, rep.Size, rep.Path)
+	return nil
+}
+
+func stats(c *cli.Context) (err error) {
+	var rep *pb.StatsReply
+	if rep, err = client.Stats(); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if !rep.Success {
+		return cli.NewExitError(rep.Error, 2)
+	}
+
+	keys := "unknown"
+	if rep.Keys >= 0 {
+		keys = fmt.Sprintf("%d", rep.Keys)
+	}
+
+	fmt.Printf("serving the %s store with %s keys for %s\n", rep.Store, keys, time.Duration(rep.Uptime).Round(time.Second))
+	fmt.Printf("heap: %d bytes in %d objects, %d bytes from the os, %d gc cycles\n", rep.HeapAlloc, rep.HeapObjects, rep.Sys, rep.NumGc)
+	for _, client := range rep.Requests {
+		name := client.Identity
+		if name == "" {
+			name = "(anonymous)"
+		}
+		fmt.Printf("  %s: %d\n", name, client.Requests)
+	}
+	return nil
+}
+
+func flush(c *cli.Context) (err error) {
+	var rep *pb.FlushReply
+	if rep, err = client.Flush(); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if !rep.Success {
+		return cli.NewExitError(rep.Error, 2)
+	}
+
+	fmt.Printf("deleted %d keys\n", rep.Keys)
+	return nil
+}
+
+func swap(c *cli.Context) (err error) {
+	if c.NArg() != 1 {
+		return cli.NewExitError("specify the store to swap to, e.g. basic, shard or sync", 1)
+	}
+
+	var rep *pb.SwapReply
+	if rep, err = client.SwapStore(c.Args().First()); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if !rep.Success {
+		return cli.NewExitError(rep.Error, 2)
+	}
+
+	fmt.Printf("migrated %d keys from the %s store to the %s store in %s\n", rep.Keys, rep.Previous, rep.Store, time.Duration(rep.Duration))
+	return nil
+}
+
+func replication(c *cli.Context) (err error) {
+	var rep *pb.ReplicationReply
+	if rep, err = client.Replication(); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if !rep.Success {
+		return cli.NewExitError(rep.Error, 2)
+	}
+
+	printReplication(rep)
+	return nil
+}
+
+func promote(c *cli.Context) (err error) {
+	var rep *pb.ReplicationReply
+	if rep, err = client.Promote(); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if !rep.Success {
+		return cli.NewExitError(rep.Error, 2)
+	}
+
+	printReplication(rep)
+	return nil
+}
+
+func printReplication(rep *pb.ReplicationReply) {
+	if rep.Role == "primary" {
+		fmt.Printf("primary at mutation %d of epoch %x with %d backups\n", rep.Head, rep.Epoch, rep.Backups)
+		return
+	}
+
+	state := "disconnected"
+	if rep.Connected {
+		state = "connected"
+	}
+	fmt.Printf("backup of %s (%s) at mutation %d of %d, lag %s\n", rep.Primary, state, rep.Applied, rep.Head, time.Duration(rep.Lag))
+	if rep.StreamError != "" {
+		fmt.Printf("last stream error: %s\n", rep.StreamError)
+	}
+}
+
+func members(c *cli.Context) (err error) {
+	var rep *pb.ClusterReply
+	if rep, err = client.Cluster(); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if !rep.Success {
+		return cli.NewExitError(rep.Error, 2)
+	}
+
+	for _, member := range rep.Members {
+		marker := " "
+		if member.Name == rep.Node {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\t%s\n", marker, member.Name, member.Addr)
+	}
+
+	if rep.Primary != "" {
+		fmt.Printf("the server is a backup, writes are served by the primary %s\n", rep.Primary)
+	}
+
+	if rep.Replicated {
+		if rep.Leader != "" {
+			fmt.Printf("every member replicates the store, the leader is %s\n", rep.Leader)
+		} else {
+			fmt.Println("every member replicates the store, no leader has been elected")
+		}
+	}
+
+	if key := c.String("key"); key != "" {
+		fmt.Printf("%q is owned by %s\n", key, client.Owner(key))
+	}
+	return nil
+}
+
+func bench(c *cli.Context) (err error) {
+	N := c.Int("rounds")
+	T := c.Int("threads")
+	if T < 1 {
+		return cli.NewExitError("specify at least one thread", 1)
+	}
+
+	// Each concurrent client has its own identity and, if requested, its own
+	// connection; otherwise they share the connection of the global client.
+	clients := make([]*server.Client, 0, T)
+	for i := 1; i <= T; i++ {
+		identity := fmt.Sprintf("%s-%d", client.Identity(), i)
+		if !c.Bool("connections") {
+			clients = append(clients, client.WithIdentity(identity))
+			continue
+		}
+
+		var conn *server.Client
+		if conn, err = dial(c, identity); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		defer conn.Close()
+		clients = append(clients, conn)
+	}
+
+	// Fetch the members of a cluster up front so that requests are routed
+	// without redirects and can fail over to another member of a replicated
+	// cluster; servers that are not clustered reply with an error.
+	for _, cl := range clients {
+		cl.Cluster()
+	}
+
+	var kv *server.Remote
+	if c.Bool("pipeline") {
+		kv, err = server.NewPipelinedRemote(server.DefaultPipelineWindow, clients...)
+	} else {
+		kv, err = server.NewRemote(clients...)
+	}
+
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	defer kv.Close()
+
+	readratio := float32(c.Float64("readratio"))
+	var bench *speedmap.Benchmark
+	if zipf := c.Float64("zipf"); zipf > 0 {
+		bench = speedmap.New(workload.NewSkewed(zipf, readratio), T)
+	} else {
+		bench = speedmap.New(workload.NewBatchConflict(float32(c.Float64("prob")), readratio, c.Int("batch")), T)
+	}
+
+	if prefill := c.Int("prefill"); prefill > 0 {
+		fmt.Fprintf(os.Stderr, "prefilling the server with %d keys\n", prefill)
+		if err = workload.Prefill(kv, prefill); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%s workload commencing against %s in %d rounds\n", bench.Workload, c.GlobalString("addr"), N*T)
+	for n := 0; n < N; n++ {
+		if err = bench.Run(kv); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		fmt.Fprint(os.Stderr, ".")
+	}
+	fmt.Fprint(os.Stderr, "\n")
+
+	if path := c.String("outpath"); path != "" {
+		err = bench.Save(path)
+	} else {
+		err = bench.Write(os.Stdout)
+	}
+
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
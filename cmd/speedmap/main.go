@@ -1,12 +1,25 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/bbengfort/speedmap"
+	"github.com/bbengfort/speedmap/cluster"
+	"github.com/bbengfort/speedmap/raft"
+	"github.com/bbengfort/speedmap/replica"
 	"github.com/bbengfort/speedmap/server"
 	"github.com/bbengfort/speedmap/store"
+	"github.com/bbengfort/speedmap/wal"
 	"github.com/bbengfort/speedmap/workload"
 	"github.com/urfave/cli"
 )
@@ -50,6 +63,31 @@ func main() {
 					Usage: "percent of reads in workload (0 for all writes)",
 					Value: 0.5,
 				},
+				cli.IntFlag{
+					Name:  "b, batch",
+					Usage: "number of keys per batched get or put (1 disables batching)",
+					Value: 1,
+				},
+				cli.DurationFlag{
+					Name:  "ttl",
+					Usage: "also evaluate the expiring store with and without a sweeper using the specified ttl",
+				},
+				cli.BoolFlag{
+					Name:  "x, txn",
+					Usage: "run the transactional workload on the versioned store",
+				},
+				cli.Float64Flag{
+					Name:  "z, zipf",
+					Usage: "run the skewed cache workload with the specified zipf exponent (> 1)",
+				},
+				cli.IntFlag{
+					Name:  "capacity",
+					Usage: "also evaluate a cache with each eviction policy holding at most this many keys",
+				},
+				cli.Int64Flag{
+					Name:  "budget",
+					Usage: "also evaluate a cache with each eviction policy holding at most this many bytes",
+				},
 				cli.BoolFlag{
 					Name:  "B, no-basic",
 					Usage: "exclude the basic store from evaluation",
@@ -66,6 +104,22 @@ func main() {
 					Name:  "H, no-shard",
 					Usage: "exclude the shard store from evaluation",
 				},
+				cli.BoolFlag{
+					Name:  "A, no-arena",
+					Usage: "exclude the arena store from evaluation",
+				},
+				cli.StringFlag{
+					Name:  "wal-dir",
+					Usage: "also evaluate the shard store with a write-ahead log in this directory for each fsync policy",
+				},
+				cli.StringFlag{
+					Name:  "lsm-dir",
+					Usage: "also evaluate the lsm store (syncing its log every 10ms) in this directory",
+				},
+				cli.IntFlag{
+					Name:  "prefill",
+					Usage: "number of keys to put into each store before the benchmark to measure gc costs",
+				},
 			},
 		},
 		{
@@ -94,6 +148,178 @@ func main() {
 					Name:  "H, shard",
 					Usage: "serve the shard store",
 				},
+				cli.BoolFlag{
+					Name:  "A, arena",
+					Usage: "serve the arena store",
+				},
+				cli.BoolFlag{
+					Name:  "V, versioned",
+					Usage: "serve the versioned store",
+				},
+				cli.BoolFlag{
+					Name:  "C, mvcc",
+					Usage: "serve the multi-version store",
+				},
+				cli.BoolFlag{
+					Name:  "E, expiring",
+					Usage: "serve the expiring store",
+				},
+				cli.IntFlag{
+					Name:  "capacity",
+					Usage: "serve a cache that holds at most this many keys",
+				},
+				cli.Int64Flag{
+					Name:  "budget",
+					Usage: "serve a cache that holds at most this many bytes",
+				},
+				cli.StringFlag{
+					Name:  "policy",
+					Usage: "eviction policy of the cache (lru, clock, tinylfu, arc)",
+					Value: "lru",
+				},
+				cli.StringFlag{
+					Name:  "lsm-dir",
+					Usage: "serve the lsm store persisted in this directory",
+				},
+				cli.StringFlag{
+					Name:  "wal-dir",
+					Usage: "recover the store from and log changes to a write-ahead log in this directory",
+				},
+				cli.StringFlag{
+					Name:  "fsync",
+					Usage: "fsync policy of the write-ahead log or lsm store (always, never, or an interval such as 10ms)",
+					Value: "always",
+				},
+				cli.StringFlag{
+					Name:  "load",
+					Usage: "restore the store from a snapshot file before serving",
+				},
+				cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "path of the file that admin snapshot requests write to",
+					Value: server.DefaultSnapshotPath,
+				},
+				cli.BoolFlag{
+					Name:  "swappable",
+					Usage: "allow admin requests to migrate the store to another store implementation while serving",
+				},
+				cli.BoolFlag{
+					Name:  "w, watch",
+					Usage: "allow clients to watch keys for changes",
+				},
+				cli.StringFlag{
+					Name:  "http-addr",
+					Usage: "also serve the store with an http/json gateway on this address",
+				},
+				cli.StringFlag{
+					Name:  "resp-addr",
+					Usage: "also serve the store to redis clients on this address",
+				},
+				cli.StringFlag{
+					Name:  "memcached-addr",
+					Usage: "also serve the store to memcached clients on this address",
+				},
+				cli.StringFlag{
+					Name:  "tls-cert",
+					Usage: "serve over TLS with this certificate (PEM)",
+				},
+				cli.StringFlag{
+					Name:  "tls-key",
+					Usage: "key of the TLS certificate (PEM)",
+				},
+				cli.StringFlag{
+					Name:  "tls-ca",
+					Usage: "require client certificates signed by this CA (mutual TLS)",
+				},
+				cli.StringFlag{
+					Name:  "tokens",
+					Usage: "authenticate clients by the tokens in this file (lines of token and identity)",
+				},
+				cli.BoolFlag{
+					Name:  "auth-tls",
+					Usage: "authenticate clients by the common name of their certificate (requires --tls-ca)",
+				},
+				cli.StringFlag{
+					Name:  "acl",
+					Usage: "authorize requests with the access control list in this file (requires --tokens or --auth-tls)",
+				},
+				cli.StringFlag{
+					Name:  "cluster",
+					Usage: "serve the keys owned by --node in the cluster with the members in this file",
+				},
+				cli.StringFlag{
+					Name:  "node",
+					Usage: "name of this server in the cluster or raft membership file",
+				},
+				cli.IntFlag{
+					Name:  "vnodes",
+					Usage: "number of virtual nodes of each member on the cluster ring",
+					Value: cluster.DefaultVirtualNodes,
+				},
+				cli.StringFlag{
+					Name:  "raft",
+					Usage: "replicate the store to every member in this file, serving requests from the leader elected by raft",
+				},
+				cli.StringFlag{
+					Name:  "raft-dir",
+					Usage: "persist the raft log of this member in this directory (in memory if not set)",
+				},
+				cli.DurationFlag{
+					Name:  "election-timeout",
+					Usage: "time without a leader after which the raft members elect a new one",
+					Value: raft.DefaultElectionTimeout,
+				},
+				cli.DurationFlag{
+					Name:  "heartbeat",
+					Usage: "interval of the heartbeats the raft leader sends to the other members",
+					Value: raft.DefaultHeartbeatInterval,
+				},
+				cli.BoolFlag{
+					Name:  "primary",
+					Usage: "stream the changes to the store to backups that follow this server",
+				},
+				cli.StringFlag{
+					Name:  "backup-of",
+					Usage: "serve a backup of the primary at this address, which may be stale, redirecting writes to the primary",
+				},
+				cli.IntFlag{
+					Name:  "backlog",
+					Usage: "number of changes the primary keeps for backups that fall behind, which otherwise copy the entire store",
+					Value: replica.DefaultBacklog,
+				},
+				cli.StringFlag{
+					Name:  "peer-cert, raft-cert",
+					Usage: "client certificate presented to the other raft members or the primary if they require mutual TLS",
+				},
+				cli.StringFlag{
+					Name:  "peer-key, raft-key",
+					Usage: "key of the peer client certificate (PEM)",
+				},
+				cli.StringFlag{
+					Name:  "peer-token, raft-token",
+					Usage: "token sent to the other raft members or the primary if they authenticate clients by token",
+				},
+				cli.DurationFlag{
+					Name:  "shutdown-timeout",
+					Usage: "time to wait for in-flight requests to complete on shutdown",
+					Value: 30 * time.Second,
+				},
+			},
+		},
+		{
+			Name:   "certs",
+			Usage:  "generate a self-signed CA with server and client certificates for testing",
+			Action: certs,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "o, outdir",
+					Usage: "directory to write the certificates and keys to",
+					Value: "certs",
+				},
+				cli.StringSliceFlag{
+					Name:  "host",
+					Usage: "host name or ip address of the server (repeat for multiple hosts)",
+				},
 			},
 		},
 	}
@@ -105,12 +331,27 @@ func main() {
 
 func bench(c *cli.Context) (err error) {
 
-	N := c.Int("rounds")
 	T := c.Int("threads")
 
 	stores := make([]speedmap.Store, 0, 4)
-	workload := workload.NewConflict(float32(c.Float64("prob")), float32(c.Float64("readratio")))
-	bench := speedmap.New(workload, T)
+	prob, readratio := float32(c.Float64("prob")), float32(c.Float64("readratio"))
+
+	// The transaction workload is only run against the versioned store
+	if c.Bool("txn") {
+		var vers *store.Versioned
+		if vers, err = store.NewVersioned(); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		stores = append(stores, vers)
+		return runBench(c, speedmap.New(workload.NewTransaction(prob, readratio), T), stores)
+	}
+
+	var bench *speedmap.Benchmark
+	if zipf := c.Float64("zipf"); zipf > 0 {
+		bench = speedmap.New(workload.NewSkewed(zipf, readratio), T)
+	} else {
+		bench = speedmap.New(workload.NewBatchConflict(prob, readratio, c.Int("batch")), T)
+	}
 
 	if !c.Bool("no-basic") {
 		var basic *store.Basic
@@ -144,8 +385,106 @@ func bench(c *cli.Context) (err error) {
 		stores = append(stores, shard)
 	}
 
+	if !c.Bool("no-arena") {
+		var arena *store.Arena
+		if arena, err = store.NewArena(); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		stores = append(stores, arena)
+	}
+
+	// Compare the expiring store with and without the background sweeper
+	if ttl := c.Duration("ttl"); ttl > 0 {
+		for _, sweep := range []time.Duration{store.DefaultSweepInterval, 0} {
+			var exp *store.Expiring
+			if exp, err = store.NewExpiring(sweep); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			defer exp.Close()
+
+			exp.SetDefaultTTL(ttl)
+			stores = append(stores, exp)
+		}
+	}
+
+	// Compare the cost of each fsync policy of the write-ahead log
+	if walDir := c.String("wal-dir"); walDir != "" {
+		if err = os.MkdirAll(walDir, 0755); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+
+		// Start from empty logs that are removed after the benchmark
+		var tmp string
+		if tmp, err = ioutil.TempDir(walDir, "bench-"); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		defer os.RemoveAll(tmp)
+
+		for _, policy := range []string{"always", "10ms", "never"} {
+			var durable *store.Durable
+			if durable, err = openDurable(filepath.Join(tmp, policy), policy); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			defer durable.Close()
+			stores = append(stores, durable)
+		}
+	}
+
+	// Evaluate the on-disk engine, starting from an empty store that is
+	// removed after the benchmark
+	if lsmDir := c.String("lsm-dir"); lsmDir != "" {
+		if err = os.MkdirAll(lsmDir, 0755); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+
+		var tmp string
+		if tmp, err = ioutil.TempDir(lsmDir, "bench-"); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		defer os.RemoveAll(tmp)
+
+		var lsm *store.LSM
+		if lsm, err = openLSM(tmp, "10ms"); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		defer lsm.Close()
+		stores = append(stores, lsm)
+	}
+
+	// Compare the eviction policies of bounded caches
+	if capacity, budget := c.Int("capacity"), c.Int64("budget"); capacity > 0 || budget > 0 {
+		for _, name := range []string{"lru", "clock", "tinylfu", "arc"} {
+			var cache *store.Cache
+			if cache, err = store.NewCache(capacity, budget, store.Policies[name]); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			stores = append(stores, cache)
+		}
+	}
+
+	return runBench(c, bench, stores)
+}
+
+// Runs the benchmark against each of the stores for the specified number of
+// rounds then saves the results to disk.
+func runBench(c *cli.Context, bench *speedmap.Benchmark, stores []speedmap.Store) error {
+	N := c.Int("rounds")
+	T := bench.MaxConcurrency
+
+	// Prefill the stores to measure the cost of garbage collection, since
+	// every store is on the heap it is best to evaluate one store at a time.
+	if prefill := c.Int("prefill"); prefill > 0 {
+		fmt.Printf("prefilling %d stores with %d keys\n", len(stores), prefill)
+		for _, s := range stores {
+			if err := workload.Prefill(s, prefill); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+		}
+		bench.MeasureGC = true
+	}
+
 	rounds := N * T * len(stores)
-	fmt.Printf("%s workload commencing for %d stores in %d rounds\n", workload, len(stores), rounds)
+	fmt.Printf("%s workload commencing for %d stores in %d rounds\n", bench.Workload, len(stores), rounds)
 
 	for n := 0; n < N; n++ {
 		for _, s := range stores {
@@ -163,29 +502,367 @@ func bench(c *cli.Context) (err error) {
 	return nil
 }
 
-func serve(c *cli.Context) (err error) {
-	var kv speedmap.Store
-
+// Creates the in-memory store selected by the flags of the serve command.
+func newStore(c *cli.Context) (kv speedmap.Store, err error) {
 	switch {
 	case c.Bool("basic"):
-		kv, err = store.NewBasic()
+		return store.NewBasic()
 	case c.Bool("misframe"):
-		kv, err = store.NewMisframe()
+		return store.NewMisframe()
 	case c.Bool("sync"):
-		kv, err = store.NewSyncMap()
+		return store.NewSyncMap()
 	case c.Bool("shard"):
-		kv, err = store.NewShard()
+		return store.NewShard()
+	case c.Bool("arena"):
+		return store.NewArena()
+	case c.Bool("versioned"):
+		return store.NewVersioned()
+	case c.Bool("mvcc"):
+		return store.NewMVCC()
+	case c.Bool("expiring"):
+		return store.NewExpiring(store.DefaultSweepInterval)
+	case c.Int("capacity") > 0 || c.Int64("budget") > 0:
+		var policy store.PolicyFactory
+		if policy, err = store.GetPolicy(c.String("policy")); err != nil {
+			return nil, err
+		}
+		return store.NewCache(c.Int("capacity"), c.Int64("budget"), policy)
 	default:
-		kv, err = store.NewBasic()
+		return store.NewBasic()
+	}
+}
+
+// Creates a shard store with a write-ahead log with the fsync policy in dir.
+func openDurable(dir, policy string) (durable *store.Durable, err error) {
+	var opts wal.Options
+	if opts, err = wal.ParseSync(policy); err != nil {
+		return nil, err
+	}
+
+	var log *wal.Log
+	if log, err = wal.Open(dir, opts); err != nil {
+		return nil, err
+	}
+
+	var shard store.Shard
+	if shard, err = store.NewShard(); err != nil {
+		return nil, err
+	}
+	return store.NewDurable(shard, log)
+}
+
+// Opens the lsm store in dir with the fsync policy for its log.
+func openLSM(dir, policy string) (lsm *store.LSM, err error) {
+	opts := store.LSMOptions{}
+	if opts.Sync, err = wal.ParseSync(policy); err != nil {
+		return nil, err
+	}
+	return store.NewLSM(dir, opts)
+}
+
+func serve(c *cli.Context) (err error) {
+	var kv speedmap.Store
+
+	if dir := c.String("lsm-dir"); dir != "" {
+		var lsm *store.LSM
+		if lsm, err = openLSM(dir, c.String("fsync")); err == nil {
+			defer lsm.Close()
+			kv = lsm
+		}
+	} else {
+		kv, err = newStore(c)
 	}
 
 	if err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
 
-	if err := server.Serve(kv, c.String("addr")); err != nil {
+	// The snapshot is loaded into the store before it is made durable so that
+	// it is not appended to the log again on every start; the log then replays
+	// the writes made since the snapshot on top of it.
+	if path := c.String("load"); path != "" {
+		if err = load(kv, path); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	if walDir := c.String("wal-dir"); walDir != "" {
+		var opts wal.Options
+		if opts, err = wal.ParseSync(c.String("fsync")); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+
+		var log *wal.Log
+		if log, err = wal.Open(walDir, opts); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		defer log.Close()
+
+		if kv, err = store.NewDurable(kv, log); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	// The server only swaps the store it serves, so it must be the outermost store
+	if c.Bool("swappable") {
+		if c.String("wal-dir") != "" || c.String("lsm-dir") != "" || c.Bool("watch") {
+			return cli.NewExitError("only in-memory stores that are not watched can be swapped", 1)
+		}
+
+		if c.Bool("primary") || c.String("backup-of") != "" || c.String("raft") != "" {
+			return cli.NewExitError("a replicated store cannot be swapped", 1)
+		}
+
+		if kv, err = store.NewSwappable(kv); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	if c.Bool("watch") {
+		if kv, err = store.NewWatched(kv); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	// The store of a replicated member is its state machine, which is only
+	// modified by applying the committed entries of the raft log.
+	var (
+		node    *raft.Node
+		members []cluster.Member
+	)
+
+	if path := c.String("raft"); path != "" {
+		if c.String("cluster") != "" {
+			return cli.NewExitError("specify either --cluster or --raft", 1)
+		}
+
+		if c.String("wal-dir") != "" || c.String("lsm-dir") != "" || c.String("load") != "" {
+			return cli.NewExitError("a replicated store is recovered from its raft log, use --raft-dir instead", 1)
+		}
+
+		if members, err = cluster.LoadMembers(path); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+
+		var conf *tls.Config
+		if c.String("tls-cert") != "" {
+			if conf, err = server.ClientTLS(c.String("tls-ca"), c.String("peer-cert"), c.String("peer-key")); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+		}
+
+		transport := server.NewRaftTransport(members, conf, c.String("peer-token"))
+		defer transport.Close()
+
+		peers := make([]string, 0, len(members))
+		for _, member := range members {
+			peers = append(peers, member.Name)
+		}
+
+		var replicated *raft.Store
+		if replicated, err = raft.NewStore(raft.Config{
+			ID:                c.String("node"),
+			Peers:             peers,
+			ElectionTimeout:   c.Duration("election-timeout"),
+			HeartbeatInterval: c.Duration("heartbeat"),
+			Dir:               c.String("raft-dir"),
+		}, transport, kv); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		kv, node = replicated, replicated.Node()
+	}
+
+	// A backup replaces its store with a new one whenever it copies the entire
+	// store of the primary, so its store is created by the backup.
+	if addr := c.String("backup-of"); addr != "" {
+		if c.Bool("primary") || c.String("raft") != "" || c.String("cluster") != "" {
+			return cli.NewExitError("a backup cannot be a primary or a member of a cluster", 1)
+		}
+
+		if c.String("wal-dir") != "" || c.String("lsm-dir") != "" || c.String("load") != "" || c.Bool("watch") {
+			return cli.NewExitError("a backup is recovered from its primary and cannot be watched", 1)
+		}
+
+		var conf *tls.Config
+		if c.String("tls-cert") != "" {
+			if conf, err = server.ClientTLS(c.String("tls-ca"), c.String("peer-cert"), c.String("peer-key")); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+		}
+
+		identity := c.String("node")
+		if identity == "" {
+			identity = c.String("addr")
+		}
+
+		var source *server.ReplicationSource
+		if source, err = server.NewReplicationSource(addr, identity, conf, c.String("peer-token")); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		defer source.Close()
+
+		// The backup creates its own store, so the store created above is not used
+		if closer, ok := kv.(io.Closer); ok {
+			closer.Close()
+		}
+
+		factory := func() (speedmap.Store, error) { return newStore(c) }
+		if kv, err = replica.NewBackup(addr, source, factory, c.Int("backlog")); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	if c.Bool("primary") {
+		if c.String("raft") != "" {
+			return cli.NewExitError("specify either --primary or --raft", 1)
+		}
+
+		if kv, err = replica.NewPrimary(kv, c.Int("backlog")); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	srv := server.New(kv)
+	srv.SetSnapshotPath(c.String("snapshot"))
+
+	if c.String("tls-cert") != "" || c.String("tls-key") != "" {
+		var conf *tls.Config
+		if conf, err = server.ServerTLS(c.String("tls-cert"), c.String("tls-key"), c.String("tls-ca")); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		srv.SetTLS(conf)
+	} else if c.String("tls-ca") != "" {
+		return cli.NewExitError("mutual TLS requires a server certificate and key", 1)
+	}
+
+	switch {
+	case c.String("tokens") != "" && c.Bool("auth-tls"):
+		return cli.NewExitError("specify either token or tls authentication", 1)
+	case c.String("tokens") != "":
+		var auth *server.TokenAuth
+		if auth, err = server.NewTokenAuth(c.String("tokens")); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		srv.SetAuth(auth)
+	case c.Bool("auth-tls"):
+		if c.String("tls-ca") == "" {
+			return cli.NewExitError("tls authentication requires --tls-ca", 1)
+		}
+		srv.SetAuth(server.TLSAuth{})
+	}
+
+	if path := c.String("acl"); path != "" {
+		// Without an authenticator the ACL would trust the identity clients claim
+		if c.String("tokens") == "" && !c.Bool("auth-tls") {
+			return cli.NewExitError("an acl requires --tokens or --auth-tls", 1)
+		}
+
+		var acl *server.ACL
+		if acl, err = server.LoadACL(path); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		srv.SetACL(acl)
+	}
+
+	if path := c.String("cluster"); path != "" {
+		var ring *cluster.Ring
+		if ring, err = cluster.Load(path, c.Int("vnodes")); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+
+		if err = srv.SetCluster(ring, c.String("node")); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	if node != nil {
+		if err = srv.SetReplication(node, members); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	listeners := 1
+	errc := make(chan error, 4)
+	go func() { errc <- srv.Listen(c.String("addr")) }()
+
+	if addr := c.String("http-addr"); addr != "" {
+		listeners++
+		go func() { errc <- srv.ListenHTTP(addr) }()
+	}
+
+	if addr := c.String("resp-addr"); addr != "" {
+		listeners++
+		go func() { errc <- srv.ListenRESP(addr) }()
+	}
+
+	if addr := c.String("memcached-addr"); addr != "" {
+		listeners++
+		go func() { errc <- srv.ListenMemcached(addr) }()
+	}
+
+	// Shut down gracefully on the first interrupt and forcefully on the second
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err = <-errc:
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		return nil
+	case sig := <-quit:
+		fmt.Printf("received %s, shutting down\n", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Duration("shutdown-timeout"))
+	defer cancel()
+	go func() {
+		select {
+		case <-quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err = srv.Shutdown(ctx); err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
 
+	for ; listeners > 0; listeners-- {
+		if err = <-errc; err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+	return nil
+}
+
+func certs(c *cli.Context) (err error) {
+	hosts := c.StringSlice("host")
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1"}
+	}
+
+	if err = server.GenerateCerts(c.String("outdir"), hosts...); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	fmt.Printf("wrote certificates for %s to %s\n", strings.Join(hosts, ", "), c.String("outdir"))
+	return nil
+}
+
+// Restores the store from the snapshot file at the specified path.
+func load(kv speedmap.Store, path string) (err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var keys uint64
+	if keys, err = speedmap.Restore(kv, f); err != nil {
+		return fmt.Errorf("could not load %s: %w", path, err)
+	}
+
+	fmt.Printf("loaded %d keys from %s\n", keys, path)
 	return nil
 }
@@ -0,0 +1,77 @@
+package speedmap
+
+import "context"
+
+// ContextStore is a Store whose operations accept a context so that work can
+// be abandoned when a caller cancels the request or its deadline expires.
+// Stores that may block (e.g. on disk, the network, or another goroutine)
+// should implement this interface directly and abort as soon as the context
+// is done; all other stores can be adapted with WithContext.
+type ContextStore interface {
+	Store
+	GetCtx(ctx context.Context, key string) (value []byte, err error)
+	PutCtx(ctx context.Context, key string, value []byte) (err error)
+	DeleteCtx(ctx context.Context, key string) (err error)
+	GetOrCreateCtx(ctx context.Context, key string, value []byte) (actual []byte, created bool, err error)
+}
+
+// WithContext returns a ContextStore for the specified store. If the store
+// already implements ContextStore it is returned as is, otherwise it is
+// wrapped so that each operation checks the context before it is applied.
+// Because the wrapped operations don't block, an operation that has started
+// is always allowed to complete.
+func WithContext(store Store) ContextStore {
+	if cs, ok := store.(ContextStore); ok {
+		return cs
+	}
+	return &contextStore{store}
+}
+
+// Unwrap returns the underlying store of a store adapted by WithContext so
+// that optional interfaces such as Batcher can be detected on it. Any other
+// store is returned as is.
+func Unwrap(store Store) Store {
+	if cs, ok := store.(*contextStore); ok {
+		return cs.Store
+	}
+	return store
+}
+
+// Adapts a Store that does not block to the ContextStore interface.
+type contextStore struct {
+	Store
+}
+
+// GetCtx returns the context error if it is done, otherwise calls Get.
+func (s *contextStore) GetCtx(ctx context.Context, key string) (value []byte, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Get(key)
+}
+
+// PutCtx returns the context error if it is done, otherwise calls Put.
+func (s *contextStore) PutCtx(ctx context.Context, key string, value []byte) (err error) {
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	return s.Put(key, value)
+}
+
+// DeleteCtx returns the context error if it is done, otherwise calls Delete.
+func (s *contextStore) DeleteCtx(ctx context.Context, key string) (err error) {
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	return s.Delete(key)
+}
+
+// GetOrCreateCtx returns the context error if it is done, otherwise calls
+// GetOrCreate.
+func (s *contextStore) GetOrCreateCtx(ctx context.Context, key string, value []byte) (actual []byte, created bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	actual, created = s.GetOrCreate(key, value)
+	return actual, created, nil
+}
@@ -0,0 +1,55 @@
+package speedmap_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bbengfort/speedmap"
+	"github.com/bbengfort/speedmap/store"
+)
+
+var _ = Describe("ContextStore", func() {
+
+	var kv ContextStore
+
+	BeforeEach(func() {
+		basic, err := store.NewBasic()
+		Ω(err).ShouldNot(HaveOccurred())
+		kv = WithContext(basic)
+	})
+
+	It("should not wrap a context store twice", func() {
+		Ω(WithContext(kv)).Should(BeIdenticalTo(kv))
+	})
+
+	It("should perform operations with a live context", func() {
+		ctx := context.Background()
+		Ω(kv.PutCtx(ctx, "foo", []byte("bar"))).Should(Succeed())
+
+		val, err := kv.GetCtx(ctx, "foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		actual, created, err := kv.GetOrCreateCtx(ctx, "foo", []byte("red"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(actual).Should(Equal([]byte("bar")))
+		Ω(created).Should(BeFalse())
+
+		Ω(kv.DeleteCtx(ctx, "foo")).Should(Succeed())
+	})
+
+	It("should abort operations when the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		Ω(kv.PutCtx(ctx, "foo", []byte("bar"))).Should(MatchError(context.Canceled))
+		_, _, err := kv.GetOrCreateCtx(ctx, "foo", []byte("bar"))
+		Ω(err).Should(MatchError(context.Canceled))
+
+		_, err = kv.Get("foo")
+		Ω(err).Should(HaveOccurred())
+	})
+
+})
@@ -0,0 +1,23 @@
+package speedmap
+
+import "errors"
+
+// MaxKeySize is the largest key in bytes that a Store will accept on Put.
+const MaxKeySize = 65535
+
+// Standard errors returned by Store implementations. Stores wrap these errors
+// with additional context (such as the key being accessed), so callers should
+// compare them using errors.Is rather than by equality or message.
+var (
+	ErrNotFound     = errors.New("no value found for key")
+	ErrClosed       = errors.New("store is closed")
+	ErrValueType    = errors.New("could not cast value to bytes")
+	ErrKeyTooLarge  = errors.New("key exceeds maximum key size")
+	ErrConflict     = errors.New("transaction conflict")
+	ErrTxnDone      = errors.New("transaction has already been committed or rolled back")
+	ErrCompacted    = errors.New("version has been garbage collected")
+	ErrSlowConsumer = errors.New("watcher fell too far behind the changes to the store")
+	ErrCorrupt      = errors.New("data on disk is corrupt")
+	ErrNotLeader    = errors.New("not the leader of the replicated store")
+	ErrReadOnly     = errors.New("store is a read-only backup")
+)
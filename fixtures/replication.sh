@@ -0,0 +1,69 @@
+#!/bin/bash
+# Measures the overhead of raft replication for each store by running the
+# sclient bench against a single unreplicated server and then against a group
+# of three replicated servers on localhost. Expects the speedmap and sclient
+# commands to be on the PATH; writes replication.csv to the current directory.
+
+set -e
+
+STORES="basic shard sync"
+THREADS=${THREADS:-8}
+ROUNDS=${ROUNDS:-3}
+OUTPATH=${OUTPATH:-replication.csv}
+
+WORKDIR=$(mktemp -d)
+PIDS=()
+
+stop() {
+    for pid in "${PIDS[@]}"; do
+        kill -INT $pid 2>/dev/null || true
+    done
+    wait "${PIDS[@]}" 2>/dev/null || true
+    PIDS=()
+}
+
+trap 'stop; rm -rf $WORKDIR' EXIT
+
+cat > $WORKDIR/members.txt <<EOF
+alpha   127.0.0.1:4301
+bravo   127.0.0.1:4302
+charlie 127.0.0.1:4303
+EOF
+
+# Runs the benchmark against the first member, labeling each row of the
+# results with the store and the number of replicas.
+bench() {
+    sleep 2
+    sclient -a 127.0.0.1:4301 bench -n $ROUNDS -t $THREADS --prefill 1000 2>/dev/null \
+        | sed -e "1s/^/replicas,/" -e "2,\$s/^remote/$2,$1/"
+}
+
+rm -f $OUTPATH
+for store in $STORES; do
+    echo "benchmarking the $store store without replication"
+    speedmap serve -a 127.0.0.1:4301 --$store > $WORKDIR/$store-1.log 2>&1 &
+    PIDS+=($!)
+    bench $store 1 > $WORKDIR/$store-1.csv
+    stop
+
+    echo "benchmarking the $store store replicated to 3 members"
+    for member in alpha:4301 bravo:4302 charlie:4303; do
+        name=${member%%:*}
+        speedmap serve -a 127.0.0.1:${member##*:} --$store --raft $WORKDIR/members.txt \
+            --node $name --raft-dir $WORKDIR/$store/$name > $WORKDIR/$store-$name.log 2>&1 &
+        PIDS+=($!)
+    done
+    bench $store 3 > $WORKDIR/$store-3.csv
+    stop
+
+    # Keep the header of the first file only
+    for path in $WORKDIR/$store-1.csv $WORKDIR/$store-3.csv; do
+        if [ -f $OUTPATH ]; then
+            tail -n +2 $path >> $OUTPATH
+        else
+            cat $path > $OUTPATH
+        fi
+    done
+done
+
+echo "results written to $OUTPATH"
@@ -0,0 +1,39 @@
+package speedmap
+
+import "fmt"
+
+// Iterable is an optional interface for stores whose contents can be iterated
+// over, e.g. to snapshot the store. Range calls fn for every key/value pair in
+// the store, in no particular order, until fn returns false. The store may be
+// locked while fn is called, so fn must not access the store. Stores are not
+// required to present a point-in-time view of their contents to Range; keys
+// that are modified concurrently may or may not be visited.
+type Iterable interface {
+	Range(fn func(key string, value []byte) bool) error
+}
+
+// Range calls fn for every key/value pair in the store if the store is
+// Iterable, otherwise returns an error.
+func Range(store Store, fn func(key string, value []byte) bool) error {
+	if iter, ok := Unwrap(store).(Iterable); ok {
+		return iter.Range(fn)
+	}
+	return fmt.Errorf("the %s store does not support iteration", store)
+}
+
+// Scanner is an optional interface for stores that keep their keys in order.
+// Scan calls fn for every key/value pair with start <= key < end in ascending
+// order of keys until fn returns false; an empty end scans to the last key in
+// the store. As with Range, fn must not access the store.
+type Scanner interface {
+	Scan(start, end string, fn func(key string, value []byte) bool) error
+}
+
+// Scan calls fn in key order for every key/value pair in the range of keys
+// [start, end) if the store is a Scanner, otherwise returns an error.
+func Scan(store Store, start, end string, fn func(key string, value []byte) bool) error {
+	if scanner, ok := Unwrap(store).(Scanner); ok {
+		return scanner.Scan(start, end, fn)
+	}
+	return fmt.Errorf("the %s store does not support ordered scans", store)
+}
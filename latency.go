@@ -0,0 +1,38 @@
+package speedmap
+
+import (
+	"sort"
+	"time"
+)
+
+// Timed is an optional interface for stores that measure the latency of each
+// operation, e.g. stores that are accessed over the network, where the time
+// a client waits for each operation matters as much as the throughput of all
+// of the clients. Latencies returns the latencies of the operations completed
+// since it was last called; the benchmark calls it before and after each run
+// of the workload to report the latency percentiles of the run.
+type Timed interface {
+	Latencies() []time.Duration
+}
+
+// Records the latency percentiles of the run from the latencies of its
+// operations, using the nearest rank of each percentile.
+func (r *Result) setLatencies(latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		rank := int(p*float64(len(latencies))+0.5) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		return latencies[rank]
+	}
+
+	r.P50Latency = percentile(0.50)
+	r.P90Latency = percentile(0.90)
+	r.P99Latency = percentile(0.99)
+	r.MaxLatency = latencies[len(latencies)-1]
+}
@@ -0,0 +1,83 @@
+package speedmap_test
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bbengfort/speedmap"
+	"github.com/bbengfort/speedmap/store"
+)
+
+// A store that reports the latencies recorded by the timed workload.
+type timedStore struct {
+	*store.Basic
+	latencies []time.Duration
+}
+
+func (s *timedStore) Latencies() []time.Duration {
+	latencies := s.latencies
+	s.latencies = nil
+	return latencies
+}
+
+// A workload that gets a key 100 times, recording latencies from 100ms down to
+// 1ms if the store is a timedStore.
+type timedWorkload struct{}
+
+func (timedWorkload) Run(kv Store, clients int) (*Result, error) {
+	timed, ok := kv.(*timedStore)
+	for i := 100; i > 0; i-- {
+		kv.Get("foo")
+		if ok {
+			timed.latencies = append(timed.latencies, time.Duration(i)*time.Millisecond)
+		}
+	}
+	return &Result{Store: kv, Workload: timedWorkload{}, Concurrency: clients, Operations: 100}, nil
+}
+
+func (timedWorkload) String() string {
+	return "timed"
+}
+
+var _ = Describe("Timed", func() {
+
+	It("should record the latency percentiles of timed stores", func() {
+		basic, err := store.NewBasic()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		// Latencies from before the benchmark are discarded
+		kv := &timedStore{Basic: basic, latencies: []time.Duration{time.Hour}}
+
+		bench := New(timedWorkload{}, 2)
+		Ω(bench.Run(kv)).Should(Succeed())
+		Ω(bench.Results).Should(HaveLen(2))
+
+		for _, result := range bench.Results {
+			Ω(result.P50Latency).Should(Equal(50 * time.Millisecond))
+			Ω(result.P90Latency).Should(Equal(90 * time.Millisecond))
+			Ω(result.P99Latency).Should(Equal(99 * time.Millisecond))
+			Ω(result.MaxLatency).Should(Equal(100 * time.Millisecond))
+		}
+
+		buf := new(bytes.Buffer)
+		Ω(bench.Write(buf)).Should(Succeed())
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		Ω(lines).Should(HaveLen(3))
+		Ω(lines[0]).Should(HaveSuffix(",p50 latency (ns),p90 latency (ns),p99 latency (ns),max latency (ns)"))
+		Ω(lines[1]).Should(HaveSuffix(",50000000,90000000,99000000,100000000"))
+	})
+
+	It("should not record latencies of stores that are not timed", func() {
+		basic, err := store.NewBasic()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		bench := New(timedWorkload{}, 1)
+		Ω(bench.Run(basic)).Should(Succeed())
+		Ω(bench.Results[0].P50Latency).Should(BeZero())
+		Ω(bench.Results[0].MaxLatency).Should(BeZero())
+	})
+})
@@ -0,0 +1,333 @@
+package raft
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Names of the files a node persists its state and log to in its directory.
+const (
+	stateFile = "raft.state"
+	logFile   = "raft.log"
+)
+
+// Each entry in the log file is framed by the CRC of the entry body followed
+// by the length of the body. The body is the index and term of the entry
+// followed by (the remainder of the body) its command.
+const (
+	entryHeader  = 4 + 4
+	entryBody    = 8 + 8
+	maxEntrySize = 1 << 30
+)
+
+// The CRC table used to checksum the state and entries.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// The log of a node and the state that must survive a restart: the current
+// term and the vote cast in it. If the log has a directory, every change is
+// synced to disk before it is used; otherwise the log is only in memory. The
+// log is not safe for concurrent use; the node protects it with its mutex.
+type raftLog struct {
+	dir      string
+	file     *os.File // the log file, opened for appending
+	term     uint64   // the latest term the node has seen
+	votedFor string   // the candidate voted for in the term, if any
+	entries  []Entry  // entries[i] has index i; entries[0] is a placeholder
+}
+
+// Opens the log in the directory, creating it if necessary and reading the
+// persisted state and entries. If dir is empty the log is only in memory. A
+// torn entry at the end of the log file (e.g. from a crash during a write) is
+// discarded; any other corruption is an error wrapping speedmap.ErrCorrupt.
+func openLog(dir string) (log *raftLog, err error) {
+	log = &raftLog{dir: dir, entries: []Entry{{}}}
+	if dir == "" {
+		return log, nil
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if err = log.readState(); err != nil {
+		return nil, err
+	}
+
+	var valid int64
+	if valid, err = log.readEntries(); err != nil {
+		return nil, err
+	}
+
+	if log.file, err = os.OpenFile(filepath.Join(dir, logFile), os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		return nil, err
+	}
+
+	// Discard a torn entry at the end of the file so that appends follow the
+	// last complete entry.
+	if err = log.file.Truncate(valid); err != nil {
+		log.file.Close()
+		return nil, err
+	}
+
+	if _, err = log.file.Seek(valid, io.SeekStart); err != nil {
+		log.file.Close()
+		return nil, err
+	}
+	return log, nil
+}
+
+// Index of the last entry in the log, zero if the log is empty.
+func (l *raftLog) lastIndex() uint64 {
+	return uint64(len(l.entries) - 1)
+}
+
+// Term of the entry at the index, zero if there is no entry at the index.
+func (l *raftLog) termAt(index uint64) uint64 {
+	if index >= uint64(len(l.entries)) {
+		return 0
+	}
+	return l.entries[index].Term
+}
+
+// Returns at most max entries starting at the index.
+func (l *raftLog) slice(from uint64, max int) []Entry {
+	if from >= uint64(len(l.entries)) {
+		return nil
+	}
+
+	to := uint64(len(l.entries))
+	if max > 0 && to-from > uint64(max) {
+		to = from + uint64(max)
+	}
+
+	entries := make([]Entry, to-from)
+	copy(entries, l.entries[from:to])
+	return entries
+}
+
+// Sets the current term and vote, syncing them to disk if they changed.
+func (l *raftLog) setState(term uint64, votedFor string) error {
+	if term == l.term && votedFor == l.votedFor {
+		return nil
+	}
+
+	l.term, l.votedFor = term, votedFor
+	if l.dir == "" {
+		return nil
+	}
+
+	// The state is small, so it is rewritten and renamed over the old state
+	buf := make([]byte, 4+8, 4+8+len(votedFor))
+	binary.LittleEndian.PutUint64(buf[4:12], term)
+	buf = append(buf, votedFor...)
+	binary.LittleEndian.PutUint32(buf[0:4], crc32.Checksum(buf[4:], crcTable))
+	return writeFile(filepath.Join(l.dir, stateFile), buf)
+}
+
+// Appends the entries to the log, syncing them to disk.
+func (l *raftLog) append(entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if l.dir != "" {
+		var buf []byte
+		for _, entry := range entries {
+			buf = appendEntry(buf, entry)
+		}
+
+		if _, err := l.file.Write(buf); err != nil {
+			return err
+		}
+
+		if err := l.file.Sync(); err != nil {
+			return err
+		}
+	}
+
+	l.entries = append(l.entries, entries...)
+	return nil
+}
+
+// Removes the entry at the index and all of the entries that follow it. The
+// log file is rewritten, which is expensive but only happens when a new
+// leader overwrites entries that were not committed.
+func (l *raftLog) truncate(index uint64) error {
+	if index >= uint64(len(l.entries)) {
+		return nil
+	}
+
+	if l.dir != "" {
+		var buf []byte
+		for _, entry := range l.entries[1:index] {
+			buf = appendEntry(buf, entry)
+		}
+
+		path := filepath.Join(l.dir, logFile)
+		if err := writeFile(path, buf); err != nil {
+			return err
+		}
+
+		// Reopen the log file since the old file has been replaced
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		l.file.Close()
+		l.file = file
+	}
+
+	l.entries = l.entries[:index]
+	return nil
+}
+
+// Close the log file.
+func (l *raftLog) close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Reads the term and vote from the state file, if it exists.
+func (l *raftLog) readState() error {
+	buf, err := ioutil.ReadFile(filepath.Join(l.dir, stateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if len(buf) < 4+8 || binary.LittleEndian.Uint32(buf[0:4]) != crc32.Checksum(buf[4:], crcTable) {
+		return fmt.Errorf("%w: invalid raft state in %s", speedmap.ErrCorrupt, l.dir)
+	}
+
+	l.term = binary.LittleEndian.Uint64(buf[4:12])
+	l.votedFor = string(buf[12:])
+	return nil
+}
+
+// Reads the entries from the log file, returning the number of bytes of
+// complete entries read.
+func (l *raftLog) readEntries() (valid int64, err error) {
+	var f *os.File
+	if f, err = os.Open(filepath.Join(l.dir, logFile)); err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		var (
+			entry Entry
+			n     int
+		)
+
+		if entry, n, err = readEntry(r); err != nil {
+			if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+				return valid, nil
+			}
+			return 0, err
+		}
+
+		if entry.Index != l.lastIndex()+1 {
+			return 0, fmt.Errorf("%w: raft log entry %d follows entry %d", speedmap.ErrCorrupt, entry.Index, l.lastIndex())
+		}
+
+		l.entries = append(l.entries, entry)
+		valid += int64(n)
+	}
+}
+
+// Appends the encoded entry to the buffer and returns the extended buffer.
+func appendEntry(buf []byte, entry Entry) []byte {
+	size := entryBody + len(entry.Command)
+
+	start := len(buf)
+	buf = append(buf, make([]byte, entryHeader+size)...)
+
+	body := buf[start+entryHeader:]
+	binary.LittleEndian.PutUint64(body[0:8], entry.Index)
+	binary.LittleEndian.PutUint64(body[8:16], entry.Term)
+	copy(body[entryBody:], entry.Command)
+
+	binary.LittleEndian.PutUint32(buf[start:start+4], crc32.Checksum(body, crcTable))
+	binary.LittleEndian.PutUint32(buf[start+4:start+8], uint32(size))
+	return buf
+}
+
+// Reads the next entry from the reader, returning the number of bytes read.
+// Returns io.EOF if there are no more entries, io.ErrUnexpectedEOF if the
+// entry is truncated, or an error wrapping speedmap.ErrCorrupt if the entry
+// fails its checksum.
+func readEntry(r io.Reader) (entry Entry, n int, err error) {
+	var header [entryHeader]byte
+	if n, err = io.ReadFull(r, header[:]); err != nil {
+		return entry, n, err
+	}
+
+	size := int(binary.LittleEndian.Uint32(header[4:8]))
+	if size < entryBody || size > maxEntrySize {
+		return entry, n, fmt.Errorf("%w: invalid raft log entry length %d", speedmap.ErrCorrupt, size)
+	}
+
+	body := make([]byte, size)
+	var m int
+	m, err = io.ReadFull(r, body)
+	n += m
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return entry, n, err
+	}
+
+	if binary.LittleEndian.Uint32(header[0:4]) != crc32.Checksum(body, crcTable) {
+		return entry, n, fmt.Errorf("%w: raft log entry checksum mismatch", speedmap.ErrCorrupt)
+	}
+
+	entry.Index = binary.LittleEndian.Uint64(body[0:8])
+	entry.Term = binary.LittleEndian.Uint64(body[8:16])
+	if len(body) > entryBody {
+		entry.Command = body[entryBody:]
+	}
+	return entry, n, nil
+}
+
+// Writes the data to a temporary file that is synced and then renamed to the
+// path, so that the file at the path is always complete.
+func writeFile(path string, data []byte) (err error) {
+	tmp := path + ".tmp"
+	var f *os.File
+	if f, err = os.Create(tmp); err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
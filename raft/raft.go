@@ -0,0 +1,786 @@
+/*
+Package raft replicates a speedmap store between a group of servers with the
+Raft consensus algorithm so that the store remains available, and strongly
+consistent, as long as a majority of the group is available. Writes are
+appended to a log that the leader of the group replicates to the followers,
+and are applied to the local store of every member (its state machine) once a
+majority has stored them. Reads are served by the leader once it has
+confirmed that it is still the leader, so that they see every write that
+completed before the read began.
+*/
+package raft
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Defaults used when the corresponding field of the Config is zero.
+const (
+	DefaultElectionTimeout   = 300 * time.Millisecond
+	DefaultHeartbeatInterval = 50 * time.Millisecond
+)
+
+// The maximum number of entries sent in a single append request.
+const maxAppendEntries = 512
+
+// Config of a node of a replicated group.
+type Config struct {
+	ID                string        // the name of the node, which must be one of the peers
+	Peers             []string      // the names of every node in the group, including this one
+	ElectionTimeout   time.Duration // followers elect a new leader after between one and two timeouts without one
+	HeartbeatInterval time.Duration // how often the leader sends entries or heartbeats to each follower
+	Dir               string        // the directory the state and log are persisted to; in memory if empty
+}
+
+// Entry in the replicated log. Entries with a nil command are appended by a
+// new leader to commit the entries of earlier terms and are not applied.
+type Entry struct {
+	Index   uint64
+	Term    uint64
+	Command []byte
+}
+
+// VoteRequest is sent by candidates to the other nodes to request their vote.
+type VoteRequest struct {
+	Term         uint64
+	Candidate    string
+	LastLogIndex uint64
+	LastLogTerm  uint64
+}
+
+// VoteReply is the reply to a VoteRequest.
+type VoteReply struct {
+	Term    uint64
+	Granted bool
+}
+
+// AppendRequest is sent by the leader to replicate entries to a follower, or
+// with no entries as a heartbeat.
+type AppendRequest struct {
+	Term         uint64
+	Leader       string
+	PrevLogIndex uint64
+	PrevLogTerm  uint64
+	Entries      []Entry
+	LeaderCommit uint64
+}
+
+// AppendReply is the reply to an AppendRequest. If the log of the follower
+// does not match the leader's, ConflictIndex is the index the leader should
+// send entries from next.
+type AppendReply struct {
+	Term          uint64
+	Success       bool
+	ConflictIndex uint64
+}
+
+// Transport sends requests to the other nodes of the group by their name.
+type Transport interface {
+	RequestVote(ctx context.Context, peer string, req *VoteRequest) (*VoteReply, error)
+	AppendEntries(ctx context.Context, peer string, req *AppendRequest) (*AppendReply, error)
+}
+
+// StateMachine applies the commands of committed entries in log order. Every
+// node applies the same commands in the same order, so Apply must be
+// deterministic. The result is returned to the proposer of the command.
+type StateMachine interface {
+	Apply(command []byte) (result interface{}, err error)
+}
+
+// NotLeaderError is returned by requests made to a node that is not the
+// leader of the group, with the name of the leader if the node knows it.
+type NotLeaderError struct {
+	Leader string
+}
+
+func (e *NotLeaderError) Error() string {
+	if e.Leader == "" {
+		return fmt.Sprintf("%s: no leader has been elected", speedmap.ErrNotLeader)
+	}
+	return fmt.Sprintf("%s: the leader is %s", speedmap.ErrNotLeader, e.Leader)
+}
+
+// Unwrap returns speedmap.ErrNotLeader.
+func (e *NotLeaderError) Unwrap() error {
+	return speedmap.ErrNotLeader
+}
+
+// The role of a node in its term.
+type role uint8
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+// Node is a member of a replicated group that takes part in elections and
+// replicates the log, applying committed entries to its state machine. A
+// Node is safe for concurrent use; the handlers of the requests from the
+// other nodes must be called by the transport of the group.
+type Node struct {
+	conf      Config
+	peers     []string // the other nodes in the group
+	transport Transport
+	sm        StateMachine
+
+	mu          sync.Mutex
+	log         *raftLog
+	role        role
+	leader      string                   // the leader of the current term, if known
+	commitIndex uint64                   // the index of the last entry known to be committed
+	lastApplied uint64                   // the index of the last entry applied to the state machine
+	nextIndex   map[string]uint64        // the next entry to send to each follower (leader)
+	matchIndex  map[string]uint64        // the last entry replicated to each follower (leader)
+	acked       map[string]time.Time     // when the last acknowledged request was sent to each follower (leader)
+	proposals   map[uint64]*proposal     // waiting for their entry to be applied by index
+	notify      map[string]chan struct{} // wakes the replicator of each follower
+	deadline    time.Time                // when to start an election if no leader is heard from
+	changed     chan struct{}            // closed and replaced when the state of the node changes
+	done        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// A proposed command waiting for its entry to be applied.
+type proposal struct {
+	term uint64
+	done chan result
+}
+
+// The result of applying a command.
+type result struct {
+	value interface{}
+	err   error
+}
+
+// NewNode creates a node of the group and starts it. The state and log of the
+// node are read from its directory, and every committed entry is applied to
+// the state machine again once the node learns that it is committed, so the
+// state machine should be empty when the node starts.
+func NewNode(conf Config, transport Transport, sm StateMachine) (node *Node, err error) {
+	if conf.ElectionTimeout <= 0 {
+		conf.ElectionTimeout = DefaultElectionTimeout
+	}
+
+	if conf.HeartbeatInterval <= 0 {
+		conf.HeartbeatInterval = DefaultHeartbeatInterval
+	}
+
+	node = &Node{
+		conf:       conf,
+		transport:  transport,
+		sm:         sm,
+		nextIndex:  make(map[string]uint64),
+		matchIndex: make(map[string]uint64),
+		acked:      make(map[string]time.Time),
+		proposals:  make(map[uint64]*proposal),
+		notify:     make(map[string]chan struct{}),
+		changed:    make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	member := false
+	for _, peer := range conf.Peers {
+		if peer == conf.ID {
+			member = true
+			continue
+		}
+
+		node.peers = append(node.peers, peer)
+		node.notify[peer] = make(chan struct{}, 1)
+	}
+
+	if !member {
+		return nil, fmt.Errorf("%q is not one of the peers of the group", conf.ID)
+	}
+
+	if node.log, err = openLog(conf.Dir); err != nil {
+		return nil, err
+	}
+
+	node.resetElection()
+	node.wg.Add(2 + len(node.peers))
+	go node.run()
+	go node.apply()
+	for _, peer := range node.peers {
+		go node.replicate(peer)
+	}
+	return node, nil
+}
+
+// ID returns the name of the node.
+func (n *Node) ID() string {
+	return n.conf.ID
+}
+
+// Leader returns the name of the leader of the group, if the node knows it.
+func (n *Node) Leader() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.leader
+}
+
+// Status returns the current term of the node and whether it is the leader.
+func (n *Node) Status() (term uint64, isLeader bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.log.term, n.role == leader
+}
+
+// Propose appends the command to the log if the node is the leader, then
+// waits for it to be committed and applied, returning the result of applying
+// it. If the node is not the leader, or loses its leadership before the
+// command is committed, a NotLeaderError is returned. If the context is done
+// first, the command may still be committed.
+func (n *Node) Propose(ctx context.Context, command []byte) (value interface{}, err error) {
+	if command == nil {
+		command = []byte{}
+	}
+
+	n.mu.Lock()
+	if n.role != leader {
+		n.mu.Unlock()
+		return nil, &NotLeaderError{Leader: n.leader}
+	}
+
+	entry := Entry{Index: n.log.lastIndex() + 1, Term: n.log.term, Command: command}
+	if err = n.log.append(entry); err != nil {
+		n.mu.Unlock()
+		return nil, err
+	}
+
+	p := &proposal{term: entry.Term, done: make(chan result, 1)}
+	n.proposals[entry.Index] = p
+	n.advanceCommit()
+	n.wakeReplicators()
+	n.mu.Unlock()
+
+	select {
+	case r := <-p.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		n.mu.Lock()
+		delete(n.proposals, entry.Index)
+		n.mu.Unlock()
+		return nil, ctx.Err()
+	case <-n.done:
+		return nil, speedmap.ErrClosed
+	}
+}
+
+// ReadIndex waits until reads of the state machine will see every command
+// committed before ReadIndex was called: it confirms that the node is still
+// the leader with a round of heartbeats to a majority of the group, then waits
+// for the commit index at the time of the call to be applied. A
+// NotLeaderError is returned if the node is not the leader.
+func (n *Node) ReadIndex(ctx context.Context) (err error) {
+	start := time.Now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.role != leader {
+		return &NotLeaderError{Leader: n.leader}
+	}
+	term := n.log.term
+
+	// The commit index is only known once an entry of the term is committed
+	for n.log.termAt(n.commitIndex) != term {
+		if err = n.wait(ctx, term); err != nil {
+			return err
+		}
+	}
+	index := n.commitIndex
+
+	n.wakeReplicators()
+	for !n.confirmed(start) {
+		if err = n.wait(ctx, term); err != nil {
+			return err
+		}
+	}
+
+	for n.lastApplied < index {
+		if err = n.wait(ctx, term); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops the node and closes its log. Proposals and reads that are
+// waiting return speedmap.ErrClosed.
+func (n *Node) Close() error {
+	n.mu.Lock()
+	select {
+	case <-n.done:
+		n.mu.Unlock()
+		return nil
+	default:
+		close(n.done)
+	}
+	n.mu.Unlock()
+
+	n.wg.Wait()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.log.close()
+}
+
+//===========================================================================
+// Request Handlers
+//===========================================================================
+
+// RequestVote handles a request for the vote of the node from a candidate.
+// The vote is granted if the node has not voted for another candidate in
+// the term and the log of the candidate is at least as up to date as its own.
+func (n *Node) RequestVote(req *VoteRequest) (rep *VoteReply, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if req.Term > n.log.term {
+		if err = n.becomeFollower(req.Term, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	rep = &VoteReply{Term: n.log.term}
+	if req.Term < n.log.term {
+		return rep, nil
+	}
+
+	lastIndex := n.log.lastIndex()
+	lastTerm := n.log.termAt(lastIndex)
+	upToDate := req.LastLogTerm > lastTerm || (req.LastLogTerm == lastTerm && req.LastLogIndex >= lastIndex)
+
+	if (n.log.votedFor == "" || n.log.votedFor == req.Candidate) && upToDate {
+		if err = n.log.setState(n.log.term, req.Candidate); err != nil {
+			return nil, err
+		}
+
+		n.resetElection()
+		rep.Granted = true
+	}
+	return rep, nil
+}
+
+// AppendEntries handles a request from the leader to append entries to the
+// log of the node, overwriting any entries that conflict with them.
+func (n *Node) AppendEntries(req *AppendRequest) (rep *AppendReply, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	rep = &AppendReply{Term: n.log.term}
+	if req.Term < n.log.term {
+		return rep, nil
+	}
+
+	// A request from the leader of the term ends any election by this node
+	if req.Term > n.log.term || n.role != follower || n.leader != req.Leader {
+		if err = n.becomeFollower(req.Term, req.Leader); err != nil {
+			return nil, err
+		}
+		rep.Term = n.log.term
+	}
+	n.resetElection()
+
+	// The log must contain the entry that precedes the entries
+	lastIndex := n.log.lastIndex()
+	if req.PrevLogIndex > lastIndex {
+		rep.ConflictIndex = lastIndex + 1
+		return rep, nil
+	}
+
+	if term := n.log.termAt(req.PrevLogIndex); term != req.PrevLogTerm {
+		// Skip the entire conflicting term rather than one entry at a time
+		index := req.PrevLogIndex
+		for index > 1 && n.log.termAt(index-1) == term {
+			index--
+		}
+		rep.ConflictIndex = index
+		return rep, nil
+	}
+
+	for i, entry := range req.Entries {
+		if entry.Index <= n.log.lastIndex() {
+			if n.log.termAt(entry.Index) == entry.Term {
+				continue
+			}
+
+			if err = n.log.truncate(entry.Index); err != nil {
+				return nil, err
+			}
+		}
+
+		if err = n.log.append(req.Entries[i:]...); err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	// Only the entries known to match the leader's log can be committed
+	if last := req.PrevLogIndex + uint64(len(req.Entries)); req.LeaderCommit > n.commitIndex {
+		n.commitIndex = min(req.LeaderCommit, last)
+		n.broadcast()
+	}
+
+	rep.Success = true
+	return rep, nil
+}
+
+//===========================================================================
+// Elections and Replication
+//===========================================================================
+
+// Starts an election whenever the election deadline passes without hearing
+// from a leader.
+func (n *Node) run() {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.conf.HeartbeatInterval / 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-ticker.C:
+		}
+
+		n.mu.Lock()
+		if n.role != leader && time.Now().After(n.deadline) {
+			n.campaign()
+		}
+		n.mu.Unlock()
+	}
+}
+
+// Becomes a candidate in the next term and requests votes from the other
+// nodes, becoming the leader if a majority of the group votes for it. Must be
+// called with the mutex held.
+func (n *Node) campaign() {
+	term := n.log.term + 1
+	if err := n.log.setState(term, n.conf.ID); err != nil {
+		// The node cannot vote for itself, so try again after the next timeout
+		n.resetElection()
+		return
+	}
+
+	n.role, n.leader = candidate, ""
+	n.resetElection()
+	n.broadcast()
+
+	votes := 1
+	if votes >= n.quorum() {
+		n.becomeLeader()
+		return
+	}
+
+	lastIndex := n.log.lastIndex()
+	req := &VoteRequest{Term: term, Candidate: n.conf.ID, LastLogIndex: lastIndex, LastLogTerm: n.log.termAt(lastIndex)}
+	for _, peer := range n.peers {
+		go func(peer string) {
+			ctx, cancel := context.WithTimeout(context.Background(), n.conf.ElectionTimeout)
+			defer cancel()
+
+			rep, err := n.transport.RequestVote(ctx, peer, req)
+			if err != nil {
+				return
+			}
+
+			n.mu.Lock()
+			defer n.mu.Unlock()
+
+			if rep.Term > n.log.term {
+				n.becomeFollower(rep.Term, "")
+				return
+			}
+
+			if n.role == candidate && n.log.term == term && rep.Granted {
+				if votes++; votes >= n.quorum() {
+					n.becomeLeader()
+				}
+			}
+		}(peer)
+	}
+}
+
+// Becomes the leader of the current term and appends an entry with no
+// command so that the entries of earlier terms are committed. Must be called
+// with the mutex held.
+func (n *Node) becomeLeader() {
+	n.role, n.leader = leader, n.conf.ID
+
+	next := n.log.lastIndex() + 1
+	for _, peer := range n.peers {
+		n.nextIndex[peer] = next
+		n.matchIndex[peer] = 0
+		delete(n.acked, peer)
+	}
+
+	if err := n.log.append(Entry{Index: next, Term: n.log.term}); err != nil {
+		// Without the entry the leader cannot commit, so let another node lead
+		n.becomeFollower(n.log.term, "")
+		return
+	}
+
+	n.advanceCommit()
+	n.wakeReplicators()
+	n.broadcast()
+}
+
+// Becomes a follower in the term, forgetting the vote of an earlier term.
+// Must be called with the mutex held.
+func (n *Node) becomeFollower(term uint64, leader string) error {
+	votedFor := n.log.votedFor
+	if term > n.log.term {
+		votedFor = ""
+	}
+
+	if err := n.log.setState(term, votedFor); err != nil {
+		return err
+	}
+
+	n.role, n.leader = follower, leader
+	n.broadcast()
+	return nil
+}
+
+// Sends entries (or heartbeats) to the follower every heartbeat interval,
+// or as soon as the replicator is woken, while the node is the leader.
+func (n *Node) replicate(peer string) {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.conf.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-ticker.C:
+		case <-n.notify[peer]:
+		}
+
+		for n.sendAppend(peer) {
+		}
+	}
+}
+
+// Sends the next entries to the follower, returning true if there are more
+// entries to send immediately.
+func (n *Node) sendAppend(peer string) (more bool) {
+	n.mu.Lock()
+	if n.role != leader {
+		n.mu.Unlock()
+		return false
+	}
+
+	term := n.log.term
+	prev := n.nextIndex[peer] - 1
+	req := &AppendRequest{
+		Term:         term,
+		Leader:       n.conf.ID,
+		PrevLogIndex: prev,
+		PrevLogTerm:  n.log.termAt(prev),
+		Entries:      n.log.slice(prev+1, maxAppendEntries),
+		LeaderCommit: n.commitIndex,
+	}
+	n.mu.Unlock()
+
+	sent := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), n.conf.ElectionTimeout)
+	rep, err := n.transport.AppendEntries(ctx, peer, req)
+	cancel()
+	if err != nil {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if rep.Term > n.log.term {
+		n.becomeFollower(rep.Term, "")
+		return false
+	}
+
+	if n.role != leader || n.log.term != term {
+		return false
+	}
+
+	// Any reply in the term confirms that the node is still the leader
+	if sent.After(n.acked[peer]) {
+		n.acked[peer] = sent
+	}
+	defer n.broadcast()
+
+	if !rep.Success {
+		next := rep.ConflictIndex
+		if next > prev {
+			next = prev
+		}
+		if next < 1 {
+			next = 1
+		}
+		n.nextIndex[peer] = next
+		return true
+	}
+
+	if match := prev + uint64(len(req.Entries)); match > n.matchIndex[peer] {
+		n.matchIndex[peer] = match
+		n.nextIndex[peer] = match + 1
+		n.advanceCommit()
+	}
+	return n.nextIndex[peer] <= n.log.lastIndex()
+}
+
+// Commits the latest entry of the current term that a majority of the group
+// has stored. Must be called with the mutex held.
+func (n *Node) advanceCommit() {
+	for index := n.log.lastIndex(); index > n.commitIndex; index-- {
+		if n.log.termAt(index) != n.log.term {
+			return
+		}
+
+		count := 1
+		for _, peer := range n.peers {
+			if n.matchIndex[peer] >= index {
+				count++
+			}
+		}
+
+		if count >= n.quorum() {
+			n.commitIndex = index
+			n.broadcast()
+			return
+		}
+	}
+}
+
+// Applies committed entries to the state machine in order and completes the
+// proposals waiting for them.
+func (n *Node) apply() {
+	defer n.wg.Done()
+
+	for {
+		n.mu.Lock()
+		for n.lastApplied >= n.commitIndex {
+			changed := n.changed
+			n.mu.Unlock()
+
+			select {
+			case <-changed:
+			case <-n.done:
+				return
+			}
+			n.mu.Lock()
+		}
+		entries := n.log.slice(n.lastApplied+1, int(n.commitIndex-n.lastApplied))
+		n.mu.Unlock()
+
+		for _, entry := range entries {
+			var r result
+			if entry.Command != nil {
+				r.value, r.err = n.sm.Apply(entry.Command)
+			}
+
+			n.mu.Lock()
+			n.lastApplied = entry.Index
+			if p, ok := n.proposals[entry.Index]; ok {
+				delete(n.proposals, entry.Index)
+				if p.term != entry.Term {
+					// The entry proposed at this index was overwritten by a new leader
+					r = result{err: &NotLeaderError{Leader: n.leader}}
+				}
+				p.done <- r
+			}
+			n.broadcast()
+			n.mu.Unlock()
+		}
+	}
+}
+
+//===========================================================================
+// Helpers
+//===========================================================================
+
+// The number of nodes that make up a majority of the group.
+func (n *Node) quorum() int {
+	return (len(n.peers)+1)/2 + 1
+}
+
+// Returns true if a majority of the group has replied to requests sent after
+// the time in the current term.
+func (n *Node) confirmed(since time.Time) bool {
+	count := 1
+	for _, peer := range n.peers {
+		if !n.acked[peer].Before(since) {
+			count++
+		}
+	}
+	return count >= n.quorum()
+}
+
+// Waits for the state of the node to change, returning an error if the node
+// is no longer the leader of the term, is closed, or the context is done.
+// Must be called with the mutex held, which is released while waiting.
+func (n *Node) wait(ctx context.Context, term uint64) error {
+	changed := n.changed
+	n.mu.Unlock()
+
+	var err error
+	select {
+	case <-changed:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-n.done:
+		err = speedmap.ErrClosed
+	}
+
+	n.mu.Lock()
+	if err != nil {
+		return err
+	}
+
+	if n.role != leader || n.log.term != term {
+		return &NotLeaderError{Leader: n.leader}
+	}
+	return nil
+}
+
+// Wakes the waiters for a change to the state of the node. Must be called
+// with the mutex held.
+func (n *Node) broadcast() {
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+// Wakes the replicators to send entries to the followers immediately.
+func (n *Node) wakeReplicators() {
+	for _, peer := range n.peers {
+		select {
+		case n.notify[peer] <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Sets a new randomized election deadline so that nodes rarely time out at
+// the same time. Must be called with the mutex held.
+func (n *Node) resetElection() {
+	timeout := n.conf.ElectionTimeout + time.Duration(rand.Int63n(int64(n.conf.ElectionTimeout)))
+	n.deadline = time.Now().Add(timeout)
+}
+
+func min(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
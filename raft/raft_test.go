@@ -0,0 +1,250 @@
+package raft_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/raft"
+	"github.com/bbengfort/speedmap/store"
+)
+
+var errUnreachable = errors.New("peer is unreachable")
+
+// An in-memory transport between the members of a group that can disconnect
+// members to simulate failures.
+type network struct {
+	sync.RWMutex
+	nodes map[string]*Node
+	down  map[string]bool
+}
+
+func newNetwork() *network {
+	return &network{nodes: make(map[string]*Node), down: make(map[string]bool)}
+}
+
+// Returns the node if neither it nor the sender is disconnected.
+func (n *network) node(from, to string) (*Node, error) {
+	n.RLock()
+	defer n.RUnlock()
+	if n.down[from] || n.down[to] || n.nodes[to] == nil {
+		return nil, errUnreachable
+	}
+	return n.nodes[to], nil
+}
+
+func (n *network) set(name string, node *Node, down bool) {
+	n.Lock()
+	defer n.Unlock()
+	if node != nil {
+		n.nodes[name] = node
+	}
+	n.down[name] = down
+}
+
+// The transport of a single member.
+type transport struct {
+	net  *network
+	name string
+}
+
+func (t *transport) RequestVote(ctx context.Context, peer string, req *VoteRequest) (*VoteReply, error) {
+	node, err := t.net.node(t.name, peer)
+	if err != nil {
+		return nil, err
+	}
+	return node.RequestVote(req)
+}
+
+func (t *transport) AppendEntries(ctx context.Context, peer string, req *AppendRequest) (*AppendReply, error) {
+	node, err := t.net.node(t.name, peer)
+	if err != nil {
+		return nil, err
+	}
+	return node.AppendEntries(req)
+}
+
+var _ = Describe("Raft", func() {
+
+	var (
+		dir    string
+		net    *network
+		stores map[string]*Store
+	)
+
+	peers := []string{"alpha", "bravo", "charlie"}
+
+	// Starts the member with an empty local store.
+	start := func(name string) *Store {
+		local, err := store.NewBasic()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		conf := Config{
+			ID:                name,
+			Peers:             peers,
+			ElectionTimeout:   100 * time.Millisecond,
+			HeartbeatInterval: 20 * time.Millisecond,
+			Dir:               filepath.Join(dir, name),
+		}
+
+		s, err := NewStore(conf, &transport{net: net, name: name}, local)
+		Ω(err).ShouldNot(HaveOccurred())
+		net.set(name, s.Node(), false)
+		stores[name] = s
+		return s
+	}
+
+	// Waits for a connected member to become the leader and returns its name.
+	leader := func() string {
+		var name string
+		Eventually(func() string {
+			name = ""
+			for _, peer := range peers {
+				if _, isLeader := stores[peer].Node().Status(); isLeader && !net.down[peer] {
+					name = peer
+				}
+			}
+			return name
+		}, 5*time.Second, 10*time.Millisecond).ShouldNot(BeEmpty())
+		return name
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "speedmap-raft")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		net = newNetwork()
+		stores = make(map[string]*Store)
+		for _, peer := range peers {
+			start(peer)
+		}
+	})
+
+	AfterEach(func() {
+		for _, s := range stores {
+			s.Close()
+		}
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	It("should elect a single leader", func() {
+		name := leader()
+		for _, peer := range peers {
+			Eventually(stores[peer].Node().Leader, 2*time.Second).Should(Equal(name))
+		}
+	})
+
+	It("should replicate puts and deletes to every member", func() {
+		s := stores[leader()]
+		Ω(s.Put("foo", []byte("bar"))).Should(Succeed())
+		Ω(s.Put("baz", []byte("qux"))).Should(Succeed())
+		Ω(s.Delete("baz")).Should(Succeed())
+		Ω(s.MultiPut(map[string][]byte{"a": []byte("1"), "b": []byte("2")})).Should(Succeed())
+
+		val, err := s.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		vals, err := s.MultiGet([]string{"a", "b", "baz"})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(vals).Should(Equal([][]byte{[]byte("1"), []byte("2"), nil}))
+
+		actual, created := s.GetOrCreate("foo", []byte("zap"))
+		Ω(created).Should(BeFalse())
+		Ω(actual).Should(Equal([]byte("bar")))
+
+		actual, created = s.GetOrCreate("new", []byte("zap"))
+		Ω(created).Should(BeTrue())
+		Ω(actual).Should(Equal([]byte("zap")))
+
+		// Followers apply the entries once they learn that they are committed
+		for _, peer := range peers {
+			s := stores[peer]
+			Eventually(func() (bool, error) {
+				_, created := s.GetOrCreate("new", nil)
+				return created, nil
+			}).Should(BeFalse())
+		}
+	})
+
+	It("should return the leader from followers", func() {
+		name := leader()
+		for _, peer := range peers {
+			if peer == name {
+				continue
+			}
+
+			Eventually(stores[peer].Node().Leader, 2*time.Second).Should(Equal(name))
+			err := stores[peer].Put("foo", []byte("bar"))
+			Ω(errors.Is(err, speedmap.ErrNotLeader)).Should(BeTrue())
+
+			var nle *NotLeaderError
+			Ω(errors.As(err, &nle)).Should(BeTrue())
+			Ω(nle.Leader).Should(Equal(name))
+
+			_, err = stores[peer].Get("foo")
+			Ω(errors.Is(err, speedmap.ErrNotLeader)).Should(BeTrue())
+		}
+	})
+
+	It("should elect a new leader that has every committed put", func() {
+		old := leader()
+		Ω(stores[old].Put("foo", []byte("bar"))).Should(Succeed())
+
+		net.set(old, nil, true)
+		name := leader()
+		Ω(name).ShouldNot(Equal(old))
+
+		val, err := stores[name].Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+		Ω(stores[name].Put("foo", []byte("baz"))).Should(Succeed())
+
+		// The old leader cannot commit or read without a majority
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		_, err = stores[old].GetCtx(ctx, "foo")
+		Ω(err).Should(HaveOccurred())
+
+		// Once reconnected, the old leader follows the new leader
+		net.set(old, nil, false)
+		Eventually(stores[old].Node().Leader, 2*time.Second).ShouldNot(Equal(old))
+	})
+
+	It("should recover the log after a restart", func() {
+		s := stores[leader()]
+		for _, key := range []string{"a", "b", "c"} {
+			Ω(s.Put(key, []byte(key))).Should(Succeed())
+		}
+
+		for _, peer := range peers {
+			net.set(peer, nil, true)
+			Ω(stores[peer].Close()).Should(Succeed())
+		}
+
+		for _, peer := range peers {
+			start(peer)
+		}
+
+		s = stores[leader()]
+		for _, key := range []string{"a", "b", "c"} {
+			val, err := s.Get(key)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(val).Should(Equal([]byte(key)))
+		}
+	})
+
+	It("should not create members that are not peers", func() {
+		_, err := NewStore(Config{ID: "delta", Peers: peers}, &transport{net: net, name: "delta"}, nil)
+		Ω(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,338 @@
+package raft
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// DefaultTimeout of the operations of a Store that do not accept a context.
+const DefaultTimeout = 5 * time.Second
+
+// The operations of the commands in the log.
+const (
+	opPut uint8 = iota + 1
+	opPutTTL
+	opDelete
+	opCreate
+	opBatch
+)
+
+// Store is a speedmap.Store that is replicated by a Raft group. Puts, deletes
+// and creates are appended to the log by the leader and applied to the local
+// store of every member once they are committed. Gets are served from the
+// local store of the leader after it confirms its leadership. Every operation
+// made on a member that is not the leader returns a NotLeaderError with the
+// name of the leader if it is known.
+//
+// The local store is the state machine of the member, so it must not be
+// modified other than by the Store. Because the committed entries of the log
+// are applied again when a member restarts, the local store should be empty
+// (i.e. not durable) when the Store is created.
+type Store struct {
+	node  *Node
+	local speedmap.Store
+}
+
+// NewStore creates a member of the replicated group described by the config,
+// which sends requests to the other members on the transport and applies the
+// committed commands to the local store.
+func NewStore(conf Config, transport Transport, local speedmap.Store) (store *Store, err error) {
+	store = &Store{local: local}
+	if store.node, err = NewNode(conf, transport, store); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Node returns the member of the group, whose handlers serve the requests of
+// the other members.
+func (s *Store) Node() *Node {
+	return s.node
+}
+
+// Get the value for the key from the local store of the leader.
+func (s *Store) Get(key string) (value []byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	return s.GetCtx(ctx, key)
+}
+
+// Put the value for the key once the put is committed.
+func (s *Store) Put(key string, value []byte) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	return s.PutCtx(ctx, key, value)
+}
+
+// Delete the key once the delete is committed.
+func (s *Store) Delete(key string) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	return s.DeleteCtx(ctx, key)
+}
+
+// GetOrCreate returns the value for the key if it exists, otherwise it
+// creates the key with the value once the create is committed. Errors are
+// treated as misses that do not create the key.
+func (s *Store) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	actual, created, _ = s.GetOrCreateCtx(ctx, key, value)
+	return actual, created
+}
+
+// GetCtx returns the value for the key from the local store once the leader
+// has confirmed that it has applied every committed put.
+func (s *Store) GetCtx(ctx context.Context, key string) (value []byte, err error) {
+	if err = s.node.ReadIndex(ctx); err != nil {
+		return nil, err
+	}
+	return s.local.Get(key)
+}
+
+// PutCtx proposes the put and waits for it to be applied.
+func (s *Store) PutCtx(ctx context.Context, key string, value []byte) (err error) {
+	if len(key) > speedmap.MaxKeySize {
+		return fmt.Errorf("%w (%d > %d bytes)", speedmap.ErrKeyTooLarge, len(key), speedmap.MaxKeySize)
+	}
+
+	_, err = s.node.Propose(ctx, encodePair(opPut, nil, key, value))
+	return err
+}
+
+// DeleteCtx proposes the delete and waits for it to be applied.
+func (s *Store) DeleteCtx(ctx context.Context, key string) (err error) {
+	_, err = s.node.Propose(ctx, encodePair(opDelete, nil, key, nil))
+	return err
+}
+
+// GetOrCreateCtx proposes the create and waits for it to be applied, so that
+// the creates of different members are ordered by the log. If the value is
+// nil the key is not created, so the value is read as it is by GetCtx.
+func (s *Store) GetOrCreateCtx(ctx context.Context, key string, value []byte) (actual []byte, created bool, err error) {
+	if value == nil {
+		if actual, err = s.GetCtx(ctx, key); err != nil && !errors.Is(err, speedmap.ErrNotFound) {
+			return nil, false, err
+		}
+		return actual, false, nil
+	}
+
+	if len(key) > speedmap.MaxKeySize {
+		return nil, false, fmt.Errorf("%w (%d > %d bytes)", speedmap.ErrKeyTooLarge, len(key), speedmap.MaxKeySize)
+	}
+
+	var rep interface{}
+	if rep, err = s.node.Propose(ctx, encodePair(opCreate, nil, key, value)); err != nil {
+		return nil, false, err
+	}
+
+	res := rep.(createResult)
+	return res.actual, res.created, nil
+}
+
+// PutWithTTL proposes a put that expires after the ttl, which requires the
+// local store to be a speedmap.Expirer. The expiration is replicated as an
+// absolute time, so the clocks of the members should be synchronized.
+func (s *Store) PutWithTTL(key string, value []byte, ttl time.Duration) (err error) {
+	if _, ok := s.local.(speedmap.Expirer); !ok {
+		return fmt.Errorf("the %s store does not support expiration", s.local)
+	}
+
+	if len(key) > speedmap.MaxKeySize {
+		return fmt.Errorf("%w (%d > %d bytes)", speedmap.ErrKeyTooLarge, len(key), speedmap.MaxKeySize)
+	}
+
+	var expires [8]byte
+	if ttl > 0 {
+		binary.LittleEndian.PutUint64(expires[:], uint64(time.Now().Add(ttl).UnixNano()))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	_, err = s.node.Propose(ctx, encodePair(opPutTTL, expires[:], key, value))
+	return err
+}
+
+// MultiGet returns the values of the keys from the local store of the leader
+// after a single confirmation of its leadership.
+func (s *Store) MultiGet(keys []string) (values [][]byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	if err = s.node.ReadIndex(ctx); err != nil {
+		return nil, err
+	}
+	return speedmap.MultiGet(s.local, keys)
+}
+
+// MultiPut proposes all of the pairs as a single command, so that the batch
+// is replicated and applied as one entry.
+func (s *Store) MultiPut(pairs map[string][]byte) (err error) {
+	cmd := []byte{opBatch}
+	cmd = binary.LittleEndian.AppendUint32(cmd, uint32(len(pairs)))
+	for key, value := range pairs {
+		if len(key) > speedmap.MaxKeySize {
+			return fmt.Errorf("%w (%d > %d bytes)", speedmap.ErrKeyTooLarge, len(key), speedmap.MaxKeySize)
+		}
+		cmd = appendBytes(cmd, []byte(key), value)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	_, err = s.node.Propose(ctx, cmd)
+	return err
+}
+
+// Close stops the member and closes the local store if it is closable.
+func (s *Store) Close() (err error) {
+	err = s.node.Close()
+	if closer, ok := s.local.(interface{ Close() error }); ok {
+		if cerr := closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// String returns the name of the replicated store.
+func (s *Store) String() string {
+	return "raft " + s.local.String()
+}
+
+//===========================================================================
+// State Machine
+//===========================================================================
+
+// The result of applying a create command.
+type createResult struct {
+	actual  []byte
+	created bool
+}
+
+// Apply a committed command to the local store.
+func (s *Store) Apply(command []byte) (result interface{}, err error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("%w: empty raft command", speedmap.ErrCorrupt)
+	}
+
+	op, buf := command[0], command[1:]
+	switch op {
+	case opPut, opDelete, opCreate:
+		var key, value []byte
+		if key, value, err = decodePair(buf); err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case opPut:
+			return nil, s.local.Put(string(key), value)
+		case opDelete:
+			return nil, s.local.Delete(string(key))
+		default:
+			actual, created := s.local.GetOrCreate(string(key), value)
+			return createResult{actual: actual, created: created}, nil
+		}
+
+	case opPutTTL:
+		if len(buf) < 8 {
+			return nil, fmt.Errorf("%w: truncated raft command", speedmap.ErrCorrupt)
+		}
+
+		var key, value []byte
+		if key, value, err = decodePair(buf[8:]); err != nil {
+			return nil, err
+		}
+
+		var ttl time.Duration
+		if expires := int64(binary.LittleEndian.Uint64(buf[:8])); expires > 0 {
+			// Entries applied after they expire (e.g. on restart) remove the key
+			if ttl = time.Until(time.Unix(0, expires)); ttl <= 0 {
+				if err = s.local.Delete(string(key)); errors.Is(err, speedmap.ErrNotFound) {
+					err = nil
+				}
+				return nil, err
+			}
+		}
+		return nil, s.local.(speedmap.Expirer).PutWithTTL(string(key), value, ttl)
+
+	case opBatch:
+		if len(buf) < 4 {
+			return nil, fmt.Errorf("%w: truncated raft command", speedmap.ErrCorrupt)
+		}
+
+		n := binary.LittleEndian.Uint32(buf[:4])
+		pairs := make(map[string][]byte, n)
+		for buf = buf[4:]; n > 0; n-- {
+			var key, value []byte
+			if key, buf, err = readBytes(buf); err != nil {
+				return nil, err
+			}
+			if value, buf, err = readBytes(buf); err != nil {
+				return nil, err
+			}
+			pairs[string(key)] = value
+		}
+		return nil, speedmap.MultiPut(s.local, pairs)
+
+	default:
+		return nil, fmt.Errorf("%w: unknown raft command %d", speedmap.ErrCorrupt, op)
+	}
+}
+
+// Encodes a command for the key and value, with the fixed size arguments
+// preceding them.
+func encodePair(op uint8, args []byte, key string, value []byte) []byte {
+	cmd := make([]byte, 0, 1+len(args)+8+len(key)+len(value))
+	cmd = append(cmd, op)
+	cmd = append(cmd, args...)
+	return appendBytes(cmd, []byte(key), value)
+}
+
+// Decodes the key and value of a command.
+func decodePair(buf []byte) (key, value []byte, err error) {
+	if key, buf, err = readBytes(buf); err != nil {
+		return nil, nil, err
+	}
+
+	if value, _, err = readBytes(buf); err != nil {
+		return nil, nil, err
+	}
+	return key, value, nil
+}
+
+// Appends each of the byte slices to the buffer prefixed by its length. A nil
+// slice is distinguished from an empty slice so that nil values are kept.
+func appendBytes(buf []byte, fields ...[]byte) []byte {
+	for _, field := range fields {
+		if field == nil {
+			buf = binary.LittleEndian.AppendUint32(buf, 1<<32-1)
+			continue
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(field)))
+		buf = append(buf, field...)
+	}
+	return buf
+}
+
+// Reads a length prefixed byte slice from the buffer, returning the rest of
+// the buffer.
+func readBytes(buf []byte) (field, rest []byte, err error) {
+	if len(buf) < 4 {
+		return nil, nil, fmt.Errorf("%w: truncated raft command", speedmap.ErrCorrupt)
+	}
+
+	size := binary.LittleEndian.Uint32(buf[:4])
+	if size == 1<<32-1 {
+		return nil, buf[4:], nil
+	}
+
+	if uint64(len(buf)-4) < uint64(size) {
+		return nil, nil, fmt.Errorf("%w: truncated raft command", speedmap.ErrCorrupt)
+	}
+	return buf[4 : 4+size], buf[4+size:], nil
+}
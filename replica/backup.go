@@ -0,0 +1,376 @@
+package replica
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// The time a backup waits before reconnecting to the primary, doubling after
+// each failed attempt up to the maximum.
+const (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 5 * time.Second
+)
+
+// Backup is a speedmap.Store that streams the mutations of a primary and
+// applies them to its local store in order. Reads are served from the local
+// store and may not see the latest writes to the primary; writes return a
+// ReadOnlyError with the address of the primary until the backup is promoted,
+// after which it is the primary of its local store.
+type Backup struct {
+	sync.RWMutex
+	primary   string                         // the address of the primary
+	source    Source                         // streams the mutations of the primary
+	factory   func() (speedmap.Store, error) // creates the store for a full sync
+	backlog   int                            // the backlog of the log once promoted
+	local     speedmap.Store                 // the store the mutations are applied to
+	syncing   speedmap.Store                 // the store being filled by a full sync
+	promoted  *Primary                       // the primary of the local store once promoted
+	epoch     uint64                         // the epoch of the log of the primary
+	applied   uint64                         // the last mutation applied to the local store
+	head      uint64                         // the head of the log of the primary, as last heard
+	synced    bool                           // whether the local store has been synced
+	connected bool                           // whether the backup is streaming from the primary
+	last      time.Time                      // when the primary applied the last mutation applied
+	err       error                          // the error that ended the last stream from the primary
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// NewBackup creates a backup of the primary at the address, streaming its
+// mutations from the source. The factory creates the local store, and a new
+// store whenever the backup must sync the entire store of the primary (which
+// replaces the local store once complete). Once promoted, the backup keeps
+// the latest backlog mutations in its log (DefaultBacklog if zero).
+func NewBackup(primary string, source Source, factory func() (speedmap.Store, error), backlog int) (store *Backup, err error) {
+	store = &Backup{primary: primary, source: source, factory: factory, backlog: backlog, done: make(chan struct{})}
+	if store.local, err = factory(); err != nil {
+		return nil, err
+	}
+
+	var ctx context.Context
+	ctx, store.cancel = context.WithCancel(context.Background())
+	go store.run(ctx)
+	return store, nil
+}
+
+// Get the value of the key from the local store.
+func (b *Backup) Get(key string) (value []byte, err error) {
+	return b.store().Get(key)
+}
+
+// Put returns a ReadOnlyError unless the backup has been promoted.
+func (b *Backup) Put(key string, value []byte) (err error) {
+	if p := b.primaryStore(); p != nil {
+		return p.Put(key, value)
+	}
+	return &ReadOnlyError{Primary: b.primary}
+}
+
+// Delete returns a ReadOnlyError unless the backup has been promoted.
+func (b *Backup) Delete(key string) (err error) {
+	if p := b.primaryStore(); p != nil {
+		return p.Delete(key)
+	}
+	return &ReadOnlyError{Primary: b.primary}
+}
+
+// GetOrCreate returns the value of the key from the local store, but does not
+// create keys that are not found unless the backup has been promoted.
+func (b *Backup) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	if p := b.primaryStore(); p != nil {
+		return p.GetOrCreate(key, value)
+	}
+
+	actual, _ = b.store().Get(key)
+	return actual, false
+}
+
+// PutWithTTL returns a ReadOnlyError unless the backup has been promoted.
+func (b *Backup) PutWithTTL(key string, value []byte, ttl time.Duration) (err error) {
+	if p := b.primaryStore(); p != nil {
+		return p.PutWithTTL(key, value, ttl)
+	}
+	return &ReadOnlyError{Primary: b.primary}
+}
+
+// MultiGet the values of the keys from the local store.
+func (b *Backup) MultiGet(keys []string) (values [][]byte, err error) {
+	return speedmap.MultiGet(b.store(), keys)
+}
+
+// MultiPut returns a ReadOnlyError unless the backup has been promoted.
+func (b *Backup) MultiPut(pairs map[string][]byte) (err error) {
+	if p := b.primaryStore(); p != nil {
+		return p.MultiPut(pairs)
+	}
+	return &ReadOnlyError{Primary: b.primary}
+}
+
+// Range over the pairs of the local store, which must be speedmap.Iterable.
+func (b *Backup) Range(fn func(key string, value []byte) bool) error {
+	return speedmap.Range(b.store(), fn)
+}
+
+// Promote the backup to the primary of its local store, so that it accepts
+// writes and other backups can stream its log. The backup stops streaming
+// from its primary first, so any mutations it has not received are lost.
+// Promote does nothing if the backup has already been promoted.
+func (b *Backup) Promote() (err error) {
+	b.stop()
+
+	b.Lock()
+	defer b.Unlock()
+	if b.promoted == nil {
+		if b.promoted, err = NewPrimary(b.local, b.backlog); err != nil {
+			return err
+		}
+		b.connected = false
+	}
+	return nil
+}
+
+// Sync streams the log to another backup once the backup has been promoted,
+// see Primary.Sync; until then it returns a ReadOnlyError.
+func (b *Backup) Sync(ctx context.Context, epoch, from uint64, send func(*Mutation) error) error {
+	if p := b.primaryStore(); p != nil {
+		return p.Sync(ctx, epoch, from, send)
+	}
+	return &ReadOnlyError{Primary: b.primary}
+}
+
+// Status returns the position of the backup in the log of the primary and its
+// lag, or the status of the primary once promoted.
+func (b *Backup) Status() Status {
+	if p := b.primaryStore(); p != nil {
+		return p.Status()
+	}
+
+	b.RLock()
+	defer b.RUnlock()
+
+	status := Status{
+		Role:      "backup",
+		Primary:   b.primary,
+		Connected: b.connected,
+		Epoch:     b.epoch,
+		Head:      b.head,
+		Applied:   b.applied,
+		Err:       b.err,
+	}
+
+	if b.synced && b.applied < b.head && !b.last.IsZero() {
+		status.Lag = time.Since(b.last)
+	}
+	return status
+}
+
+// Close stops streaming from the primary and closes the local store if it is
+// closable.
+func (b *Backup) Close() (err error) {
+	b.stop()
+
+	b.Lock()
+	defer b.Unlock()
+
+	if b.syncing != nil {
+		closeStore(b.syncing)
+		b.syncing = nil
+	}
+
+	if b.promoted != nil {
+		return b.promoted.Close()
+	}
+	return closeStore(b.local)
+}
+
+// String returns the name of the replicated store.
+func (b *Backup) String() string {
+	if p := b.primaryStore(); p != nil {
+		return p.String()
+	}
+	return "backup " + b.store().String()
+}
+
+//===========================================================================
+// Streaming
+//===========================================================================
+
+// Streams the mutations of the primary until the context is canceled,
+// reconnecting with a backoff whenever the stream ends.
+func (b *Backup) run(ctx context.Context) {
+	defer close(b.done)
+
+	backoff := minBackoff
+	for {
+		b.RLock()
+		epoch, from := b.epoch, b.applied+1
+		if !b.synced {
+			from = 0
+		}
+		b.RUnlock()
+
+		applied, err := b.follow(ctx, epoch, from)
+
+		b.Lock()
+		b.connected, b.err = false, err
+		if b.syncing != nil {
+			closeStore(b.syncing)
+			b.syncing = nil
+		}
+		b.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if applied {
+			backoff = minBackoff
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Applies the mutations of a stream from the primary until the stream ends,
+// returning true if any mutations were applied.
+func (b *Backup) follow(ctx context.Context, epoch, from uint64) (applied bool, err error) {
+	var stream Stream
+	if stream, err = b.source.Follow(ctx, epoch, from); err != nil {
+		return false, err
+	}
+
+	for {
+		var m *Mutation
+		if m, err = stream.Recv(); err != nil {
+			return applied, err
+		}
+
+		if err = b.apply(m); err != nil {
+			return applied, err
+		}
+		applied = true
+	}
+}
+
+// Applies a mutation from the stream to the local store, or to the store
+// being filled by a full sync.
+func (b *Backup) apply(m *Mutation) (err error) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.connected = true
+	if m.Head > b.head || m.Op == OpReset {
+		b.head = m.Head
+	}
+
+	switch m.Op {
+	case OpHeartbeat:
+		return nil
+
+	case OpReset:
+		if b.syncing != nil {
+			closeStore(b.syncing)
+		}
+		b.syncing, err = b.factory()
+		return err
+
+	case OpSynced:
+		if b.syncing == nil {
+			return errors.New("primary completed a sync that was not started")
+		}
+
+		closeStore(b.local)
+		b.local, b.syncing = b.syncing, nil
+		b.epoch, b.applied, b.synced, b.last = m.Epoch, m.Seq, true, m.Time
+		return nil
+
+	case OpPut, OpDelete:
+		// Pairs sent by a full sync do not have sequence numbers
+		if m.Seq == 0 {
+			if b.syncing == nil {
+				return errors.New("primary sent a pair outside of a sync")
+			}
+			return applyMutation(b.syncing, m)
+		}
+
+		if !b.synced || m.Seq != b.applied+1 {
+			return fmt.Errorf("expected mutation %d from the primary but received %d", b.applied+1, m.Seq)
+		}
+
+		if err = applyMutation(b.local, m); err != nil {
+			return err
+		}
+		b.applied, b.last = m.Seq, m.Time
+		return nil
+
+	default:
+		return fmt.Errorf("unknown mutation %s from the primary", m.Op)
+	}
+}
+
+// Stops streaming from the primary and waits for the stream to end.
+func (b *Backup) stop() {
+	b.cancel()
+	<-b.done
+}
+
+// Returns the local store.
+func (b *Backup) store() speedmap.Store {
+	b.RLock()
+	defer b.RUnlock()
+	return b.local
+}
+
+// Returns the primary of the local store if the backup has been promoted.
+func (b *Backup) primaryStore() *Primary {
+	b.RLock()
+	defer b.RUnlock()
+	return b.promoted
+}
+
+// Applies a put or delete to the store. Values that expire are only put with
+// their remaining TTL if the store is a speedmap.Expirer, values that have
+// already expired are deleted, and deletes of keys that are not found are
+// ignored since the key may not have been sent by a full sync.
+func applyMutation(store speedmap.Store, m *Mutation) (err error) {
+	if m.Op == OpPut && !m.Expires.IsZero() {
+		if expirer, ok := store.(speedmap.Expirer); ok {
+			if ttl := time.Until(m.Expires); ttl > 0 {
+				return expirer.PutWithTTL(m.Key, m.Value, ttl)
+			}
+			m = &Mutation{Op: OpDelete, Key: m.Key}
+		}
+	}
+
+	if m.Op == OpPut {
+		return store.Put(m.Key, m.Value)
+	}
+
+	if err = store.Delete(m.Key); errors.Is(err, speedmap.ErrNotFound) {
+		err = nil
+	}
+	return err
+}
+
+// Closes the store if it is closable.
+func closeStore(store speedmap.Store) error {
+	if closer, ok := store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
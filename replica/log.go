@@ -0,0 +1,105 @@
+package replica
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// DefaultBacklog is the number of mutations a primary keeps in its log for
+// backups that fall behind or reconnect; backups that fall further behind
+// than the backlog must sync the entire store.
+const DefaultBacklog = 1 << 16
+
+// The log of the mutations of a primary, which keeps the latest mutations in
+// a ring buffer. The log is safe for concurrent use.
+type mutationLog struct {
+	sync.Mutex
+	epoch   uint64        // identifies the log so that backups detect a new primary
+	ring    []Mutation    // the mutation with sequence number seq is at seq % len(ring)
+	head    uint64        // the sequence number of the last mutation, zero if empty
+	changed chan struct{} // closed and replaced when a mutation is appended
+	closed  bool
+}
+
+// Creates an empty log that keeps the latest backlog mutations.
+func newLog(backlog int) *mutationLog {
+	if backlog <= 0 {
+		backlog = DefaultBacklog
+	}
+
+	return &mutationLog{
+		epoch:   rand.Uint64(),
+		ring:    make([]Mutation, backlog),
+		changed: make(chan struct{}),
+	}
+}
+
+// Appends the mutation to the log, assigning its sequence number and time.
+func (l *mutationLog) append(m Mutation) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.closed {
+		return
+	}
+
+	l.head++
+	m.Seq, m.Time = l.head, time.Now()
+	l.ring[m.Seq%uint64(len(l.ring))] = m
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Returns the epoch and head of the log.
+func (l *mutationLog) position() (epoch, head uint64) {
+	l.Lock()
+	defer l.Unlock()
+	return l.epoch, l.head
+}
+
+// Returns at most max mutations starting with the sequence number, each with
+// the current head of the log, and a channel that is closed when another
+// mutation is appended, to wait on if no mutations are returned. Returns an
+// error wrapping speedmap.ErrCompacted if the mutation is no longer in the
+// log, or speedmap.ErrClosed if the log is closed.
+func (l *mutationLog) read(from uint64, max int) (mutations []Mutation, changed <-chan struct{}, err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.closed {
+		return nil, nil, speedmap.ErrClosed
+	}
+
+	if from == 0 || from > l.head+1 || l.head-(from-1) > uint64(len(l.ring)) {
+		return nil, nil, fmt.Errorf("%w: mutation %d is not in the log", speedmap.ErrCompacted, from)
+	}
+
+	n := l.head + 1 - from
+	if n > uint64(max) {
+		n = uint64(max)
+	}
+
+	mutations = make([]Mutation, 0, n)
+	for seq := from; seq < from+n; seq++ {
+		m := l.ring[seq%uint64(len(l.ring))]
+		m.Head = l.head
+		mutations = append(mutations, m)
+	}
+	return mutations, l.changed, nil
+}
+
+// Closes the log, waking any readers.
+func (l *mutationLog) close() {
+	l.Lock()
+	defer l.Unlock()
+
+	if !l.closed {
+		l.closed = true
+		close(l.changed)
+	}
+}
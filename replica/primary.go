@@ -0,0 +1,277 @@
+package replica
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// HeartbeatInterval is how often a primary sends the head of its log to
+// backups that are caught up, so that they can report their lag.
+const HeartbeatInterval = time.Second
+
+// The maximum number of mutations read from the log at a time.
+const maxSyncBatch = 256
+
+// Writes to the same key are serialized by one of the stripes so that the
+// mutations of a key are appended to the log in the order they are applied.
+const numStripes = 64
+
+// Primary is a speedmap.Store that appends every write to its local store to
+// a log that backups stream with Sync. Writes to different keys proceed
+// concurrently, and writes do not wait for backups.
+type Primary struct {
+	local   speedmap.Store
+	log     *mutationLog
+	stripes [numStripes]sync.Mutex
+	backups int32 // the number of backups streaming the log
+}
+
+// NewPrimary creates a primary that replicates the local store, keeping the
+// latest backlog mutations in its log (DefaultBacklog if zero).
+func NewPrimary(local speedmap.Store, backlog int) (store *Primary, err error) {
+	return &Primary{local: local, log: newLog(backlog)}, nil
+}
+
+// Get the value of the key from the local store.
+func (p *Primary) Get(key string) (value []byte, err error) {
+	return p.local.Get(key)
+}
+
+// Put the value of the key in the local store and append it to the log.
+func (p *Primary) Put(key string, value []byte) (err error) {
+	mu := p.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err = p.local.Put(key, value); err != nil {
+		return err
+	}
+
+	p.log.append(Mutation{Op: OpPut, Key: key, Value: value})
+	return nil
+}
+
+// Delete the key from the local store and append the delete to the log.
+func (p *Primary) Delete(key string) (err error) {
+	mu := p.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err = p.local.Delete(key); err != nil {
+		return err
+	}
+
+	p.log.append(Mutation{Op: OpDelete, Key: key})
+	return nil
+}
+
+// GetOrCreate the key in the local store, appending a put to the log if the
+// key was created.
+func (p *Primary) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	mu := p.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if actual, created = p.local.GetOrCreate(key, value); created {
+		p.log.append(Mutation{Op: OpPut, Key: key, Value: value})
+	}
+	return actual, created
+}
+
+// PutWithTTL puts the value of the key in the local store, which must be a
+// speedmap.Expirer, and appends it to the log with the time that it expires.
+func (p *Primary) PutWithTTL(key string, value []byte, ttl time.Duration) (err error) {
+	expirer, ok := p.local.(speedmap.Expirer)
+	if !ok {
+		return fmt.Errorf("the %s store does not support expiration", p.local)
+	}
+
+	mu := p.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err = expirer.PutWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+
+	m := Mutation{Op: OpPut, Key: key, Value: value}
+	if ttl > 0 {
+		m.Expires = time.Now().Add(ttl)
+	}
+	p.log.append(m)
+	return nil
+}
+
+// MultiGet the values of the keys from the local store.
+func (p *Primary) MultiGet(keys []string) (values [][]byte, err error) {
+	return speedmap.MultiGet(p.local, keys)
+}
+
+// MultiPut the pairs in the local store in a single batch and append them to
+// the log.
+func (p *Primary) MultiPut(pairs map[string][]byte) (err error) {
+	// Lock the stripes in order so that batches cannot deadlock
+	locked := make(map[int]bool)
+	for key := range pairs {
+		locked[p.stripeIndex(key)] = true
+	}
+
+	stripes := make([]int, 0, len(locked))
+	for i := range locked {
+		stripes = append(stripes, i)
+	}
+	sort.Ints(stripes)
+
+	for _, i := range stripes {
+		p.stripes[i].Lock()
+		defer p.stripes[i].Unlock()
+	}
+
+	if err = speedmap.MultiPut(p.local, pairs); err != nil {
+		return err
+	}
+
+	for key, value := range pairs {
+		p.log.append(Mutation{Op: OpPut, Key: key, Value: value})
+	}
+	return nil
+}
+
+// Range over the pairs of the local store, which must be speedmap.Iterable.
+func (p *Primary) Range(fn func(key string, value []byte) bool) error {
+	return speedmap.Range(p.local, fn)
+}
+
+// Close the log, ending the streams to the backups, and the local store if it
+// is closable.
+func (p *Primary) Close() error {
+	p.log.close()
+	if closer, ok := p.local.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// String returns the name of the replicated store.
+func (p *Primary) String() string {
+	return "primary " + p.local.String()
+}
+
+// Status returns the head of the log and the number of backups streaming it.
+func (p *Primary) Status() Status {
+	epoch, head := p.log.position()
+	return Status{
+		Role:      "primary",
+		Connected: true,
+		Epoch:     epoch,
+		Head:      head,
+		Applied:   head,
+		Backups:   int(atomic.LoadInt32(&p.backups)),
+	}
+}
+
+// Sync sends the mutations of the log that follow from to a backup, first
+// sending the entire store if from is zero, the epoch is not the epoch of
+// the log, or the log no longer has the mutation. The local store must be
+// speedmap.Iterable to send the entire store, and the TTLs of its values are
+// not sent. Sync returns when the context is done, send returns an error, or
+// the backup falls so far behind that the log no longer has the mutations it
+// needs, in which case the backup must sync the entire store again.
+func (p *Primary) Sync(ctx context.Context, epoch, from uint64, send func(*Mutation) error) (err error) {
+	atomic.AddInt32(&p.backups, 1)
+	defer atomic.AddInt32(&p.backups, -1)
+
+	current, _ := p.log.position()
+	if _, _, err = p.log.read(from, 0); err != nil || epoch != current {
+		if !errors.Is(err, speedmap.ErrClosed) {
+			err = nil
+			from, err = p.sync(send)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		mutations, changed, err := p.log.read(from, maxSyncBatch)
+		if err != nil {
+			return err
+		}
+
+		for i := range mutations {
+			if err = send(&mutations[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(mutations) > 0 {
+			from += uint64(len(mutations))
+			continue
+		}
+
+		select {
+		case <-changed:
+		case <-ticker.C:
+			_, head := p.log.position()
+			if err = send(&Mutation{Op: OpHeartbeat, Head: head, Time: time.Now()}); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Sends every pair in the local store to the backup, returning the sequence
+// number of the first mutation that the backup does not have. Writes made
+// during the sync may be sent as well as appended to the log, which is safe
+// since the backup applies the mutations that follow in order.
+func (p *Primary) sync(send func(*Mutation) error) (next uint64, err error) {
+	epoch, head := p.log.position()
+	if err = send(&Mutation{Op: OpReset, Head: head, Epoch: epoch}); err != nil {
+		return 0, err
+	}
+
+	// Copy the pairs so that the store is not locked while they are sent
+	pairs := make([]Mutation, 0)
+	if err = speedmap.Range(p.local, func(key string, value []byte) bool {
+		pairs = append(pairs, Mutation{Op: OpPut, Key: key, Value: value, Head: head})
+		return true
+	}); err != nil {
+		return 0, err
+	}
+
+	for i := range pairs {
+		if err = send(&pairs[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = send(&Mutation{Seq: head, Op: OpSynced, Head: head, Epoch: epoch, Time: time.Now()}); err != nil {
+		return 0, err
+	}
+	return head + 1, nil
+}
+
+// Returns the stripe that serializes the writes to the key.
+func (p *Primary) stripe(key string) *sync.Mutex {
+	return &p.stripes[p.stripeIndex(key)]
+}
+
+func (p *Primary) stripeIndex(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % numStripes)
+}
@@ -0,0 +1,121 @@
+/*
+Package replica replicates a speedmap store from a primary to any number of
+backups asynchronously. The primary applies writes to its store immediately
+and appends them to an in-memory log of mutations, which backups stream and
+apply to their own store in the same order. Backups serve reads from their
+store, which may be stale by the replication lag, and reject writes with the
+address of the primary so that clients can send writes to it instead. Unlike
+the raft package, writes do not wait for the backups, so a write that has not
+been streamed to a backup is lost if the primary fails; a backup can then be
+promoted to become the new primary.
+*/
+package replica
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Op identifies the kind of a mutation in the stream from the primary.
+type Op uint8
+
+// The operations in a stream. A stream that begins with a full sync sends a
+// reset, then a put (with no sequence number) for every key in the store of
+// the primary, then a synced mutation with the sequence number the sync is
+// consistent with, after which the mutations of the log follow. The primary
+// sends heartbeats with the head of its log when there are no mutations.
+const (
+	OpPut Op = iota
+	OpDelete
+	OpReset
+	OpSynced
+	OpHeartbeat
+)
+
+// String returns a human readable representation of the operation.
+func (o Op) String() string {
+	switch o {
+	case OpPut:
+		return "put"
+	case OpDelete:
+		return "delete"
+	case OpReset:
+		return "reset"
+	case OpSynced:
+		return "synced"
+	case OpHeartbeat:
+		return "heartbeat"
+	default:
+		return fmt.Sprintf("op(%d)", uint8(o))
+	}
+}
+
+// Mutation is a change to the store of the primary, or a message that
+// controls the stream, see Op.
+type Mutation struct {
+	Seq     uint64    // the position of the mutation in the log of the primary
+	Op      Op        // the kind of mutation
+	Key     string    // the key that was put or deleted
+	Value   []byte    // the value that was put
+	Expires time.Time // when the value expires, zero if it does not
+	Time    time.Time // when the primary applied the mutation
+	Head    uint64    // the sequence number of the last mutation in the log of the primary
+	Epoch   uint64    // identifies the log of the primary, sent with synced mutations
+}
+
+// Source streams the mutations of a primary to a backup, e.g. over the network.
+type Source interface {
+	// Follow opens a stream of the mutations of the primary that follow the
+	// sequence number from in the log with the epoch. If from is zero, or the
+	// primary no longer has the mutations, the stream begins with a full sync.
+	Follow(ctx context.Context, epoch, from uint64) (Stream, error)
+}
+
+// Stream of mutations from a primary.
+type Stream interface {
+	Recv() (*Mutation, error)
+}
+
+// ReadOnlyError is returned by writes to a backup, with the address of the
+// primary that writes should be sent to.
+type ReadOnlyError struct {
+	Primary string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("%s: writes are served by the primary %s", speedmap.ErrReadOnly, e.Primary)
+}
+
+// Unwrap returns speedmap.ErrReadOnly.
+func (e *ReadOnlyError) Unwrap() error {
+	return speedmap.ErrReadOnly
+}
+
+// Status of the replication of a primary or a backup.
+type Status struct {
+	Role      string        // either primary or backup
+	Primary   string        // the address of the primary, if a backup
+	Connected bool          // whether a backup is streaming mutations from the primary
+	Epoch     uint64        // the epoch of the log of the primary
+	Head      uint64        // the last mutation in the log of the primary, as last heard by a backup
+	Applied   uint64        // the last mutation applied to the store, the head on a primary
+	Lag       time.Duration // how long ago the primary applied the oldest mutation not applied by a backup
+	Backups   int           // the number of backups streaming from a primary
+	Err       error         // the error that ended the last stream of a backup from the primary
+}
+
+// Replicator is implemented by the Primary and Backup stores.
+type Replicator interface {
+	speedmap.Store
+
+	// Sync streams the log of the store to a backup, see Source.Follow. It
+	// returns when the context is done or send returns an error; a backup that
+	// has not been promoted returns a ReadOnlyError.
+	Sync(ctx context.Context, epoch, from uint64, send func(*Mutation) error) error
+
+	// Status returns the state of the replication.
+	Status() Status
+}
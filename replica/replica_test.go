@@ -0,0 +1,207 @@
+package replica_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/replica"
+	"github.com/bbengfort/speedmap/store"
+)
+
+var errDown = errors.New("primary is down")
+
+// Streams the mutations of a replicator in memory, and can be cut off to
+// simulate a network partition.
+type localSource struct {
+	sync.Mutex
+	primary Replicator
+	down    bool
+	cancels []context.CancelFunc
+}
+
+func (s *localSource) Follow(ctx context.Context, epoch, from uint64) (Stream, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.down {
+		return nil, errDown
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.cancels = append(s.cancels, cancel)
+
+	stream := &localStream{mutations: make(chan *Mutation, 64)}
+	go func() {
+		stream.err = s.primary.Sync(ctx, epoch, from, func(m *Mutation) error {
+			copy := *m
+			select {
+			case stream.mutations <- &copy:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(stream.mutations)
+	}()
+	return stream, nil
+}
+
+// Ends the open streams and fails new streams until restored.
+func (s *localSource) cut(down bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.down = down
+	for _, cancel := range s.cancels {
+		cancel()
+	}
+	s.cancels = nil
+}
+
+type localStream struct {
+	mutations chan *Mutation
+	err       error
+}
+
+func (s *localStream) Recv() (*Mutation, error) {
+	if m, ok := <-s.mutations; ok {
+		return m, nil
+	}
+
+	if s.err == nil {
+		return nil, io.EOF
+	}
+	return nil, s.err
+}
+
+func newBasic() (speedmap.Store, error) {
+	return store.NewBasic()
+}
+
+var _ = Describe("Replica", func() {
+
+	var (
+		primary *Primary
+		source  *localSource
+		backup  *Backup
+	)
+
+	BeforeEach(func() {
+		local, err := store.NewBasic()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		primary, err = NewPrimary(local, 8)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		// Keys written before the backup connects are sent by a full sync
+		Ω(primary.Put("before", []byte("sync"))).Should(Succeed())
+
+		source = &localSource{primary: primary}
+		backup, err = NewBackup("127.0.0.1:3264", source, newBasic, 0)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(backup.Close()).Should(Succeed())
+		Ω(primary.Close()).Should(Succeed())
+	})
+
+	// Waits for the backup to apply every mutation of the primary.
+	caughtUp := func(backup *Backup, primary Replicator) {
+		Eventually(func() uint64 {
+			return backup.Status().Applied
+		}, 2*time.Second, 5*time.Millisecond).Should(Equal(primary.Status().Head))
+	}
+
+	It("should replicate the writes of the primary", func() {
+		Eventually(func() ([]byte, error) { return backup.Get("before") }, time.Second).Should(Equal([]byte("sync")))
+
+		Ω(primary.Put("foo", []byte("bar"))).Should(Succeed())
+		Ω(primary.Put("baz", []byte("qux"))).Should(Succeed())
+		Ω(primary.Delete("baz")).Should(Succeed())
+		Ω(primary.MultiPut(map[string][]byte{"a": []byte("1"), "b": []byte("2")})).Should(Succeed())
+
+		actual, created := primary.GetOrCreate("new", []byte("zap"))
+		Ω(created).Should(BeTrue())
+		Ω(actual).Should(Equal([]byte("zap")))
+
+		caughtUp(backup, primary)
+		vals, err := backup.MultiGet([]string{"foo", "baz", "a", "b", "new"})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(vals).Should(Equal([][]byte{[]byte("bar"), nil, []byte("1"), []byte("2"), []byte("zap")}))
+
+		status := backup.Status()
+		Ω(status.Role).Should(Equal("backup"))
+		Ω(status.Connected).Should(BeTrue())
+		Ω(status.Lag).Should(BeZero())
+		Ω(primary.Status().Backups).Should(Equal(1))
+	})
+
+	It("should reject writes to the backup with the primary", func() {
+		err := backup.Put("foo", []byte("bar"))
+		Ω(errors.Is(err, speedmap.ErrReadOnly)).Should(BeTrue())
+
+		var roe *ReadOnlyError
+		Ω(errors.As(err, &roe)).Should(BeTrue())
+		Ω(roe.Primary).Should(Equal("127.0.0.1:3264"))
+
+		Ω(errors.Is(backup.Delete("foo"), speedmap.ErrReadOnly)).Should(BeTrue())
+		Ω(errors.Is(backup.MultiPut(map[string][]byte{"a": nil}), speedmap.ErrReadOnly)).Should(BeTrue())
+
+		_, created := backup.GetOrCreate("foo", []byte("bar"))
+		Ω(created).Should(BeFalse())
+	})
+
+	It("should resume the stream where it was cut off", func() {
+		caughtUp(backup, primary)
+		source.cut(true)
+		Ω(primary.Put("foo", []byte("bar"))).Should(Succeed())
+		Eventually(func() bool { return backup.Status().Connected }).Should(BeFalse())
+
+		source.cut(false)
+		caughtUp(backup, primary)
+		Ω(backup.Get("foo")).Should(Equal([]byte("bar")))
+	})
+
+	It("should sync the entire store when the backup falls behind the log", func() {
+		caughtUp(backup, primary)
+		source.cut(true)
+
+		// More writes than the backlog of the log
+		for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+			Ω(primary.Put(key, []byte(key))).Should(Succeed())
+		}
+		Ω(primary.Delete("before")).Should(Succeed())
+
+		source.cut(false)
+		caughtUp(backup, primary)
+		Ω(backup.Get("j")).Should(Equal([]byte("j")))
+
+		_, err := backup.Get("before")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+	})
+
+	It("should promote the backup to a primary", func() {
+		caughtUp(backup, primary)
+		Ω(backup.Promote()).Should(Succeed())
+		Ω(backup.Put("foo", []byte("bar"))).Should(Succeed())
+		Ω(backup.Status().Role).Should(Equal("primary"))
+
+		// The promoted backup no longer follows the old primary
+		Ω(primary.Put("foo", []byte("old"))).Should(Succeed())
+		Consistently(func() ([]byte, error) { return backup.Get("foo") }, 50*time.Millisecond).Should(Equal([]byte("bar")))
+
+		other, err := NewBackup("127.0.0.1:3265", &localSource{primary: backup}, newBasic, 0)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer other.Close()
+
+		caughtUp(other, backup)
+		Ω(other.Get("foo")).Should(Equal([]byte("bar")))
+		Ω(other.Get("before")).Should(Equal([]byte("sync")))
+	})
+})
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Operation is a kind of request that an ACL allows identities to make.
+// Operations are flags so that a rule can allow several of them at once.
+type Operation uint8
+
+// The operations that are controlled by an ACL. Admin requests operate on the
+// whole store (e.g. a snapshot contains every key), so an identity that is
+// allowed to make them is not limited to its key prefixes.
+const (
+	OpGet Operation = 1 << iota
+	OpPut
+	OpDelete
+	OpWatch
+	OpAdmin
+	OpAll = OpGet | OpPut | OpDelete | OpWatch | OpAdmin
+)
+
+// Names of the operations in ACL files.
+var operationNames = map[string]Operation{
+	"get":   OpGet,
+	"put":   OpPut,
+	"del":   OpDelete,
+	"watch": OpWatch,
+	"admin": OpAdmin,
+	"*":     OpAll,
+}
+
+// String returns the names of the operations separated by commas.
+func (o Operation) String() string {
+	if o == OpAll {
+		return "*"
+	}
+
+	names := make([]string, 0, len(operationNames))
+	for name, op := range operationNames {
+		if op != OpAll && o&op != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// ACL maps client identities to the operations they are allowed to make and
+// the prefixes of the keys they are allowed to make them on, so that clients
+// sharing a server can be isolated from each other's keys. Identities that do
+// not have a rule are denied unless there is a rule for the "*" identity.
+type ACL struct {
+	rules map[string]aclRule
+}
+
+// The operations and key prefixes allowed to an identity.
+type aclRule struct {
+	ops      Operation
+	prefixes []string
+}
+
+// LoadACL reads an ACL from a file in which each line is an identity, the
+// operations it is allowed (get, put, del, watch, admin, or * for all of
+// them) separated by commas, and the key prefixes it is allowed to access (or
+// * for all keys) separated by commas. Blank lines and lines starting with #
+// are ignored. For example:
+//
+//	# identity  operations    prefixes
+//	team-a      get,put,del   a/,shared/
+//	team-b      get,watch     b/,shared/
+//	ops         *             *
+func LoadACL(path string) (acl *ACL, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	acl = &ACL{rules: make(map[string]aclRule)}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected an identity, operations and prefixes", path, line)
+		}
+
+		var rule aclRule
+		for _, name := range strings.Split(fields[1], ",") {
+			op, ok := operationNames[name]
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: unknown operation %q", path, line, name)
+			}
+			rule.ops |= op
+		}
+
+		for _, prefix := range strings.Split(fields[2], ",") {
+			if prefix == "*" {
+				prefix = ""
+			}
+			rule.prefixes = append(rule.prefixes, prefix)
+		}
+
+		if _, ok := acl.rules[fields[0]]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate rule for %s", path, line, fields[0])
+		}
+		acl.rules[fields[0]] = rule
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+// Allowed returns true if the identity may perform the operation on the key.
+// For a watch of a prefix, the key is the prefix being watched. The key is
+// not checked for admin operations.
+func (a *ACL) Allowed(identity string, op Operation, key string) bool {
+	rule, ok := a.rules[identity]
+	if !ok {
+		if rule, ok = a.rules["*"]; !ok {
+			return false
+		}
+	}
+
+	if rule.ops&op != op {
+		return false
+	}
+
+	if op == OpAdmin {
+		return true
+	}
+
+	for _, prefix := range rule.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
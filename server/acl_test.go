@@ -0,0 +1,98 @@
+package server_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bbengfort/speedmap/server"
+)
+
+// Writes the contents to a file named name in the directory, returning its path.
+func writeFile(dir, name, contents string) string {
+	path := filepath.Join(dir, name)
+	Ω(ioutil.WriteFile(path, []byte(contents), 0600)).Should(Succeed())
+	return path
+}
+
+var _ = Describe("ACL", func() {
+
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "speedmap-acl")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	It("should deny identities without a rule by default", func() {
+		acl, err := LoadACL(writeFile(dir, "acl.txt", "team-a get,put a/\n"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(acl.Allowed("team-a", OpGet, "a/foo")).Should(BeTrue())
+		Ω(acl.Allowed("team-b", OpGet, "a/foo")).Should(BeFalse())
+		Ω(acl.Allowed("", OpGet, "a/foo")).Should(BeFalse())
+		Ω(acl.Allowed("team-a", OpAdmin, "")).Should(BeFalse())
+	})
+
+	It("should fall back to the rule of the wildcard identity", func() {
+		acl, err := LoadACL(writeFile(dir, "acl.txt", "team-a * a/\n* get shared/\n"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(acl.Allowed("team-b", OpGet, "shared/foo")).Should(BeTrue())
+		Ω(acl.Allowed("team-b", OpPut, "shared/foo")).Should(BeFalse())
+		Ω(acl.Allowed("team-b", OpGet, "a/foo")).Should(BeFalse())
+
+		// An identity with a rule does not inherit the wildcard rule
+		Ω(acl.Allowed("team-a", OpGet, "shared/foo")).Should(BeFalse())
+	})
+
+	It("should match operations and key prefixes", func() {
+		acl, err := LoadACL(writeFile(dir, "acl.txt", `
+			# identity  operations    prefixes
+			team-a      get,put,del   a/,shared/
+			team-b      get,watch     b/,shared/
+			ops         *             *
+		`))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(acl.Allowed("team-a", OpPut, "a/foo")).Should(BeTrue())
+		Ω(acl.Allowed("team-a", OpDelete, "shared/foo")).Should(BeTrue())
+		Ω(acl.Allowed("team-a", OpGet, "b/foo")).Should(BeFalse())
+		Ω(acl.Allowed("team-a", OpGet, "a")).Should(BeFalse())
+		Ω(acl.Allowed("team-a", OpWatch, "a/")).Should(BeFalse())
+
+		Ω(acl.Allowed("team-b", OpWatch, "b/")).Should(BeTrue())
+		Ω(acl.Allowed("team-b", OpPut, "b/foo")).Should(BeFalse())
+
+		Ω(acl.Allowed("ops", OpDelete, "anything")).Should(BeTrue())
+		Ω(acl.Allowed("ops", OpAdmin, "")).Should(BeTrue())
+	})
+
+	It("should reject invalid files", func() {
+		_, err := LoadACL(filepath.Join(dir, "missing.txt"))
+		Ω(err).Should(HaveOccurred())
+
+		_, err = LoadACL(writeFile(dir, "fields.txt", "team-a get\n"))
+		Ω(err).Should(MatchError(ContainSubstring("expected an identity")))
+
+		_, err = LoadACL(writeFile(dir, "op.txt", "team-a get,scan a/\n"))
+		Ω(err).Should(MatchError(ContainSubstring("unknown operation")))
+
+		_, err = LoadACL(writeFile(dir, "dup.txt", "team-a get a/\nteam-a put a/\n"))
+		Ω(err).Should(MatchError(ContainSubstring("duplicate rule")))
+	})
+
+	It("should name its operations", func() {
+		Ω(OpAll.String()).Should(Equal("*"))
+		Ω((OpGet | OpDelete).String()).Should(Equal("del,get"))
+	})
+
+})
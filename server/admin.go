@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+	"github.com/bbengfort/speedmap/server/pb"
+	"github.com/bbengfort/speedmap/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultSnapshotPath is where the admin snapshot RPC writes the store contents
+// unless another path is set with SetSnapshotPath.
+const DefaultSnapshotPath = "speedmap.snapshot"
+
+// SetSnapshotPath sets the path of the file that the admin snapshot RPC writes.
+func (s *Server) SetSnapshotPath(path string) {
+	s.smu.Lock()
+	s.snapshot = path
+	s.smu.Unlock()
+}
+
+// Snapshot handles an admin request to write the contents of the store to the
+// snapshot path of the server. The snapshot is written to a temporary file and
+// then renamed so that a previous snapshot is only replaced by a complete one.
+// The store must be Iterable.
+func (s *Server) Snapshot(ctx context.Context, in *pb.SnapshotRequest) (*pb.SnapshotReply, error) {
+	if _, ok := speedmap.Unwrap(s.kv).(speedmap.Iterable); !ok {
+		return nil, status.Errorf(codes.Unimplemented, "the %s store cannot be snapshot", s.kv)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, statusError(err)
+	}
+
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	keys, size, err := writeSnapshot(s.kv, s.snapshot, in.Compress)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &pb.SnapshotReply{Success: true, Error: "", Path: s.snapshot, Keys: keys, Size: size}, nil
+}
+
+// Stats handles an admin request for the requests served to each client, the
+// number of keys in the store and the memory used by the server. Keys are
+// counted with Range unless the store is Bounded, so the count of a store that
+// is modified concurrently is approximate.
+func (s *Server) Stats(ctx context.Context, in *pb.StatsRequest) (*pb.StatsReply, error) {
+	requests := s.Requests()
+	identities := make([]string, 0, len(requests))
+	for identity := range requests {
+		identities = append(identities, identity)
+	}
+	sort.Strings(identities)
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	rep := &pb.StatsReply{
+		Success:     true,
+		Store:       s.kv.String(),
+		Keys:        s.keys(),
+		Requests:    make([]*pb.ClientRequests, 0, len(identities)),
+		Uptime:      int64(time.Since(s.started)),
+		HeapAlloc:   mem.HeapAlloc,
+		HeapObjects: mem.HeapObjects,
+		Sys:         mem.Sys,
+		NumGc:       mem.NumGC,
+	}
+
+	for _, identity := range identities {
+		rep.Requests = append(rep.Requests, &pb.ClientRequests{Identity: identity, Requests: requests[identity]})
+	}
+	return rep, nil
+}
+
+// Flush handles an admin request to delete every key in the store, which must
+// be Iterable. Keys that are written while the store is flushed may remain.
+func (s *Server) Flush(ctx context.Context, in *pb.FlushRequest) (*pb.FlushReply, error) {
+	if _, ok := speedmap.Unwrap(s.kv).(speedmap.Iterable); !ok {
+		return nil, status.Errorf(codes.Unimplemented, "the %s store cannot be flushed", s.kv)
+	}
+
+	// Collect the keys first since the store may be locked during Range
+	keys := make([]string, 0)
+	if err := speedmap.Range(s.kv, func(key string, value []byte) bool {
+		keys = append(keys, key)
+		return true
+	}); err != nil {
+		return nil, statusError(err)
+	}
+
+	for _, key := range keys {
+		if err := s.kv.DeleteCtx(ctx, key); err != nil {
+			return nil, statusError(err)
+		}
+	}
+	return &pb.FlushReply{Success: true, Error: "", Keys: uint64(len(keys))}, nil
+}
+
+// SwapStore handles an admin request to migrate the pairs of the store to a new
+// store of the named implementation (see store.Stores) and serve it instead,
+// without interrupting requests. The server must serve a store.Swappable.
+func (s *Server) SwapStore(ctx context.Context, in *pb.SwapRequest) (*pb.SwapReply, error) {
+	swappable, ok := speedmap.Unwrap(s.kv).(*store.Swappable)
+	if !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "the %s store cannot be swapped", s.kv)
+	}
+
+	factory, err := store.GetStore(in.Store)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var next speedmap.Store
+	if next, err = factory(); err != nil {
+		return nil, statusError(err)
+	}
+
+	previous := swappable.Current().String()
+	start := time.Now()
+
+	var keys uint64
+	if keys, err = swappable.Swap(next); err != nil {
+		if errors.Is(err, store.ErrNotSwappable) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, statusError(err)
+	}
+
+	return &pb.SwapReply{
+		Success:  true,
+		Previous: previous,
+		Store:    next.String(),
+		Keys:     keys,
+		Duration: int64(time.Since(start)),
+	}, nil
+}
+
+// Returns the number of keys in the store, or -1 if they cannot be counted.
+func (s *Server) keys() int64 {
+	if bounded, ok := speedmap.Unwrap(s.kv).(speedmap.Bounded); ok {
+		return int64(bounded.Stats().Entries)
+	}
+
+	var keys int64
+	if err := speedmap.Range(s.kv, func(key string, value []byte) bool {
+		keys++
+		return true
+	}); err != nil {
+		return -1
+	}
+	return keys
+}
+
+// Writes a snapshot of the store to a temporary file in the same directory as
+// the path, syncs it, and renames it to the path, returning the number of keys
+// and the size of the snapshot in bytes.
+func writeSnapshot(store speedmap.Store, path string, compress bool) (keys uint64, size int64, err error) {
+	var tmp *os.File
+	if tmp, err = ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp"); err != nil {
+		return 0, 0, fmt.Errorf("could not create snapshot: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if keys, err = speedmap.Snapshot(store, tmp, compress); err != nil {
+		return 0, 0, err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return 0, 0, err
+	}
+
+	var info os.FileInfo
+	if info, err = tmp.Stat(); err != nil {
+		return 0, 0, err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return 0, 0, err
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return 0, 0, err
+	}
+	return keys, info.Size(), nil
+}
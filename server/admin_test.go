@@ -0,0 +1,74 @@
+package server_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/bbengfort/speedmap/server"
+	"github.com/bbengfort/speedmap/store"
+)
+
+var _ = Describe("Admin", func() {
+
+	var (
+		srv    *Server
+		client *Client
+	)
+
+	BeforeEach(func() {
+		basic, err := store.NewBasic()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		swappable, err := store.NewSwappable(basic)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		srv = New(swappable)
+		client = NewClient("admin")
+		Ω(client.Connect(listen(srv))).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Ω(client.Close()).Should(Succeed())
+		shutdown(srv)
+	})
+
+	It("should swap the store to another implementation", func() {
+		_, err := client.Put("foo", []byte("bar"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		rep, err := client.SwapStore("shard")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(rep.Previous).Should(Equal("basic"))
+		Ω(rep.Store).Should(Equal("shard"))
+		Ω(rep.Keys).Should(BeEquivalentTo(1))
+
+		get, err := client.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(get.Pair.Value).Should(Equal([]byte("bar")))
+	})
+
+	It("should refuse to swap to a store whose capabilities would be hidden", func() {
+		_, err := client.Put("foo", []byte("bar"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		for _, name := range []string{"mvcc", "versioned"} {
+			_, err = client.SwapStore(name)
+			Ω(status.Code(err)).Should(Equal(codes.InvalidArgument))
+		}
+
+		// The current store keeps serving requests
+		_, err = client.Put("baz", []byte("qux"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		get, err := client.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(get.Pair.Value).Should(Equal([]byte("bar")))
+
+		stats, err := client.Stats()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(stats.Store).Should(Equal("basic swappable"))
+	})
+
+})
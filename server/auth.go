@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// The metadata key that clients send their token in, as "Bearer <token>".
+const authorizationHeader = "authorization"
+
+// Authenticator authenticates the client that sent a request from the request
+// context, returning the identity of the client. If the client cannot be
+// authenticated, the error should be an Unauthenticated status error. When the
+// server has an authenticator, the authenticated identity is used in place of
+// the identity in the request.
+type Authenticator interface {
+	Authenticate(ctx context.Context) (identity string, err error)
+}
+
+// TokenAuth authenticates clients by a static token sent in the metadata of
+// each request, mapping each token to the identity of a client.
+type TokenAuth struct {
+	tokens map[[sha256.Size]byte]string // identities by the hash of their token
+}
+
+// NewTokenAuth loads the tokens from a file in which each line is a token
+// followed by the identity of the client it authenticates, separated by
+// whitespace. Blank lines and lines starting with # are ignored.
+func NewTokenAuth(path string) (auth *TokenAuth, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	auth = &TokenAuth{tokens: make(map[[sha256.Size]byte]string)}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a token and an identity", path, line)
+		}
+		auth.tokens[sha256.Sum256([]byte(fields[0]))] = fields[1]
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// Authenticate returns the identity of the token in the request metadata. The
+// tokens are compared by their hash so that the time taken by the lookup does
+// not reveal the tokens.
+func (a *TokenAuth) Authenticate(ctx context.Context) (identity string, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "no token in request")
+	}
+
+	identity, ok := a.lookup(strings.TrimPrefix(values[0], "Bearer "))
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return identity, nil
+}
+
+// Returns the identity of the token, if it is valid.
+func (a *TokenAuth) lookup(token string) (identity string, ok bool) {
+	identity, ok = a.tokens[sha256.Sum256([]byte(token))]
+	return identity, ok
+}
+
+// TLSAuth authenticates clients by the subject common name of the certificate
+// they presented over mutual TLS. The server must verify client certificates,
+// see ServerTLS.
+type TLSAuth struct{}
+
+// Authenticate returns the common name of the verified client certificate.
+func (TLSAuth) Authenticate(ctx context.Context) (identity string, err error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "no peer in request")
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", status.Error(codes.Unauthenticated, "no verified client certificate")
+	}
+
+	if identity = info.State.VerifiedChains[0][0].Subject.CommonName; identity == "" {
+		return "", status.Error(codes.Unauthenticated, "client certificate has no common name")
+	}
+	return identity, nil
+}
+
+// Sends a token with each request, see Client.SetToken.
+type tokenCredentials struct {
+	token string
+}
+
+// GetRequestMetadata adds the token to the metadata of the request.
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{authorizationHeader: "Bearer " + t.token}, nil
+}
+
+// RequireTransportSecurity returns true so that tokens are only sent over TLS.
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
@@ -0,0 +1,70 @@
+package server_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bbengfort/speedmap/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Returns an incoming request context with the metadata key/value pairs.
+func incoming(kv ...string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+}
+
+var _ = Describe("Authentication", func() {
+
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "speedmap-auth")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	It("should authenticate tokens", func() {
+		auth, err := NewTokenAuth(writeFile(dir, "tokens.txt", "# token identity\nsecret-a team-a\n\nsecret-b team-b\n"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		identity, err := auth.Authenticate(incoming("authorization", "Bearer secret-a"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(identity).Should(Equal("team-a"))
+
+		identity, err = auth.Authenticate(incoming("authorization", "Bearer secret-b"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(identity).Should(Equal("team-b"))
+	})
+
+	It("should reject missing and invalid tokens", func() {
+		auth, err := NewTokenAuth(writeFile(dir, "tokens.txt", "secret-a team-a\n"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = auth.Authenticate(context.Background())
+		Ω(status.Code(err)).Should(Equal(codes.Unauthenticated))
+
+		_, err = auth.Authenticate(incoming("authorization", "Bearer team-a"))
+		Ω(status.Code(err)).Should(Equal(codes.Unauthenticated))
+	})
+
+	It("should reject invalid token files", func() {
+		_, err := NewTokenAuth(writeFile(dir, "tokens.txt", "secret-a\n"))
+		Ω(err).Should(MatchError(ContainSubstring("expected a token and an identity")))
+	})
+
+	It("should reject requests without a client certificate", func() {
+		_, err := TLSAuth{}.Authenticate(context.Background())
+		Ω(status.Code(err)).Should(Equal(codes.Unauthenticated))
+	})
+
+})
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -10,16 +11,24 @@ import (
 
 	"github.com/bbengfort/speedmap/server/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // DefaultTimeout for making client requests to the speedmap server
 const DefaultTimeout = 30 * time.Second
 
 // Client is a helper struct to connect to the speedmap server and make requests.
+// If the server is a member of a cluster, the client follows the redirects of
+// requests for keys owned by other members and caches the ring of the cluster
+// so that later requests are sent directly to the owner of each key.
 type Client struct {
 	identity string
 	conn     *grpc.ClientConn
 	client   pb.KVClient
+	admin    pb.AdminClient
+	tls      *tls.Config
+	token    string
+	routes   *routes // routes requests to the members of a cluster
 }
 
 // NewClient creates a new speedmap server client and returns it
@@ -42,18 +51,58 @@ func NewClient(identity string) *Client {
 // Connection Handling
 //===========================================================================
 
+// SetTLS configures the client to connect to the server over TLS, see
+// ClientTLS. It must be called before Connect.
+func (c *Client) SetTLS(conf *tls.Config) {
+	c.tls = conf
+}
+
+// SetToken configures the client to authenticate itself to the server with the
+// token. Tokens are only sent over TLS, so SetTLS must also be called. It must
+// be called before Connect.
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// Identity returns the unique identity the client sends with its requests.
+func (c *Client) Identity() string {
+	return c.identity
+}
+
+// WithIdentity returns a client with the specified identity that makes its
+// requests on the connection of this client, so that many clients can share
+// a single connection. Only this client should be closed, which closes the
+// connection of the clients returned.
+func (c *Client) WithIdentity(identity string) *Client {
+	clone := *c
+	clone.identity = identity
+	return &clone
+}
+
 // Connect to the speedmap server and prepare to make requests
 func (c *Client) Connect(addr string) (err error) {
 	// Close the connection if one is already open.
 	c.Close()
 
-	// Connect to the specified address
-	if c.conn, err = grpc.Dial(addr, grpc.WithInsecure(), grpc.WithTimeout(DefaultTimeout)); err != nil {
+	// Connect to the specified address, over TLS if configured
+	creds := grpc.WithInsecure()
+	if c.tls != nil {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(c.tls))
+	}
+
+	opts := []grpc.DialOption{creds, grpc.WithTimeout(DefaultTimeout)}
+	if c.token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials{token: c.token}))
+	}
+
+	if c.conn, err = grpc.Dial(addr, opts...); err != nil {
 		return err
 	}
 
 	// Create the grpc client
 	c.client = pb.NewKVClient(c.conn)
+	c.admin = pb.NewAdminClient(c.conn)
+	c.routes = &routes{addr: addr, origin: c.client, opts: opts, peers: make(map[string]*memberConn)}
 	return nil
 }
 
@@ -63,8 +112,16 @@ func (c *Client) Close() (err error) {
 	defer func() {
 		c.conn = nil
 		c.client = nil
+		c.admin = nil
+		c.routes = nil
 	}()
 
+	if c.routes != nil {
+		if err = c.routes.close(); err != nil {
+			return err
+		}
+	}
+
 	if c.conn != nil {
 		if err = c.conn.Close(); err != nil {
 			return err
@@ -91,11 +148,9 @@ func (c *Client) Get(key string) (*pb.ClientReply, error) {
 		Key:      key,
 	}
 
-	// Create the context
-	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
-	defer cancel()
-
-	return c.client.Get(ctx, req)
+	return c.do(key, false, func(ctx context.Context, kv pb.KVClient) (*pb.ClientReply, error) {
+		return kv.Get(ctx, req)
+	})
 }
 
 // Put performs a request to the speedmap server for the specified key and value.
@@ -112,11 +167,32 @@ func (c *Client) Put(key string, value []byte) (*pb.ClientReply, error) {
 		Value:    value,
 	}
 
-	// Create the context
-	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
-	defer cancel()
+	return c.do(key, true, func(ctx context.Context, kv pb.KVClient) (*pb.ClientReply, error) {
+		return kv.Put(ctx, req)
+	})
+}
+
+// PutWithTTL performs a request to the speedmap server for the specified key
+// and value, which expires after the ttl. The ttl is rounded up to the next
+// millisecond so that a ttl of less than a millisecond is not sent as zero,
+// which the server treats as never expiring.
+func (c *Client) PutWithTTL(key string, value []byte, ttl time.Duration) (*pb.ClientReply, error) {
+	// Ensure that we're connected
+	if c.client == nil {
+		return nil, errors.New("not connected to speedmap server")
+	}
+
+	// Create the request
+	req := &pb.PutRequest{
+		Identity: c.identity,
+		Key:      key,
+		Value:    value,
+		Ttl:      int64((ttl + time.Millisecond - 1) / time.Millisecond),
+	}
 
-	return c.client.Put(ctx, req)
+	return c.do(key, true, func(ctx context.Context, kv pb.KVClient) (*pb.ClientReply, error) {
+		return kv.Put(ctx, req)
+	})
 }
 
 // Del performs a request to the speedmap server for the specified key.
@@ -133,9 +209,191 @@ func (c *Client) Del(key string, force bool) (*pb.ClientReply, error) {
 		Force:    force,
 	}
 
+	return c.do(key, true, func(ctx context.Context, kv pb.KVClient) (*pb.ClientReply, error) {
+		return kv.Del(ctx, req)
+	})
+}
+
+// BatchGet performs a single request to the speedmap server for all of the
+// specified keys, the reply contains the pairs in the same order as the keys.
+// If the server is a member of a cluster, a request is made to each member
+// that owns some of the keys.
+func (c *Client) BatchGet(keys []string) (*pb.BatchReply, error) {
+	// Ensure that we're connected
+	if c.client == nil {
+		return nil, errors.New("not connected to speedmap server")
+	}
+
+	// Create the request
+	req := &pb.BatchGetRequest{
+		Identity: c.identity,
+		Keys:     keys,
+	}
+
+	return c.doBatch(keys, false, func(ctx context.Context, kv pb.KVClient, idx []int) (*pb.BatchReply, error) {
+		if len(idx) == len(keys) {
+			return kv.BatchGet(ctx, req)
+		}
+
+		part := &pb.BatchGetRequest{Identity: req.Identity, Keys: make([]string, 0, len(idx))}
+		for _, i := range idx {
+			part.Keys = append(part.Keys, keys[i])
+		}
+		return kv.BatchGet(ctx, part)
+	})
+}
+
+// BatchPut performs a single request to the speedmap server to put all of the
+// specified key/value pairs, or a request to each member that owns some of
+// the keys if the server is a member of a cluster.
+func (c *Client) BatchPut(pairs map[string][]byte) (*pb.BatchReply, error) {
+	// Ensure that we're connected
+	if c.client == nil {
+		return nil, errors.New("not connected to speedmap server")
+	}
+
+	// Create the request
+	req := &pb.BatchPutRequest{
+		Identity: c.identity,
+		Pairs:    make([]*pb.KVPair, 0, len(pairs)),
+	}
+
+	keys := make([]string, 0, len(pairs))
+	for key, value := range pairs {
+		req.Pairs = append(req.Pairs, &pb.KVPair{Key: key, Value: value})
+		keys = append(keys, key)
+	}
+
+	return c.doBatch(keys, true, func(ctx context.Context, kv pb.KVClient, idx []int) (*pb.BatchReply, error) {
+		if len(idx) == len(keys) {
+			return kv.BatchPut(ctx, req)
+		}
+
+		part := &pb.BatchPutRequest{Identity: req.Identity, Pairs: make([]*pb.KVPair, 0, len(idx))}
+		for _, i := range idx {
+			part.Pairs = append(part.Pairs, req.Pairs[i])
+		}
+		return kv.BatchPut(ctx, part)
+	})
+}
+
+// Watch opens a stream from the speedmap server that receives changes to the
+// specified key, or to all keys with the key as a prefix if prefix is true.
+// The stream remains open until the context is canceled or the server ends it.
+func (c *Client) Watch(ctx context.Context, key string, prefix bool) (pb.KV_WatchClient, error) {
+	// Ensure that we're connected
+	if c.client == nil {
+		return nil, errors.New("not connected to speedmap server")
+	}
+
+	// Create the request
+	req := &pb.WatchRequest{
+		Identity: c.identity,
+		Key:      key,
+		Prefix:   prefix,
+	}
+
+	return c.client.Watch(ctx, req)
+}
+
+// Snapshot requests that the speedmap server write the contents of its store
+// to its snapshot file, optionally compressing it.
+func (c *Client) Snapshot(compress bool) (*pb.SnapshotReply, error) {
+	// Ensure that we're connected
+	if c.admin == nil {
+		return nil, errors.New("not connected to speedmap server")
+	}
+
+	// Create the request
+	req := &pb.SnapshotRequest{
+		Identity: c.identity,
+		Compress: compress,
+	}
+
+	// Create the context
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	return c.admin.Snapshot(ctx, req)
+}
+
+// Stats requests the number of requests the speedmap server has served to each
+// client, the number of keys in its store and its memory usage.
+func (c *Client) Stats() (*pb.StatsReply, error) {
+	// Ensure that we're connected
+	if c.admin == nil {
+		return nil, errors.New("not connected to speedmap server")
+	}
+
+	// Create the context
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	return c.admin.Stats(ctx, &pb.StatsRequest{Identity: c.identity})
+}
+
+// Flush requests that the speedmap server delete every key in its store.
+func (c *Client) Flush() (*pb.FlushReply, error) {
+	// Ensure that we're connected
+	if c.admin == nil {
+		return nil, errors.New("not connected to speedmap server")
+	}
+
+	// Create the context
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	return c.admin.Flush(ctx, &pb.FlushRequest{Identity: c.identity})
+}
+
+// SwapStore requests that the speedmap server migrate its store to a new store
+// of the named implementation (e.g. basic, shard or sync) while it continues
+// to serve requests. The migration is not canceled if the request times out.
+func (c *Client) SwapStore(name string) (*pb.SwapReply, error) {
+	// Ensure that we're connected
+	if c.admin == nil {
+		return nil, errors.New("not connected to speedmap server")
+	}
+
+	// Create the request
+	req := &pb.SwapRequest{
+		Identity: c.identity,
+		Store:    name,
+	}
+
+	// Create the context
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	return c.admin.SwapStore(ctx, req)
+}
+
+// Replication requests the status of the replication of the speedmap server's
+// store, including the lag of a backup behind its primary.
+func (c *Client) Replication() (*pb.ReplicationReply, error) {
+	// Ensure that we're connected
+	if c.admin == nil {
+		return nil, errors.New("not connected to speedmap server")
+	}
+
+	// Create the context
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	return c.admin.Replication(ctx, &pb.ReplicationRequest{Identity: c.identity})
+}
+
+// Promote requests that a backup server become the primary of its store, so
+// that it accepts writes; writes it has not received from its primary are lost.
+func (c *Client) Promote() (*pb.ReplicationReply, error) {
+	// Ensure that we're connected
+	if c.admin == nil {
+		return nil, errors.New("not connected to speedmap server")
+	}
+
 	// Create the context
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
 	defer cancel()
 
-	return c.client.Del(ctx, req)
+	return c.admin.Promote(ctx, &pb.PromoteRequest{Identity: c.identity})
 }
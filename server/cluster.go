@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bbengfort/speedmap/cluster"
+	"github.com/bbengfort/speedmap/raft"
+	"github.com/bbengfort/speedmap/replica"
+	"github.com/bbengfort/speedmap/server/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SetCluster configures the server as the member of the cluster with the name
+// self, so that it only serves the keys that it owns on the ring. Get, put and
+// del requests (including those on a pipelined stream) and batches for keys
+// that are owned by another member are not handled; instead the reply is not
+// successful and its redirect is the name of the owner of the key, which
+// clients use to fetch the ring with the Cluster RPC and route the request to
+// the owner. Watches, snapshots and the HTTP, RESP and memcached listeners
+// are not redirected and only see the keys stored by this member. It must be
+// called before Listen.
+func (s *Server) SetCluster(ring *cluster.Ring, self string) error {
+	if s.node != nil {
+		return errors.New("server is already a member of a replicated cluster")
+	}
+
+	if _, ok := ring.Member(self); !ok {
+		return fmt.Errorf("%q is not a member of the cluster", self)
+	}
+
+	s.ring, s.self = ring, self
+	return nil
+}
+
+// Cluster handles a request for the members of the cluster the server belongs
+// to, returning a FailedPrecondition error if the server is not clustered. The
+// reply of a member of a replicated cluster names the leader, if it is known,
+// and the reply of a backup has the address of its primary instead of members.
+func (s *Server) Cluster(ctx context.Context, in *pb.ClusterRequest) (*pb.ClusterReply, error) {
+	if primary, ok := s.primary(); ok {
+		return &pb.ClusterReply{Success: true, Node: s.self, Primary: primary}, nil
+	}
+
+	if s.node != nil {
+		rep := &pb.ClusterReply{Success: true, Node: s.self, Replicated: true, Leader: s.node.Leader()}
+		for _, member := range s.members {
+			rep.Members = append(rep.Members, &pb.Member{Name: member.Name, Addr: member.Addr})
+		}
+		return rep, nil
+	}
+
+	if s.ring == nil {
+		return nil, status.Error(codes.FailedPrecondition, "server is not a member of a cluster")
+	}
+
+	rep := &pb.ClusterReply{Success: true, Node: s.self, Vnodes: uint32(s.ring.VirtualNodes())}
+	for _, member := range s.ring.Members() {
+		rep.Members = append(rep.Members, &pb.Member{Name: member.Name, Addr: member.Addr})
+	}
+	return rep, nil
+}
+
+// Returns the name of the member that owns the key if the server is clustered
+// and the key is owned by another member.
+func (s *Server) redirect(key string) (owner string, ok bool) {
+	if s.ring == nil {
+		return "", false
+	}
+
+	if owner = s.ring.Owner(key).Name; owner != s.self {
+		return owner, true
+	}
+	return "", false
+}
+
+// Returns the name of the member that owns the first of the keys that is not
+// owned by this server, if any.
+func (s *Server) redirectAny(keys []string) (owner string, ok bool) {
+	for _, key := range keys {
+		if owner, ok = s.redirect(key); ok {
+			return owner, ok
+		}
+	}
+	return "", false
+}
+
+// The reply to a request for a key that is owned by another member.
+func redirectReply(key, owner string) *pb.ClientReply {
+	return &pb.ClientReply{Success: false, Redirect: owner, Error: fmt.Sprintf("%q is owned by %s", key, owner)}
+}
+
+// The reply to a batch with keys that are owned by another member.
+func redirectBatchReply(owner string) *pb.BatchReply {
+	return &pb.BatchReply{Success: false, Redirect: owner, Error: fmt.Sprintf("keys in the batch are owned by %s", owner)}
+}
+
+// Returns the server that a request which failed with the error should be
+// sent to instead and the reason: the leader if the error is a
+// raft.NotLeaderError and the leader is known, or the primary if the error is
+// a replica.ReadOnlyError.
+func forward(err error) (to, reason string, ok bool) {
+	var nle *raft.NotLeaderError
+	if errors.As(err, &nle) && nle.Leader != "" {
+		return nle.Leader, fmt.Sprintf("requests are served by the leader %s", nle.Leader), true
+	}
+
+	var roe *replica.ReadOnlyError
+	if errors.As(err, &roe) {
+		return roe.Primary, fmt.Sprintf("writes are served by the primary %s", roe.Primary), true
+	}
+	return "", "", false
+}
+
+// The reply to a request that is served by another server.
+func forwardReply(to, reason string) *pb.ClientReply {
+	return &pb.ClientReply{Success: false, Redirect: to, Error: reason}
+}
+
+// The reply to a batch that is served by another server.
+func forwardBatchReply(to, reason string) *pb.BatchReply {
+	return &pb.BatchReply{Success: false, Redirect: to, Error: reason}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/bbengfort/speedmap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Maps the standard speedmap errors to gRPC status codes so that clients can
+// branch on the kind of error using status.Code rather than its message.
+func statusError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, speedmap.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, speedmap.ErrClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, speedmap.ErrValueType):
+		return status.Error(codes.Internal, err.Error())
+	case errors.Is(err, speedmap.ErrKeyTooLarge):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, speedmap.ErrConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, speedmap.ErrTxnDone):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, speedmap.ErrCompacted):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, speedmap.ErrSlowConsumer):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, speedmap.ErrCorrupt):
+		return status.Error(codes.DataLoss, err.Error())
+	case errors.Is(err, speedmap.ErrNotLeader):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, speedmap.ErrReadOnly):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Unknown, err.Error())
+	}
+}
+
+// Maps the standard speedmap errors to HTTP status codes for the HTTP gateway.
+func httpStatus(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, speedmap.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, speedmap.ErrClosed), errors.Is(err, speedmap.ErrNotLeader):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, speedmap.ErrKeyTooLarge):
+		return http.StatusBadRequest
+	case errors.Is(err, speedmap.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, speedmap.ErrReadOnly):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// The standard speedmap errors of the gRPC status codes returned by statusError.
+var codeErrors = map[codes.Code][]error{
+	codes.NotFound:           {speedmap.ErrNotFound},
+	codes.Unavailable:        {speedmap.ErrClosed, speedmap.ErrNotLeader},
+	codes.Internal:           {speedmap.ErrValueType},
+	codes.InvalidArgument:    {speedmap.ErrKeyTooLarge},
+	codes.Aborted:            {speedmap.ErrConflict},
+	codes.FailedPrecondition: {speedmap.ErrTxnDone, speedmap.ErrReadOnly},
+	codes.OutOfRange:         {speedmap.ErrCompacted},
+	codes.ResourceExhausted:  {speedmap.ErrSlowConsumer},
+	codes.DataLoss:           {speedmap.ErrCorrupt},
+}
+
+// Maps the gRPC status errors returned by the server back to the standard
+// speedmap errors, the inverse of statusError, so that errors.Is can be used
+// on the errors of a remote store as it would be on a local store. Since
+// status codes are shared with errors that are not from the store (e.g. an
+// Unavailable server), the message must also contain the speedmap error.
+func storeError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok || err == nil {
+		return err
+	}
+
+	switch st.Code() {
+	case codes.DeadlineExceeded:
+		return &remoteError{err: context.DeadlineExceeded, msg: st.Message()}
+	case codes.Canceled:
+		return &remoteError{err: context.Canceled, msg: st.Message()}
+	}
+
+	for _, target := range codeErrors[st.Code()] {
+		if strings.Contains(st.Message(), target.Error()) {
+			return &remoteError{err: target, msg: st.Message()}
+		}
+	}
+	return err
+}
+
+// An error returned by the server that unwraps to the speedmap error it was
+// caused by, keeping the message sent by the server.
+type remoteError struct {
+	err error
+	msg string
+}
+
+func (e *remoteError) Error() string {
+	return e.msg
+}
+
+func (e *remoteError) Unwrap() error {
+	return e.err
+}
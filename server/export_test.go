@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net"
+	"net/http"
+)
+
+// ServeRESP exposes the RESP connection handler to the tests, closing the
+// connection once it returns as the listener does.
+func (s *Server) ServeRESP(conn net.Conn) {
+	defer conn.Close()
+	s.serveRESP(conn)
+}
+
+// ServeMemcached exposes the memcached connection handler to the tests,
+// closing the connection once it returns as the listener does.
+func (s *Server) ServeMemcached(conn net.Conn) {
+	defer conn.Close()
+	s.serveMemcached(conn)
+}
+
+// ServeHTTP exposes the HTTP gateway handler to the tests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.serveHTTP(w, r)
+}
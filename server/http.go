@@ -0,0 +1,519 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Limits on the requests accepted by the HTTP gateway.
+const (
+	httpMaxBody      = 32 * 1024 * 1024
+	httpDefaultLimit = 100
+	httpMaxLimit     = 10000
+)
+
+// A key/value pair in the JSON requests and replies of the HTTP gateway;
+// values are encoded as base64 strings and are null for keys that are not
+// in the store. The version is only set by versioned stores.
+type httpPair struct {
+	Key     string `json:"key"`
+	Value   []byte `json:"value"`
+	Version uint64 `json:"version,omitempty"`
+}
+
+// ListenHTTP serves the store on the specified address with an HTTP/JSON
+// gateway so that tools that cannot use gRPC (curl, scripts, browsers) can
+// access the store. The gateway has the following endpoints:
+//
+//	GET    /kv/{key}   get the value of the key, 404 if it is not in the store
+//	PUT    /kv/{key}   put the request body as the value of the key
+//	DELETE /kv/{key}   delete the key, 404 if it is not in the store
+//	GET    /kv         list the keys in [start, end) with a prefix, in key order
+//	POST   /batch/get  get the values of the keys in {"keys": [...]}
+//	POST   /batch/put  put the pairs in {"pairs": [{"key": k, "value": v}]}
+//
+// Values are sent and received as raw bytes, or as {"key", "value",
+// "version"} JSON objects with base64 values if the request is JSON or
+// accepts JSON. Versioned stores reply with the version of a value in the
+// ETag header, which can be sent in the If-Match header of a put to compare
+// and swap the value (409 if it has changed since); If-None-Match: * only
+// creates the value if the key is not in the store (409 if it is). A ttl query
+// parameter (e.g. ?ttl=30s) puts a value that expires on expiring stores.
+//
+// The list endpoint takes prefix, start, end and limit query parameters and
+// replies with the pairs and the key to start the next page from, if any.
+// Stores that are not a speedmap.Scanner are listed by sorting all of their
+// keys, so listing them is expensive.
+//
+// The gateway uses the TLS configuration, authenticator and ACL of the server;
+// tokens are sent in the Authorization header as "Bearer <token>". ListenHTTP
+// blocks until the server is shut down, then returns nil.
+func (s *Server) ListenHTTP(addr string) (err error) {
+	var sock net.Listener
+	if sock, err = s.listen(addr); err != nil {
+		return fmt.Errorf("could not listen on %s", addr)
+	}
+
+	web := &http.Server{Handler: http.HandlerFunc(s.serveHTTP)}
+	s.mu.Lock()
+	s.web = web
+	s.mu.Unlock()
+
+	fmt.Printf("serving the %s store with the http gateway on %s\n", s.kv.String(), addr)
+	if err = web.Serve(sock); err != nil {
+		select {
+		case <-s.done:
+			return nil
+		default:
+		}
+	}
+	return err
+}
+
+// Routes the request to the handler of its endpoint once the client has been
+// authenticated.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	identity, err := s.httpAuthenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		httpError(w, http.StatusUnauthorized, status.Convert(err).Message())
+		return
+	}
+
+	s.count(identity)
+	r.Body = http.MaxBytesReader(w, r.Body, httpMaxBody)
+
+	switch path := r.URL.Path; {
+	case path == "/kv" || path == "/kv/":
+		if httpMethod(w, r, http.MethodGet) {
+			s.httpList(w, r, identity)
+		}
+	case strings.HasPrefix(path, "/kv/"):
+		key := strings.TrimPrefix(path, "/kv/")
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			s.httpGet(w, r, identity, key)
+		case http.MethodPut:
+			s.httpPut(w, r, identity, key)
+		case http.MethodDelete:
+			s.httpDelete(w, r, identity, key)
+		default:
+			httpMethod(w, r, http.MethodGet, http.MethodPut, http.MethodDelete)
+		}
+	case path == "/batch/get":
+		if httpMethod(w, r, http.MethodPost) {
+			s.httpBatchGet(w, r, identity)
+		}
+	case path == "/batch/put":
+		if httpMethod(w, r, http.MethodPost) {
+			s.httpBatchPut(w, r, identity)
+		}
+	default:
+		httpError(w, http.StatusNotFound, "no such endpoint")
+	}
+}
+
+// Authenticates the client with the authenticator of the server by passing it
+// the Authorization header and TLS state of the request as they would be for
+// a gRPC request.
+func (s *Server) httpAuthenticate(r *http.Request) (identity string, err error) {
+	if s.auth == nil {
+		return "", nil
+	}
+
+	ctx := r.Context()
+	if header := r.Header.Get("Authorization"); header != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(authorizationHeader, header))
+	}
+
+	if r.TLS != nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{State: *r.TLS}})
+	}
+	return s.auth.Authenticate(ctx)
+}
+
+// Writes a permission error and returns false if the ACL of the server does
+// not allow the operation on every key.
+func (s *Server) httpAllow(w http.ResponseWriter, identity string, op Operation, keys ...string) bool {
+	if s.acl == nil {
+		return true
+	}
+
+	for _, key := range keys {
+		if !s.acl.Allowed(identity, op, key) {
+			httpError(w, http.StatusForbidden, fmt.Sprintf("%s may not %s %q", identity, op, key))
+			return false
+		}
+	}
+	return true
+}
+
+//===========================================================================
+// Endpoints
+//===========================================================================
+
+// GET /kv/{key}
+func (s *Server) httpGet(w http.ResponseWriter, r *http.Request, identity, key string) {
+	if !s.httpAllow(w, identity, OpGet, key) {
+		return
+	}
+
+	var (
+		val  []byte
+		vers uint64
+		err  error
+	)
+
+	if versioner, ok := speedmap.Unwrap(s.kv).(speedmap.Versioner); ok {
+		val, vers, err = versioner.GetVersion(key)
+	} else {
+		val, err = s.kv.GetCtx(r.Context(), key)
+	}
+
+	if err != nil {
+		httpError(w, httpStatus(err), err.Error())
+		return
+	}
+
+	if vers > 0 {
+		w.Header().Set("ETag", strconv.Quote(strconv.FormatUint(vers, 10)))
+	}
+
+	if httpAcceptsJSON(r) {
+		httpJSON(w, http.StatusOK, &httpPair{Key: key, Value: val, Version: vers})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(val)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(val)
+	}
+}
+
+// PUT /kv/{key}[?ttl=duration]
+func (s *Server) httpPut(w http.ResponseWriter, r *http.Request, identity, key string) {
+	if !s.httpAllow(w, identity, OpPut, key) {
+		return
+	}
+
+	var (
+		val []byte
+		ttl time.Duration
+		err error
+	)
+
+	if httpIsJSON(r) {
+		pair := &httpPair{}
+		if err = json.NewDecoder(r.Body).Decode(pair); err != nil {
+			httpError(w, http.StatusBadRequest, "could not decode value: "+err.Error())
+			return
+		}
+		val = pair.Value
+	} else if val, err = ioutil.ReadAll(r.Body); err != nil {
+		httpError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+
+	if param := r.URL.Query().Get("ttl"); param != "" {
+		if ttl, err = time.ParseDuration(param); err != nil || ttl <= 0 {
+			httpError(w, http.StatusBadRequest, fmt.Sprintf("invalid ttl %q", param))
+			return
+		}
+	}
+
+	match, noneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	if (match != "" || noneMatch != "") && ttl > 0 {
+		httpError(w, http.StatusBadRequest, "conditional puts with a ttl are not supported")
+		return
+	}
+
+	switch {
+	case match != "":
+		vers, perr := strconv.ParseUint(strings.Trim(strings.TrimPrefix(match, "W/"), `"`), 10, 64)
+		if perr != nil {
+			httpError(w, http.StatusBadRequest, fmt.Sprintf("invalid version %q", match))
+			return
+		}
+
+		swapper, ok := speedmap.Unwrap(s.kv).(speedmap.Swapper)
+		if !ok {
+			httpError(w, http.StatusNotImplemented, fmt.Sprintf("the %s store does not support compare and swap", s.kv))
+			return
+		}
+
+		var swapped bool
+		if swapped, err = swapper.CompareAndSwap(key, vers, val); err == nil && !swapped {
+			httpError(w, http.StatusConflict, fmt.Sprintf("version of %q is not %d", key, vers))
+			return
+		}
+	case noneMatch == "*":
+		var created bool
+		if _, created, err = s.kv.GetOrCreateCtx(r.Context(), key, val); err == nil && !created {
+			httpError(w, http.StatusConflict, fmt.Sprintf("%q already exists", key))
+			return
+		}
+	case noneMatch != "":
+		httpError(w, http.StatusBadRequest, "only If-None-Match: * is supported")
+		return
+	case ttl > 0:
+		expirer, ok := speedmap.Unwrap(s.kv).(speedmap.Expirer)
+		if !ok {
+			httpError(w, http.StatusNotImplemented, fmt.Sprintf("the %s store does not support expiration", s.kv))
+			return
+		}
+		err = expirer.PutWithTTL(key, val, ttl)
+	default:
+		err = s.kv.PutCtx(r.Context(), key, val)
+	}
+
+	if err != nil {
+		httpError(w, httpStatus(err), err.Error())
+		return
+	}
+
+	if vers := s.version(key); vers > 0 {
+		w.Header().Set("ETag", strconv.Quote(strconv.FormatUint(vers, 10)))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /kv/{key}
+func (s *Server) httpDelete(w http.ResponseWriter, r *http.Request, identity, key string) {
+	if !s.httpAllow(w, identity, OpDelete, key) {
+		return
+	}
+
+	if _, err := s.kv.GetCtx(r.Context(), key); err != nil {
+		httpError(w, httpStatus(err), err.Error())
+		return
+	}
+
+	if err := s.kv.DeleteCtx(r.Context(), key); err != nil {
+		httpError(w, httpStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /kv[?prefix=p][&start=k][&end=k][&limit=n]
+//
+// Keys that the client may not get are skipped.
+func (s *Server) httpList(w http.ResponseWriter, r *http.Request, identity string) {
+	query := r.URL.Query()
+	prefix, start, end := query.Get("prefix"), query.Get("start"), query.Get("end")
+
+	limit := httpDefaultLimit
+	if param := query.Get("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 1 || n > httpMaxLimit {
+			httpError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", httpMaxLimit))
+			return
+		}
+		limit = n
+	}
+
+	if start < prefix {
+		start = prefix
+	}
+
+	visible := func(key string) bool {
+		return strings.HasPrefix(key, prefix) && (s.acl == nil || s.acl.Allowed(identity, OpGet, key))
+	}
+
+	var (
+		pairs []*httpPair
+		next  string
+		err   error
+	)
+
+	if _, ok := speedmap.Unwrap(s.kv).(speedmap.Scanner); ok {
+		err = speedmap.Scan(s.kv, start, end, func(key string, value []byte) bool {
+			// Scans start at the prefix, so the first key without it ends the scan
+			if !strings.HasPrefix(key, prefix) {
+				return false
+			}
+
+			if len(pairs) == limit {
+				next = key
+				return false
+			}
+
+			if visible(key) {
+				pairs = append(pairs, &httpPair{Key: key, Value: value})
+			}
+			return true
+		})
+	} else {
+		err = speedmap.Range(s.kv, func(key string, value []byte) bool {
+			if key >= start && (end == "" || key < end) && visible(key) {
+				pairs = append(pairs, &httpPair{Key: key, Value: value})
+			}
+			return true
+		})
+
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+		if len(pairs) > limit {
+			next = pairs[limit].Key
+			pairs = pairs[:limit]
+		}
+	}
+
+	if err != nil {
+		httpError(w, http.StatusNotImplemented, fmt.Sprintf("the %s store cannot be listed", s.kv))
+		return
+	}
+
+	reply := struct {
+		Pairs []*httpPair `json:"pairs"`
+		Next  string      `json:"next,omitempty"`
+	}{Pairs: pairs, Next: next}
+
+	if reply.Pairs == nil {
+		reply.Pairs = []*httpPair{}
+	}
+	httpJSON(w, http.StatusOK, reply)
+}
+
+// POST /batch/get {"keys": [...]}
+//
+// Replies with the pairs in the same order as the keys.
+func (s *Server) httpBatchGet(w http.ResponseWriter, r *http.Request, identity string) {
+	var req struct {
+		Keys []string `json:"keys"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "could not decode keys: "+err.Error())
+		return
+	}
+
+	if !s.httpAllow(w, identity, OpGet, req.Keys...) {
+		return
+	}
+
+	vals, err := speedmap.MultiGet(s.kv, req.Keys)
+	if err != nil {
+		httpError(w, httpStatus(err), err.Error())
+		return
+	}
+
+	reply := struct {
+		Pairs []*httpPair `json:"pairs"`
+	}{Pairs: make([]*httpPair, len(req.Keys))}
+
+	for i, key := range req.Keys {
+		reply.Pairs[i] = &httpPair{Key: key, Value: vals[i]}
+	}
+	httpJSON(w, http.StatusOK, reply)
+}
+
+// POST /batch/put {"pairs": [{"key": k, "value": v}, ...]}
+func (s *Server) httpBatchPut(w http.ResponseWriter, r *http.Request, identity string) {
+	var req struct {
+		Pairs []*httpPair `json:"pairs"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "could not decode pairs: "+err.Error())
+		return
+	}
+
+	pairs := make(map[string][]byte, len(req.Pairs))
+	keys := make([]string, 0, len(req.Pairs))
+	for _, pair := range req.Pairs {
+		pairs[pair.Key] = pair.Value
+		keys = append(keys, pair.Key)
+	}
+
+	if !s.httpAllow(w, identity, OpPut, keys...) {
+		return
+	}
+
+	if err := speedmap.MultiPut(s.kv, pairs); err != nil {
+		httpError(w, httpStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//===========================================================================
+// Helpers
+//===========================================================================
+
+// Returns true if the request method is one of the allowed methods, otherwise
+// writes a method not allowed error.
+func httpMethod(w http.ResponseWriter, r *http.Request, allowed ...string) bool {
+	for _, method := range allowed {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	httpError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed", r.Method))
+	return false
+}
+
+// Returns true if the body of the request is JSON.
+func httpIsJSON(r *http.Request) bool {
+	mediatype, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediatype == "application/json"
+}
+
+// Returns true if the client prefers a JSON reply to raw bytes.
+func httpAcceptsJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediatype, _, _ := mime.ParseMediaType(strings.TrimSpace(accept)); mediatype == "application/json" {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes the reply encoded as JSON with the status code.
+func httpJSON(w http.ResponseWriter, code int, reply interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(reply)
+}
+
+// Writes an error as a JSON object with the status code.
+func httpError(w http.ResponseWriter, code int, msg string) {
+	httpJSON(w, code, map[string]string{"error": msg})
+}
+
+// Stops the HTTP gateway, if it is running, waiting for in-flight requests
+// until the context is done.
+func (s *Server) shutdownHTTP(ctx context.Context) error {
+	s.mu.Lock()
+	web := s.web
+	s.mu.Unlock()
+
+	if web == nil {
+		return nil
+	}
+
+	if err := web.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		web.Close()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/server"
+	"github.com/bbengfort/speedmap/store"
+)
+
+var _ = Describe("HTTP", func() {
+
+	var srv *Server
+
+	// Makes a request to the gateway with the headers as name/value pairs.
+	request := func(method, target, body string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Decodes the JSON body of the reply.
+	decode := func(rec *httptest.ResponseRecorder, reply interface{}) {
+		Ω(rec.Header().Get("Content-Type")).Should(Equal("application/json"))
+		Ω(json.Unmarshal(rec.Body.Bytes(), reply)).Should(Succeed())
+	}
+
+	// Returns the error message of a reply.
+	message := func(rec *httptest.ResponseRecorder) string {
+		var reply map[string]string
+		decode(rec, &reply)
+		return reply["error"]
+	}
+
+	Context("with an unversioned store", func() {
+
+		BeforeEach(func() {
+			kv, err := store.NewShard()
+			Ω(err).ShouldNot(HaveOccurred())
+			srv = New(kv)
+		})
+
+		It("should put, get and delete raw values", func() {
+			rec := request(http.MethodPut, "/kv/foo", "bar")
+			Ω(rec.Code).Should(Equal(http.StatusNoContent))
+			Ω(rec.Header().Get("ETag")).Should(BeEmpty())
+
+			rec = request(http.MethodGet, "/kv/foo", "")
+			Ω(rec.Code).Should(Equal(http.StatusOK))
+			Ω(rec.Header().Get("Content-Type")).Should(Equal("application/octet-stream"))
+			Ω(rec.Body.String()).Should(Equal("bar"))
+
+			rec = request(http.MethodHead, "/kv/foo", "")
+			Ω(rec.Code).Should(Equal(http.StatusOK))
+			Ω(rec.Header().Get("Content-Length")).Should(Equal("3"))
+			Ω(rec.Body.Len()).Should(BeZero())
+
+			rec = request(http.MethodDelete, "/kv/foo", "")
+			Ω(rec.Code).Should(Equal(http.StatusNoContent))
+
+			rec = request(http.MethodGet, "/kv/foo", "")
+			Ω(rec.Code).Should(Equal(http.StatusNotFound))
+			Ω(message(rec)).Should(ContainSubstring(speedmap.ErrNotFound.Error()))
+
+			rec = request(http.MethodDelete, "/kv/foo", "")
+			Ω(rec.Code).Should(Equal(http.StatusNotFound))
+		})
+
+		It("should put and get JSON values", func() {
+			rec := request(http.MethodPut, "/kv/foo", `{"value": "YmFy"}`, "Content-Type", "application/json; charset=utf-8")
+			Ω(rec.Code).Should(Equal(http.StatusNoContent))
+
+			rec = request(http.MethodGet, "/kv/foo", "", "Accept", "text/html, application/json")
+			Ω(rec.Code).Should(Equal(http.StatusOK))
+
+			var pair map[string]interface{}
+			decode(rec, &pair)
+			Ω(pair).Should(Equal(map[string]interface{}{"key": "foo", "value": "YmFy"}))
+
+			rec = request(http.MethodPut, "/kv/foo", `{"value": `, "Content-Type", "application/json")
+			Ω(rec.Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should map store errors to status codes", func() {
+			rec := request(http.MethodPut, "/kv/"+strings.Repeat("a", speedmap.MaxKeySize+1), "bar")
+			Ω(rec.Code).Should(Equal(http.StatusBadRequest))
+			Ω(message(rec)).Should(ContainSubstring(speedmap.ErrKeyTooLarge.Error()))
+
+			rec = request(http.MethodPut, "/kv/foo?ttl=1m", "bar")
+			Ω(rec.Code).Should(Equal(http.StatusNotImplemented))
+
+			rec = request(http.MethodPut, "/kv/foo?ttl=soon", "bar")
+			Ω(rec.Code).Should(Equal(http.StatusBadRequest))
+
+			rec = request(http.MethodPut, "/kv/foo", "bar", "If-Match", `"1"`)
+			Ω(rec.Code).Should(Equal(http.StatusNotImplemented))
+
+			rec = request(http.MethodPut, "/kv/foo", "bar", "If-None-Match", `"1"`)
+			Ω(rec.Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should only create values with If-None-Match", func() {
+			rec := request(http.MethodPut, "/kv/foo", "bar", "If-None-Match", "*")
+			Ω(rec.Code).Should(Equal(http.StatusNoContent))
+
+			rec = request(http.MethodPut, "/kv/foo", "baz", "If-None-Match", "*")
+			Ω(rec.Code).Should(Equal(http.StatusConflict))
+
+			rec = request(http.MethodGet, "/kv/foo", "")
+			Ω(rec.Body.String()).Should(Equal("bar"))
+		})
+
+		It("should reject unknown endpoints and methods", func() {
+			rec := request(http.MethodGet, "/foo", "")
+			Ω(rec.Code).Should(Equal(http.StatusNotFound))
+
+			rec = request(http.MethodPost, "/kv/foo", "bar")
+			Ω(rec.Code).Should(Equal(http.StatusMethodNotAllowed))
+			Ω(rec.Header().Get("Allow")).Should(Equal("GET, PUT, DELETE"))
+
+			rec = request(http.MethodGet, "/batch/get", "")
+			Ω(rec.Code).Should(Equal(http.StatusMethodNotAllowed))
+			Ω(rec.Header().Get("Allow")).Should(Equal("POST"))
+		})
+
+		It("should list keys in order with pages", func() {
+			for _, key := range []string{"b/2", "a/3", "b/1", "a/1", "a/2", "c"} {
+				Ω(request(http.MethodPut, "/kv/"+key, key).Code).Should(Equal(http.StatusNoContent))
+			}
+
+			var reply struct {
+				Pairs []struct {
+					Key   string `json:"key"`
+					Value []byte `json:"value"`
+				} `json:"pairs"`
+				Next string `json:"next"`
+			}
+
+			rec := request(http.MethodGet, "/kv?prefix=a/&limit=2", "")
+			Ω(rec.Code).Should(Equal(http.StatusOK))
+			decode(rec, &reply)
+			Ω(reply.Pairs).Should(HaveLen(2))
+			Ω(reply.Pairs[0].Key).Should(Equal("a/1"))
+			Ω(reply.Pairs[0].Value).Should(Equal([]byte("a/1")))
+			Ω(reply.Pairs[1].Key).Should(Equal("a/2"))
+			Ω(reply.Next).Should(Equal("a/3"))
+
+			reply.Next = ""
+			decode(request(http.MethodGet, "/kv?prefix=a/&start=a/3", ""), &reply)
+			Ω(reply.Pairs).Should(HaveLen(1))
+			Ω(reply.Pairs[0].Key).Should(Equal("a/3"))
+			Ω(reply.Next).Should(BeEmpty())
+
+			decode(request(http.MethodGet, "/kv?start=a/2&end=b/2", ""), &reply)
+			Ω(reply.Pairs).Should(HaveLen(3))
+			Ω(reply.Pairs[2].Key).Should(Equal("b/1"))
+
+			rec = request(http.MethodGet, "/kv?limit=0", "")
+			Ω(rec.Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should get and put batches", func() {
+			rec := request(http.MethodPost, "/batch/put", `{"pairs": [{"key": "foo", "value": "YmFy"}, {"key": "bar", "value": "YmF6"}]}`)
+			Ω(rec.Code).Should(Equal(http.StatusNoContent))
+
+			rec = request(http.MethodPost, "/batch/get", `{"keys": ["bar", "missing", "foo"]}`)
+			Ω(rec.Code).Should(Equal(http.StatusOK))
+
+			var reply struct {
+				Pairs []map[string]interface{} `json:"pairs"`
+			}
+			decode(rec, &reply)
+			Ω(reply.Pairs).Should(Equal([]map[string]interface{}{
+				{"key": "bar", "value": "YmF6"},
+				{"key": "missing", "value": nil},
+				{"key": "foo", "value": "YmFy"},
+			}))
+
+			rec = request(http.MethodPost, "/batch/get", `["foo"]`)
+			Ω(rec.Code).Should(Equal(http.StatusBadRequest))
+		})
+
+	})
+
+	Context("with a versioned store", func() {
+
+		BeforeEach(func() {
+			kv, err := store.NewVersioned()
+			Ω(err).ShouldNot(HaveOccurred())
+			srv = New(kv)
+		})
+
+		It("should compare and swap values by their ETag", func() {
+			rec := request(http.MethodPut, "/kv/foo", "bar")
+			Ω(rec.Code).Should(Equal(http.StatusNoContent))
+			etag := rec.Header().Get("ETag")
+			Ω(etag).ShouldNot(BeEmpty())
+
+			rec = request(http.MethodGet, "/kv/foo", "")
+			Ω(rec.Header().Get("ETag")).Should(Equal(etag))
+
+			rec = request(http.MethodPut, "/kv/foo", "baz", "If-Match", etag)
+			Ω(rec.Code).Should(Equal(http.StatusNoContent))
+			Ω(rec.Header().Get("ETag")).ShouldNot(Equal(etag))
+
+			rec = request(http.MethodPut, "/kv/foo", "qux", "If-Match", etag)
+			Ω(rec.Code).Should(Equal(http.StatusConflict))
+
+			rec = request(http.MethodPut, "/kv/foo", "qux", "If-Match", "latest")
+			Ω(rec.Code).Should(Equal(http.StatusBadRequest))
+
+			rec = request(http.MethodGet, "/kv/foo", "")
+			Ω(rec.Body.String()).Should(Equal("baz"))
+		})
+
+	})
+
+	Context("with authentication and an ACL", func() {
+
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "speedmap-http")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			auth, err := NewTokenAuth(writeFile(dir, "tokens.txt", "secret-a team-a\n"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			acl, err := LoadACL(writeFile(dir, "acl.txt", "team-a get,put a/,shared/\n"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			kv, err := store.NewShard()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(kv.Put("b/foo", []byte("secret"))).Should(Succeed())
+
+			srv = New(kv)
+			srv.SetAuth(auth)
+			srv.SetACL(acl)
+		})
+
+		AfterEach(func() {
+			Ω(os.RemoveAll(dir)).Should(Succeed())
+		})
+
+		It("should require a valid token", func() {
+			rec := request(http.MethodGet, "/kv/a/foo", "")
+			Ω(rec.Code).Should(Equal(http.StatusUnauthorized))
+			Ω(rec.Header().Get("WWW-Authenticate")).Should(Equal("Bearer"))
+
+			rec = request(http.MethodGet, "/kv/a/foo", "", "Authorization", "Bearer secret-b")
+			Ω(rec.Code).Should(Equal(http.StatusUnauthorized))
+			Ω(message(rec)).Should(Equal("invalid token"))
+
+			rec = request(http.MethodGet, "/kv/a/foo", "", "Authorization", "Bearer secret-a")
+			Ω(rec.Code).Should(Equal(http.StatusNotFound))
+		})
+
+		It("should only allow the operations and keys of the ACL", func() {
+			auth := []string{"Authorization", "Bearer secret-a"}
+
+			rec := request(http.MethodPut, "/kv/a/foo", "bar", auth...)
+			Ω(rec.Code).Should(Equal(http.StatusNoContent))
+
+			rec = request(http.MethodGet, "/kv/b/foo", "", auth...)
+			Ω(rec.Code).Should(Equal(http.StatusForbidden))
+			Ω(message(rec)).Should(Equal(`team-a may not get "b/foo"`))
+
+			rec = request(http.MethodDelete, "/kv/a/foo", "", auth...)
+			Ω(rec.Code).Should(Equal(http.StatusForbidden))
+
+			rec = request(http.MethodPost, "/batch/get", `{"keys": ["a/foo", "b/foo"]}`, auth...)
+			Ω(rec.Code).Should(Equal(http.StatusForbidden))
+
+			rec = request(http.MethodPost, "/batch/put", `{"pairs": [{"key": "b/foo", "value": ""}]}`, auth...)
+			Ω(rec.Code).Should(Equal(http.StatusForbidden))
+
+			// Keys the client may not get are not listed
+			var reply struct {
+				Pairs []struct {
+					Key string `json:"key"`
+				} `json:"pairs"`
+			}
+			decode(request(http.MethodGet, "/kv", "", auth...), &reply)
+			Ω(reply.Pairs).Should(HaveLen(1))
+			Ω(reply.Pairs[0].Key).Should(Equal("a/foo"))
+		})
+
+	})
+
+})
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+
+	"github.com/bbengfort/speedmap/server/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Requests carry the identity of the client that sent them.
+type identified interface {
+	GetIdentity() string
+}
+
+// The context key of the identity authenticated when a stream is opened, which
+// is only set if the server has an authenticator.
+type identityKey struct{}
+
+// Intercepts unary requests to authenticate the client, count the request
+// against its identity, and authorize the request before it is handled.
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	identity, err := s.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.count(identity)
+	if err = s.authorize(identity, req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Intercepts streams to authenticate the client when the stream is opened,
+// then to count and authorize each message received on the stream.
+func (s *Server) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	wrapped := &interceptedStream{ServerStream: stream, server: s, ctx: stream.Context()}
+	if s.auth != nil {
+		identity, err := s.auth.Authenticate(stream.Context())
+		if err != nil {
+			return err
+		}
+		wrapped.identity = identity
+		wrapped.ctx = context.WithValue(wrapped.ctx, identityKey{}, identity)
+	}
+	return handler(srv, wrapped)
+}
+
+// Wraps a server stream to count and authorize the messages received on it.
+type interceptedStream struct {
+	grpc.ServerStream
+	server   *Server
+	ctx      context.Context // the stream context with the authenticated identity
+	identity string          // the authenticated identity, if the server authenticates
+}
+
+func (c *interceptedStream) Context() context.Context {
+	return c.ctx
+}
+
+func (c *interceptedStream) RecvMsg(m interface{}) error {
+	if err := c.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	// Requests on a pipelined stream are counted and authorized by Stream so
+	// that a request that is not allowed fails without ending the stream.
+	if _, ok := m.(*pb.StreamRequest); ok {
+		return nil
+	}
+
+	identity := c.identity
+	if c.server.auth == nil {
+		identity, _ = c.server.authenticate(c.Context(), m)
+	}
+
+	c.server.count(identity)
+	return c.server.authorize(identity, m)
+}
+
+// Returns the identity of the client that sent the request: the identity
+// authenticated by the server's authenticator if it has one, otherwise the
+// identity claimed by the request.
+func (s *Server) authenticate(ctx context.Context, req interface{}) (string, error) {
+	if s.auth != nil {
+		return s.auth.Authenticate(ctx)
+	}
+
+	if msg, ok := req.(identified); ok {
+		return msg.GetIdentity(), nil
+	}
+	return "", nil
+}
+
+// Checks the operations and keys of the request against the ACL of the
+// server, returning a PermissionDenied error if any of them are not allowed.
+func (s *Server) authorize(identity string, req interface{}) error {
+	if s.acl == nil {
+		return nil
+	}
+
+	switch req := req.(type) {
+	case *pb.GetRequest:
+		return s.allow(identity, OpGet, req.Key)
+	case *pb.PutRequest:
+		return s.allow(identity, OpPut, req.Key)
+	case *pb.DelRequest:
+		return s.allow(identity, OpDelete, req.Key)
+	case *pb.BatchGetRequest:
+		for _, key := range req.Keys {
+			if err := s.allow(identity, OpGet, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *pb.BatchPutRequest:
+		for _, pair := range req.Pairs {
+			if err := s.allow(identity, OpPut, pair.Key); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *pb.WatchRequest:
+		return s.allow(identity, OpWatch, req.Key)
+	case *pb.SnapshotRequest, *pb.StatsRequest, *pb.FlushRequest, *pb.SwapRequest, *pb.ReplicationRequest, *pb.PromoteRequest:
+		return s.allow(identity, OpAdmin, "")
+	case *pb.VoteRequest, *pb.AppendRequest, *pb.FollowRequest:
+		// Only the members of a replicated cluster or backups may replicate its store
+		return s.allow(identity, OpAdmin, "")
+	case *pb.ClusterRequest:
+		// Any client may learn the members of the cluster to route its requests
+		return nil
+	default:
+		return status.Errorf(codes.PermissionDenied, "%s may not make %T requests", identity, req)
+	}
+}
+
+// Returns a PermissionDenied error if the ACL does not allow the operation.
+func (s *Server) allow(identity string, op Operation, key string) error {
+	if s.acl.Allowed(identity, op, key) {
+		return nil
+	}
+
+	if op == OpAdmin {
+		return status.Errorf(codes.PermissionDenied, "%s may not make admin requests", identity)
+	}
+	return status.Errorf(codes.PermissionDenied, "%s may not %s %q", identity, op, key)
+}
@@ -0,0 +1,110 @@
+package server_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bbengfort/speedmap/server"
+	"github.com/bbengfort/speedmap/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Interceptors", func() {
+
+	var (
+		dir  string
+		addr string
+		srv  *Server
+	)
+
+	// Returns a client that connects to the server over TLS with the token.
+	connect := func(identity, token string) *Client {
+		conf, err := ClientTLS(filepath.Join(dir, CACertFile), "", "")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		client := NewClient(identity)
+		client.SetTLS(conf)
+		client.SetToken(token)
+		Ω(client.Connect(addr)).Should(Succeed())
+		return client
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "speedmap-interceptors")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(GenerateCerts(dir, "127.0.0.1")).Should(Succeed())
+
+		conf, err := ServerTLS(filepath.Join(dir, ServerCertFile), filepath.Join(dir, ServerKeyFile), "")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		auth, err := NewTokenAuth(writeFile(dir, "tokens.txt", "secret-a team-a\nsecret-ops ops\n"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		acl, err := LoadACL(writeFile(dir, "acl.txt", "team-a get,put a/\nops * *\n"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		kv, err := store.NewShard()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		srv = New(kv)
+		srv.SetTLS(conf)
+		srv.SetAuth(auth)
+		srv.SetACL(acl)
+		addr = listen(srv)
+	})
+
+	AfterEach(func() {
+		shutdown(srv)
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	It("should reject requests with an invalid token", func() {
+		client := connect("team-a", "not-a-token")
+		defer client.Close()
+
+		_, err := client.Put("a/foo", []byte("bar"))
+		Ω(status.Code(err)).Should(Equal(codes.Unauthenticated))
+
+		_, err = client.Stats()
+		Ω(status.Code(err)).Should(Equal(codes.Unauthenticated))
+	})
+
+	It("should authorize requests with the authenticated identity", func() {
+		client := connect("team-a", "secret-a")
+		defer client.Close()
+
+		_, err := client.Put("a/foo", []byte("bar"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		rep, err := client.Get("a/foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(rep.Pair.Value).Should(Equal([]byte("bar")))
+
+		_, err = client.Put("b/foo", []byte("bar"))
+		Ω(status.Code(err)).Should(Equal(codes.PermissionDenied))
+
+		_, err = client.Del("a/foo", false)
+		Ω(status.Code(err)).Should(Equal(codes.PermissionDenied))
+	})
+
+	It("should ignore the identity claimed by the client", func() {
+		client := connect("ops", "secret-a")
+		defer client.Close()
+
+		_, err := client.Stats()
+		Ω(status.Code(err)).Should(Equal(codes.PermissionDenied))
+
+		admin := connect("team-a", "secret-ops")
+		defer admin.Close()
+
+		_, err = admin.Stats()
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+})
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+)
+
+// Returned by readLine if a line is longer than the maximum.
+var errLineTooLong = errors.New("line too long")
+
+// Listens on the address for one of the text or binary protocols that the
+// server implements itself rather than with gRPC, over TLS if the server is
+// configured for it. The listener is closed when the server is shut down.
+func (s *Server) listen(addr string) (sock net.Listener, err error) {
+	if sock, err = net.Listen("tcp", addr); err != nil {
+		return nil, err
+	}
+
+	if s.tls != nil {
+		sock = tls.NewListener(sock, s.tls)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		sock.Close()
+		return nil, errors.New("server has been shut down")
+	default:
+		s.listeners = append(s.listeners, sock)
+	}
+	return sock, nil
+}
+
+// Accepts connections on the listener, handling each in its own go routine
+// with serve, until the server is shut down. The connection is closed once
+// serve returns.
+func (s *Server) accept(sock net.Listener, serve func(conn net.Conn)) (err error) {
+	for {
+		var conn net.Conn
+		if conn, err = sock.Accept(); err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+			}
+
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.connwg.Add(1)
+		go func() {
+			defer s.connwg.Done()
+			serve(conn)
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// Stops accepting connections on the listeners and ends the open connections
+// once they have handled the request in progress, waiting for them until the
+// context is done, at which point the connections are closed.
+func (s *Server) shutdownListeners(ctx context.Context) (err error) {
+	s.mu.Lock()
+	for _, sock := range s.listeners {
+		sock.Close()
+	}
+
+	// Wake up connections that are waiting for a request
+	for conn := range s.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.connwg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Returns the identity of a client connected over mutual TLS if the server
+// authenticates clients by their certificate, see TLSAuth.
+func (s *Server) tlsIdentity(conn net.Conn) (identity string, ok bool) {
+	if _, ok = s.auth.(TLSAuth); !ok {
+		return "", false
+	}
+
+	tconn, ok := conn.(*tls.Conn)
+	if !ok || tconn.Handshake() != nil {
+		return "", false
+	}
+
+	chains := tconn.ConnectionState().VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 || chains[0][0].Subject.CommonName == "" {
+		return "", false
+	}
+	return chains[0][0].Subject.CommonName, true
+}
+
+// Reads a line terminated by CRLF (or LF) of at most max bytes, returning it
+// without the newline. The line is only valid until the next read.
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		if len(line) >= max {
+			return nil, errLineTooLong
+		}
+
+		// Lines may be larger than the buffer
+		rest, rerr := r.ReadBytes('\n')
+		line = append(append([]byte(nil), line...), rest...)
+		if err = rerr; err == nil && len(line) > max {
+			return nil, errLineTooLong
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
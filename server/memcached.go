@@ -0,0 +1,302 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Limits on the requests accepted by the memcached listener, matching the
+// memcached defaults.
+const (
+	mcMaxKey   = 250
+	mcMaxValue = 1024 * 1024
+	mcMaxLine  = 64 * 1024
+)
+
+// Expiration times larger than 30 days are absolute unix times rather than a
+// number of seconds from now.
+const mcMaxRelativeExpiration = 60 * 60 * 24 * 30
+
+// The result of a memcached operation, using the status codes of the binary
+// protocol.
+type mcStatus uint16
+
+const (
+	mcOK           mcStatus = 0x00
+	mcNotFound     mcStatus = 0x01
+	mcExists       mcStatus = 0x02
+	mcTooLarge     mcStatus = 0x03
+	mcInvalid      mcStatus = 0x04
+	mcNotStored    mcStatus = 0x05
+	mcAuthError    mcStatus = 0x20
+	mcUnknown      mcStatus = 0x81
+	mcNotSupported mcStatus = 0x83
+	mcInternal     mcStatus = 0x84
+)
+
+// ListenMemcached serves the store on the specified address using the
+// memcached text and binary protocols, detected from the first byte each
+// client sends, so that services that use memcached can use any store. The
+// get, gets, set, add, cas and delete commands are supported, along with the
+// equivalent binary opcodes and their quiet variants: add uses GetOrCreate,
+// cas uses the compare-and-swap of stores that are a speedmap.Swapper (with
+// the version of the value as the cas unique), and expiration times require a
+// speedmap.Expirer. Stores have nowhere to keep the flags of a value, so the
+// server keeps them in memory for the keys written by memcached clients.
+//
+// The listener uses the TLS configuration, authenticator and ACL of the
+// server; when the server uses token authentication, clients authenticate
+// with SASL PLAIN over the binary protocol using the token as the password.
+// ListenMemcached blocks until the server is shut down, then returns nil.
+func (s *Server) ListenMemcached(addr string) (err error) {
+	var sock net.Listener
+	if sock, err = s.listen(addr); err != nil {
+		return fmt.Errorf("could not listen on %s", addr)
+	}
+
+	fmt.Printf("serving the %s store with the memcached protocol on %s\n", s.kv.String(), addr)
+	return s.accept(sock, s.serveMemcached)
+}
+
+// A connection from a memcached client and its state.
+type mcConn struct {
+	server   *Server
+	conn     net.Conn
+	r        *bufio.Reader
+	w        *bufio.Writer
+	identity string // the identity of the client, if authenticated
+	authed   bool   // whether the client has been authenticated
+}
+
+// Handles the requests sent on the connection until it is closed, using the
+// binary protocol if the first byte is its request magic.
+func (s *Server) serveMemcached(conn net.Conn) {
+	c := &mcConn{
+		server: s,
+		conn:   conn,
+		r:      bufio.NewReaderSize(conn, 16*1024),
+		w:      bufio.NewWriterSize(conn, 16*1024),
+	}
+
+	// Authenticate clients by their certificate as soon as the handshake is done
+	c.identity, c.authed = s.tlsIdentity(conn)
+
+	magic, err := c.r.Peek(1)
+	if err != nil {
+		return
+	}
+
+	if magic[0] == mcRequestMagic {
+		c.serveBinary()
+		return
+	}
+	c.serveText()
+}
+
+// Returns an error if the server requires authentication and the client has
+// not authenticated.
+func (c *mcConn) authenticated() error {
+	if c.server.auth != nil && !c.authed {
+		return errors.New("authentication required")
+	}
+	return nil
+}
+
+// Authenticates the client by the token if the server uses token
+// authentication, returning true if the token is valid.
+func (c *mcConn) authenticate(token string) bool {
+	auth, ok := c.server.auth.(*TokenAuth)
+	if !ok {
+		return false
+	}
+
+	identity, ok := auth.lookup(token)
+	if ok {
+		c.identity, c.authed = identity, true
+	}
+	return ok
+}
+
+// Returns an error if the ACL of the server does not allow the operation.
+func (c *mcConn) allow(op Operation, key string) error {
+	if c.server.acl != nil && !c.server.acl.Allowed(c.identity, op, key) {
+		return fmt.Errorf("%s may not %s %q", c.identity, op, key)
+	}
+	return nil
+}
+
+//===========================================================================
+// Operations shared by the text and binary protocols
+//===========================================================================
+
+// An item returned by a get.
+type mcItem struct {
+	value []byte
+	flags uint32
+	cas   uint64 // the version of the value if the store is a Versioner
+}
+
+// Gets the item stored for the key.
+func (c *mcConn) get(key string) (item mcItem, status mcStatus, err error) {
+	if err = c.allow(OpGet, key); err != nil {
+		return item, mcAuthError, err
+	}
+
+	if versioner, ok := speedmap.Unwrap(c.server.kv).(speedmap.Versioner); ok {
+		item.value, item.cas, err = versioner.GetVersion(key)
+	} else {
+		item.value, err = c.server.kv.Get(key)
+	}
+
+	switch {
+	case errors.Is(err, speedmap.ErrNotFound):
+		return item, mcNotFound, nil
+	case err != nil:
+		return item, mcInternal, err
+	}
+
+	item.flags = c.server.flags.get(key)
+	return item, mcOK, nil
+}
+
+// Stores the value with a set, add or cas command. Adds use GetOrCreate, so
+// an add with an expiration sets the expiration after the value is created
+// and is not atomic with respect to other writes to the key.
+func (c *mcConn) store(cmd, key string, value []byte, flags uint32, exptime int64, cas uint64) (status mcStatus, err error) {
+	if err = c.allow(OpPut, key); err != nil {
+		return mcAuthError, err
+	}
+
+	var expirer speedmap.Expirer
+	ttl := mcTTL(exptime)
+	if ttl > 0 {
+		var ok bool
+		if expirer, ok = speedmap.Unwrap(c.server.kv).(speedmap.Expirer); !ok {
+			return mcNotSupported, fmt.Errorf("the %s store does not support expiration", c.server.kv)
+		}
+	}
+
+	switch cmd {
+	case "add":
+		if _, created := c.server.kv.GetOrCreate(key, value); !created {
+			return mcNotStored, nil
+		}
+
+		if ttl > 0 {
+			if err = expirer.PutWithTTL(key, value, ttl); err != nil {
+				return mcInternal, err
+			}
+		}
+	case "cas":
+		swapper, ok := speedmap.Unwrap(c.server.kv).(speedmap.Swapper)
+		if !ok {
+			return mcNotSupported, fmt.Errorf("the %s store does not support cas", c.server.kv)
+		}
+
+		if ttl > 0 {
+			return mcNotSupported, errors.New("cas with an expiration is not supported")
+		}
+
+		var swapped bool
+		swapped, err = swapper.CompareAndSwap(key, cas, value)
+		switch {
+		case errors.Is(err, speedmap.ErrNotFound):
+			return mcNotFound, nil
+		case err != nil:
+			return mcInternal, err
+		case !swapped:
+			return mcExists, nil
+		}
+	default:
+		if ttl > 0 {
+			err = expirer.PutWithTTL(key, value, ttl)
+		} else {
+			err = c.server.kv.Put(key, value)
+		}
+
+		if err != nil {
+			return mcInternal, err
+		}
+	}
+
+	c.server.flags.set(key, flags)
+	return mcOK, nil
+}
+
+// Deletes the key from the store.
+func (c *mcConn) delete(key string) (status mcStatus, err error) {
+	if err = c.allow(OpDelete, key); err != nil {
+		return mcAuthError, err
+	}
+
+	if _, err = c.server.kv.Get(key); err != nil {
+		if errors.Is(err, speedmap.ErrNotFound) {
+			return mcNotFound, nil
+		}
+		return mcInternal, err
+	}
+
+	if err = c.server.kv.Delete(key); err != nil {
+		return mcInternal, err
+	}
+
+	c.server.flags.set(key, 0)
+	return mcOK, nil
+}
+
+// Converts a memcached expiration time to a TTL, which is zero if the value
+// never expires. Values with an expiration time in the past are stored with
+// the smallest TTL so that they expire immediately.
+func mcTTL(exptime int64) time.Duration {
+	var ttl time.Duration
+	switch {
+	case exptime == 0:
+		return 0
+	case exptime > mcMaxRelativeExpiration:
+		ttl = time.Until(time.Unix(exptime, 0))
+	default:
+		ttl = time.Duration(exptime) * time.Second
+	}
+
+	if ttl <= 0 {
+		return time.Nanosecond
+	}
+	return ttl
+}
+
+// The flags of the values stored by memcached clients, which are kept by the
+// server since stores only hold values. Only nonzero flags are kept, and they
+// are not updated by writes from other clients.
+type mcFlags struct {
+	sync.RWMutex
+	flags map[string]uint32
+}
+
+// Returns the flags of the key, zero if none were set.
+func (f *mcFlags) get(key string) uint32 {
+	f.RLock()
+	defer f.RUnlock()
+	return f.flags[key]
+}
+
+// Sets the flags of the key, forgetting the key if the flags are zero.
+func (f *mcFlags) set(key string, flags uint32) {
+	f.Lock()
+	defer f.Unlock()
+
+	if flags == 0 {
+		delete(f.flags, key)
+		return
+	}
+
+	if f.flags == nil {
+		f.flags = make(map[string]uint32)
+	}
+	f.flags[key] = flags
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Magic bytes of binary protocol requests and responses.
+const (
+	mcRequestMagic  = 0x80
+	mcResponseMagic = 0x81
+)
+
+// Opcodes of the binary protocol that are supported; the opcodes with a Q
+// suffix are quiet and only reply on failure (or, for gets, on a hit).
+const (
+	mcOpGet      = 0x00
+	mcOpSet      = 0x01
+	mcOpAdd      = 0x02
+	mcOpDelete   = 0x04
+	mcOpQuit     = 0x07
+	mcOpGetQ     = 0x09
+	mcOpNoop     = 0x0a
+	mcOpVersion  = 0x0b
+	mcOpGetK     = 0x0c
+	mcOpGetKQ    = 0x0d
+	mcOpSetQ     = 0x11
+	mcOpAddQ     = 0x12
+	mcOpDeleteQ  = 0x14
+	mcOpQuitQ    = 0x17
+	mcOpSASLList = 0x20
+	mcOpSASLAuth = 0x21
+)
+
+// The size of the header of binary protocol requests and responses.
+const mcHeaderSize = 24
+
+// The header of a binary protocol request.
+type mcHeader struct {
+	opcode  uint8
+	keylen  uint16
+	extlen  uint8
+	bodylen uint32
+	opaque  uint32
+	cas     uint64
+}
+
+// Handles binary protocol requests until the connection is closed.
+func (c *mcConn) serveBinary() {
+	var buf [mcHeaderSize]byte
+	for {
+		select {
+		case <-c.server.done:
+			c.w.Flush()
+			return
+		default:
+		}
+
+		if _, err := io.ReadFull(c.r, buf[:]); err != nil || buf[0] != mcRequestMagic {
+			c.w.Flush()
+			return
+		}
+
+		req := &mcHeader{
+			opcode:  buf[1],
+			keylen:  binary.BigEndian.Uint16(buf[2:4]),
+			extlen:  buf[4],
+			bodylen: binary.BigEndian.Uint32(buf[8:12]),
+			opaque:  binary.BigEndian.Uint32(buf[12:16]),
+			cas:     binary.BigEndian.Uint64(buf[16:24]),
+		}
+
+		if req.bodylen < uint32(req.keylen)+uint32(req.extlen) {
+			c.w.Flush()
+			return
+		}
+
+		// Requests that are too large are read and discarded
+		if req.bodylen > mcMaxValue+mcMaxKey+64 {
+			if _, err := io.CopyN(ioutil.Discard, c.r, int64(req.bodylen)); err != nil {
+				return
+			}
+			c.binaryError(req, mcTooLarge, "Too large.")
+		} else {
+			body := make([]byte, req.bodylen)
+			if _, err := io.ReadFull(c.r, body); err != nil {
+				return
+			}
+
+			extras := body[:req.extlen]
+			key := body[req.extlen : int(req.extlen)+int(req.keylen)]
+			value := body[int(req.extlen)+int(req.keylen):]
+
+			if quit := c.binary(req, extras, string(key), value); quit {
+				c.w.Flush()
+				return
+			}
+		}
+
+		// Flush once there are no more pipelined requests to handle
+		if c.r.Buffered() == 0 {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Handles a binary protocol request, returning true if the connection should
+// be closed.
+func (c *mcConn) binary(req *mcHeader, extras []byte, key string, value []byte) (quit bool) {
+	switch req.opcode {
+	case mcOpQuit:
+		c.binaryReply(req, mcOK, nil, "", nil, 0)
+		return true
+	case mcOpQuitQ:
+		return true
+	case mcOpNoop:
+		c.binaryReply(req, mcOK, nil, "", nil, 0)
+		return false
+	case mcOpVersion:
+		c.binaryReply(req, mcOK, nil, "", []byte(speedmap.Version), 0)
+		return false
+	case mcOpSASLList:
+		c.binaryReply(req, mcOK, nil, "", []byte("PLAIN"), 0)
+		return false
+	case mcOpSASLAuth:
+		c.binarySASL(req, key, value)
+		return false
+	case mcOpGet, mcOpGetQ, mcOpGetK, mcOpGetKQ:
+	case mcOpSet, mcOpSetQ, mcOpAdd, mcOpAddQ:
+	case mcOpDelete, mcOpDeleteQ:
+	default:
+		c.binaryError(req, mcUnknown, "Unknown command")
+		return false
+	}
+
+	if len(key) == 0 || len(key) > mcMaxKey {
+		c.binaryError(req, mcInvalid, "Invalid arguments")
+		return false
+	}
+
+	if err := c.authenticated(); err != nil {
+		c.binaryError(req, mcAuthError, err.Error())
+		return false
+	}
+
+	c.server.count(c.identity)
+	switch req.opcode {
+	case mcOpGet, mcOpGetQ, mcOpGetK, mcOpGetKQ:
+		c.binaryGet(req, key)
+	case mcOpSet, mcOpSetQ, mcOpAdd, mcOpAddQ:
+		c.binaryStore(req, extras, key, value)
+	case mcOpDelete, mcOpDeleteQ:
+		c.binaryDelete(req, key)
+	}
+	return false
+}
+
+// Get, GetQ, GetK and GetKQ reply with the flags as extras and the version of
+// the value as the cas; the key is included in the reply by GetK and GetKQ.
+func (c *mcConn) binaryGet(req *mcHeader, key string) {
+	quiet := req.opcode == mcOpGetQ || req.opcode == mcOpGetKQ
+	item, status, err := c.get(key)
+	switch status {
+	case mcOK:
+	case mcNotFound:
+		if !quiet {
+			c.binaryError(req, status, "Not found")
+		}
+		return
+	default:
+		c.binaryError(req, status, err.Error())
+		return
+	}
+
+	var extras [4]byte
+	binary.BigEndian.PutUint32(extras[:], item.flags)
+
+	if req.opcode != mcOpGetK && req.opcode != mcOpGetKQ {
+		key = ""
+	}
+	c.binaryReply(req, mcOK, extras[:], key, item.value, item.cas)
+}
+
+// Set, SetQ, Add and AddQ have the flags and expiration time as extras. A set
+// with a cas is a compare-and-swap; the reply has the version of the stored
+// value as the cas.
+func (c *mcConn) binaryStore(req *mcHeader, extras []byte, key string, value []byte) {
+	if len(extras) != 8 {
+		c.binaryError(req, mcInvalid, "Invalid arguments")
+		return
+	}
+
+	flags := binary.BigEndian.Uint32(extras[0:4])
+	exptime := int64(binary.BigEndian.Uint32(extras[4:8]))
+
+	cmd := "set"
+	switch {
+	case req.opcode == mcOpAdd || req.opcode == mcOpAddQ:
+		cmd = "add"
+	case req.cas != 0:
+		cmd = "cas"
+	}
+
+	status, err := c.store(cmd, key, value, flags, exptime, req.cas)
+	switch status {
+	case mcOK:
+		if req.opcode == mcOpSet || req.opcode == mcOpAdd {
+			c.binaryReply(req, mcOK, nil, "", nil, c.server.version(key))
+		}
+	case mcNotStored, mcExists:
+		c.binaryError(req, mcExists, "Data exists for key.")
+	case mcNotFound:
+		c.binaryError(req, mcNotFound, "Not found")
+	default:
+		c.binaryError(req, status, err.Error())
+	}
+}
+
+// Delete and DeleteQ; deletes conditional on a cas are not supported.
+func (c *mcConn) binaryDelete(req *mcHeader, key string) {
+	if req.cas != 0 {
+		c.binaryError(req, mcNotSupported, "Not supported")
+		return
+	}
+
+	status, err := c.delete(key)
+	switch status {
+	case mcOK:
+		if req.opcode == mcOpDelete {
+			c.binaryReply(req, mcOK, nil, "", nil, 0)
+		}
+	case mcNotFound:
+		c.binaryError(req, status, "Not found")
+	default:
+		c.binaryError(req, status, err.Error())
+	}
+}
+
+// Authenticates the client with the PLAIN mechanism, in which the value is
+// the authorization identity, user and password separated by null bytes. The
+// password is the token of the client; the user is ignored.
+func (c *mcConn) binarySASL(req *mcHeader, mechanism string, value []byte) {
+	if c.server.auth == nil {
+		c.binaryReply(req, mcOK, nil, "", []byte("Authenticated"), 0)
+		return
+	}
+
+	parts := bytes.Split(value, []byte{0})
+	if mechanism != "PLAIN" || len(parts) != 3 || !c.authenticate(string(parts[2])) {
+		c.binaryError(req, mcAuthError, "Auth failure")
+		return
+	}
+	c.binaryReply(req, mcOK, nil, "", []byte("Authenticated"), 0)
+}
+
+// Writes a reply to the request with the status and an error message.
+func (c *mcConn) binaryError(req *mcHeader, status mcStatus, msg string) {
+	c.binaryReply(req, status, nil, "", []byte(msg), 0)
+}
+
+// Writes a reply to the request.
+func (c *mcConn) binaryReply(req *mcHeader, status mcStatus, extras []byte, key string, value []byte, cas uint64) {
+	var buf [mcHeaderSize]byte
+	buf[0] = mcResponseMagic
+	buf[1] = req.opcode
+	binary.BigEndian.PutUint16(buf[2:4], uint16(len(key)))
+	buf[4] = uint8(len(extras))
+	binary.BigEndian.PutUint16(buf[6:8], uint16(status))
+	binary.BigEndian.PutUint32(buf[8:12], uint32(len(extras)+len(key)+len(value)))
+	binary.BigEndian.PutUint32(buf[12:16], req.opaque)
+	binary.BigEndian.PutUint64(buf[16:24], cas)
+
+	c.w.Write(buf[:])
+	c.w.Write(extras)
+	c.w.WriteString(key)
+	c.w.Write(value)
+}
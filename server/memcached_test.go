@@ -0,0 +1,191 @@
+package server_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/server"
+	"github.com/bbengfort/speedmap/store"
+)
+
+var _ = Describe("Memcached", func() {
+
+	var (
+		kv   *store.Versioned
+		srv  *Server
+		conn *pipe
+	)
+
+	// Returns the cas unique of the key from a gets command.
+	gets := func(key string) (cas uint64) {
+		conn.send("gets " + key + "\r\n")
+
+		var (
+			name  string
+			flags uint32
+			size  int
+		)
+		_, err := fmt.Sscanf(conn.line(), "VALUE %s %d %d %d", &name, &flags, &size, &cas)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(name).Should(Equal(key))
+
+		conn.line()
+		conn.expect("END\r\n")
+		return cas
+	}
+
+	BeforeEach(func() {
+		var err error
+		kv, err = store.NewVersioned()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		srv = New(kv)
+		conn = newPipe(srv.ServeMemcached)
+	})
+
+	AfterEach(func() {
+		conn.Close()
+	})
+
+	It("should store and get values", func() {
+		conn.send("set foo 42 0 3\r\nbar\r\n")
+		conn.expect("STORED\r\n")
+
+		conn.send("get foo missing\r\n")
+		conn.expect("VALUE foo 42 3\r\nbar\r\nEND\r\n")
+
+		val, err := kv.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		conn.send("delete foo\r\n")
+		conn.expect("DELETED\r\n")
+
+		conn.send("delete foo\r\n")
+		conn.expect("NOT_FOUND\r\n")
+
+		conn.send("get foo\r\n")
+		conn.expect("END\r\n")
+	})
+
+	It("should store data blocks with binary data and CRLFs", func() {
+		value := "a\r\nb\x00c\r\n"
+		conn.send(fmt.Sprintf("set foo 0 0 %d\r\n%s\r\n", len(value), value))
+		conn.expect("STORED\r\n")
+
+		conn.send("get foo\r\n")
+		conn.expect(fmt.Sprintf("VALUE foo 0 %d\r\n%s\r\nEND\r\n", len(value), value))
+	})
+
+	It("should only add keys that do not exist", func() {
+		conn.send("add foo 0 0 3\r\nbar\r\n")
+		conn.expect("STORED\r\n")
+
+		conn.send("add foo 0 0 3\r\nbaz\r\n")
+		conn.expect("NOT_STORED\r\n")
+
+		conn.send("get foo\r\n")
+		conn.expect("VALUE foo 0 3\r\nbar\r\nEND\r\n")
+	})
+
+	It("should compare and swap with the cas unique from gets", func() {
+		conn.send("cas foo 0 0 3 1\r\nbar\r\n")
+		conn.expect("NOT_FOUND\r\n")
+
+		conn.send("set foo 0 0 3\r\nbar\r\n")
+		conn.expect("STORED\r\n")
+
+		cas := gets("foo")
+		conn.send(fmt.Sprintf("cas foo 0 0 3 %d\r\nbaz\r\n", cas))
+		conn.expect("STORED\r\n")
+
+		// The cas unique changed with the swap
+		conn.send(fmt.Sprintf("cas foo 0 0 3 %d\r\nqux\r\n", cas))
+		conn.expect("EXISTS\r\n")
+
+		Ω(gets("foo")).Should(BeNumerically(">", cas))
+		conn.send("get foo\r\n")
+		conn.expect("VALUE foo 0 3\r\nbaz\r\nEND\r\n")
+	})
+
+	It("should not reply to commands with noreply", func() {
+		conn.send("set foo 0 0 3 noreply\r\nbar\r\n")
+		conn.send("add foo 0 0 3 noreply\r\nbaz\r\n")
+		conn.send("delete missing noreply\r\n")
+		conn.send("delete foo 0 noreply\r\n")
+		conn.send("set bar 0 0 3 noreply\r\nbar\r\n")
+		conn.send("get foo bar\r\n")
+		conn.expect("VALUE bar 0 3\r\nbar\r\nEND\r\n")
+	})
+
+	It("should reply to pipelined commands in order", func() {
+		var req, reply strings.Builder
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key%d", i)
+			req.WriteString(fmt.Sprintf("set %s 0 0 %d\r\n%s\r\nget %s\r\n", key, len(key), key, key))
+			reply.WriteString(fmt.Sprintf("STORED\r\nVALUE %s 0 %d\r\n%s\r\nEND\r\n", key, len(key), key))
+		}
+		req.WriteString("quit\r\n")
+
+		conn.send(req.String())
+		conn.expect(reply.String())
+		conn.expectClosed()
+	})
+
+	It("should reject malformed command lines", func() {
+		conn.send("set foo 0 0\r\n")
+		conn.expect("ERROR\r\n")
+
+		conn.send("set foo 0 0 abc\r\nbar\r\n")
+		conn.expect("CLIENT_ERROR bad command line format\r\n")
+		conn.expect("ERROR\r\n") // the data block is read as a command
+
+		conn.send("set foo 0 0 -1\r\n")
+		conn.expect("CLIENT_ERROR bad command line format\r\n")
+
+		conn.send("cas foo 0 0 3 abc\r\n")
+		conn.expect("CLIENT_ERROR bad command line format\r\n")
+
+		conn.send(fmt.Sprintf("get %s\r\n", strings.Repeat("a", 251)))
+		conn.expect("CLIENT_ERROR bad command line format\r\n")
+
+		conn.send("incr foo 1\r\n")
+		conn.expect("ERROR\r\n")
+
+		conn.send("version\r\n")
+		conn.expect("VERSION " + speedmap.Version + "\r\n")
+	})
+
+	It("should close the connection after a data block longer than its length", func() {
+		conn.send("set foo 0 0 2\r\nbar\r\n")
+		conn.expect("CLIENT_ERROR bad data chunk\r\n")
+		conn.expectClosed()
+
+		_, err := kv.Get("foo")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+	})
+
+	It("should close the connection after a truncated data block", func() {
+		conn.send("set foo 0 0 10\r\nbar")
+		Ω(conn.Close()).Should(Succeed())
+		Eventually(conn.done).Should(BeClosed())
+
+		_, err := kv.Get("foo")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+	})
+
+	It("should discard values that are too large", func() {
+		value := strings.Repeat("x", 1024*1024+1)
+		conn.send(fmt.Sprintf("set foo 0 0 %d\r\n%s\r\n", len(value), value))
+		conn.expect("SERVER_ERROR object too large for cache\r\n")
+
+		conn.send("get foo\r\n")
+		conn.expect("END\r\n")
+	})
+
+})
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Handles text protocol commands until the connection is closed.
+func (c *mcConn) serveText() {
+	for {
+		select {
+		case <-c.server.done:
+			c.w.Flush()
+			return
+		default:
+		}
+
+		line, err := readLine(c.r, mcMaxLine)
+		if err != nil {
+			if err == errLineTooLong {
+				c.w.WriteString("CLIENT_ERROR line too long\r\n")
+			}
+			c.w.Flush()
+			return
+		}
+
+		quit := c.text(strings.Fields(string(line)))
+
+		// Flush once there are no more pipelined commands to handle
+		if quit || c.r.Buffered() == 0 {
+			if err = c.w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// Handles a text protocol command, returning true if the connection should
+// be closed.
+func (c *mcConn) text(fields []string) (quit bool) {
+	if len(fields) == 0 {
+		c.w.WriteString("ERROR\r\n")
+		return false
+	}
+
+	switch fields[0] {
+	case "quit":
+		return true
+	case "version":
+		c.w.WriteString("VERSION " + speedmap.Version + "\r\n")
+		return false
+	case "get", "gets", "set", "add", "cas", "delete":
+	default:
+		c.w.WriteString("ERROR\r\n")
+		return false
+	}
+
+	if err := c.authenticated(); err != nil {
+		c.clientError(err.Error())
+		return false
+	}
+
+	c.server.count(c.identity)
+	switch fields[0] {
+	case "get", "gets":
+		c.textGet(fields)
+	case "set", "add", "cas":
+		return c.textStore(fields)
+	case "delete":
+		c.textDelete(fields)
+	}
+	return false
+}
+
+// get <key>*
+// gets <key>*
+func (c *mcConn) textGet(fields []string) {
+	if len(fields) < 2 {
+		c.w.WriteString("ERROR\r\n")
+		return
+	}
+
+	for _, key := range fields[1:] {
+		if len(key) > mcMaxKey {
+			c.clientError("bad command line format")
+			return
+		}
+	}
+
+	for _, key := range fields[1:] {
+		item, status, err := c.get(key)
+		switch status {
+		case mcOK:
+		case mcNotFound:
+			continue
+		default:
+			c.textError(status, err)
+			return
+		}
+
+		c.w.WriteString("VALUE ")
+		c.w.WriteString(key)
+		c.w.WriteByte(' ')
+		c.w.WriteString(strconv.FormatUint(uint64(item.flags), 10))
+		c.w.WriteByte(' ')
+		c.w.WriteString(strconv.Itoa(len(item.value)))
+		if fields[0] == "gets" {
+			c.w.WriteByte(' ')
+			c.w.WriteString(strconv.FormatUint(item.cas, 10))
+		}
+		c.w.WriteString("\r\n")
+		c.w.Write(item.value)
+		c.w.WriteString("\r\n")
+	}
+	c.w.WriteString("END\r\n")
+}
+
+// set <key> <flags> <exptime> <bytes> [noreply]
+// add <key> <flags> <exptime> <bytes> [noreply]
+// cas <key> <flags> <exptime> <bytes> <cas unique> [noreply]
+//
+// The data block follows the command line. Returns true if the connection
+// should be closed because the data block could not be read.
+func (c *mcConn) textStore(fields []string) (quit bool) {
+	nargs := 5
+	if fields[0] == "cas" {
+		nargs = 6
+	}
+
+	noreply := len(fields) == nargs+1 && fields[nargs] == "noreply"
+	if len(fields) != nargs && !noreply {
+		c.w.WriteString("ERROR\r\n")
+		return false
+	}
+
+	var (
+		flags   uint64
+		exptime int64
+		size    int
+		cas     uint64
+		errs    [4]error
+	)
+
+	flags, errs[0] = strconv.ParseUint(fields[2], 10, 32)
+	exptime, errs[1] = strconv.ParseInt(fields[3], 10, 64)
+	size, errs[2] = strconv.Atoi(fields[4])
+	if fields[0] == "cas" {
+		cas, errs[3] = strconv.ParseUint(fields[5], 10, 64)
+	}
+
+	for _, err := range errs {
+		if err != nil || len(fields[1]) > mcMaxKey || size < 0 {
+			c.clientError("bad command line format")
+			return false
+		}
+	}
+
+	// Values that are too large are read and discarded
+	if size > mcMaxValue {
+		if _, err := io.CopyN(ioutil.Discard, c.r, int64(size)+2); err != nil {
+			return true
+		}
+		c.w.WriteString("SERVER_ERROR object too large for cache\r\n")
+		return false
+	}
+
+	value := make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, value); err != nil {
+		return true
+	}
+
+	if value[size] != '\r' || value[size+1] != '\n' {
+		c.clientError("bad data chunk")
+		return true
+	}
+
+	status, err := c.store(fields[0], fields[1], value[:size], uint32(flags), exptime, cas)
+	if noreply {
+		return false
+	}
+
+	switch status {
+	case mcOK:
+		c.w.WriteString("STORED\r\n")
+	case mcNotStored:
+		c.w.WriteString("NOT_STORED\r\n")
+	case mcExists:
+		c.w.WriteString("EXISTS\r\n")
+	case mcNotFound:
+		c.w.WriteString("NOT_FOUND\r\n")
+	default:
+		c.textError(status, err)
+	}
+	return false
+}
+
+// delete <key> [noreply]
+func (c *mcConn) textDelete(fields []string) {
+	// Older clients send a hold time of zero before noreply
+	if len(fields) > 2 && fields[2] == "0" {
+		fields = append(fields[:2], fields[3:]...)
+	}
+
+	noreply := len(fields) == 3 && fields[2] == "noreply"
+	if len(fields) != 2 && !noreply {
+		c.clientError("bad command line format")
+		return
+	}
+
+	if len(fields[1]) > mcMaxKey {
+		c.clientError("bad command line format")
+		return
+	}
+
+	status, err := c.delete(fields[1])
+	if noreply {
+		return
+	}
+
+	switch status {
+	case mcOK:
+		c.w.WriteString("DELETED\r\n")
+	case mcNotFound:
+		c.w.WriteString("NOT_FOUND\r\n")
+	default:
+		c.textError(status, err)
+	}
+}
+
+// Writes the error of a failed operation, as a client error if the client
+// was not allowed to make it, otherwise as a server error.
+func (c *mcConn) textError(status mcStatus, err error) {
+	if status == mcAuthError {
+		c.clientError(err.Error())
+		return
+	}
+
+	c.w.WriteString("SERVER_ERROR ")
+	c.w.WriteString(strings.NewReplacer("\r", " ", "\n", " ").Replace(err.Error()))
+	c.w.WriteString("\r\n")
+}
+
+func (c *mcConn) clientError(msg string) {
+	c.w.WriteString("CLIENT_ERROR ")
+	c.w.WriteString(msg)
+	c.w.WriteString("\r\n")
+}
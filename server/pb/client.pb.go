@@ -5,15 +5,46 @@
 Package pb is a generated protocol buffer package.
 
 It is generated from these files:
+
 	client.proto
 	service.proto
 
 It has these top-level messages:
+
 	GetRequest
 	PutRequest
 	DelRequest
+	BatchGetRequest
+	BatchPutRequest
 	ClientReply
+	BatchReply
+	WatchRequest
+	WatchEvent
+	StreamRequest
+	StreamReply
 	KVPair
+	ClusterRequest
+	ClusterReply
+	Member
+	VoteRequest
+	VoteReply
+	AppendRequest
+	AppendReply
+	Entry
+	FollowRequest
+	Mutation
+	SnapshotRequest
+	SnapshotReply
+	StatsRequest
+	StatsReply
+	ClientRequests
+	FlushRequest
+	FlushReply
+	SwapRequest
+	SwapReply
+	ReplicationRequest
+	PromoteRequest
+	ReplicationReply
 */
 package pb
 
@@ -32,6 +63,57 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type WatchEvent_Type int32
+
+const (
+	WatchEvent_PUT    WatchEvent_Type = 0
+	WatchEvent_DELETE WatchEvent_Type = 1
+)
+
+var WatchEvent_Type_name = map[int32]string{
+	0: "PUT",
+	1: "DELETE",
+}
+var WatchEvent_Type_value = map[string]int32{
+	"PUT":    0,
+	"DELETE": 1,
+}
+
+func (x WatchEvent_Type) String() string {
+	return proto.EnumName(WatchEvent_Type_name, int32(x))
+}
+func (WatchEvent_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{8, 0} }
+
+type Mutation_Op int32
+
+const (
+	Mutation_PUT       Mutation_Op = 0
+	Mutation_DELETE    Mutation_Op = 1
+	Mutation_RESET     Mutation_Op = 2
+	Mutation_SYNCED    Mutation_Op = 3
+	Mutation_HEARTBEAT Mutation_Op = 4
+)
+
+var Mutation_Op_name = map[int32]string{
+	0: "PUT",
+	1: "DELETE",
+	2: "RESET",
+	3: "SYNCED",
+	4: "HEARTBEAT",
+}
+var Mutation_Op_value = map[string]int32{
+	"PUT":       0,
+	"DELETE":    1,
+	"RESET":     2,
+	"SYNCED":    3,
+	"HEARTBEAT": 4,
+}
+
+func (x Mutation_Op) String() string {
+	return proto.EnumName(Mutation_Op_name, int32(x))
+}
+func (Mutation_Op) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{21, 0} }
+
 type GetRequest struct {
 	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
 	Key      string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
@@ -59,6 +141,7 @@ func (m *GetRequest) GetKey() string {
 type PutRequest struct {
 	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
 	Key      string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	Ttl      int64  `protobuf:"varint,3,opt,name=ttl" json:"ttl,omitempty"`
 	Value    []byte `protobuf:"bytes,7,opt,name=value,proto3" json:"value,omitempty"`
 }
 
@@ -81,6 +164,13 @@ func (m *PutRequest) GetKey() string {
 	return ""
 }
 
+func (m *PutRequest) GetTtl() int64 {
+	if m != nil {
+		return m.Ttl
+	}
+	return 0
+}
+
 func (m *PutRequest) GetValue() []byte {
 	if m != nil {
 		return m.Value
@@ -120,6 +210,54 @@ func (m *DelRequest) GetForce() bool {
 	return false
 }
 
+type BatchGetRequest struct {
+	Identity string   `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+	Keys     []string `protobuf:"bytes,2,rep,name=keys" json:"keys,omitempty"`
+}
+
+func (m *BatchGetRequest) Reset()                    { *m = BatchGetRequest{} }
+func (m *BatchGetRequest) String() string            { return proto.CompactTextString(m) }
+func (*BatchGetRequest) ProtoMessage()               {}
+func (*BatchGetRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *BatchGetRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+func (m *BatchGetRequest) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+type BatchPutRequest struct {
+	Identity string    `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+	Pairs    []*KVPair `protobuf:"bytes,7,rep,name=pairs" json:"pairs,omitempty"`
+}
+
+func (m *BatchPutRequest) Reset()                    { *m = BatchPutRequest{} }
+func (m *BatchPutRequest) String() string            { return proto.CompactTextString(m) }
+func (*BatchPutRequest) ProtoMessage()               {}
+func (*BatchPutRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *BatchPutRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+func (m *BatchPutRequest) GetPairs() []*KVPair {
+	if m != nil {
+		return m.Pairs
+	}
+	return nil
+}
+
 type ClientReply struct {
 	Success  bool    `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
 	Redirect string  `protobuf:"bytes,2,opt,name=redirect" json:"redirect,omitempty"`
@@ -130,7 +268,7 @@ type ClientReply struct {
 func (m *ClientReply) Reset()                    { *m = ClientReply{} }
 func (m *ClientReply) String() string            { return proto.CompactTextString(m) }
 func (*ClientReply) ProtoMessage()               {}
-func (*ClientReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*ClientReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *ClientReply) GetSuccess() bool {
 	if m != nil {
@@ -160,16 +298,189 @@ func (m *ClientReply) GetPair() *KVPair {
 	return nil
 }
 
+type BatchReply struct {
+	Success  bool      `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Redirect string    `protobuf:"bytes,2,opt,name=redirect" json:"redirect,omitempty"`
+	Error    string    `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Pairs    []*KVPair `protobuf:"bytes,7,rep,name=pairs" json:"pairs,omitempty"`
+}
+
+func (m *BatchReply) Reset()                    { *m = BatchReply{} }
+func (m *BatchReply) String() string            { return proto.CompactTextString(m) }
+func (*BatchReply) ProtoMessage()               {}
+func (*BatchReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *BatchReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *BatchReply) GetRedirect() string {
+	if m != nil {
+		return m.Redirect
+	}
+	return ""
+}
+
+func (m *BatchReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *BatchReply) GetPairs() []*KVPair {
+	if m != nil {
+		return m.Pairs
+	}
+	return nil
+}
+
+type WatchRequest struct {
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+	Key      string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	Prefix   bool   `protobuf:"varint,3,opt,name=prefix" json:"prefix,omitempty"`
+}
+
+func (m *WatchRequest) Reset()                    { *m = WatchRequest{} }
+func (m *WatchRequest) String() string            { return proto.CompactTextString(m) }
+func (*WatchRequest) ProtoMessage()               {}
+func (*WatchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *WatchRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+func (m *WatchRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *WatchRequest) GetPrefix() bool {
+	if m != nil {
+		return m.Prefix
+	}
+	return false
+}
+
+// A change to an object on the server, streamed to watchers
+type WatchEvent struct {
+	Type WatchEvent_Type `protobuf:"varint,1,opt,name=type,enum=pb.WatchEvent_Type" json:"type,omitempty"`
+	Pair *KVPair         `protobuf:"bytes,2,opt,name=pair" json:"pair,omitempty"`
+}
+
+func (m *WatchEvent) Reset()                    { *m = WatchEvent{} }
+func (m *WatchEvent) String() string            { return proto.CompactTextString(m) }
+func (*WatchEvent) ProtoMessage()               {}
+func (*WatchEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *WatchEvent) GetType() WatchEvent_Type {
+	if m != nil {
+		return m.Type
+	}
+	return WatchEvent_PUT
+}
+
+func (m *WatchEvent) GetPair() *KVPair {
+	if m != nil {
+		return m.Pair
+	}
+	return nil
+}
+
+// A request sent on a pipelined stream, exactly one of get, put or del is set
+type StreamRequest struct {
+	Id  uint64      `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	Get *GetRequest `protobuf:"bytes,2,opt,name=get" json:"get,omitempty"`
+	Put *PutRequest `protobuf:"bytes,3,opt,name=put" json:"put,omitempty"`
+	Del *DelRequest `protobuf:"bytes,4,opt,name=del" json:"del,omitempty"`
+}
+
+func (m *StreamRequest) Reset()                    { *m = StreamRequest{} }
+func (m *StreamRequest) String() string            { return proto.CompactTextString(m) }
+func (*StreamRequest) ProtoMessage()               {}
+func (*StreamRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *StreamRequest) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *StreamRequest) GetGet() *GetRequest {
+	if m != nil {
+		return m.Get
+	}
+	return nil
+}
+
+func (m *StreamRequest) GetPut() *PutRequest {
+	if m != nil {
+		return m.Put
+	}
+	return nil
+}
+
+func (m *StreamRequest) GetDel() *DelRequest {
+	if m != nil {
+		return m.Del
+	}
+	return nil
+}
+
+// The reply to a request on a pipelined stream, replies are not sent in the
+// order of their requests since requests are handled concurrently
+type StreamReply struct {
+	Id    uint64       `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	Code  uint32       `protobuf:"varint,2,opt,name=code" json:"code,omitempty"`
+	Reply *ClientReply `protobuf:"bytes,7,opt,name=reply" json:"reply,omitempty"`
+}
+
+func (m *StreamReply) Reset()                    { *m = StreamReply{} }
+func (m *StreamReply) String() string            { return proto.CompactTextString(m) }
+func (*StreamReply) ProtoMessage()               {}
+func (*StreamReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *StreamReply) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *StreamReply) GetCode() uint32 {
+	if m != nil {
+		return m.Code
+	}
+	return 0
+}
+
+func (m *StreamReply) GetReply() *ClientReply {
+	if m != nil {
+		return m.Reply
+	}
+	return nil
+}
+
 // Used for transmitting key/value pairs on the network
 type KVPair struct {
-	Key   string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
-	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Key     string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value   []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Version uint64 `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
 }
 
 func (m *KVPair) Reset()                    { *m = KVPair{} }
 func (m *KVPair) String() string            { return proto.CompactTextString(m) }
 func (*KVPair) ProtoMessage()               {}
-func (*KVPair) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*KVPair) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
 
 func (m *KVPair) GetKey() string {
 	if m != nil {
@@ -185,31 +496,1003 @@ func (m *KVPair) GetValue() []byte {
 	return nil
 }
 
+func (m *KVPair) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type ClusterRequest struct {
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+}
+
+func (m *ClusterRequest) Reset()                    { *m = ClusterRequest{} }
+func (m *ClusterRequest) String() string            { return proto.CompactTextString(m) }
+func (*ClusterRequest) ProtoMessage()               {}
+func (*ClusterRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *ClusterRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+// The members of the cluster, from which clients build the consistent-hash
+// ring that assigns each key to the member that owns it
+type ClusterReply struct {
+	Success    bool      `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error      string    `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Node       string    `protobuf:"bytes,4,opt,name=node" json:"node,omitempty"`
+	Vnodes     uint32    `protobuf:"varint,5,opt,name=vnodes" json:"vnodes,omitempty"`
+	Members    []*Member `protobuf:"bytes,7,rep,name=members" json:"members,omitempty"`
+	Replicated bool      `protobuf:"varint,8,opt,name=replicated" json:"replicated,omitempty"`
+	Leader     string    `protobuf:"bytes,9,opt,name=leader" json:"leader,omitempty"`
+	Primary    string    `protobuf:"bytes,10,opt,name=primary" json:"primary,omitempty"`
+}
+
+func (m *ClusterReply) Reset()                    { *m = ClusterReply{} }
+func (m *ClusterReply) String() string            { return proto.CompactTextString(m) }
+func (*ClusterReply) ProtoMessage()               {}
+func (*ClusterReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *ClusterReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ClusterReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ClusterReply) GetNode() string {
+	if m != nil {
+		return m.Node
+	}
+	return ""
+}
+
+func (m *ClusterReply) GetVnodes() uint32 {
+	if m != nil {
+		return m.Vnodes
+	}
+	return 0
+}
+
+func (m *ClusterReply) GetMembers() []*Member {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+func (m *ClusterReply) GetReplicated() bool {
+	if m != nil {
+		return m.Replicated
+	}
+	return false
+}
+
+func (m *ClusterReply) GetLeader() string {
+	if m != nil {
+		return m.Leader
+	}
+	return ""
+}
+
+func (m *ClusterReply) GetPrimary() string {
+	if m != nil {
+		return m.Primary
+	}
+	return ""
+}
+
+// A server that is a member of a cluster
+type Member struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Addr string `protobuf:"bytes,2,opt,name=addr" json:"addr,omitempty"`
+}
+
+func (m *Member) Reset()                    { *m = Member{} }
+func (m *Member) String() string            { return proto.CompactTextString(m) }
+func (*Member) ProtoMessage()               {}
+func (*Member) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *Member) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Member) GetAddr() string {
+	if m != nil {
+		return m.Addr
+	}
+	return ""
+}
+
+// Sent by a candidate to request the vote of the other members
+type VoteRequest struct {
+	Identity     string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+	Term         uint64 `protobuf:"varint,2,opt,name=term" json:"term,omitempty"`
+	LastLogIndex uint64 `protobuf:"varint,3,opt,name=last_log_index,json=lastLogIndex" json:"last_log_index,omitempty"`
+	LastLogTerm  uint64 `protobuf:"varint,4,opt,name=last_log_term,json=lastLogTerm" json:"last_log_term,omitempty"`
+}
+
+func (m *VoteRequest) Reset()                    { *m = VoteRequest{} }
+func (m *VoteRequest) String() string            { return proto.CompactTextString(m) }
+func (*VoteRequest) ProtoMessage()               {}
+func (*VoteRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *VoteRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+func (m *VoteRequest) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *VoteRequest) GetLastLogIndex() uint64 {
+	if m != nil {
+		return m.LastLogIndex
+	}
+	return 0
+}
+
+func (m *VoteRequest) GetLastLogTerm() uint64 {
+	if m != nil {
+		return m.LastLogTerm
+	}
+	return 0
+}
+
+type VoteReply struct {
+	Term    uint64 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	Granted bool   `protobuf:"varint,2,opt,name=granted" json:"granted,omitempty"`
+}
+
+func (m *VoteReply) Reset()                    { *m = VoteReply{} }
+func (m *VoteReply) String() string            { return proto.CompactTextString(m) }
+func (*VoteReply) ProtoMessage()               {}
+func (*VoteReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *VoteReply) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *VoteReply) GetGranted() bool {
+	if m != nil {
+		return m.Granted
+	}
+	return false
+}
+
+// Sent by the leader to replicate the entries of its log to a follower
+type AppendRequest struct {
+	Identity     string   `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+	Term         uint64   `protobuf:"varint,2,opt,name=term" json:"term,omitempty"`
+	PrevLogIndex uint64   `protobuf:"varint,3,opt,name=prev_log_index,json=prevLogIndex" json:"prev_log_index,omitempty"`
+	PrevLogTerm  uint64   `protobuf:"varint,4,opt,name=prev_log_term,json=prevLogTerm" json:"prev_log_term,omitempty"`
+	Entries      []*Entry `protobuf:"bytes,5,rep,name=entries" json:"entries,omitempty"`
+	LeaderCommit uint64   `protobuf:"varint,6,opt,name=leader_commit,json=leaderCommit" json:"leader_commit,omitempty"`
+}
+
+func (m *AppendRequest) Reset()                    { *m = AppendRequest{} }
+func (m *AppendRequest) String() string            { return proto.CompactTextString(m) }
+func (*AppendRequest) ProtoMessage()               {}
+func (*AppendRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *AppendRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+func (m *AppendRequest) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *AppendRequest) GetPrevLogIndex() uint64 {
+	if m != nil {
+		return m.PrevLogIndex
+	}
+	return 0
+}
+
+func (m *AppendRequest) GetPrevLogTerm() uint64 {
+	if m != nil {
+		return m.PrevLogTerm
+	}
+	return 0
+}
+
+func (m *AppendRequest) GetEntries() []*Entry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+func (m *AppendRequest) GetLeaderCommit() uint64 {
+	if m != nil {
+		return m.LeaderCommit
+	}
+	return 0
+}
+
+type AppendReply struct {
+	Term          uint64 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	Success       bool   `protobuf:"varint,2,opt,name=success" json:"success,omitempty"`
+	ConflictIndex uint64 `protobuf:"varint,3,opt,name=conflict_index,json=conflictIndex" json:"conflict_index,omitempty"`
+}
+
+func (m *AppendReply) Reset()                    { *m = AppendReply{} }
+func (m *AppendReply) String() string            { return proto.CompactTextString(m) }
+func (*AppendReply) ProtoMessage()               {}
+func (*AppendReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *AppendReply) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *AppendReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *AppendReply) GetConflictIndex() uint64 {
+	if m != nil {
+		return m.ConflictIndex
+	}
+	return 0
+}
+
+// An entry in the replicated log
+type Entry struct {
+	Index   uint64 `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Term    uint64 `protobuf:"varint,2,opt,name=term" json:"term,omitempty"`
+	Command []byte `protobuf:"bytes,3,opt,name=command,proto3" json:"command,omitempty"`
+}
+
+func (m *Entry) Reset()                    { *m = Entry{} }
+func (m *Entry) String() string            { return proto.CompactTextString(m) }
+func (*Entry) ProtoMessage()               {}
+func (*Entry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *Entry) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *Entry) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *Entry) GetCommand() []byte {
+	if m != nil {
+		return m.Command
+	}
+	return nil
+}
+
+// Sent by a backup to stream the mutations of the primary
+type FollowRequest struct {
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+	Epoch    uint64 `protobuf:"varint,2,opt,name=epoch" json:"epoch,omitempty"`
+	From     uint64 `protobuf:"varint,3,opt,name=from" json:"from,omitempty"`
+}
+
+func (m *FollowRequest) Reset()                    { *m = FollowRequest{} }
+func (m *FollowRequest) String() string            { return proto.CompactTextString(m) }
+func (*FollowRequest) ProtoMessage()               {}
+func (*FollowRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *FollowRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+func (m *FollowRequest) GetEpoch() uint64 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *FollowRequest) GetFrom() uint64 {
+	if m != nil {
+		return m.From
+	}
+	return 0
+}
+
+// A change to the store of the primary, or a message that controls the stream
+type Mutation struct {
+	Seq     uint64      `protobuf:"varint,1,opt,name=seq" json:"seq,omitempty"`
+	Op      Mutation_Op `protobuf:"varint,2,opt,name=op,enum=pb.Mutation_Op" json:"op,omitempty"`
+	Key     string      `protobuf:"bytes,3,opt,name=key" json:"key,omitempty"`
+	Value   []byte      `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	Expires int64       `protobuf:"varint,5,opt,name=expires" json:"expires,omitempty"`
+	Time    int64       `protobuf:"varint,6,opt,name=time" json:"time,omitempty"`
+	Head    uint64      `protobuf:"varint,7,opt,name=head" json:"head,omitempty"`
+	Epoch   uint64      `protobuf:"varint,8,opt,name=epoch" json:"epoch,omitempty"`
+}
+
+func (m *Mutation) Reset()                    { *m = Mutation{} }
+func (m *Mutation) String() string            { return proto.CompactTextString(m) }
+func (*Mutation) ProtoMessage()               {}
+func (*Mutation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *Mutation) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *Mutation) GetOp() Mutation_Op {
+	if m != nil {
+		return m.Op
+	}
+	return Mutation_PUT
+}
+
+func (m *Mutation) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *Mutation) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *Mutation) GetExpires() int64 {
+	if m != nil {
+		return m.Expires
+	}
+	return 0
+}
+
+func (m *Mutation) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+func (m *Mutation) GetHead() uint64 {
+	if m != nil {
+		return m.Head
+	}
+	return 0
+}
+
+func (m *Mutation) GetEpoch() uint64 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+type SnapshotRequest struct {
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+	Compress bool   `protobuf:"varint,2,opt,name=compress" json:"compress,omitempty"`
+}
+
+func (m *SnapshotRequest) Reset()                    { *m = SnapshotRequest{} }
+func (m *SnapshotRequest) String() string            { return proto.CompactTextString(m) }
+func (*SnapshotRequest) ProtoMessage()               {}
+func (*SnapshotRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *SnapshotRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+func (m *SnapshotRequest) GetCompress() bool {
+	if m != nil {
+		return m.Compress
+	}
+	return false
+}
+
+type SnapshotReply struct {
+	Success bool   `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error   string `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Path    string `protobuf:"bytes,4,opt,name=path" json:"path,omitempty"`
+	Keys    uint64 `protobuf:"varint,5,opt,name=keys" json:"keys,omitempty"`
+	Size    int64  `protobuf:"varint,6,opt,name=size" json:"size,omitempty"`
+}
+
+func (m *SnapshotReply) Reset()                    { *m = SnapshotReply{} }
+func (m *SnapshotReply) String() string            { return proto.CompactTextString(m) }
+func (*SnapshotReply) ProtoMessage()               {}
+func (*SnapshotReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *SnapshotReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *SnapshotReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *SnapshotReply) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *SnapshotReply) GetKeys() uint64 {
+	if m != nil {
+		return m.Keys
+	}
+	return 0
+}
+
+func (m *SnapshotReply) GetSize() int64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+type StatsRequest struct {
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+}
+
+func (m *StatsRequest) Reset()                    { *m = StatsRequest{} }
+func (m *StatsRequest) String() string            { return proto.CompactTextString(m) }
+func (*StatsRequest) ProtoMessage()               {}
+func (*StatsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *StatsRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+type StatsReply struct {
+	Success     bool              `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error       string            `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Store       string            `protobuf:"bytes,4,opt,name=store" json:"store,omitempty"`
+	Keys        int64             `protobuf:"varint,5,opt,name=keys" json:"keys,omitempty"`
+	Requests    []*ClientRequests `protobuf:"bytes,6,rep,name=requests" json:"requests,omitempty"`
+	Uptime      int64             `protobuf:"varint,7,opt,name=uptime" json:"uptime,omitempty"`
+	HeapAlloc   uint64            `protobuf:"varint,8,opt,name=heap_alloc,json=heapAlloc" json:"heap_alloc,omitempty"`
+	HeapObjects uint64            `protobuf:"varint,9,opt,name=heap_objects,json=heapObjects" json:"heap_objects,omitempty"`
+	Sys         uint64            `protobuf:"varint,10,opt,name=sys" json:"sys,omitempty"`
+	NumGc       uint32            `protobuf:"varint,11,opt,name=num_gc,json=numGc" json:"num_gc,omitempty"`
+}
+
+func (m *StatsReply) Reset()                    { *m = StatsReply{} }
+func (m *StatsReply) String() string            { return proto.CompactTextString(m) }
+func (*StatsReply) ProtoMessage()               {}
+func (*StatsReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *StatsReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *StatsReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *StatsReply) GetStore() string {
+	if m != nil {
+		return m.Store
+	}
+	return ""
+}
+
+func (m *StatsReply) GetKeys() int64 {
+	if m != nil {
+		return m.Keys
+	}
+	return 0
+}
+
+func (m *StatsReply) GetRequests() []*ClientRequests {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+func (m *StatsReply) GetUptime() int64 {
+	if m != nil {
+		return m.Uptime
+	}
+	return 0
+}
+
+func (m *StatsReply) GetHeapAlloc() uint64 {
+	if m != nil {
+		return m.HeapAlloc
+	}
+	return 0
+}
+
+func (m *StatsReply) GetHeapObjects() uint64 {
+	if m != nil {
+		return m.HeapObjects
+	}
+	return 0
+}
+
+func (m *StatsReply) GetSys() uint64 {
+	if m != nil {
+		return m.Sys
+	}
+	return 0
+}
+
+func (m *StatsReply) GetNumGc() uint32 {
+	if m != nil {
+		return m.NumGc
+	}
+	return 0
+}
+
+// The number of requests served to a client
+type ClientRequests struct {
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+	Requests uint64 `protobuf:"varint,2,opt,name=requests" json:"requests,omitempty"`
+}
+
+func (m *ClientRequests) Reset()                    { *m = ClientRequests{} }
+func (m *ClientRequests) String() string            { return proto.CompactTextString(m) }
+func (*ClientRequests) ProtoMessage()               {}
+func (*ClientRequests) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *ClientRequests) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+func (m *ClientRequests) GetRequests() uint64 {
+	if m != nil {
+		return m.Requests
+	}
+	return 0
+}
+
+type FlushRequest struct {
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+}
+
+func (m *FlushRequest) Reset()                    { *m = FlushRequest{} }
+func (m *FlushRequest) String() string            { return proto.CompactTextString(m) }
+func (*FlushRequest) ProtoMessage()               {}
+func (*FlushRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *FlushRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+type FlushReply struct {
+	Success bool   `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error   string `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Keys    uint64 `protobuf:"varint,4,opt,name=keys" json:"keys,omitempty"`
+}
+
+func (m *FlushReply) Reset()                    { *m = FlushReply{} }
+func (m *FlushReply) String() string            { return proto.CompactTextString(m) }
+func (*FlushReply) ProtoMessage()               {}
+func (*FlushReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+func (m *FlushReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *FlushReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *FlushReply) GetKeys() uint64 {
+	if m != nil {
+		return m.Keys
+	}
+	return 0
+}
+
+type SwapRequest struct {
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+	Store    string `protobuf:"bytes,2,opt,name=store" json:"store,omitempty"`
+}
+
+func (m *SwapRequest) Reset()                    { *m = SwapRequest{} }
+func (m *SwapRequest) String() string            { return proto.CompactTextString(m) }
+func (*SwapRequest) ProtoMessage()               {}
+func (*SwapRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *SwapRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+func (m *SwapRequest) GetStore() string {
+	if m != nil {
+		return m.Store
+	}
+	return ""
+}
+
+type SwapReply struct {
+	Success  bool   `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error    string `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Previous string `protobuf:"bytes,4,opt,name=previous" json:"previous,omitempty"`
+	Store    string `protobuf:"bytes,5,opt,name=store" json:"store,omitempty"`
+	Keys     uint64 `protobuf:"varint,6,opt,name=keys" json:"keys,omitempty"`
+	Duration int64  `protobuf:"varint,7,opt,name=duration" json:"duration,omitempty"`
+}
+
+func (m *SwapReply) Reset()                    { *m = SwapReply{} }
+func (m *SwapReply) String() string            { return proto.CompactTextString(m) }
+func (*SwapReply) ProtoMessage()               {}
+func (*SwapReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *SwapReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *SwapReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *SwapReply) GetPrevious() string {
+	if m != nil {
+		return m.Previous
+	}
+	return ""
+}
+
+func (m *SwapReply) GetStore() string {
+	if m != nil {
+		return m.Store
+	}
+	return ""
+}
+
+func (m *SwapReply) GetKeys() uint64 {
+	if m != nil {
+		return m.Keys
+	}
+	return 0
+}
+
+func (m *SwapReply) GetDuration() int64 {
+	if m != nil {
+		return m.Duration
+	}
+	return 0
+}
+
+type ReplicationRequest struct {
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+}
+
+func (m *ReplicationRequest) Reset()                    { *m = ReplicationRequest{} }
+func (m *ReplicationRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReplicationRequest) ProtoMessage()               {}
+func (*ReplicationRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *ReplicationRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+type PromoteRequest struct {
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+}
+
+func (m *PromoteRequest) Reset()                    { *m = PromoteRequest{} }
+func (m *PromoteRequest) String() string            { return proto.CompactTextString(m) }
+func (*PromoteRequest) ProtoMessage()               {}
+func (*PromoteRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *PromoteRequest) GetIdentity() string {
+	if m != nil {
+		return m.Identity
+	}
+	return ""
+}
+
+type ReplicationReply struct {
+	Success     bool   `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Error       string `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Role        string `protobuf:"bytes,4,opt,name=role" json:"role,omitempty"`
+	Primary     string `protobuf:"bytes,5,opt,name=primary" json:"primary,omitempty"`
+	Connected   bool   `protobuf:"varint,6,opt,name=connected" json:"connected,omitempty"`
+	Epoch       uint64 `protobuf:"varint,7,opt,name=epoch" json:"epoch,omitempty"`
+	Head        uint64 `protobuf:"varint,8,opt,name=head" json:"head,omitempty"`
+	Applied     uint64 `protobuf:"varint,9,opt,name=applied" json:"applied,omitempty"`
+	Lag         int64  `protobuf:"varint,10,opt,name=lag" json:"lag,omitempty"`
+	Backups     uint32 `protobuf:"varint,11,opt,name=backups" json:"backups,omitempty"`
+	StreamError string `protobuf:"bytes,12,opt,name=stream_error,json=streamError" json:"stream_error,omitempty"`
+}
+
+func (m *ReplicationReply) Reset()                    { *m = ReplicationReply{} }
+func (m *ReplicationReply) String() string            { return proto.CompactTextString(m) }
+func (*ReplicationReply) ProtoMessage()               {}
+func (*ReplicationReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *ReplicationReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *ReplicationReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ReplicationReply) GetRole() string {
+	if m != nil {
+		return m.Role
+	}
+	return ""
+}
+
+func (m *ReplicationReply) GetPrimary() string {
+	if m != nil {
+		return m.Primary
+	}
+	return ""
+}
+
+func (m *ReplicationReply) GetConnected() bool {
+	if m != nil {
+		return m.Connected
+	}
+	return false
+}
+
+func (m *ReplicationReply) GetEpoch() uint64 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *ReplicationReply) GetHead() uint64 {
+	if m != nil {
+		return m.Head
+	}
+	return 0
+}
+
+func (m *ReplicationReply) GetApplied() uint64 {
+	if m != nil {
+		return m.Applied
+	}
+	return 0
+}
+
+func (m *ReplicationReply) GetLag() int64 {
+	if m != nil {
+		return m.Lag
+	}
+	return 0
+}
+
+func (m *ReplicationReply) GetBackups() uint32 {
+	if m != nil {
+		return m.Backups
+	}
+	return 0
+}
+
+func (m *ReplicationReply) GetStreamError() string {
+	if m != nil {
+		return m.StreamError
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*GetRequest)(nil), "pb.GetRequest")
 	proto.RegisterType((*PutRequest)(nil), "pb.PutRequest")
 	proto.RegisterType((*DelRequest)(nil), "pb.DelRequest")
+	proto.RegisterType((*BatchGetRequest)(nil), "pb.BatchGetRequest")
+	proto.RegisterType((*BatchPutRequest)(nil), "pb.BatchPutRequest")
 	proto.RegisterType((*ClientReply)(nil), "pb.ClientReply")
+	proto.RegisterType((*BatchReply)(nil), "pb.BatchReply")
+	proto.RegisterType((*WatchRequest)(nil), "pb.WatchRequest")
+	proto.RegisterType((*WatchEvent)(nil), "pb.WatchEvent")
+	proto.RegisterType((*StreamRequest)(nil), "pb.StreamRequest")
+	proto.RegisterType((*StreamReply)(nil), "pb.StreamReply")
 	proto.RegisterType((*KVPair)(nil), "pb.KVPair")
+	proto.RegisterType((*ClusterRequest)(nil), "pb.ClusterRequest")
+	proto.RegisterType((*ClusterReply)(nil), "pb.ClusterReply")
+	proto.RegisterType((*Member)(nil), "pb.Member")
+	proto.RegisterType((*VoteRequest)(nil), "pb.VoteRequest")
+	proto.RegisterType((*VoteReply)(nil), "pb.VoteReply")
+	proto.RegisterType((*AppendRequest)(nil), "pb.AppendRequest")
+	proto.RegisterType((*AppendReply)(nil), "pb.AppendReply")
+	proto.RegisterType((*Entry)(nil), "pb.Entry")
+	proto.RegisterType((*FollowRequest)(nil), "pb.FollowRequest")
+	proto.RegisterType((*Mutation)(nil), "pb.Mutation")
+	proto.RegisterType((*SnapshotRequest)(nil), "pb.SnapshotRequest")
+	proto.RegisterType((*SnapshotReply)(nil), "pb.SnapshotReply")
+	proto.RegisterType((*StatsRequest)(nil), "pb.StatsRequest")
+	proto.RegisterType((*StatsReply)(nil), "pb.StatsReply")
+	proto.RegisterType((*ClientRequests)(nil), "pb.ClientRequests")
+	proto.RegisterType((*FlushRequest)(nil), "pb.FlushRequest")
+	proto.RegisterType((*FlushReply)(nil), "pb.FlushReply")
+	proto.RegisterType((*SwapRequest)(nil), "pb.SwapRequest")
+	proto.RegisterType((*SwapReply)(nil), "pb.SwapReply")
+	proto.RegisterType((*ReplicationRequest)(nil), "pb.ReplicationRequest")
+	proto.RegisterType((*PromoteRequest)(nil), "pb.PromoteRequest")
+	proto.RegisterType((*ReplicationReply)(nil), "pb.ReplicationReply")
+	proto.RegisterEnum("pb.WatchEvent_Type", WatchEvent_Type_name, WatchEvent_Type_value)
+	proto.RegisterEnum("pb.Mutation_Op", Mutation_Op_name, Mutation_Op_value)
 }
 
 func init() { proto.RegisterFile("client.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 231 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x91, 0x31, 0x4b, 0xc4, 0x30,
-	0x14, 0x80, 0x49, 0x4f, 0xef, 0x7a, 0xef, 0x6e, 0x90, 0xe0, 0x10, 0x1c, 0xa4, 0x74, 0xea, 0x54,
-	0x44, 0x37, 0x57, 0x05, 0x07, 0x97, 0xf2, 0x06, 0xf7, 0x36, 0xf7, 0x84, 0x60, 0xb9, 0xc6, 0xd7,
-	0x44, 0xc8, 0xbf, 0x97, 0x24, 0xde, 0xe1, 0x70, 0x53, 0xb7, 0x7c, 0x0f, 0xf2, 0x25, 0x1f, 0x0f,
-	0xf6, 0x7a, 0x34, 0x74, 0x74, 0xad, 0xe5, 0xc9, 0x4d, 0xb2, 0xb0, 0x43, 0xfd, 0x0c, 0xf0, 0x46,
-	0x0e, 0xe9, 0xdb, 0xd3, 0xec, 0xe4, 0x1d, 0x94, 0xe6, 0x40, 0x47, 0x67, 0x5c, 0x50, 0xa2, 0x12,
-	0xcd, 0x16, 0xcf, 0x2c, 0x6f, 0x60, 0xf5, 0x45, 0x41, 0x15, 0x69, 0x1c, 0x8f, 0x75, 0x07, 0xd0,
-	0xf9, 0x65, 0x77, 0xe5, 0x2d, 0x5c, 0xff, 0xf4, 0xa3, 0x27, 0xb5, 0xa9, 0x44, 0xb3, 0xc7, 0x0c,
-	0xd1, 0xf8, 0x4a, 0xe3, 0x62, 0xe3, 0xe7, 0xc4, 0x9a, 0xd4, 0xaa, 0x12, 0x4d, 0x89, 0x19, 0xea,
-	0x00, 0xbb, 0x97, 0xd4, 0x8c, 0x64, 0xc7, 0x20, 0x15, 0x6c, 0x66, 0xaf, 0x35, 0xcd, 0x73, 0x32,
-	0x96, 0x78, 0xc2, 0xf8, 0x18, 0xd3, 0xc1, 0x30, 0x69, 0xf7, 0x67, 0x3d, 0x73, 0x54, 0x13, 0xf3,
-	0xc4, 0x49, 0xbd, 0xc5, 0x0c, 0xf2, 0x1e, 0xae, 0x6c, 0x6f, 0x38, 0x15, 0xec, 0x1e, 0xa1, 0xb5,
-	0x43, 0xfb, 0xfe, 0xd1, 0xf5, 0x86, 0x31, 0xcd, 0xeb, 0x07, 0x58, 0x67, 0x3e, 0x7d, 0x56, 0x5c,
-	0xc8, 0x2f, 0xfe, 0xe5, 0x0f, 0xeb, 0xb4, 0x97, 0xa7, 0xdf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x77,
-	0xc2, 0x32, 0xde, 0xa7, 0x01, 0x00, 0x00,
+	// 1329 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x57, 0x5b, 0x6f, 0x1b, 0x45,
+	0x14, 0xc6, 0xeb, 0xf5, 0xed, 0xf8, 0xd2, 0x68, 0x28, 0x68, 0x15, 0xa0, 0x98, 0x69, 0x2b, 0x22,
+	0x54, 0x45, 0x55, 0x79, 0x82, 0x97, 0x2a, 0x4d, 0xdd, 0x0b, 0x6d, 0x49, 0x34, 0x71, 0x0b, 0x3c,
+	0x45, 0xeb, 0xdd, 0x49, 0xb2, 0x74, 0x2f, 0xd3, 0xd9, 0x71, 0x5a, 0x97, 0x67, 0xc4, 0x6f, 0xe0,
+	0x81, 0x07, 0x7e, 0x13, 0xff, 0x80, 0x1f, 0x82, 0xd0, 0x39, 0x3b, 0xbb, 0x6b, 0x97, 0xd0, 0x5a,
+	0x86, 0xb7, 0xf3, 0x9d, 0x39, 0x3e, 0xd7, 0x6f, 0xce, 0x8e, 0x61, 0x10, 0xc4, 0x91, 0x4c, 0xcd,
+	0xae, 0xd2, 0x99, 0xc9, 0x98, 0xa3, 0x66, 0xfc, 0x6b, 0x80, 0xfb, 0xd2, 0x08, 0xf9, 0x62, 0x2e,
+	0x73, 0xc3, 0xb6, 0xa1, 0x1b, 0x85, 0x32, 0x35, 0x91, 0x59, 0x78, 0x8d, 0x71, 0x63, 0xa7, 0x27,
+	0x2a, 0xcc, 0xb6, 0xa0, 0xf9, 0x5c, 0x2e, 0x3c, 0x87, 0xd4, 0x28, 0xf2, 0x19, 0xc0, 0xe1, 0x7c,
+	0xb3, 0xdf, 0xa2, 0xc6, 0x98, 0xd8, 0x6b, 0x8e, 0x1b, 0x3b, 0x4d, 0x81, 0x22, 0xbb, 0x0c, 0xad,
+	0x73, 0x3f, 0x9e, 0x4b, 0xaf, 0x33, 0x6e, 0xec, 0x0c, 0x44, 0x01, 0xf8, 0x21, 0xc0, 0x5d, 0x19,
+	0x6f, 0x16, 0xe3, 0x32, 0xb4, 0x4e, 0x32, 0x1d, 0x48, 0x8a, 0xd2, 0x15, 0x05, 0xe0, 0x7b, 0x70,
+	0xe9, 0x8e, 0x6f, 0x82, 0xb3, 0x35, 0xcb, 0x66, 0xe0, 0x3e, 0x97, 0x8b, 0xdc, 0x73, 0xc6, 0xcd,
+	0x9d, 0x9e, 0x20, 0x99, 0x1f, 0x58, 0x17, 0x6b, 0x56, 0x3f, 0x86, 0x96, 0xf2, 0x23, 0x9d, 0x7b,
+	0x9d, 0x71, 0x73, 0xa7, 0x7f, 0x0b, 0x76, 0xd5, 0x6c, 0xf7, 0xd1, 0xb3, 0x43, 0x3f, 0xd2, 0xa2,
+	0x38, 0xe0, 0x0b, 0xe8, 0xef, 0xd3, 0x64, 0x84, 0x54, 0xf1, 0x82, 0x79, 0xd0, 0xc9, 0xe7, 0x41,
+	0x20, 0xf3, 0x9c, 0x7c, 0x75, 0x45, 0x09, 0x31, 0x8c, 0x96, 0x61, 0xa4, 0x65, 0x60, 0x6c, 0xa5,
+	0x15, 0xc6, 0x72, 0xa5, 0xd6, 0x99, 0xa6, 0x72, 0x7b, 0xa2, 0x00, 0xec, 0x0a, 0xb8, 0x18, 0x83,
+	0xba, 0xba, 0x1a, 0x9b, 0xf4, 0xfc, 0x35, 0x00, 0xd5, 0xf2, 0xff, 0x47, 0x7e, 0x77, 0xd9, 0x53,
+	0x18, 0x7c, 0x57, 0xc4, 0xde, 0x64, 0xbc, 0x1f, 0x42, 0x5b, 0x69, 0x79, 0x12, 0xbd, 0xb2, 0xf3,
+	0xb5, 0x88, 0x6b, 0x00, 0xf2, 0x3a, 0x39, 0x97, 0xa9, 0x61, 0x9f, 0x83, 0x6b, 0x16, 0x4a, 0x92,
+	0xbf, 0xd1, 0xad, 0xf7, 0x31, 0x89, 0xfa, 0x74, 0x77, 0xba, 0x50, 0x52, 0x90, 0x41, 0xd5, 0x28,
+	0xe7, 0x5f, 0x1a, 0xf5, 0x11, 0xb8, 0x68, 0xcd, 0x3a, 0xd0, 0x3c, 0x7c, 0x3a, 0xdd, 0x7a, 0x8f,
+	0x01, 0xb4, 0xef, 0x4e, 0x1e, 0x4f, 0xa6, 0x93, 0xad, 0x06, 0xff, 0xb9, 0x01, 0xc3, 0x23, 0xa3,
+	0xa5, 0x9f, 0x94, 0xb5, 0x8c, 0xc0, 0x89, 0x42, 0x8a, 0xea, 0x0a, 0x27, 0x0a, 0xd9, 0x18, 0x9a,
+	0xa7, 0xd2, 0x58, 0xef, 0x23, 0xf4, 0x5e, 0x13, 0x50, 0xe0, 0x11, 0x5a, 0xa8, 0xb9, 0xf1, 0x9a,
+	0xb5, 0x45, 0xcd, 0x2f, 0x81, 0x47, 0x68, 0x11, 0xca, 0xd8, 0x73, 0x6b, 0x8b, 0xfa, 0x6e, 0x08,
+	0x3c, 0xe2, 0xdf, 0x43, 0xbf, 0x4c, 0x03, 0xc7, 0xf9, 0x66, 0x12, 0x0c, 0xdc, 0x20, 0x0b, 0x25,
+	0x65, 0x31, 0x14, 0x24, 0xb3, 0xeb, 0xd0, 0xd2, 0x68, 0x6c, 0x19, 0x72, 0x09, 0xdd, 0x2e, 0x91,
+	0x51, 0x14, 0xa7, 0xfc, 0x1b, 0x68, 0x17, 0xed, 0x28, 0x27, 0xd1, 0x58, 0xb9, 0x68, 0xc5, 0xd5,
+	0x75, 0x96, 0xae, 0x2e, 0x72, 0xe9, 0x5c, 0xea, 0x3c, 0xca, 0x52, 0xaa, 0xc9, 0x15, 0x25, 0xe4,
+	0x37, 0x60, 0xb4, 0x1f, 0xcf, 0x73, 0x23, 0xf5, 0x1a, 0x93, 0xe7, 0x7f, 0x36, 0x60, 0x50, 0x99,
+	0xbf, 0x9d, 0xa4, 0x17, 0x13, 0x91, 0x81, 0x9b, 0x62, 0xd5, 0x2e, 0x29, 0x49, 0x46, 0xf2, 0x9c,
+	0xa3, 0x90, 0x7b, 0x2d, 0xea, 0x85, 0x45, 0xec, 0x1a, 0x74, 0x12, 0x99, 0xcc, 0xe4, 0x2a, 0x6d,
+	0x9f, 0x90, 0x4a, 0x94, 0x47, 0xec, 0x0a, 0x00, 0x76, 0x25, 0x0a, 0x7c, 0x23, 0x43, 0xaf, 0x4b,
+	0x49, 0x2c, 0x69, 0xd0, 0x7b, 0x2c, 0xfd, 0x50, 0x6a, 0xaf, 0x47, 0x31, 0x2d, 0xc2, 0xcc, 0x95,
+	0x8e, 0x12, 0x5f, 0x2f, 0x3c, 0xa0, 0x83, 0x12, 0xf2, 0x9b, 0xd0, 0x2e, 0x82, 0x50, 0xb6, 0x7e,
+	0x22, 0x6d, 0x1b, 0x48, 0x46, 0x9d, 0x1f, 0x86, 0xda, 0xb2, 0x9f, 0x64, 0xfe, 0x4b, 0x03, 0xfa,
+	0xcf, 0x32, 0x23, 0xd7, 0x5c, 0x62, 0x46, 0xea, 0x84, 0x7e, 0xef, 0x0a, 0x92, 0xd9, 0x35, 0x18,
+	0xc5, 0x7e, 0x6e, 0x8e, 0xe3, 0xec, 0xf4, 0x38, 0x4a, 0x43, 0xf9, 0xca, 0x4e, 0x69, 0x80, 0xda,
+	0xc7, 0xd9, 0xe9, 0x43, 0xd4, 0x31, 0x0e, 0xc3, 0xca, 0x8a, 0x5c, 0xb8, 0x64, 0xd4, 0xb7, 0x46,
+	0x53, 0xa9, 0x13, 0xfe, 0x15, 0xf4, 0x8a, 0x44, 0x54, 0x5c, 0x87, 0x6a, 0x2c, 0x85, 0xf2, 0xa0,
+	0x73, 0xaa, 0xfd, 0x14, 0x7b, 0xe5, 0x14, 0x03, 0xb3, 0x90, 0xff, 0xd1, 0x80, 0xe1, 0x9e, 0x52,
+	0x32, 0x0d, 0xff, 0x43, 0x19, 0x4a, 0xcb, 0xf3, 0x7f, 0x96, 0x81, 0xda, 0xe5, 0x32, 0x2a, 0xab,
+	0xe5, 0x32, 0xac, 0x11, 0x96, 0xc1, 0xae, 0x42, 0x47, 0xa6, 0x46, 0x47, 0xc4, 0x09, 0x1c, 0x7d,
+	0x0f, 0x47, 0x3f, 0x49, 0x8d, 0x5e, 0x88, 0xf2, 0x84, 0x5d, 0x85, 0x61, 0x31, 0xcb, 0xe3, 0x20,
+	0x4b, 0x92, 0xc8, 0x78, 0x6d, 0xdb, 0x34, 0x52, 0xee, 0x93, 0x8e, 0xcf, 0xa0, 0x5f, 0x16, 0xf5,
+	0x96, 0x96, 0x94, 0x1c, 0x76, 0x56, 0x39, 0x7c, 0x1d, 0x46, 0x41, 0x96, 0x9e, 0xc4, 0x51, 0x60,
+	0x56, 0x0a, 0x1a, 0x96, 0x5a, 0xaa, 0x88, 0x3f, 0x82, 0x16, 0xa5, 0x86, 0x9c, 0x2f, 0xcc, 0x0a,
+	0xf7, 0x05, 0xb8, 0xb0, 0x55, 0x1e, 0x74, 0x30, 0x69, 0x3f, 0x0d, 0xc9, 0xe5, 0x40, 0x94, 0x90,
+	0x3f, 0x85, 0xe1, 0xbd, 0x2c, 0x8e, 0xb3, 0x97, 0xeb, 0x4c, 0x01, 0x2f, 0x99, 0xca, 0x82, 0x33,
+	0xeb, 0xbb, 0x00, 0x18, 0xf0, 0x44, 0x67, 0x89, 0x4d, 0x96, 0x64, 0xfe, 0x57, 0x03, 0xba, 0x4f,
+	0xe6, 0xc6, 0x37, 0x51, 0x96, 0xe2, 0xda, 0xc8, 0xe5, 0x0b, 0x9b, 0x25, 0x8a, 0xec, 0x53, 0x70,
+	0x32, 0x45, 0x5e, 0x46, 0xc5, 0xda, 0x29, 0x6d, 0x77, 0x0f, 0x94, 0x70, 0x32, 0x55, 0x6e, 0x9a,
+	0xe6, 0x05, 0x9b, 0xc6, 0x7d, 0x63, 0xd3, 0xc8, 0x57, 0x2a, 0xd2, 0xf6, 0x36, 0x37, 0x45, 0x09,
+	0xa9, 0x0d, 0x51, 0x22, 0x69, 0x4a, 0x4d, 0x41, 0x32, 0xea, 0xce, 0xa4, 0x1f, 0xd2, 0xbe, 0x73,
+	0x05, 0xc9, 0x75, 0x4d, 0xdd, 0xa5, 0x9a, 0xf8, 0x6d, 0x70, 0x0e, 0xd4, 0x85, 0x0b, 0x9f, 0xf5,
+	0xa0, 0x25, 0x26, 0x47, 0x93, 0xe9, 0x96, 0x83, 0xea, 0xa3, 0x1f, 0xbe, 0xdd, 0x9f, 0xdc, 0xdd,
+	0x6a, 0xb2, 0x21, 0xf4, 0x1e, 0x4c, 0xf6, 0xc4, 0xf4, 0xce, 0x64, 0x6f, 0xba, 0xe5, 0xf2, 0x87,
+	0x70, 0xe9, 0x28, 0xf5, 0x55, 0x7e, 0x96, 0xad, 0xf5, 0x50, 0xd8, 0x86, 0x6e, 0x90, 0x25, 0x4a,
+	0xd7, 0xac, 0xa8, 0x30, 0xff, 0x09, 0x86, 0xb5, 0xab, 0x0d, 0xb7, 0xa0, 0xf2, 0xcd, 0x59, 0xb9,
+	0x05, 0x51, 0xae, 0x1e, 0x37, 0xad, 0xa2, 0x15, 0x28, 0xa3, 0x2e, 0x8f, 0x5e, 0x57, 0x2d, 0x43,
+	0x99, 0x7f, 0x01, 0x83, 0x23, 0xe3, 0x9b, 0x7c, 0x9d, 0x75, 0xfd, 0xab, 0x03, 0x60, 0x8d, 0x37,
+	0x49, 0xf3, 0x32, 0xb4, 0x72, 0x93, 0xe9, 0x72, 0x5b, 0x17, 0x60, 0x25, 0xd1, 0xa6, 0x4d, 0x74,
+	0x17, 0x5f, 0x24, 0x94, 0x4f, 0xee, 0xb5, 0xe9, 0xc2, 0xb2, 0xe5, 0x6f, 0x57, 0x71, 0x22, 0x2a,
+	0x1b, 0x5c, 0xca, 0x73, 0x45, 0x6c, 0xe8, 0x90, 0x17, 0x8b, 0xd8, 0x27, 0x00, 0x67, 0xd2, 0x57,
+	0xc7, 0x7e, 0x1c, 0x67, 0x81, 0x25, 0x40, 0x0f, 0x35, 0x7b, 0xa8, 0x60, 0x9f, 0xc1, 0x80, 0x8e,
+	0xb3, 0xd9, 0x8f, 0x32, 0x30, 0x39, 0x6d, 0x74, 0x57, 0xf4, 0x51, 0x77, 0x50, 0xa8, 0x88, 0xda,
+	0x8b, 0xdc, 0x03, 0x4b, 0xed, 0x45, 0xce, 0x3e, 0x80, 0x76, 0x3a, 0x4f, 0x8e, 0x4f, 0x03, 0xaf,
+	0x4f, 0x9f, 0x97, 0x56, 0x3a, 0x4f, 0xee, 0x07, 0xfc, 0x01, 0x8c, 0x56, 0xd3, 0x7b, 0x17, 0x1d,
+	0xaa, 0x02, 0x8b, 0xbb, 0x56, 0x61, 0x9c, 0xc8, 0xbd, 0x78, 0x9e, 0xaf, 0xf3, 0x74, 0xc2, 0x37,
+	0xb4, 0xb5, 0xdd, 0x90, 0x37, 0xd4, 0x7a, 0xb7, 0xe6, 0x08, 0xbf, 0x0d, 0xfd, 0xa3, 0x97, 0xbe,
+	0x5a, 0x73, 0x5b, 0x14, 0xf3, 0x74, 0x96, 0xe6, 0xc9, 0x7f, 0x6b, 0x40, 0xaf, 0xf0, 0xb0, 0x49,
+	0x4a, 0xdb, 0xd0, 0xc5, 0xc5, 0x1d, 0x65, 0xf3, 0xdc, 0xd2, 0xa4, 0xc2, 0x75, 0xbc, 0xd6, 0x45,
+	0xfc, 0x69, 0x2f, 0x11, 0x7d, 0x1b, 0xba, 0xe1, 0x5c, 0xd3, 0xc2, 0xb1, 0x8c, 0xa8, 0x30, 0xbf,
+	0x09, 0x4c, 0xd8, 0xcf, 0x79, 0x94, 0xa5, 0xeb, 0x34, 0xf9, 0x06, 0x8c, 0x0e, 0x75, 0x96, 0xac,
+	0xf7, 0x41, 0xe6, 0xbf, 0x3b, 0xb0, 0xb5, 0x12, 0x60, 0xc3, 0xc9, 0xe8, 0x2c, 0xae, 0xde, 0x35,
+	0x28, 0x2f, 0xbf, 0x30, 0x5a, 0x2b, 0x2f, 0x0c, 0xf6, 0x31, 0xf4, 0x82, 0x2c, 0x4d, 0x65, 0x80,
+	0x9f, 0xe1, 0x36, 0xf9, 0xaf, 0x15, 0xf5, 0x02, 0xec, 0xbc, 0xb1, 0xd4, 0x69, 0x55, 0x76, 0x97,
+	0x56, 0xa5, 0x07, 0x1d, 0x5f, 0xa9, 0x38, 0x92, 0xa1, 0xbd, 0x0a, 0x25, 0xc4, 0x6b, 0x10, 0xfb,
+	0xa7, 0x74, 0x0d, 0x9a, 0x02, 0x45, 0xb4, 0x9d, 0xf9, 0xc1, 0xf3, 0xb9, 0xca, 0xed, 0x3d, 0x28,
+	0x21, 0xde, 0xaa, 0x9c, 0x1e, 0xaa, 0xc7, 0x45, 0x61, 0x03, 0x4a, 0xb6, 0x5f, 0xe8, 0x26, 0xa8,
+	0x9a, 0xb5, 0xe9, 0x5f, 0xea, 0x97, 0x7f, 0x0f, 0x00, 0xaf, 0x1c, 0x73, 0x9c, 0xb5, 0x0e, 0x00,
+	0x00,
 }
@@ -31,6 +31,11 @@ type KVClient interface {
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*ClientReply, error)
 	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*ClientReply, error)
 	Del(ctx context.Context, in *DelRequest, opts ...grpc.CallOption) (*ClientReply, error)
+	BatchGet(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*BatchReply, error)
+	BatchPut(ctx context.Context, in *BatchPutRequest, opts ...grpc.CallOption) (*BatchReply, error)
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KV_WatchClient, error)
+	Stream(ctx context.Context, opts ...grpc.CallOption) (KV_StreamClient, error)
+	Cluster(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*ClusterReply, error)
 }
 
 type kVClient struct {
@@ -68,12 +73,107 @@ func (c *kVClient) Del(ctx context.Context, in *DelRequest, opts ...grpc.CallOpt
 	return out, nil
 }
 
+func (c *kVClient) BatchGet(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*BatchReply, error) {
+	out := new(BatchReply)
+	err := grpc.Invoke(ctx, "/pb.KV/BatchGet", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) BatchPut(ctx context.Context, in *BatchPutRequest, opts ...grpc.CallOption) (*BatchReply, error) {
+	out := new(BatchReply)
+	err := grpc.Invoke(ctx, "/pb.KV/BatchPut", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KV_WatchClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_KV_serviceDesc.Streams[0], c.cc, "/pb.KV/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KV_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type kVWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVClient) Stream(ctx context.Context, opts ...grpc.CallOption) (KV_StreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_KV_serviceDesc.Streams[1], c.cc, "/pb.KV/Stream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVStreamClient{stream}
+	return x, nil
+}
+
+type KV_StreamClient interface {
+	Send(*StreamRequest) error
+	Recv() (*StreamReply, error)
+	grpc.ClientStream
+}
+
+type kVStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVStreamClient) Send(m *StreamRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kVStreamClient) Recv() (*StreamReply, error) {
+	m := new(StreamReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVClient) Cluster(ctx context.Context, in *ClusterRequest, opts ...grpc.CallOption) (*ClusterReply, error) {
+	out := new(ClusterReply)
+	err := grpc.Invoke(ctx, "/pb.KV/Cluster", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for KV service
 
 type KVServer interface {
 	Get(context.Context, *GetRequest) (*ClientReply, error)
 	Put(context.Context, *PutRequest) (*ClientReply, error)
 	Del(context.Context, *DelRequest) (*ClientReply, error)
+	BatchGet(context.Context, *BatchGetRequest) (*BatchReply, error)
+	BatchPut(context.Context, *BatchPutRequest) (*BatchReply, error)
+	Watch(*WatchRequest, KV_WatchServer) error
+	Stream(KV_StreamServer) error
+	Cluster(context.Context, *ClusterRequest) (*ClusterReply, error)
 }
 
 func RegisterKVServer(s *grpc.Server, srv KVServer) {
@@ -134,6 +234,107 @@ func _KV_Del_Handler(srv interface{}, ctx context.Context, dec func(interface{})
 	return interceptor(ctx, in, info, handler)
 }
 
+func _KV_BatchGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).BatchGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.KV/BatchGet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).BatchGet(ctx, req.(*BatchGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_BatchPut_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchPutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).BatchPut(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.KV/BatchPut",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).BatchPut(ctx, req.(*BatchPutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVServer).Watch(m, &kVWatchServer{stream})
+}
+
+type KV_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type kVWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _KV_Stream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KVServer).Stream(&kVStreamServer{stream})
+}
+
+type KV_StreamServer interface {
+	Send(*StreamReply) error
+	Recv() (*StreamRequest, error)
+	grpc.ServerStream
+}
+
+type kVStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVStreamServer) Send(m *StreamReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kVStreamServer) Recv() (*StreamRequest, error) {
+	m := new(StreamRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _KV_Cluster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Cluster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.KV/Cluster",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Cluster(ctx, req.(*ClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _KV_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "pb.KV",
 	HandlerType: (*KVServer)(nil),
@@ -150,21 +351,504 @@ var _KV_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Del",
 			Handler:    _KV_Del_Handler,
 		},
+		{
+			MethodName: "BatchGet",
+			Handler:    _KV_BatchGet_Handler,
+		},
+		{
+			MethodName: "BatchPut",
+			Handler:    _KV_BatchPut_Handler,
+		},
+		{
+			MethodName: "Cluster",
+			Handler:    _KV_Cluster_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _KV_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Stream",
+			Handler:       _KV_Stream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "service.proto",
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Admin service
+
+type AdminClient interface {
+	Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotReply, error)
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsReply, error)
+	Flush(ctx context.Context, in *FlushRequest, opts ...grpc.CallOption) (*FlushReply, error)
+	SwapStore(ctx context.Context, in *SwapRequest, opts ...grpc.CallOption) (*SwapReply, error)
+	Replication(ctx context.Context, in *ReplicationRequest, opts ...grpc.CallOption) (*ReplicationReply, error)
+	Promote(ctx context.Context, in *PromoteRequest, opts ...grpc.CallOption) (*ReplicationReply, error)
+}
+
+type adminClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAdminClient(cc *grpc.ClientConn) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotReply, error) {
+	out := new(SnapshotReply)
+	err := grpc.Invoke(ctx, "/pb.Admin/Snapshot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsReply, error) {
+	out := new(StatsReply)
+	err := grpc.Invoke(ctx, "/pb.Admin/Stats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Flush(ctx context.Context, in *FlushRequest, opts ...grpc.CallOption) (*FlushReply, error) {
+	out := new(FlushReply)
+	err := grpc.Invoke(ctx, "/pb.Admin/Flush", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SwapStore(ctx context.Context, in *SwapRequest, opts ...grpc.CallOption) (*SwapReply, error) {
+	out := new(SwapReply)
+	err := grpc.Invoke(ctx, "/pb.Admin/SwapStore", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Replication(ctx context.Context, in *ReplicationRequest, opts ...grpc.CallOption) (*ReplicationReply, error) {
+	out := new(ReplicationReply)
+	err := grpc.Invoke(ctx, "/pb.Admin/Replication", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Promote(ctx context.Context, in *PromoteRequest, opts ...grpc.CallOption) (*ReplicationReply, error) {
+	out := new(ReplicationReply)
+	err := grpc.Invoke(ctx, "/pb.Admin/Promote", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Admin service
+
+type AdminServer interface {
+	Snapshot(context.Context, *SnapshotRequest) (*SnapshotReply, error)
+	Stats(context.Context, *StatsRequest) (*StatsReply, error)
+	Flush(context.Context, *FlushRequest) (*FlushReply, error)
+	SwapStore(context.Context, *SwapRequest) (*SwapReply, error)
+	Replication(context.Context, *ReplicationRequest) (*ReplicationReply, error)
+	Promote(context.Context, *PromoteRequest) (*ReplicationReply, error)
+}
+
+func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
+	s.RegisterService(&_Admin_serviceDesc, srv)
+}
+
+func _Admin_Snapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Snapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Admin/Snapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Snapshot(ctx, req.(*SnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Admin/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Flush_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FlushRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Flush(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Admin/Flush",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Flush(ctx, req.(*FlushRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_SwapStore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SwapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).SwapStore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Admin/SwapStore",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).SwapStore(ctx, req.(*SwapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Replication_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplicationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Replication(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Admin/Replication",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Replication(ctx, req.(*ReplicationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Promote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PromoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Promote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Admin/Promote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Promote(ctx, req.(*PromoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Admin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Snapshot",
+			Handler:    _Admin_Snapshot_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _Admin_Stats_Handler,
+		},
+		{
+			MethodName: "Flush",
+			Handler:    _Admin_Flush_Handler,
+		},
+		{
+			MethodName: "SwapStore",
+			Handler:    _Admin_SwapStore_Handler,
+		},
+		{
+			MethodName: "Replication",
+			Handler:    _Admin_Replication_Handler,
+		},
+		{
+			MethodName: "Promote",
+			Handler:    _Admin_Promote_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "service.proto",
 }
 
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Raft service
+
+type RaftClient interface {
+	RequestVote(ctx context.Context, in *VoteRequest, opts ...grpc.CallOption) (*VoteReply, error)
+	AppendEntries(ctx context.Context, in *AppendRequest, opts ...grpc.CallOption) (*AppendReply, error)
+}
+
+type raftClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewRaftClient(cc *grpc.ClientConn) RaftClient {
+	return &raftClient{cc}
+}
+
+func (c *raftClient) RequestVote(ctx context.Context, in *VoteRequest, opts ...grpc.CallOption) (*VoteReply, error) {
+	out := new(VoteReply)
+	err := grpc.Invoke(ctx, "/pb.Raft/RequestVote", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftClient) AppendEntries(ctx context.Context, in *AppendRequest, opts ...grpc.CallOption) (*AppendReply, error) {
+	out := new(AppendReply)
+	err := grpc.Invoke(ctx, "/pb.Raft/AppendEntries", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Raft service
+
+type RaftServer interface {
+	RequestVote(context.Context, *VoteRequest) (*VoteReply, error)
+	AppendEntries(context.Context, *AppendRequest) (*AppendReply, error)
+}
+
+func RegisterRaftServer(s *grpc.Server, srv RaftServer) {
+	s.RegisterService(&_Raft_serviceDesc, srv)
+}
+
+func _Raft_RequestVote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftServer).RequestVote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Raft/RequestVote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftServer).RequestVote(ctx, req.(*VoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Raft_AppendEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftServer).AppendEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Raft/AppendEntries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftServer).AppendEntries(ctx, req.(*AppendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Raft_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Raft",
+	HandlerType: (*RaftServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RequestVote",
+			Handler:    _Raft_RequestVote_Handler,
+		},
+		{
+			MethodName: "AppendEntries",
+			Handler:    _Raft_AppendEntries_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "service.proto",
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Replication service
+
+type ReplicationClient interface {
+	Follow(ctx context.Context, in *FollowRequest, opts ...grpc.CallOption) (Replication_FollowClient, error)
+}
+
+type replicationClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewReplicationClient(cc *grpc.ClientConn) ReplicationClient {
+	return &replicationClient{cc}
+}
+
+func (c *replicationClient) Follow(ctx context.Context, in *FollowRequest, opts ...grpc.CallOption) (Replication_FollowClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Replication_serviceDesc.Streams[0], c.cc, "/pb.Replication/Follow", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &replicationFollowClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Replication_FollowClient interface {
+	Recv() (*Mutation, error)
+	grpc.ClientStream
+}
+
+type replicationFollowClient struct {
+	grpc.ClientStream
+}
+
+func (x *replicationFollowClient) Recv() (*Mutation, error) {
+	m := new(Mutation)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for Replication service
+
+type ReplicationServer interface {
+	Follow(*FollowRequest, Replication_FollowServer) error
+}
+
+func RegisterReplicationServer(s *grpc.Server, srv ReplicationServer) {
+	s.RegisterService(&_Replication_serviceDesc, srv)
+}
+
+func _Replication_Follow_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FollowRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ReplicationServer).Follow(m, &replicationFollowServer{stream})
+}
+
+type Replication_FollowServer interface {
+	Send(*Mutation) error
+	grpc.ServerStream
+}
+
+type replicationFollowServer struct {
+	grpc.ServerStream
+}
+
+func (x *replicationFollowServer) Send(m *Mutation) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Replication_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Replication",
+	HandlerType: (*ReplicationServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Follow",
+			Handler:       _Replication_Follow_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "service.proto",
+}
+
 func init() { proto.RegisterFile("service.proto", fileDescriptor1) }
 
 var fileDescriptor1 = []byte{
-	// 122 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2d, 0x4e, 0x2d, 0x2a,
-	0xcb, 0x4c, 0x4e, 0xd5, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x2a, 0x48, 0x92, 0xe2, 0x49,
-	0xce, 0xc9, 0x4c, 0xcd, 0x2b, 0x81, 0x88, 0x18, 0x35, 0x31, 0x72, 0x31, 0x79, 0x87, 0x09, 0x69,
-	0x70, 0x31, 0xbb, 0xa7, 0x96, 0x08, 0xf1, 0xe9, 0x15, 0x24, 0xe9, 0xb9, 0xa7, 0x96, 0x04, 0xa5,
-	0x16, 0x96, 0xa6, 0x16, 0x97, 0x48, 0xf1, 0x83, 0xf8, 0xce, 0x60, 0xf5, 0x41, 0xa9, 0x05, 0x39,
-	0x95, 0x4a, 0x0c, 0x20, 0x95, 0x01, 0xa5, 0x50, 0x95, 0x01, 0xa5, 0x04, 0x54, 0xba, 0xa4, 0xe6,
-	0x40, 0x54, 0xba, 0xa4, 0xe6, 0xe0, 0x56, 0x99, 0xc4, 0x06, 0x76, 0x8b, 0x31, 0x20, 0x00, 0x00,
-	0xff, 0xff, 0xcb, 0x89, 0xae, 0xef, 0xae, 0x00, 0x00, 0x00,
+	// 406 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x93, 0x4f, 0x6f, 0x13, 0x31,
+	0x10, 0xc5, 0x9b, 0x85, 0xa4, 0xc5, 0xed, 0x96, 0xd6, 0x20, 0x0e, 0x3d, 0xf6, 0x54, 0x09, 0x35,
+	0xb4, 0x0b, 0xdc, 0xe0, 0x50, 0xfa, 0x27, 0x07, 0x84, 0xb4, 0xca, 0x4a, 0xc9, 0xd9, 0xd9, 0x0c,
+	0xca, 0x4a, 0x8e, 0x6d, 0x76, 0xc7, 0x89, 0xf8, 0x6e, 0x7c, 0x36, 0x84, 0xec, 0xb1, 0x93, 0x5d,
+	0x2a, 0x25, 0xb7, 0x99, 0xdf, 0xbc, 0x17, 0xfb, 0x8d, 0xb3, 0x2c, 0x6d, 0xa0, 0x5e, 0x55, 0x25,
+	0x0c, 0x4d, 0xad, 0x51, 0xf3, 0xc4, 0xcc, 0x2e, 0x4e, 0x4a, 0x59, 0x81, 0x42, 0x22, 0xd9, 0xdf,
+	0x84, 0x25, 0xdf, 0x27, 0xfc, 0x8a, 0xbd, 0x18, 0x01, 0xf2, 0xd3, 0xa1, 0x99, 0x0d, 0x47, 0x80,
+	0x63, 0xf8, 0x65, 0xa1, 0xc1, 0x8b, 0xd7, 0xae, 0xbf, 0xf7, 0xfa, 0x31, 0x18, 0xf9, 0xfb, 0xf2,
+	0xc0, 0x29, 0x73, 0x1b, 0x94, 0xb9, 0xdd, 0xa3, 0x7c, 0x00, 0x49, 0xca, 0x07, 0x90, 0x3b, 0x94,
+	0xb7, 0xec, 0xe8, 0x9b, 0xc0, 0x72, 0xe1, 0xae, 0xf0, 0xc6, 0x8d, 0x63, 0x17, 0x3d, 0xa7, 0x1b,
+	0xf8, 0xbf, 0x25, 0xb7, 0x6d, 0x4b, 0x6e, 0x77, 0x58, 0xae, 0x59, 0x7f, 0xea, 0x7a, 0x7e, 0xe6,
+	0x46, 0x53, 0x1a, 0xb5, 0xc4, 0x9e, 0x3c, 0xae, 0x40, 0xe1, 0xe5, 0xc1, 0x4d, 0x8f, 0x67, 0x6c,
+	0x50, 0x60, 0x0d, 0x62, 0xc9, 0xcf, 0xdd, 0x94, 0xea, 0x4e, 0x88, 0x88, 0xfc, 0xcf, 0x5f, 0xf5,
+	0x6e, 0x7a, 0xfc, 0x96, 0x1d, 0xde, 0x4b, 0xdb, 0x20, 0xd4, 0x9c, 0x53, 0x4c, 0xdf, 0x44, 0xd7,
+	0x59, 0x87, 0x79, 0x5b, 0xf6, 0x27, 0x61, 0xfd, 0xbb, 0xf9, 0xb2, 0x52, 0xfc, 0x13, 0x3b, 0x2a,
+	0x94, 0x30, 0xcd, 0x42, 0x87, 0x48, 0xb1, 0x8b, 0xf6, 0xf3, 0x2e, 0xa4, 0x54, 0xef, 0x59, 0xbf,
+	0x40, 0x81, 0x0d, 0xa5, 0xf2, 0x65, 0x27, 0x55, 0x20, 0x1b, 0xf1, 0x93, 0xb4, 0x4d, 0x58, 0x81,
+	0x2f, 0x3b, 0xe2, 0x40, 0xe2, 0xbe, 0x5e, 0x15, 0x6b, 0x61, 0x0a, 0xd4, 0x35, 0x70, 0x0a, 0xbc,
+	0x16, 0x26, 0xea, 0xd3, 0x2d, 0x20, 0xf9, 0x57, 0x76, 0xec, 0xca, 0xaa, 0x14, 0x58, 0x69, 0xc5,
+	0xdf, 0xb9, 0x79, 0x0b, 0x44, 0xdf, 0xdb, 0x67, 0x9c, 0xec, 0x9f, 0xd9, 0x61, 0x5e, 0xeb, 0xa5,
+	0x46, 0xa0, 0xd5, 0x85, 0x66, 0x8f, 0x2d, 0x53, 0xec, 0xe5, 0x58, 0xfc, 0x44, 0xfe, 0x81, 0x1d,
+	0x07, 0xe9, 0x44, 0x63, 0xb8, 0xee, 0xa4, 0xe5, 0x4f, 0xb7, 0x20, 0x9e, 0x97, 0xde, 0x19, 0x03,
+	0x6a, 0xfe, 0xa8, 0xb0, 0xae, 0xa0, 0xa1, 0x57, 0x26, 0xd4, 0x79, 0xe5, 0x88, 0xe8, 0xbc, 0x2f,
+	0xdd, 0x94, 0xd7, 0x6c, 0xf0, 0xa4, 0xa5, 0xd4, 0x6b, 0xb2, 0x53, 0x1d, 0xed, 0x27, 0x0e, 0xfd,
+	0xb0, 0xe8, 0xa5, 0xee, 0x3f, 0x35, 0x1b, 0xf8, 0x8f, 0xee, 0xe3, 0xbf, 0x01, 0x00, 0x92, 0x1a,
+	0x8f, 0xff, 0x97, 0x03, 0x00, 0x00,
 }
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/bbengfort/speedmap/server/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultPipelineWindow is the number of requests a pipeline keeps in flight
+// if no window is specified.
+const DefaultPipelineWindow = 128
+
+// Returned by the requests of a pipeline that has been closed.
+var errPipelineClosed = errors.New("pipeline is closed")
+
+// Pipeline sends get, put and del requests to the speedmap server on a single
+// stream without waiting for the reply to one request before sending the
+// next, so that many requests are in flight at once rather than each costing
+// a round trip. The server handles the requests on the stream concurrently,
+// so requests that depend on each other (e.g. a get of a key that is being
+// put) must wait for the earlier request to complete. A Pipeline is safe for
+// concurrent use by multiple go routines; the async methods return a Call
+// that completes when the reply is received, while the blocking methods wait
+// for the reply. Errors have the same gRPC status codes as unary requests.
+// Pipelines are not routed within a cluster, so replies may be redirects.
+type Pipeline struct {
+	identity string
+	stream   pb.KV_StreamClient
+	cancel   context.CancelFunc
+	window   chan struct{} // limits the number of requests in flight
+	smu      sync.Mutex    // only one go routine may send on the stream at a time
+
+	mu      sync.Mutex       // protects the fields below
+	next    uint64           // the id of the next request
+	pending map[uint64]*Call // requests waiting for their reply by id
+	err     error            // the reason the stream ended, if it has
+	done    chan struct{}    // closed when the stream has ended
+}
+
+// Call is a request in flight on a pipeline.
+type Call struct {
+	reply *pb.ClientReply
+	err   error
+	done  chan struct{}
+}
+
+// Done returns a channel that is closed when the reply has been received.
+func (c *Call) Done() <-chan struct{} {
+	return c.done
+}
+
+// Wait for the reply to the request.
+func (c *Call) Wait() (*pb.ClientReply, error) {
+	<-c.done
+	return c.reply, c.err
+}
+
+// Completes the call with the reply or error.
+func (c *Call) complete(reply *pb.ClientReply, err error) {
+	c.reply, c.err = reply, err
+	close(c.done)
+}
+
+// Pipeline opens a stream to the speedmap server on which at most window
+// requests are kept in flight, DefaultPipelineWindow if window is zero or
+// less. The pipeline must be closed when it is no longer needed.
+func (c *Client) Pipeline(window int) (*Pipeline, error) {
+	// Ensure that we're connected
+	if c.client == nil {
+		return nil, errors.New("not connected to speedmap server")
+	}
+
+	if window <= 0 {
+		window = DefaultPipelineWindow
+	}
+
+	// The stream remains open until the pipeline is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.client.Stream(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	p := &Pipeline{
+		identity: c.identity,
+		stream:   stream,
+		cancel:   cancel,
+		window:   make(chan struct{}, window),
+		pending:  make(map[uint64]*Call),
+		done:     make(chan struct{}),
+	}
+
+	go p.recv()
+	return p, nil
+}
+
+// GetAsync sends a request for the specified key without waiting for the reply.
+func (p *Pipeline) GetAsync(key string) *Call {
+	return p.send(&pb.StreamRequest{Get: &pb.GetRequest{Identity: p.identity, Key: key}})
+}
+
+// PutAsync sends a request for the specified key and value without waiting
+// for the reply.
+func (p *Pipeline) PutAsync(key string, value []byte) *Call {
+	return p.send(&pb.StreamRequest{Put: &pb.PutRequest{Identity: p.identity, Key: key, Value: value}})
+}
+
+// DelAsync sends a request for the specified key without waiting for the reply.
+func (p *Pipeline) DelAsync(key string, force bool) *Call {
+	return p.send(&pb.StreamRequest{Del: &pb.DelRequest{Identity: p.identity, Key: key, Force: force}})
+}
+
+// Get performs a request for the specified key and waits for the reply.
+func (p *Pipeline) Get(key string) (*pb.ClientReply, error) {
+	return p.GetAsync(key).Wait()
+}
+
+// Put performs a request for the specified key and value and waits for the reply.
+func (p *Pipeline) Put(key string, value []byte) (*pb.ClientReply, error) {
+	return p.PutAsync(key, value).Wait()
+}
+
+// Del performs a request for the specified key and waits for the reply.
+func (p *Pipeline) Del(key string, force bool) (*pb.ClientReply, error) {
+	return p.DelAsync(key, force).Wait()
+}
+
+// Close the stream once the replies to the requests in flight have been
+// received, returning an error if the stream ended before it was closed.
+func (p *Pipeline) Close() (err error) {
+	defer p.cancel()
+
+	p.smu.Lock()
+	p.stream.CloseSend()
+	p.smu.Unlock()
+
+	<-p.done
+	if p.err != errPipelineClosed {
+		return p.err
+	}
+	return nil
+}
+
+// Sends the request once there is room in the window, tagging it with the
+// next id so that its reply can be matched to the call.
+func (p *Pipeline) send(req *pb.StreamRequest) *Call {
+	call := &Call{done: make(chan struct{})}
+
+	select {
+	case p.window <- struct{}{}:
+	case <-p.done:
+		call.complete(nil, p.err)
+		return call
+	}
+
+	p.mu.Lock()
+	if p.err != nil {
+		p.mu.Unlock()
+		call.complete(nil, p.err)
+		return call
+	}
+
+	req.Id = p.next
+	p.next++
+	p.pending[req.Id] = call
+	p.mu.Unlock()
+
+	p.smu.Lock()
+	err := p.stream.Send(req)
+	p.smu.Unlock()
+
+	// The reason the stream failed is returned by recv, which fails the call
+	if err != nil && err != io.EOF {
+		p.fail(err)
+	}
+	return call
+}
+
+// Receives replies and completes their calls until the stream ends, then
+// fails the calls that are still waiting.
+func (p *Pipeline) recv() {
+	for {
+		reply, err := p.stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				err = errPipelineClosed
+			}
+			p.fail(err)
+			return
+		}
+
+		p.mu.Lock()
+		call, ok := p.pending[reply.Id]
+		delete(p.pending, reply.Id)
+		p.mu.Unlock()
+
+		if !ok {
+			continue
+		}
+
+		<-p.window
+		if reply.Code != uint32(codes.OK) {
+			call.complete(nil, status.Error(codes.Code(reply.Code), reply.Reply.GetError()))
+			continue
+		}
+		call.complete(reply.Reply, nil)
+	}
+}
+
+// Ends the pipeline with the error, failing every call waiting for a reply.
+func (p *Pipeline) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return
+	}
+
+	p.err = err
+	for id, call := range p.pending {
+		delete(p.pending, id)
+		call.complete(nil, err)
+	}
+	close(p.done)
+}
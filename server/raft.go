@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/speedmap/cluster"
+	"github.com/bbengfort/speedmap/raft"
+	"github.com/bbengfort/speedmap/server/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// SetReplication configures the server as the member of a replicated cluster
+// whose store is a raft.Store that replicates every key to all of the
+// members, and serves the requests of the other members of the Raft group
+// with the node of the store. Get, put and del requests (including those on a
+// pipelined stream) and batches made to a member that is not the leader are
+// not successful; if the member knows the leader the redirect of the reply is
+// its name, which clients use to fetch the members with the Cluster RPC and
+// send their requests to the leader, otherwise an Unavailable error is
+// returned while the members elect a leader. It must be called before Listen.
+func (s *Server) SetReplication(node *raft.Node, members []cluster.Member) error {
+	if s.ring != nil {
+		return errors.New("server is already a member of a cluster")
+	}
+
+	for _, member := range members {
+		if member.Name == node.ID() {
+			s.node, s.members, s.self = node, members, member.Name
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not a member of the cluster", node.ID())
+}
+
+// RequestVote handles a request for the vote of this member from a candidate.
+func (s *Server) RequestVote(ctx context.Context, in *pb.VoteRequest) (*pb.VoteReply, error) {
+	if s.node == nil {
+		return nil, status.Error(codes.FailedPrecondition, "server is not a member of a replicated cluster")
+	}
+
+	rep, err := s.node.RequestVote(&raft.VoteRequest{
+		Term:         in.Term,
+		Candidate:    in.Identity,
+		LastLogIndex: in.LastLogIndex,
+		LastLogTerm:  in.LastLogTerm,
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.VoteReply{Term: rep.Term, Granted: rep.Granted}, nil
+}
+
+// AppendEntries handles a request from the leader to append entries to the log
+// of this member.
+func (s *Server) AppendEntries(ctx context.Context, in *pb.AppendRequest) (*pb.AppendReply, error) {
+	if s.node == nil {
+		return nil, status.Error(codes.FailedPrecondition, "server is not a member of a replicated cluster")
+	}
+
+	req := &raft.AppendRequest{
+		Term:         in.Term,
+		Leader:       in.Identity,
+		PrevLogIndex: in.PrevLogIndex,
+		PrevLogTerm:  in.PrevLogTerm,
+		Entries:      make([]raft.Entry, 0, len(in.Entries)),
+		LeaderCommit: in.LeaderCommit,
+	}
+	for _, entry := range in.Entries {
+		req.Entries = append(req.Entries, raft.Entry{Index: entry.Index, Term: entry.Term, Command: entry.Command})
+	}
+
+	rep, err := s.node.AppendEntries(req)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.AppendReply{Term: rep.Term, Success: rep.Success, ConflictIndex: rep.ConflictIndex}, nil
+}
+
+//===========================================================================
+// Raft Transport
+//===========================================================================
+
+// The maximum time between attempts to reconnect to a member of the group.
+const maxRaftBackoff = time.Second
+
+// RaftTransport implements raft.Transport with gRPC requests to the Raft
+// service of the other members of a replicated cluster, connecting to each
+// member when a request is first sent to it.
+type RaftTransport struct {
+	sync.Mutex
+	addrs map[string]string // the addresses of the members by name
+	opts  []grpc.DialOption
+	conns map[string]*grpc.ClientConn
+	peers map[string]pb.RaftClient
+}
+
+// NewRaftTransport creates a transport to the members, over TLS if the config
+// is not nil. If the servers authenticate clients with tokens, the token is
+// sent with every request.
+func NewRaftTransport(members []cluster.Member, conf *tls.Config, token string) *RaftTransport {
+	creds := grpc.WithInsecure()
+	if conf != nil {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(conf))
+	}
+
+	// Reconnect quickly to members that restart so that they rejoin the group
+	opts := []grpc.DialOption{creds, grpc.WithBackoffMaxDelay(maxRaftBackoff)}
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials{token: token}))
+	}
+
+	t := &RaftTransport{
+		addrs: make(map[string]string, len(members)),
+		opts:  opts,
+		conns: make(map[string]*grpc.ClientConn),
+		peers: make(map[string]pb.RaftClient),
+	}
+	for _, member := range members {
+		t.addrs[member.Name] = member.Addr
+	}
+	return t
+}
+
+// RequestVote sends the request of a candidate to the member.
+func (t *RaftTransport) RequestVote(ctx context.Context, peer string, req *raft.VoteRequest) (*raft.VoteReply, error) {
+	client, err := t.peer(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	rep, err := client.RequestVote(ctx, &pb.VoteRequest{
+		Identity:     req.Candidate,
+		Term:         req.Term,
+		LastLogIndex: req.LastLogIndex,
+		LastLogTerm:  req.LastLogTerm,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &raft.VoteReply{Term: rep.Term, Granted: rep.Granted}, nil
+}
+
+// AppendEntries sends the entries of the leader to the member.
+func (t *RaftTransport) AppendEntries(ctx context.Context, peer string, req *raft.AppendRequest) (*raft.AppendReply, error) {
+	client, err := t.peer(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	in := &pb.AppendRequest{
+		Identity:     req.Leader,
+		Term:         req.Term,
+		PrevLogIndex: req.PrevLogIndex,
+		PrevLogTerm:  req.PrevLogTerm,
+		Entries:      make([]*pb.Entry, 0, len(req.Entries)),
+		LeaderCommit: req.LeaderCommit,
+	}
+	for _, entry := range req.Entries {
+		in.Entries = append(in.Entries, &pb.Entry{Index: entry.Index, Term: entry.Term, Command: entry.Command})
+	}
+
+	rep, err := client.AppendEntries(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return &raft.AppendReply{Term: rep.Term, Success: rep.Success, ConflictIndex: rep.ConflictIndex}, nil
+}
+
+// Close the connections to the members.
+func (t *RaftTransport) Close() (err error) {
+	t.Lock()
+	defer t.Unlock()
+
+	for name, conn := range t.conns {
+		if cerr := conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(t.conns, name)
+		delete(t.peers, name)
+	}
+	return err
+}
+
+// Returns a client for the member, connecting to it on first use. Dialing
+// does not block, so members that are down are retried on each request.
+func (t *RaftTransport) peer(name string) (pb.RaftClient, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	if client, ok := t.peers[name]; ok {
+		return client, nil
+	}
+
+	addr, ok := t.addrs[name]
+	if !ok {
+		return nil, fmt.Errorf("%q is not a member of the cluster", name)
+	}
+
+	conn, err := grpc.Dial(addr, t.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t.conns[name] = conn
+	t.peers[name] = pb.NewRaftClient(conn)
+	return t.peers[name], nil
+}
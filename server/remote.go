@@ -0,0 +1,218 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+	"github.com/bbengfort/speedmap/server/pb"
+)
+
+// The requests made by the clients of a remote store, either with unary RPCs
+// or on a pipeline.
+type requester interface {
+	Get(key string) (*pb.ClientReply, error)
+	Put(key string, value []byte) (*pb.ClientReply, error)
+	Del(key string, force bool) (*pb.ClientReply, error)
+}
+
+// A client in the pool of a remote store. Batches and puts with a TTL are
+// always unary RPCs.
+type remoteClient struct {
+	*Client
+	ops requester
+}
+
+// Remote implements speedmap.Store with requests to a speedmap server so that
+// the workloads (or any code written against a store) can run unchanged
+// against a server over the network. Errors returned by the server are mapped
+// back to the standard speedmap errors, so errors.Is works as it does on a
+// local store, and replies that are not successful are returned as errors.
+//
+// Each operation takes a client from the pool of the store for the duration
+// of its request, so at most as many requests are in flight as there are
+// clients. The latency of every request is recorded, so the store is
+// speedmap.Timed and benchmarks report its latency percentiles. Remote also
+// implements speedmap.Batcher with batch requests and speedmap.Expirer with
+// puts with a TTL, which require an expiring store on the server.
+//
+// The server creates keys that are not found by a get with a nil value, so
+// nil values are returned as not found, and GetOrCreate is a get followed by
+// a put if the key was not found, which is not atomic.
+type Remote struct {
+	name      string
+	pool      chan *remoteClient
+	pipelines []*Pipeline
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+// NewRemote creates a store whose operations are unary requests made by the
+// clients, which must be connected. The clients are not closed with the store.
+func NewRemote(clients ...*Client) (store *Remote, err error) {
+	if len(clients) == 0 {
+		return nil, errors.New("a remote store requires at least one client")
+	}
+
+	store = &Remote{name: "remote", pool: make(chan *remoteClient, len(clients))}
+	for _, client := range clients {
+		store.pool <- &remoteClient{Client: client, ops: client}
+	}
+	return store, nil
+}
+
+// NewPipelinedRemote creates a store whose gets, puts and deletes are made on
+// a pipeline opened by each client with the specified window, rather than
+// with unary requests. The pipelines are closed with the store, the clients
+// are not.
+func NewPipelinedRemote(window int, clients ...*Client) (store *Remote, err error) {
+	if len(clients) == 0 {
+		return nil, errors.New("a remote store requires at least one client")
+	}
+
+	store = &Remote{name: "remote pipelined", pool: make(chan *remoteClient, len(clients))}
+	for _, client := range clients {
+		var p *Pipeline
+		if p, err = client.Pipeline(window); err != nil {
+			store.Close()
+			return nil, err
+		}
+
+		store.pipelines = append(store.pipelines, p)
+		store.pool <- &remoteClient{Client: client, ops: p}
+	}
+	return store, nil
+}
+
+// Get a value from the server; nil values are returned as not found.
+func (s *Remote) Get(key string) (value []byte, err error) {
+	var rep *pb.ClientReply
+	s.do(func(c *remoteClient) { rep, err = c.ops.Get(key) })
+	if err = replyError(rep, err); err != nil {
+		return nil, err
+	}
+
+	if value = rep.Pair.GetValue(); value == nil {
+		return nil, speedmap.ErrNotFound
+	}
+	return value, nil
+}
+
+// Put a value to the server.
+func (s *Remote) Put(key string, value []byte) (err error) {
+	var rep *pb.ClientReply
+	s.do(func(c *remoteClient) { rep, err = c.ops.Put(key, value) })
+	return replyError(rep, err)
+}
+
+// PutWithTTL puts a value to the server that expires after the ttl.
+func (s *Remote) PutWithTTL(key string, value []byte, ttl time.Duration) (err error) {
+	var rep *pb.ClientReply
+	s.do(func(c *remoteClient) { rep, err = c.PutWithTTL(key, value, ttl) })
+	return replyError(rep, err)
+}
+
+// Delete a key from the server.
+func (s *Remote) Delete(key string) (err error) {
+	var rep *pb.ClientReply
+	s.do(func(c *remoteClient) { rep, err = c.ops.Del(key, false) })
+	return replyError(rep, err)
+}
+
+// GetOrCreate gets the value from the server, putting the value if it is not
+// found. Both requests are recorded as separate operations.
+func (s *Remote) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	var err error
+	if actual, err = s.Get(key); err == nil {
+		return actual, false
+	}
+
+	if err = s.Put(key, value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// MultiGet gets the values of the keys from the server in a single request.
+func (s *Remote) MultiGet(keys []string) (values [][]byte, err error) {
+	var rep *pb.BatchReply
+	s.do(func(c *remoteClient) { rep, err = c.BatchGet(keys) })
+	if err != nil {
+		return nil, storeError(err)
+	}
+
+	if !rep.Success {
+		return nil, errors.New(rep.Error)
+	}
+
+	values = make([][]byte, len(rep.Pairs))
+	for i, pair := range rep.Pairs {
+		values[i] = pair.Value
+	}
+	return values, nil
+}
+
+// MultiPut puts the pairs to the server in a single request.
+func (s *Remote) MultiPut(pairs map[string][]byte) (err error) {
+	var rep *pb.BatchReply
+	s.do(func(c *remoteClient) { rep, err = c.BatchPut(pairs) })
+	if err != nil {
+		return storeError(err)
+	}
+
+	if !rep.Success {
+		return errors.New(rep.Error)
+	}
+	return nil
+}
+
+// Latencies returns the latencies of the requests since it was last called.
+func (s *Remote) Latencies() []time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latencies := s.latencies
+	s.latencies = nil
+	return latencies
+}
+
+// Close the pipelines of the store, if any.
+func (s *Remote) Close() (err error) {
+	for _, p := range s.pipelines {
+		if perr := p.Close(); perr != nil && err == nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+// String returns the name of the store for the benchmark results.
+func (s *Remote) String() string {
+	return s.name
+}
+
+// Makes a request with a client from the pool, recording its latency.
+func (s *Remote) do(request func(c *remoteClient)) {
+	client := <-s.pool
+	start := time.Now()
+	request(client)
+	latency := time.Since(start)
+	s.pool <- client
+
+	s.mu.Lock()
+	s.latencies = append(s.latencies, latency)
+	s.mu.Unlock()
+}
+
+// Returns the error of a request or its reply as a speedmap error.
+func replyError(rep *pb.ClientReply, err error) error {
+	if err != nil {
+		return storeError(err)
+	}
+
+	if !rep.Success {
+		return errors.New(rep.Error)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package server_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/server"
+	"github.com/bbengfort/speedmap/store"
+	"github.com/bbengfort/speedmap/workload"
+)
+
+var _ = Describe("Remote", func() {
+
+	var (
+		kv     store.Shard
+		srv    *Server
+		client *Client
+		remote *Remote
+	)
+
+	BeforeEach(func() {
+		var err error
+		kv, err = store.NewShard()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		srv = New(kv)
+		client = NewClient("remote")
+		Ω(client.Connect(listen(srv))).Should(Succeed())
+
+		clients := make([]*Client, 0, 4)
+		for i := 1; i <= 4; i++ {
+			clients = append(clients, client.WithIdentity(fmt.Sprintf("remote-%d", i)))
+		}
+
+		remote, err = NewRemote(clients...)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(remote.Close()).Should(Succeed())
+		Ω(client.Close()).Should(Succeed())
+		shutdown(srv)
+	})
+
+	It("should require a client", func() {
+		_, err := NewRemote()
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should perform store operations on the server", func() {
+		Ω(remote.Put("foo", []byte("bar"))).Should(Succeed())
+
+		val, err := kv.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		val, err = remote.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		Ω(remote.Delete("foo")).Should(Succeed())
+		_, err = remote.Get("foo")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+
+		err = remote.Put(strings.Repeat("a", speedmap.MaxKeySize+1), []byte("bar"))
+		Ω(errors.Is(err, speedmap.ErrKeyTooLarge)).Should(BeTrue())
+		Ω(remote.Latencies()).Should(HaveLen(5))
+	})
+
+	It("should put values with a ttl through a watched store", func() {
+		expiring, err := store.NewExpiring(0)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer expiring.Close()
+
+		watched, err := store.NewWatched(expiring)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		ttlSrv := New(watched)
+		defer shutdown(ttlSrv)
+
+		ttlClient := NewClient("ttl")
+		Ω(ttlClient.Connect(listen(ttlSrv))).Should(Succeed())
+		defer ttlClient.Close()
+
+		ttlRemote, err := NewRemote(ttlClient)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer ttlRemote.Close()
+
+		Ω(ttlRemote.PutWithTTL("foo", []byte("bar"), time.Minute)).Should(Succeed())
+		Ω(ttlRemote.PutWithTTL("baz", []byte("qux"), 500*time.Microsecond)).Should(Succeed())
+
+		// A ttl of less than a millisecond is rounded up instead of never expiring
+		Eventually(func() bool {
+			_, err := expiring.Get("baz")
+			return errors.Is(err, speedmap.ErrNotFound)
+		}).Should(BeTrue())
+
+		val, err := ttlRemote.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+	})
+
+	It("should run the conflict workload against the server", func() {
+		clients := 4
+		result, err := workload.NewConflict(0.0, 0.5).Run(remote, clients)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result.Operations).Should(BeEquivalentTo(clients * workload.OpsPerThread))
+		Ω(result.Store).Should(Equal(remote))
+
+		// Every request was made and timed, gets of missing keys are followed by a put
+		Ω(len(remote.Latencies())).Should(BeNumerically(">=", clients*workload.OpsPerThread))
+
+		// Without conflicts, each key on the server was only written by its own client
+		written := 0
+		err = kv.Range(func(key string, value []byte) bool {
+			if value == nil {
+				return true
+			}
+
+			n, err := strconv.ParseInt(key, 16, 64)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			writer, err := strconv.ParseInt(strings.SplitN(string(value), "-", 2)[0], 16, 64)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(writer).Should(Equal(n / workload.MaxKeys))
+			Ω(writer).Should(BeNumerically(">=", 1))
+			Ω(writer).Should(BeNumerically("<=", clients))
+
+			written++
+			return true
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(written).Should(BeNumerically(">", 0))
+	})
+
+})
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+	"github.com/bbengfort/speedmap/replica"
+	"github.com/bbengfort/speedmap/server/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// Follow handles a request from a backup to stream the mutations of the store,
+// which must be a replica.Primary or a promoted replica.Backup, see
+// replica.Source. The stream ends when the server is shut down.
+func (s *Server) Follow(in *pb.FollowRequest, stream pb.Replication_FollowServer) error {
+	replicator, ok := s.replicator()
+	if !ok {
+		return status.Errorf(codes.FailedPrecondition, "the %s store is not replicated", s.kv)
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := replicator.Sync(ctx, in.Epoch, in.From, func(m *replica.Mutation) error {
+		return stream.Send(mutationPB(m))
+	})
+
+	select {
+	case <-s.done:
+		return status.Error(codes.Unavailable, "server is shutting down")
+	default:
+		return statusError(err)
+	}
+}
+
+// Replication handles an admin request for the status of the replication of
+// the store, including the lag of a backup.
+func (s *Server) Replication(ctx context.Context, in *pb.ReplicationRequest) (*pb.ReplicationReply, error) {
+	replicator, ok := s.replicator()
+	if !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "the %s store is not replicated", s.kv)
+	}
+	return replicationReply(replicator.Status()), nil
+}
+
+// Promote handles an admin request to promote a backup to the primary of its
+// store, so that it accepts writes and other backups can follow it. Writes
+// that the backup has not received from its primary are lost.
+func (s *Server) Promote(ctx context.Context, in *pb.PromoteRequest) (*pb.ReplicationReply, error) {
+	backup, ok := speedmap.Unwrap(s.kv).(*replica.Backup)
+	if !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "the %s store is not a backup", s.kv)
+	}
+
+	if err := backup.Promote(); err != nil {
+		return nil, statusError(err)
+	}
+	return replicationReply(backup.Status()), nil
+}
+
+// Returns the store as a replica.Replicator if it is replicated.
+func (s *Server) replicator() (replica.Replicator, bool) {
+	replicator, ok := speedmap.Unwrap(s.kv).(replica.Replicator)
+	return replicator, ok
+}
+
+// Returns the address of the primary if the store is a backup that has not
+// been promoted.
+func (s *Server) primary() (addr string, ok bool) {
+	if replicator, ok := s.replicator(); ok {
+		if status := replicator.Status(); status.Role == "backup" {
+			return status.Primary, true
+		}
+	}
+	return "", false
+}
+
+func replicationReply(status replica.Status) *pb.ReplicationReply {
+	rep := &pb.ReplicationReply{
+		Success:   true,
+		Role:      status.Role,
+		Primary:   status.Primary,
+		Connected: status.Connected,
+		Epoch:     status.Epoch,
+		Head:      status.Head,
+		Applied:   status.Applied,
+		Lag:       int64(status.Lag),
+		Backups:   uint32(status.Backups),
+	}
+
+	if status.Err != nil {
+		rep.StreamError = status.Err.Error()
+	}
+	return rep
+}
+
+//===========================================================================
+// Replication Source
+//===========================================================================
+
+// ReplicationSource implements replica.Source with the Follow RPC of a primary
+// (or a promoted backup) so that a replica.Backup can stream its mutations.
+type ReplicationSource struct {
+	identity string
+	conn     *grpc.ClientConn
+	client   pb.ReplicationClient
+}
+
+// NewReplicationSource connects to the primary at the address, over TLS if the
+// config is not nil. Requests are made with the identity, and if the servers
+// authenticate clients with tokens, the token is sent with every request.
+// Dialing does not block, so the primary does not have to be available.
+func NewReplicationSource(addr, identity string, conf *tls.Config, token string) (source *ReplicationSource, err error) {
+	creds := grpc.WithInsecure()
+	if conf != nil {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(conf))
+	}
+
+	// Reconnect quickly to a primary that restarts so that backups catch up
+	opts := []grpc.DialOption{creds, grpc.WithBackoffMaxDelay(maxRaftBackoff)}
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials{token: token}))
+	}
+
+	source = &ReplicationSource{identity: identity}
+	if source.conn, err = grpc.Dial(addr, opts...); err != nil {
+		return nil, err
+	}
+	source.client = pb.NewReplicationClient(source.conn)
+	return source, nil
+}
+
+// Follow opens a stream of the mutations of the primary, see replica.Source.
+func (r *ReplicationSource) Follow(ctx context.Context, epoch, from uint64) (replica.Stream, error) {
+	stream, err := r.client.Follow(ctx, &pb.FollowRequest{Identity: r.identity, Epoch: epoch, From: from})
+	if err != nil {
+		return nil, err
+	}
+	return &mutationStream{stream: stream}, nil
+}
+
+// Close the connection to the primary.
+func (r *ReplicationSource) Close() error {
+	return r.conn.Close()
+}
+
+// Converts the mutations received from the primary.
+type mutationStream struct {
+	stream pb.Replication_FollowClient
+}
+
+func (m *mutationStream) Recv() (*replica.Mutation, error) {
+	in, err := m.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	mutation := &replica.Mutation{
+		Seq:   in.Seq,
+		Op:    replica.Op(in.Op),
+		Key:   in.Key,
+		Value: in.Value,
+		Head:  in.Head,
+		Epoch: in.Epoch,
+	}
+
+	if in.Expires != 0 {
+		mutation.Expires = time.Unix(0, in.Expires)
+	}
+
+	if in.Time != 0 {
+		mutation.Time = time.Unix(0, in.Time)
+	}
+	return mutation, nil
+}
+
+// Converts a mutation to send it to a backup.
+func mutationPB(m *replica.Mutation) *pb.Mutation {
+	out := &pb.Mutation{
+		Seq:   m.Seq,
+		Op:    pb.Mutation_Op(m.Op),
+		Key:   m.Key,
+		Value: m.Value,
+		Head:  m.Head,
+		Epoch: m.Epoch,
+	}
+
+	if !m.Expires.IsZero() {
+		out.Expires = m.Expires.UnixNano()
+	}
+
+	if !m.Time.IsZero() {
+		out.Time = m.Time.UnixNano()
+	}
+	return out
+}
@@ -0,0 +1,270 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Limits on the requests accepted by the RESP listener, matching Redis. Until
+// a client is authenticated (if the server requires it), its commands are
+// limited to a few small arguments so that it cannot make the server allocate
+// large requests.
+const (
+	respMaxInline     = 64 * 1024
+	respMaxBulk       = 512 * 1024 * 1024
+	respMaxArgs       = 1024 * 1024
+	respMaxNoAuthBulk = 16 * 1024
+	respMaxNoAuthArgs = 10
+	respBulkChunk     = 64 * 1024 // bulks larger than this are read as they arrive
+)
+
+// ListenRESP serves the store on the specified address using the Redis
+// serialization protocol (RESP2, or RESP3 after HELLO 3) so that Redis clients
+// and benchmarking tools can be used with any store. Commands are handled in
+// the order they are received on each connection and replies are buffered
+// until there are no more pipelined commands to read. The listener uses the
+// TLS configuration, authenticator and ACL of the server; clients
+// authenticate with AUTH when the server uses token authentication. ListenRESP
+// blocks until the server is shut down, then returns nil.
+func (s *Server) ListenRESP(addr string) (err error) {
+	var sock net.Listener
+	if sock, err = s.listen(addr); err != nil {
+		return fmt.Errorf("could not listen on %s", addr)
+	}
+
+	fmt.Printf("serving the %s store with the redis protocol on %s\n", s.kv.String(), addr)
+	return s.accept(sock, s.serveRESP)
+}
+
+// A connection from a RESP client and its state.
+type respConn struct {
+	server   *Server
+	conn     net.Conn
+	r        *bufio.Reader
+	w        *bufio.Writer
+	proto    int    // the protocol version of replies, 2 or 3
+	identity string // the identity of the client, if authenticated
+	authed   bool   // whether the client has been authenticated
+	name     string // the name set by CLIENT SETNAME
+}
+
+// Handles the commands sent on the connection until it is closed.
+func (s *Server) serveRESP(conn net.Conn) {
+	c := &respConn{
+		server: s,
+		conn:   conn,
+		r:      bufio.NewReaderSize(conn, 16*1024),
+		w:      bufio.NewWriterSize(conn, 16*1024),
+		proto:  2,
+	}
+
+	// Authenticate clients by their certificate as soon as the handshake is done
+	c.identity, c.authed = s.tlsIdentity(conn)
+
+	for {
+		select {
+		case <-s.done:
+			c.w.Flush()
+			return
+		default:
+		}
+
+		args, err := c.readCommand()
+		if err != nil {
+			var perr respProtocolError
+			if errors.As(err, &perr) {
+				c.writeError("ERR Protocol error: " + perr.Error())
+			}
+			c.w.Flush()
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := c.dispatch(args)
+
+		// Flush once there are no more pipelined commands to handle
+		if quit || c.r.Buffered() == 0 {
+			if err = c.w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+//===========================================================================
+// Protocol parsing
+//===========================================================================
+
+// An error in the protocol sent by the client, after which the connection
+// is closed.
+type respProtocolError string
+
+func (e respProtocolError) Error() string {
+	return string(e)
+}
+
+// Reads a command, either as an array of bulk strings or as an inline command
+// of space separated arguments.
+func (c *respConn) readCommand() (args [][]byte, err error) {
+	var line []byte
+	if line, err = c.readLine(); err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		fields := strings.Fields(string(line))
+		args = make([][]byte, len(fields))
+		for i, field := range fields {
+			args[i] = []byte(field)
+		}
+		return args, nil
+	}
+
+	maxArgs, maxBulk := respMaxArgs, respMaxBulk
+	if c.server.auth != nil && !c.authed {
+		maxArgs, maxBulk = respMaxNoAuthArgs, respMaxNoAuthBulk
+	}
+
+	var n int
+	if n, err = strconv.Atoi(string(line[1:])); err != nil || n > respMaxArgs {
+		return nil, respProtocolError("invalid multibulk length")
+	}
+
+	if n > maxArgs {
+		return nil, respProtocolError("unauthenticated multibulk length")
+	}
+
+	// Grow the arguments as they arrive rather than trusting the length
+	capacity := n
+	if capacity > respMaxNoAuthArgs {
+		capacity = respMaxNoAuthArgs
+	}
+
+	args = make([][]byte, 0, capacity)
+	for i := 0; i < n; i++ {
+		if line, err = c.readLine(); err != nil {
+			return nil, err
+		}
+
+		if len(line) == 0 || line[0] != '$' {
+			return nil, respProtocolError(fmt.Sprintf("expected '$', got '%s'", line))
+		}
+
+		var size int
+		if size, err = strconv.Atoi(string(line[1:])); err != nil || size < 0 || size > respMaxBulk {
+			return nil, respProtocolError("invalid bulk length")
+		}
+
+		if size > maxBulk {
+			return nil, respProtocolError("unauthenticated bulk length")
+		}
+
+		var arg []byte
+		if arg, err = c.readBulk(size); err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// Reads a bulk string of the size followed by its CRLF. Large bulks are read
+// in chunks as they arrive rather than allocated up front, so that the memory
+// used by a bulk is bounded by the bytes the client actually sends.
+func (c *respConn) readBulk(size int) (arg []byte, err error) {
+	if size+2 <= respBulkChunk {
+		arg = make([]byte, size+2)
+		if _, err = io.ReadFull(c.r, arg); err != nil {
+			return nil, err
+		}
+	} else {
+		buf := bytes.NewBuffer(make([]byte, 0, respBulkChunk))
+		if _, err = io.CopyN(buf, c.r, int64(size+2)); err != nil {
+			return nil, err
+		}
+		arg = buf.Bytes()
+	}
+
+	if arg[size] != '\r' || arg[size+1] != '\n' {
+		return nil, respProtocolError("bulk string is not terminated by CRLF")
+	}
+	return arg[:size], nil
+}
+
+// Reads a line of an inline command or of the protocol.
+func (c *respConn) readLine() ([]byte, error) {
+	line, err := readLine(c.r, respMaxInline)
+	if err == errLineTooLong {
+		return nil, respProtocolError("too big inline request")
+	}
+	return line, err
+}
+
+//===========================================================================
+// Protocol replies
+//===========================================================================
+
+func (c *respConn) writeSimple(s string) {
+	c.w.WriteByte('+')
+	c.w.WriteString(s)
+	c.w.WriteString("\r\n")
+}
+
+func (c *respConn) writeError(msg string) {
+	c.w.WriteByte('-')
+	c.w.WriteString(strings.NewReplacer("\r", " ", "\n", " ").Replace(msg))
+	c.w.WriteString("\r\n")
+}
+
+func (c *respConn) writeInt(n int64) {
+	c.w.WriteByte(':')
+	c.w.WriteString(strconv.FormatInt(n, 10))
+	c.w.WriteString("\r\n")
+}
+
+func (c *respConn) writeBulk(b []byte) {
+	c.w.WriteByte('$')
+	c.w.WriteString(strconv.Itoa(len(b)))
+	c.w.WriteString("\r\n")
+	c.w.Write(b)
+	c.w.WriteString("\r\n")
+}
+
+func (c *respConn) writeBulkString(s string) {
+	c.writeBulk([]byte(s))
+}
+
+// Writes a null, which is a null bulk string in RESP2.
+func (c *respConn) writeNull() {
+	if c.proto == 3 {
+		c.w.WriteString("_\r\n")
+		return
+	}
+	c.w.WriteString("$-1\r\n")
+}
+
+func (c *respConn) writeArray(n int) {
+	c.w.WriteByte('*')
+	c.w.WriteString(strconv.Itoa(n))
+	c.w.WriteString("\r\n")
+}
+
+// Writes the header of a map of n pairs, which is a flat array in RESP2.
+func (c *respConn) writeMap(n int) {
+	if c.proto == 3 {
+		c.w.WriteByte('%')
+		c.w.WriteString(strconv.Itoa(n))
+		c.w.WriteString("\r\n")
+		return
+	}
+	c.writeArray(2 * n)
+}
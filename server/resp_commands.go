@@ -0,0 +1,643 @@
+package server
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// A command handled by the RESP listener.
+type respCommand struct {
+	arity   int                              // number of arguments including the name, negative for a minimum
+	noauth  bool                             // may be sent before the client is authenticated
+	handler func(c *respConn, args [][]byte) // writes the reply to the command
+}
+
+// The commands supported by the RESP listener, by their upper case name.
+var respCommands = map[string]respCommand{
+	"GET":     {arity: 2, handler: (*respConn).get},
+	"SET":     {arity: -3, handler: (*respConn).set},
+	"SETNX":   {arity: 3, handler: (*respConn).setnx},
+	"DEL":     {arity: -2, handler: (*respConn).del},
+	"MGET":    {arity: -2, handler: (*respConn).mget},
+	"EXISTS":  {arity: -2, handler: (*respConn).exists},
+	"SCAN":    {arity: -2, handler: (*respConn).scan},
+	"PING":    {arity: -1, handler: (*respConn).ping},
+	"ECHO":    {arity: 2, handler: (*respConn).echo},
+	"INFO":    {arity: -1, handler: (*respConn).info},
+	"HELLO":   {arity: -1, noauth: true, handler: (*respConn).hello},
+	"AUTH":    {arity: -2, noauth: true, handler: (*respConn).auth},
+	"SELECT":  {arity: 2, handler: (*respConn).selectdb},
+	"CLIENT":  {arity: -2, handler: (*respConn).client},
+	"COMMAND": {arity: -1, handler: (*respConn).command},
+	"CONFIG":  {arity: -2, handler: (*respConn).config},
+}
+
+// The default number of keys examined by each SCAN.
+const respScanCount = 10
+
+// Handles the command, returning true if the connection should be closed.
+func (c *respConn) dispatch(args [][]byte) (quit bool) {
+	name := strings.ToUpper(string(args[0]))
+	if name == "QUIT" {
+		c.writeSimple("OK")
+		return true
+	}
+
+	cmd, ok := respCommands[name]
+	if !ok {
+		c.writeError(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return false
+	}
+
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		c.writeError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return false
+	}
+
+	if c.server.auth != nil && !c.authed && !cmd.noauth {
+		c.writeError("NOAUTH Authentication required.")
+		return false
+	}
+
+	c.server.count(c.identity)
+	cmd.handler(c, args)
+	return false
+}
+
+// Returns true if the ACL of the server allows the operation on every key,
+// otherwise writes a permission error and returns false.
+func (c *respConn) allow(op Operation, keys ...[]byte) bool {
+	if c.server.acl == nil {
+		return true
+	}
+
+	for _, key := range keys {
+		if !c.server.acl.Allowed(c.identity, op, string(key)) {
+			c.writeError(fmt.Sprintf("NOPERM %s may not %s %q", c.identity, op, key))
+			return false
+		}
+	}
+	return true
+}
+
+// Writes a store error as a generic error, or as a READONLY error if the store
+// is a backup so that redis clients can send the write to the primary.
+func (c *respConn) writeStoreError(err error) {
+	if errors.Is(err, speedmap.ErrReadOnly) {
+		c.writeError("READONLY " + err.Error())
+		return
+	}
+	c.writeError("ERR " + err.Error())
+}
+
+//===========================================================================
+// Key/value commands
+//===========================================================================
+
+// GET key
+func (c *respConn) get(args [][]byte) {
+	if !c.allow(OpGet, args[1]) {
+		return
+	}
+
+	val, err := c.server.kv.Get(string(args[1]))
+	switch {
+	case errors.Is(err, speedmap.ErrNotFound):
+		c.writeNull()
+	case err != nil:
+		c.writeStoreError(err)
+	default:
+		c.writeBulk(val)
+	}
+}
+
+// SET key value [EX seconds | PX milliseconds] [NX]
+//
+// SET with NX uses GetOrCreate, and SET with an expiration requires a store
+// that is a speedmap.Expirer; the two cannot be combined.
+func (c *respConn) set(args [][]byte) {
+	var (
+		ttl time.Duration
+		nx  bool
+	)
+
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "NX":
+			nx = true
+		case (opt == "EX" || opt == "PX") && i+1 < len(args) && ttl == 0:
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil || n <= 0 {
+				c.writeError("ERR invalid expire time in 'set' command")
+				return
+			}
+
+			i++
+			ttl = time.Duration(n) * time.Millisecond
+			if opt == "EX" {
+				ttl *= 1000
+			}
+		default:
+			c.writeError("ERR syntax error")
+			return
+		}
+	}
+
+	if !c.allow(OpPut, args[1]) {
+		return
+	}
+
+	key, value := string(args[1]), args[2]
+	switch {
+	case nx && ttl > 0:
+		c.writeError("ERR NX with an expiration is not supported")
+	case nx:
+		if _, created := c.server.kv.GetOrCreate(key, value); created {
+			c.writeSimple("OK")
+		} else {
+			c.writeNull()
+		}
+	case ttl > 0:
+		expirer, ok := speedmap.Unwrap(c.server.kv).(speedmap.Expirer)
+		if !ok {
+			c.writeError(fmt.Sprintf("ERR the %s store does not support expiration", c.server.kv))
+			return
+		}
+
+		if err := expirer.PutWithTTL(key, value, ttl); err != nil {
+			c.writeStoreError(err)
+			return
+		}
+		c.writeSimple("OK")
+	default:
+		if err := c.server.kv.Put(key, value); err != nil {
+			c.writeStoreError(err)
+			return
+		}
+		c.writeSimple("OK")
+	}
+}
+
+// SETNX key value
+func (c *respConn) setnx(args [][]byte) {
+	if !c.allow(OpPut, args[1]) {
+		return
+	}
+
+	if _, created := c.server.kv.GetOrCreate(string(args[1]), args[2]); created {
+		c.writeInt(1)
+		return
+	}
+	c.writeInt(0)
+}
+
+// DEL key [key ...]
+func (c *respConn) del(args [][]byte) {
+	if !c.allow(OpDelete, args[1:]...) {
+		return
+	}
+
+	var deleted int64
+	for _, key := range args[1:] {
+		if _, err := c.server.kv.Get(string(key)); err == nil {
+			deleted++
+		}
+
+		if err := c.server.kv.Delete(string(key)); err != nil {
+			c.writeStoreError(err)
+			return
+		}
+	}
+	c.writeInt(deleted)
+}
+
+// MGET key [key ...]
+func (c *respConn) mget(args [][]byte) {
+	if !c.allow(OpGet, args[1:]...) {
+		return
+	}
+
+	keys := make([]string, len(args)-1)
+	for i, key := range args[1:] {
+		keys[i] = string(key)
+	}
+
+	vals, err := speedmap.MultiGet(c.server.kv, keys)
+	if err != nil {
+		c.writeStoreError(err)
+		return
+	}
+
+	c.writeArray(len(vals))
+	for _, val := range vals {
+		if val == nil {
+			c.writeNull()
+			continue
+		}
+		c.writeBulk(val)
+	}
+}
+
+// EXISTS key [key ...]
+func (c *respConn) exists(args [][]byte) {
+	if !c.allow(OpGet, args[1:]...) {
+		return
+	}
+
+	var found int64
+	for _, key := range args[1:] {
+		if _, err := c.server.kv.Get(string(key)); err == nil {
+			found++
+		}
+	}
+	c.writeInt(found)
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count]
+//
+// Stores that are a speedmap.Scanner are scanned in key order and the cursor
+// encodes the key to resume the scan from; other speedmap.Iterable stores
+// return all of their keys at once. Keys that the client may not get are
+// skipped.
+func (c *respConn) scan(args [][]byte) {
+	cursor, pattern, count := string(args[1]), "*", respScanCount
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "MATCH" && i+1 < len(args):
+			pattern = string(args[i+1])
+			i++
+		case opt == "COUNT" && i+1 < len(args):
+			n, err := strconv.Atoi(string(args[i+1]))
+			if err != nil || n < 1 {
+				c.writeError("ERR syntax error")
+				return
+			}
+			count = n
+			i++
+		default:
+			c.writeError("ERR syntax error")
+			return
+		}
+	}
+
+	var start string
+	if cursor != "0" {
+		key, err := hex.DecodeString(cursor)
+		if err != nil || len(key) == 0 {
+			c.writeError("ERR invalid cursor")
+			return
+		}
+		start = string(key)
+	}
+
+	// Keys matching the pattern all begin with its literal prefix
+	prefix := pattern
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		prefix = pattern[:i]
+	}
+	if start < prefix {
+		start = prefix
+	}
+
+	_, ordered := speedmap.Unwrap(c.server.kv).(speedmap.Scanner)
+	if !ordered {
+		count = -1
+	}
+
+	var (
+		keys []string
+		next string
+		seen int
+	)
+
+	visit := func(key string, _ []byte) bool {
+		// Scans start at the prefix, so the first key without it ends the scan
+		if !strings.HasPrefix(key, prefix) {
+			return !ordered
+		}
+
+		if seen == count {
+			next = key
+			return false
+		}
+		seen++
+
+		if respMatch(pattern, key) && (c.server.acl == nil || c.server.acl.Allowed(c.identity, OpGet, key)) {
+			keys = append(keys, key)
+		}
+		return true
+	}
+
+	var err error
+	if ordered {
+		err = speedmap.Scan(c.server.kv, start, "", visit)
+	} else {
+		err = speedmap.Range(c.server.kv, visit)
+	}
+
+	if err != nil {
+		c.writeError(fmt.Sprintf("ERR the %s store does not support scan", c.server.kv))
+		return
+	}
+
+	c.writeArray(2)
+	if next == "" {
+		c.writeBulkString("0")
+	} else {
+		c.writeBulkString(hex.EncodeToString([]byte(next)))
+	}
+
+	c.writeArray(len(keys))
+	for _, key := range keys {
+		c.writeBulkString(key)
+	}
+}
+
+// Returns true if the string matches the glob-style pattern: * matches any
+// sequence of bytes, ? matches any byte, [abc] and [a-z] match any byte in
+// the class ([^abc] any byte not in it), and \ escapes the next byte.
+func respMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if respMatch(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+
+			end := strings.IndexByte(pattern[1:], ']') + 1
+			if end <= 1 {
+				// An unterminated class matches a literal [
+				if s[0] != '[' {
+					return false
+				}
+				pattern, s = pattern[1:], s[1:]
+				continue
+			}
+
+			class, negate := pattern[1:end], false
+			if len(class) > 0 && class[0] == '^' {
+				class, negate = class[1:], true
+			}
+
+			matched := false
+			for i := 0; i < len(class); i++ {
+				if i+2 < len(class) && class[i+1] == '-' {
+					if class[i] <= s[0] && s[0] <= class[i+2] {
+						matched = true
+					}
+					i += 2
+				} else if class[i] == s[0] {
+					matched = true
+				}
+			}
+
+			if matched == negate {
+				return false
+			}
+			pattern, s = pattern[end+1:], s[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+//===========================================================================
+// Connection and server commands
+//===========================================================================
+
+// PING [message]
+func (c *respConn) ping(args [][]byte) {
+	switch len(args) {
+	case 1:
+		c.writeSimple("PONG")
+	case 2:
+		c.writeBulk(args[1])
+	default:
+		c.writeError("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+// ECHO message
+func (c *respConn) echo(args [][]byte) {
+	c.writeBulk(args[1])
+}
+
+// INFO [section]
+func (c *respConn) info(args [][]byte) {
+	s := c.server
+
+	s.mu.Lock()
+	clients := len(s.conns)
+	var total uint64
+	for _, count := range s.requests {
+		total += count
+	}
+	s.mu.Unlock()
+
+	var info strings.Builder
+	fmt.Fprintf(&info, "# Server\r\n")
+	fmt.Fprintf(&info, "speedmap_version:%s\r\n", speedmap.Version)
+	fmt.Fprintf(&info, "redis_mode:standalone\r\n")
+	fmt.Fprintf(&info, "store:%s\r\n", s.kv)
+	fmt.Fprintf(&info, "uptime_in_seconds:%d\r\n", int64(time.Since(s.started).Seconds()))
+	fmt.Fprintf(&info, "\r\n# Clients\r\n")
+	fmt.Fprintf(&info, "connected_clients:%d\r\n", clients)
+	fmt.Fprintf(&info, "\r\n# Stats\r\n")
+	fmt.Fprintf(&info, "total_commands_processed:%d\r\n", total)
+
+	if bounded, ok := speedmap.Unwrap(s.kv).(speedmap.Bounded); ok {
+		stats := bounded.Stats()
+		fmt.Fprintf(&info, "keyspace_hits:%d\r\n", stats.Hits)
+		fmt.Fprintf(&info, "keyspace_misses:%d\r\n", stats.Misses)
+		fmt.Fprintf(&info, "evicted_keys:%d\r\n", stats.Evictions)
+		fmt.Fprintf(&info, "\r\n# Keyspace\r\n")
+		fmt.Fprintf(&info, "db0:keys=%d\r\n", stats.Entries)
+	}
+
+	if replicator, ok := s.replicator(); ok {
+		status := replicator.Status()
+		fmt.Fprintf(&info, "\r\n# Replication\r\n")
+		if status.Role == "primary" {
+			fmt.Fprintf(&info, "role:master\r\n")
+			fmt.Fprintf(&info, "connected_slaves:%d\r\n", status.Backups)
+			fmt.Fprintf(&info, "master_repl_offset:%d\r\n", status.Head)
+		} else {
+			link := "down"
+			if status.Connected {
+				link = "up"
+			}
+			fmt.Fprintf(&info, "role:slave\r\n")
+			fmt.Fprintf(&info, "master_host:%s\r\n", status.Primary)
+			fmt.Fprintf(&info, "master_link_status:%s\r\n", link)
+			fmt.Fprintf(&info, "master_repl_offset:%d\r\n", status.Head)
+			fmt.Fprintf(&info, "slave_repl_offset:%d\r\n", status.Applied)
+			fmt.Fprintf(&info, "slave_lag_ms:%d\r\n", status.Lag.Milliseconds())
+		}
+	}
+
+	c.writeBulkString(info.String())
+}
+
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (c *respConn) hello(args [][]byte) {
+	proto := c.proto
+	if len(args) > 1 {
+		var err error
+		if proto, err = strconv.Atoi(string(args[1])); err != nil || proto < 2 || proto > 3 {
+			c.writeError("NOPROTO unsupported protocol version")
+			return
+		}
+	}
+
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "AUTH" && i+2 < len(args):
+			if !c.authenticate(string(args[i+2])) {
+				c.writeError("WRONGPASS invalid username-password pair or user is disabled.")
+				return
+			}
+			i += 2
+		case opt == "SETNAME" && i+1 < len(args):
+			c.name = string(args[i+1])
+			i++
+		default:
+			c.writeError("ERR syntax error")
+			return
+		}
+	}
+
+	if c.server.auth != nil && !c.authed {
+		c.writeError("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+		return
+	}
+
+	c.proto = proto
+	c.writeMap(4)
+	c.writeBulkString("server")
+	c.writeBulkString("speedmap")
+	c.writeBulkString("version")
+	c.writeBulkString(speedmap.Version)
+	c.writeBulkString("proto")
+	c.writeInt(int64(c.proto))
+	c.writeBulkString("mode")
+	c.writeBulkString("standalone")
+}
+
+// AUTH [username] password
+//
+// The password is the token of the client; the username is ignored.
+func (c *respConn) auth(args [][]byte) {
+	if len(args) > 3 {
+		c.writeError("ERR syntax error")
+		return
+	}
+
+	if c.server.auth == nil {
+		c.writeError("ERR AUTH called without any password configured for the default user.")
+		return
+	}
+
+	if !c.authenticate(string(args[len(args)-1])) {
+		c.writeError("WRONGPASS invalid username-password pair or user is disabled.")
+		return
+	}
+	c.writeSimple("OK")
+}
+
+// Authenticates the client by the token if the server uses token
+// authentication, returning true if the token is valid.
+func (c *respConn) authenticate(token string) bool {
+	auth, ok := c.server.auth.(*TokenAuth)
+	if !ok {
+		return false
+	}
+
+	identity, ok := auth.lookup(token)
+	if ok {
+		c.identity, c.authed = identity, true
+	}
+	return ok
+}
+
+// SELECT index
+//
+// Stores have a single keyspace, database 0.
+func (c *respConn) selectdb(args [][]byte) {
+	if string(args[1]) != "0" {
+		c.writeError("ERR DB index is out of range")
+		return
+	}
+	c.writeSimple("OK")
+}
+
+// CLIENT SETNAME name | GETNAME | SETINFO attr value
+func (c *respConn) client(args [][]byte) {
+	switch sub := strings.ToUpper(string(args[1])); {
+	case sub == "SETNAME" && len(args) == 3:
+		c.name = string(args[2])
+		c.writeSimple("OK")
+	case sub == "GETNAME" && len(args) == 2:
+		if c.name == "" {
+			c.writeNull()
+			return
+		}
+		c.writeBulkString(c.name)
+	case sub == "SETINFO" && len(args) == 4:
+		c.writeSimple("OK")
+	default:
+		c.writeError(fmt.Sprintf("ERR unknown subcommand '%s'", args[1]))
+	}
+}
+
+// COMMAND [subcommand]
+//
+// Command documentation is not supported, an empty reply lets clients that
+// request it on connecting continue.
+func (c *respConn) command(args [][]byte) {
+	c.writeArray(0)
+}
+
+// CONFIG GET parameter
+//
+// Stores have no Redis configuration, an empty reply lets benchmarking tools
+// that request it on connecting continue.
+func (c *respConn) config(args [][]byte) {
+	if strings.ToUpper(string(args[1])) != "GET" {
+		c.writeError(fmt.Sprintf("ERR unknown subcommand '%s'", args[1]))
+		return
+	}
+	c.writeMap(0)
+}
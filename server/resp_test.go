@@ -0,0 +1,227 @@
+package server_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/server"
+	"github.com/bbengfort/speedmap/store"
+)
+
+// Encodes the arguments as a RESP array of bulk strings.
+func respCommand(args ...string) string {
+	cmd := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		cmd += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return cmd
+}
+
+var _ = Describe("RESP", func() {
+
+	var (
+		kv   store.Shard
+		srv  *Server
+		conn *pipe
+	)
+
+	// Serves a RESP connection over a pipe with the server.
+	serve := func() {
+		conn = newPipe(srv.ServeRESP)
+	}
+
+	// Expects the connection to be closed by the server after a protocol error.
+	expectProtocolError := func(msg string) {
+		conn.expect("-ERR Protocol error: " + msg + "\r\n")
+		conn.expectClosed()
+	}
+
+	BeforeEach(func() {
+		var err error
+		kv, err = store.NewShard()
+		Ω(err).ShouldNot(HaveOccurred())
+		srv = New(kv)
+	})
+
+	AfterEach(func() {
+		conn.Close()
+	})
+
+	Context("without authentication", func() {
+
+		BeforeEach(serve)
+
+		It("should handle commands sent as arrays of bulk strings", func() {
+			conn.send(respCommand("SET", "foo", "bar"))
+			conn.expect("+OK\r\n")
+
+			conn.send(respCommand("GET", "foo"))
+			conn.expect("$3\r\nbar\r\n")
+
+			conn.send(respCommand("GET", "missing"))
+			conn.expect("$-1\r\n")
+
+			conn.send(respCommand("DEL", "foo", "missing"))
+			conn.expect(":1\r\n")
+		})
+
+		It("should handle binary values", func() {
+			value := "a\r\nb\x00c"
+			conn.send(respCommand("SET", "foo", value))
+			conn.expect("+OK\r\n")
+
+			conn.send(respCommand("GET", "foo"))
+			conn.expect(fmt.Sprintf("$%d\r\n%s\r\n", len(value), value))
+		})
+
+		It("should handle inline commands", func() {
+			conn.send("SET foo bar\r\n")
+			conn.expect("+OK\r\n")
+
+			conn.send("GET   foo\n")
+			conn.expect("$3\r\nbar\r\n")
+
+			conn.send("\r\nPING\r\n")
+			conn.expect("+PONG\r\n")
+		})
+
+		It("should reply to pipelined commands in order", func() {
+			var req, reply strings.Builder
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("key%d", i)
+				req.WriteString(respCommand("SET", key, key))
+				req.WriteString("GET " + key + "\r\n")
+				reply.WriteString(fmt.Sprintf("+OK\r\n$%d\r\n%s\r\n", len(key), key))
+			}
+			req.WriteString(respCommand("QUIT"))
+			reply.WriteString("+OK\r\n")
+
+			conn.send(req.String())
+			conn.expect(reply.String())
+
+			conn.expectClosed()
+		})
+
+		It("should reply with RESP3 after HELLO 3", func() {
+			conn.send(respCommand("HELLO", "3"))
+			conn.expect("%4\r\n$6\r\nserver\r\n$8\r\nspeedmap\r\n")
+			conn.expect(fmt.Sprintf("$7\r\nversion\r\n$%d\r\n%s\r\n", len(speedmap.Version), speedmap.Version))
+			conn.expect("$5\r\nproto\r\n:3\r\n$4\r\nmode\r\n$10\r\nstandalone\r\n")
+
+			conn.send(respCommand("GET", "missing"))
+			conn.expect("_\r\n")
+
+			conn.send(respCommand("HELLO", "4"))
+			conn.expect("-NOPROTO unsupported protocol version\r\n")
+		})
+
+		It("should read large bulk strings", func() {
+			value := strings.Repeat("x", 1024*1024)
+			conn.send(respCommand("SET", "foo", value))
+			conn.expect("+OK\r\n")
+
+			conn.send(respCommand("GET", "foo"))
+			conn.expect(fmt.Sprintf("$%d\r\n%s\r\n", len(value), value))
+		})
+
+		It("should reply with errors to invalid commands", func() {
+			conn.send(respCommand("FOO"))
+			conn.expect("-ERR unknown command 'FOO'\r\n")
+
+			conn.send(respCommand("GET"))
+			conn.expect("-ERR wrong number of arguments for 'get' command\r\n")
+		})
+
+		It("should close the connection after a bulk string that is not an array element", func() {
+			conn.send("*1\r\n+PING\r\n")
+			expectProtocolError("expected '$', got '+PING'")
+		})
+
+		It("should close the connection after an invalid multibulk length", func() {
+			conn.send("*foo\r\n")
+			expectProtocolError("invalid multibulk length")
+		})
+
+		It("should close the connection after an invalid bulk length", func() {
+			conn.send("*1\r\n$-5\r\n")
+			expectProtocolError("invalid bulk length")
+		})
+
+		It("should close the connection after an unterminated bulk string", func() {
+			conn.send("*1\r\n$4\r\nPINGxx")
+			expectProtocolError("bulk string is not terminated by CRLF")
+		})
+
+		It("should close the connection after a truncated bulk string", func() {
+			conn.send("*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$1024\r\nbar")
+			Ω(conn.Close()).Should(Succeed())
+			Eventually(conn.done).Should(BeClosed())
+
+			_, err := kv.Get("foo")
+			Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+		})
+
+	})
+
+	Context("with token authentication", func() {
+
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "speedmap-resp")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			auth, err := NewTokenAuth(writeFile(dir, "tokens.txt", "secret team-a\n"))
+			Ω(err).ShouldNot(HaveOccurred())
+			srv.SetAuth(auth)
+			serve()
+		})
+
+		AfterEach(func() {
+			Ω(os.RemoveAll(dir)).Should(Succeed())
+		})
+
+		It("should require authentication", func() {
+			conn.send(respCommand("GET", "foo"))
+			conn.expect("-NOAUTH Authentication required.\r\n")
+
+			conn.send(respCommand("AUTH", "wrong"))
+			conn.expect("-WRONGPASS invalid username-password pair or user is disabled.\r\n")
+
+			conn.send(respCommand("AUTH", "secret"))
+			conn.expect("+OK\r\n")
+
+			conn.send(respCommand("GET", "foo"))
+			conn.expect("$-1\r\n")
+		})
+
+		It("should limit the number of arguments before authentication", func() {
+			conn.send("*11\r\n")
+			expectProtocolError("unauthenticated multibulk length")
+		})
+
+		It("should limit the size of bulk strings before authentication", func() {
+			conn.send(fmt.Sprintf("*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$%d\r\n", 512*1024*1024))
+			expectProtocolError("unauthenticated bulk length")
+		})
+
+		It("should accept large requests after authentication", func() {
+			conn.send(respCommand("AUTH", "secret"))
+			conn.expect("+OK\r\n")
+
+			value := strings.Repeat("x", 64*1024)
+			conn.send(respCommand("SET", "foo", value))
+			conn.expect("+OK\r\n")
+		})
+
+	})
+
+})
@@ -0,0 +1,424 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/speedmap/cluster"
+	"github.com/bbengfort/speedmap/server/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The maximum number of redirects a client follows for a request before it
+// returns the redirect to the caller.
+const maxRedirects = 3
+
+// The maximum number of times a client sends a request to another member of a
+// replicated cluster when a member is unavailable, e.g. while a new leader is
+// elected, waiting longer before each attempt up to the maximum backoff.
+const (
+	maxFailovers = 8
+	minBackoff   = 50 * time.Millisecond
+	maxBackoff   = time.Second
+)
+
+// The ring of the cluster cached by a client and its connections to the
+// members of the cluster, shared by the clients returned by WithIdentity.
+type routes struct {
+	sync.RWMutex
+	addr       string                 // the address the client connected to
+	origin     pb.KVClient            // the server the client connected to
+	opts       []grpc.DialOption      // used to connect to the members
+	ring       *cluster.Ring          // the ring of the cluster, once redirected
+	replicated bool                   // the cluster is replicated, once redirected
+	members    []cluster.Member       // the members of a replicated cluster
+	leader     cluster.Member         // the leader of a replicated cluster, if known
+	primary    cluster.Member         // the primary that serves writes, once redirected by a backup
+	peers      map[string]*memberConn // connections to the members by address
+}
+
+// The number of times a request has been redirected or failed over.
+type attempts struct {
+	redirects int
+	failovers int
+}
+
+// A connection to a member of the cluster.
+type memberConn struct {
+	conn *grpc.ClientConn
+	kv   pb.KVClient
+}
+
+// Cluster requests the members of the cluster from the speedmap server and
+// caches its ring, so that later requests are sent directly to the owner of
+// each key, or the leader of a replicated cluster. Clients fetch the ring when
+// a request is first redirected, so it is not necessary to call Cluster before
+// making requests to a cluster. However, a client only knows the members to
+// fail over to if the server it connected to is unavailable once it has
+// fetched them. Clients of a backup fetch the address of its primary, which
+// writes are sent to while reads are still sent to the backup.
+func (c *Client) Cluster() (*pb.ClusterReply, error) {
+	// Ensure that we're connected
+	if c.client == nil {
+		return nil, errors.New("not connected to speedmap server")
+	}
+
+	// Create the context
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	return c.refresh(ctx, c.client)
+}
+
+// Owner returns the name of the member of the cluster that owns the key (the
+// leader of a replicated cluster), or an empty string if the client has not
+// cached the members of a cluster.
+func (c *Client) Owner(key string) string {
+	if c.routes == nil {
+		return ""
+	}
+
+	c.routes.RLock()
+	defer c.routes.RUnlock()
+	if c.routes.replicated {
+		return c.routes.leader.Name
+	}
+
+	if c.routes.ring == nil {
+		return ""
+	}
+	return c.routes.ring.Owner(key).Name
+}
+
+// Sends the request for the key to the member that owns it, or to the server
+// the client connected to if the ring is not cached. If the reply is a
+// redirect the ring is fetched from the server that redirected the request
+// and the request is sent again, at most maxRedirects times. Requests to a
+// replicated cluster are sent to its leader and are sent to the other members
+// in turn if the leader is unavailable, see reroute. Writes are sent to the
+// primary of a backup once the backup has redirected a write to it.
+func (c *Client) do(key string, write bool, request func(ctx context.Context, kv pb.KVClient) (*pb.ClientReply, error)) (rep *pb.ClientReply, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	var n attempts
+	for {
+		var kv pb.KVClient
+		if kv, err = c.routes.route(key, write); err != nil {
+			return nil, err
+		}
+
+		rep, err = request(ctx, kv)
+
+		var redirect string
+		if err == nil {
+			redirect = rep.Redirect
+		}
+
+		var retry bool
+		if retry, err = c.reroute(ctx, kv, redirect, err, &n); !retry {
+			return rep, err
+		}
+	}
+}
+
+// Sends a batch of requests for the keys to the members that own them, one
+// request per member with the indices of its keys, and merges the replies
+// so that their pairs are in the order of the keys. Redirects and failovers
+// are followed as they are by do, resending the entire batch.
+func (c *Client) doBatch(keys []string, write bool, request func(ctx context.Context, kv pb.KVClient, idx []int) (*pb.BatchReply, error)) (rep *pb.BatchReply, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	var n attempts
+	for {
+		var (
+			groups     map[pb.KVClient][]int
+			redirected pb.KVClient
+			failed     error
+		)
+
+		if groups, err = c.routes.partition(keys, write); err != nil {
+			return nil, err
+		}
+
+		rep = &pb.BatchReply{Success: true}
+		for kv, idx := range groups {
+			var part *pb.BatchReply
+			if part, failed = request(ctx, kv, idx); failed != nil {
+				redirected = kv
+				break
+			}
+
+			if part.Redirect != "" {
+				rep, redirected = part, kv
+				break
+			}
+
+			if !part.Success {
+				return part, nil
+			}
+
+			if len(part.Pairs) == len(idx) && len(idx) > 0 {
+				if rep.Pairs == nil {
+					rep.Pairs = make([]*pb.KVPair, len(keys))
+				}
+				for j, k := range idx {
+					rep.Pairs[k] = part.Pairs[j]
+				}
+			}
+		}
+
+		if redirected == nil {
+			return rep, nil
+		}
+
+		var retry bool
+		if retry, err = c.reroute(ctx, redirected, rep.Redirect, failed, &n); !retry {
+			if err != nil {
+				return nil, err
+			}
+			return rep, nil
+		}
+	}
+}
+
+// Returns true if a request sent to the server should be sent again because
+// it was redirected to another member or it failed because the server is
+// unavailable, updating the routes of the client to send it to the member
+// that should serve it. If the request should not be sent again, the error of
+// the request (or of updating the routes) is returned.
+//
+// A redirect to a member of a replicated cluster is sent to that member since
+// it is the leader, any other redirect fetches the members of the cluster
+// from the server that redirected the request. If the server is unavailable
+// the request is sent to the next member of a replicated cluster after a
+// backoff; if the client has not fetched the members it does so first, in
+// case the server is available but its cluster does not have a leader. If
+// the primary of a backup is unavailable, writes are sent to the backup again
+// in case it has been promoted.
+func (c *Client) reroute(ctx context.Context, kv pb.KVClient, redirect string, err error, n *attempts) (retry bool, rerr error) {
+	if err != nil {
+		if status.Code(err) != codes.Unavailable || n.failovers == maxFailovers {
+			return false, err
+		}
+
+		if !c.routes.failover() {
+			if rep, ferr := c.refresh(ctx, kv); ferr != nil || (!rep.Replicated && rep.Primary == "") {
+				return false, err
+			}
+		}
+
+		backoff := minBackoff << uint(n.failovers)
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return false, err
+		}
+
+		// The members may redirect to the unavailable leader until they elect another
+		n.failovers++
+		n.redirects = 0
+		return true, nil
+	}
+
+	if redirect == "" || n.redirects == maxRedirects {
+		return false, nil
+	}
+
+	n.redirects++
+	if c.routes.follow(redirect) {
+		return true, nil
+	}
+
+	if _, err = c.refresh(ctx, kv); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Fetches the members of the cluster from the server and caches their ring,
+// or the primary if the server is a backup.
+func (c *Client) refresh(ctx context.Context, kv pb.KVClient) (rep *pb.ClusterReply, err error) {
+	if rep, err = kv.Cluster(ctx, &pb.ClusterRequest{Identity: c.identity}); err != nil {
+		return nil, err
+	}
+
+	if rep.Primary != "" {
+		c.routes.Lock()
+		c.routes.primary = cluster.Member{Name: rep.Primary, Addr: rep.Primary}
+		c.routes.Unlock()
+		return rep, nil
+	}
+
+	members := make([]cluster.Member, 0, len(rep.Members))
+	for _, member := range rep.Members {
+		members = append(members, cluster.Member{Name: member.Name, Addr: member.Addr})
+	}
+
+	if rep.Replicated {
+		c.routes.Lock()
+		defer c.routes.Unlock()
+
+		c.routes.replicated, c.routes.members, c.routes.ring = true, members, nil
+		c.routes.leader = cluster.Member{}
+		for _, member := range members {
+			if member.Name == rep.Leader {
+				c.routes.leader = member
+			}
+		}
+		return rep, nil
+	}
+
+	var ring *cluster.Ring
+	if ring, err = cluster.NewRing(members, int(rep.Vnodes)); err != nil {
+		return nil, err
+	}
+
+	c.routes.Lock()
+	c.routes.ring = ring
+	c.routes.Unlock()
+	return rep, nil
+}
+
+// Returns the server to send requests for the key to, the primary if the
+// request is a write and the primary is known.
+func (r *routes) route(key string, write bool) (pb.KVClient, error) {
+	r.RLock()
+	ring, leader, primary := r.ring, r.leader, r.primary
+	r.RUnlock()
+
+	if write && primary.Addr != "" {
+		return r.member(primary)
+	}
+
+	if leader.Addr != "" {
+		return r.member(leader)
+	}
+
+	if ring == nil {
+		return r.origin, nil
+	}
+	return r.member(ring.Owner(key))
+}
+
+// Sends requests to the member of a replicated cluster with the name, which
+// redirected requests to it as the leader, or writes to the primary with the
+// name. Returns false if the cluster is not replicated or the member is not
+// known.
+func (r *routes) follow(name string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.primary.Name != "" && r.primary.Name == name {
+		return true
+	}
+
+	if !r.replicated {
+		return false
+	}
+
+	for _, member := range r.members {
+		if member.Name == name {
+			r.leader = member
+			return true
+		}
+	}
+	return false
+}
+
+// Sends requests to the member of a replicated cluster that follows the
+// current leader (or the server the client connected to) in the members,
+// since it is unavailable. Writes are sent to the server the client connected
+// to if the primary is unavailable. Returns false if the cluster is not
+// replicated and the primary is not known.
+func (r *routes) failover() bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.primary.Addr != "" {
+		r.primary = cluster.Member{}
+		return true
+	}
+
+	if !r.replicated || len(r.members) == 0 {
+		return false
+	}
+
+	current := r.leader.Addr
+	if current == "" {
+		current = r.addr
+	}
+
+	next := 0
+	for i, member := range r.members {
+		if member.Addr == current {
+			next = (i + 1) % len(r.members)
+		}
+	}
+
+	r.leader = r.members[next]
+	return true
+}
+
+// Groups the indices of the keys by the server to send them to.
+func (r *routes) partition(keys []string, write bool) (map[pb.KVClient][]int, error) {
+	groups := make(map[pb.KVClient][]int)
+	for i, key := range keys {
+		kv, err := r.route(key, write)
+		if err != nil {
+			return nil, err
+		}
+		groups[kv] = append(groups[kv], i)
+	}
+	return groups, nil
+}
+
+// Returns a client for the member, connecting to it on first use.
+func (r *routes) member(member cluster.Member) (pb.KVClient, error) {
+	if member.Addr == r.addr {
+		return r.origin, nil
+	}
+
+	r.RLock()
+	p, ok := r.peers[member.Addr]
+	r.RUnlock()
+	if ok {
+		return p.kv, nil
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if p, ok = r.peers[member.Addr]; !ok {
+		conn, err := grpc.Dial(member.Addr, r.opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		p = &memberConn{conn: conn, kv: pb.NewKVClient(conn)}
+		r.peers[member.Addr] = p
+	}
+	return p.kv, nil
+}
+
+// Closes the connections to the members of the cluster.
+func (r *routes) close() (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	for addr, p := range r.peers {
+		if cerr := p.conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(r.peers, addr)
+	}
+	return err
+}
@@ -2,26 +2,67 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"sync"
+	"time"
 
 	"github.com/bbengfort/speedmap"
+	"github.com/bbengfort/speedmap/cluster"
+	"github.com/bbengfort/speedmap/raft"
 	"github.com/bbengfort/speedmap/server/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
 // Server implements the KVServer interface and is essentially just a wrapper
 // around a speedmap key/value Store. Note that the Server performs NO
 // synchronization, it simply passes all requests from all clients to the
 // speedmap. Because each request is handled in its own go routine, it is
-// expected that the wrapped Store is thread-safe.
+// expected that the wrapped Store is thread-safe. The request context is
+// passed to the store so that stores that block can abort when the client
+// cancels the request or its deadline expires.
 type Server struct {
-	kv speedmap.Store
+	kv       speedmap.ContextStore
+	tls      *tls.Config      // serve over TLS if not nil
+	auth     Authenticator    // authenticates clients if not nil
+	acl      *ACL             // authorizes requests if not nil
+	snapshot string           // path that the admin snapshot RPC writes to
+	smu      sync.Mutex       // allows only one snapshot to be written at a time
+	flags    mcFlags          // flags of the values stored by memcached clients
+	ring     *cluster.Ring    // the ring of the cluster, if the server is clustered
+	node     *raft.Node       // the member of the raft group, if the server is replicated
+	members  []cluster.Member // the members of the replicated cluster
+	self     string           // the name of the server in the cluster
+
+	mu        sync.Mutex            // protects the listeners, connections and request counts
+	srv       *grpc.Server          // the grpc server, once listening
+	listeners []net.Listener        // the RESP and memcached listeners
+	web       *http.Server          // the http gateway, once listening
+	conns     map[net.Conn]struct{} // open RESP and memcached connections
+	connwg    sync.WaitGroup        // waits for the connections to close
+	requests  map[string]uint64     // the number of requests served per identity
+	started   time.Time             // when the server was created
+	done      chan struct{}         // closed when the server is shut down
+	stopping  sync.Once
 }
 
-// New creates a new server with the specified key value store.
+// New creates a new server with the specified key value store, adapting the
+// store to a ContextStore if it does not implement one itself.
 func New(kv speedmap.Store) *Server {
-	return &Server{kv: kv}
+	return &Server{
+		kv:       speedmap.WithContext(kv),
+		snapshot: DefaultSnapshotPath,
+		conns:    make(map[net.Conn]struct{}),
+		requests: make(map[string]uint64),
+		started:  time.Now(),
+		done:     make(chan struct{}),
+	}
 }
 
 // Serve the key/value store with the specified store on the specified addr.
@@ -30,62 +71,324 @@ func Serve(kv speedmap.Store, addr string) error {
 	return srv.Listen(addr)
 }
 
+// SetTLS configures the server to serve requests over TLS, see ServerTLS. It
+// must be called before Listen.
+func (s *Server) SetTLS(conf *tls.Config) {
+	s.tls = conf
+}
+
+// SetAuth configures the server to authenticate every request with the
+// authenticator, rejecting requests that cannot be authenticated. The
+// authenticated identity is used in place of the identity in the request. It
+// must be called before Listen.
+func (s *Server) SetAuth(auth Authenticator) {
+	s.auth = auth
+}
+
+// SetACL configures the server to authorize every request with the ACL,
+// rejecting requests for operations or keys the client is not allowed. It
+// must be called before Listen.
+func (s *Server) SetACL(acl *ACL) {
+	s.acl = acl
+}
+
 // Listen for gRPC requests on the specified address and serve each request
-// in its own go routine. The server handlers access the speed map.
+// in its own go routine. The server handlers access the speed map. Listen
+// blocks until the server is shut down, then returns nil.
 func (s *Server) Listen(addr string) error {
 	sock, err := net.Listen("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("could not listen on %s", addr)
 	}
 	defer sock.Close()
-	fmt.Printf("serving the %s store on %s\n", s.kv.String(), addr)
 
 	// Initialize and run the gRPC server in its own thread
-	srv := grpc.NewServer()
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(s.unaryInterceptor), grpc.StreamInterceptor(s.streamInterceptor)}
+	if s.tls != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tls)))
+	}
+
+	srv := grpc.NewServer(opts...)
 	pb.RegisterKVServer(srv, s)
+	pb.RegisterAdminServer(srv, s)
+	if s.node != nil {
+		pb.RegisterRaftServer(srv, s)
+	}
+	if _, ok := s.replicator(); ok {
+		pb.RegisterReplicationServer(srv, s)
+	}
+
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		return grpc.ErrServerStopped
+	default:
+		s.srv = srv
+	}
+	s.mu.Unlock()
+
+	fmt.Printf("serving the %s store on %s\n", s.kv.String(), addr)
 	return srv.Serve(sock)
 }
 
+// Shutdown stops the server from accepting new requests, ends any open watch
+// streams, HTTP, RESP and memcached connections and waits for in-flight
+// requests to complete. If the context is done before they complete, the
+// remaining requests are canceled and the context error is returned. The
+// store is then closed (if it is an io.Closer) to flush any durable state,
+// and a summary of the requests served to each client is printed.
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+	s.stopping.Do(func() { close(s.done) })
+
+	s.mu.Lock()
+	srv := s.srv
+	s.mu.Unlock()
+
+	if srv != nil {
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			srv.Stop()
+			err = ctx.Err()
+		}
+	}
+
+	if herr := s.shutdownHTTP(ctx); err == nil {
+		err = herr
+	}
+
+	if rerr := s.shutdownListeners(ctx); err == nil {
+		err = rerr
+	}
+
+	if closer, ok := speedmap.Unwrap(s.kv).(io.Closer); ok {
+		if cerr := closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	s.printSummary()
+	return err
+}
+
 // Get handles a get request to the speedmap, relying on the speedmap for
 // concurrent synchronization of accesses. Note that Get uses GetoOrCreate
 // in the speedmap, storing nil as the default value; this means that this
 // method will not return a not found error. This decision was made to more
-// completely test the misframe implementation of the Store.
+// completely test the misframe implementation of the Store. Because the key
+// may be created, keys that exceed the maximum key size are rejected.
 func (s *Server) Get(ctx context.Context, in *pb.GetRequest) (*pb.ClientReply, error) {
-	val, _ := s.kv.GetOrCreate(in.Key, nil)
+	if len(in.Key) > speedmap.MaxKeySize {
+		return nil, status.Errorf(codes.InvalidArgument, "%s (%d > %d bytes)", speedmap.ErrKeyTooLarge, len(in.Key), speedmap.MaxKeySize)
+	}
+
+	if owner, ok := s.redirect(in.Key); ok {
+		return redirectReply(in.Key, owner), nil
+	}
+
+	val, _, err := s.kv.GetOrCreateCtx(ctx, in.Key, nil)
+	if err != nil {
+		if to, reason, ok := forward(err); ok {
+			return forwardReply(to, reason), nil
+		}
+		return nil, statusError(err)
+	}
+
+	// If the store is versioned, fetch the value along with its version. If
+	// the key was deleted in the meantime, reply with the unversioned value.
+	var vers uint64
+	if versioner, ok := speedmap.Unwrap(s.kv).(speedmap.Versioner); ok {
+		if vval, v, err := versioner.GetVersion(in.Key); err == nil {
+			val, vers = vval, v
+		}
+	}
+
 	return &pb.ClientReply{
 		Success:  true,
 		Redirect: "",
 		Error:    "",
 		Pair: &pb.KVPair{
-			Key:   in.Key,
-			Value: val,
+			Key:     in.Key,
+			Value:   val,
+			Version: vers,
 		},
 	}, nil
 }
 
 // Put handles a put request to the speedmap, relying on the speedmap for
-// concurrent synchronization of accesses.
+// concurrent synchronization of accesses. Store errors are returned as gRPC
+// status errors so that clients can branch on the status code. If the request
+// has a TTL, the store must implement speedmap.Expirer.
 func (s *Server) Put(ctx context.Context, in *pb.PutRequest) (*pb.ClientReply, error) {
-	rep := &pb.ClientReply{Success: true, Redirect: "", Error: "", Pair: nil}
+	if owner, ok := s.redirect(in.Key); ok {
+		return redirectReply(in.Key, owner), nil
+	}
 
-	if err := s.kv.Put(in.Key, in.Value); err != nil {
-		rep.Success = false
-		rep.Error = err.Error()
+	if in.Ttl > 0 {
+		return s.putWithTTL(ctx, in)
 	}
 
-	return rep, nil
+	if err := s.kv.PutCtx(ctx, in.Key, in.Value); err != nil {
+		if to, reason, ok := forward(err); ok {
+			return forwardReply(to, reason), nil
+		}
+		return nil, statusError(err)
+	}
+
+	return &pb.ClientReply{Success: true, Redirect: "", Error: "", Pair: nil}, nil
+}
+
+// Handles a put request with a TTL, which is not supported by all stores.
+func (s *Server) putWithTTL(ctx context.Context, in *pb.PutRequest) (*pb.ClientReply, error) {
+	expirer, ok := speedmap.Unwrap(s.kv).(speedmap.Expirer)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "the %s store does not support expiration", s.kv)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, statusError(err)
+	}
+
+	ttl := time.Duration(in.Ttl) * time.Millisecond
+	if err := expirer.PutWithTTL(in.Key, in.Value, ttl); err != nil {
+		if to, reason, ok := forward(err); ok {
+			return forwardReply(to, reason), nil
+		}
+		return nil, statusError(err)
+	}
+
+	return &pb.ClientReply{Success: true, Redirect: "", Error: "", Pair: nil}, nil
 }
 
 // Del handles a del request to the speedmap, relying on the speedmap for
-// concurrent synchronization of accesses.
+// concurrent synchronization of accesses. Store errors are returned as gRPC
+// status errors so that clients can branch on the status code.
 func (s *Server) Del(ctx context.Context, in *pb.DelRequest) (*pb.ClientReply, error) {
-	rep := &pb.ClientReply{Success: true, Redirect: "", Error: "", Pair: nil}
+	if owner, ok := s.redirect(in.Key); ok {
+		return redirectReply(in.Key, owner), nil
+	}
 
-	if err := s.kv.Delete(in.Key); err != nil {
-		rep.Success = false
-		rep.Error = err.Error()
+	if err := s.kv.DeleteCtx(ctx, in.Key); err != nil {
+		if to, reason, ok := forward(err); ok {
+			return forwardReply(to, reason), nil
+		}
+		return nil, statusError(err)
 	}
 
+	return &pb.ClientReply{Success: true, Redirect: "", Error: "", Pair: nil}, nil
+}
+
+// BatchGet handles a request for many keys at once, fetching them from the
+// speedmap in a single batch if the store supports it. Unlike Get, keys that
+// are not in the store are not created, they are simply returned with a nil
+// value in the reply, which contains the pairs in the same order as the keys.
+func (s *Server) BatchGet(ctx context.Context, in *pb.BatchGetRequest) (*pb.BatchReply, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, statusError(err)
+	}
+
+	if owner, ok := s.redirectAny(in.Keys); ok {
+		return redirectBatchReply(owner), nil
+	}
+
+	vals, err := speedmap.MultiGet(s.kv, in.Keys)
+	if err != nil {
+		if to, reason, ok := forward(err); ok {
+			return forwardBatchReply(to, reason), nil
+		}
+		return nil, statusError(err)
+	}
+
+	rep := &pb.BatchReply{Success: true, Redirect: "", Error: ""}
+	rep.Pairs = make([]*pb.KVPair, len(in.Keys))
+	for i, key := range in.Keys {
+		rep.Pairs[i] = &pb.KVPair{Key: key, Value: vals[i]}
+	}
 	return rep, nil
 }
+
+// BatchPut handles a request to put many key/value pairs at once, storing them
+// in the speedmap in a single batch if the store supports it.
+func (s *Server) BatchPut(ctx context.Context, in *pb.BatchPutRequest) (*pb.BatchReply, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, statusError(err)
+	}
+
+	pairs := make(map[string][]byte, len(in.Pairs))
+	for _, pair := range in.Pairs {
+		if owner, ok := s.redirect(pair.Key); ok {
+			return redirectBatchReply(owner), nil
+		}
+		pairs[pair.Key] = pair.Value
+	}
+
+	if err := speedmap.MultiPut(s.kv, pairs); err != nil {
+		if to, reason, ok := forward(err); ok {
+			return forwardBatchReply(to, reason), nil
+		}
+		return nil, statusError(err)
+	}
+
+	return &pb.BatchReply{Success: true, Redirect: "", Error: "", Pairs: nil}, nil
+}
+
+// Watch streams changes to the requested key or prefix to the client until the
+// client cancels the request. The store must be Watchable. If the client
+// falls too far behind the changes to the store the stream is ended with a
+// ResourceExhausted status and the client must watch again to resume.
+func (s *Server) Watch(in *pb.WatchRequest, stream pb.KV_WatchServer) error {
+	watchable, ok := speedmap.Unwrap(s.kv).(speedmap.Watchable)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "the %s store cannot be watched", s.kv)
+	}
+
+	watcher, err := watchable.Watch(in.Key, in.Prefix)
+	if err != nil {
+		return statusError(err)
+	}
+	defer watcher.Close()
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-s.done:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-ctx.Done():
+			return statusError(ctx.Err())
+		case event, ok := <-watcher.Events():
+			if !ok {
+				return statusError(watcher.Err())
+			}
+
+			msg := &pb.WatchEvent{
+				Type: pb.WatchEvent_PUT,
+				Pair: &pb.KVPair{Key: event.Key, Value: event.Value, Version: event.Version},
+			}
+			if event.Type == speedmap.EventDelete {
+				msg.Type = pb.WatchEvent_DELETE
+			}
+
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Returns the version of the value of the key if the store is a Versioner,
+// otherwise zero.
+func (s *Server) version(key string) uint64 {
+	if versioner, ok := speedmap.Unwrap(s.kv).(speedmap.Versioner); ok {
+		if _, vers, err := versioner.GetVersion(key); err == nil {
+			return vers
+		}
+	}
+	return 0
+}
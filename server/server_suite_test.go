@@ -0,0 +1,94 @@
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap/server"
+)
+
+func TestServer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Server Suite")
+}
+
+// Serves gRPC requests with the server on a free loopback port, returning the
+// address once the server accepts connections.
+func listen(srv *server.Server) string {
+	sock, err := net.Listen("tcp", "127.0.0.1:0")
+	Ω(err).ShouldNot(HaveOccurred())
+	addr := sock.Addr().String()
+	Ω(sock.Close()).Should(Succeed())
+
+	go srv.Listen(addr)
+	Eventually(func() error {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}).Should(Succeed())
+	return addr
+}
+
+// Shuts down the server, waiting at most a second for requests to complete.
+func shutdown(srv *server.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	Ω(srv.Shutdown(ctx)).Should(Succeed())
+}
+
+// The client end of a connection served over a pipe by one of the connection
+// handlers of the server, for the text and binary protocols.
+type pipe struct {
+	net.Conn
+	r    *bufio.Reader
+	done chan struct{} // closed when the handler returns
+}
+
+// Serves a connection over a pipe with the handler.
+func newPipe(serve func(conn net.Conn)) *pipe {
+	client, conn := net.Pipe()
+	p := &pipe{Conn: client, r: bufio.NewReader(client), done: make(chan struct{})}
+	go func() {
+		serve(conn)
+		close(p.done)
+	}()
+	return p
+}
+
+// Writes the raw request to the connection.
+func (p *pipe) send(req string) {
+	_, err := io.WriteString(p, req)
+	Ω(err).ShouldNot(HaveOccurred())
+}
+
+// Reads the expected reply from the connection.
+func (p *pipe) expect(reply string) {
+	buf := make([]byte, len(reply))
+	_, err := io.ReadFull(p.r, buf)
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(string(buf)).Should(Equal(reply))
+}
+
+// Reads a line of the reply from the connection, without the CRLF.
+func (p *pipe) line() string {
+	line, err := p.r.ReadString('\n')
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(line).Should(HaveSuffix("\r\n"))
+	return line[:len(line)-2]
+}
+
+// Expects the handler to close the connection without another reply.
+func (p *pipe) expectClosed() {
+	_, err := p.r.ReadByte()
+	Ω(err).Should(Equal(io.EOF))
+	Eventually(p.done).Should(BeClosed())
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Requests returns the number of requests the server has handled for each
+// client identity. Each message received on a stream counts as a request.
+func (s *Server) Requests() map[string]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := make(map[string]uint64, len(s.requests))
+	for identity, count := range s.requests {
+		requests[identity] = count
+	}
+	return requests
+}
+
+// Counts a request against the identity of the client.
+func (s *Server) count(identity string) {
+	s.mu.Lock()
+	s.requests[identity]++
+	s.mu.Unlock()
+}
+
+// Prints the number of requests served to each client, sorted by identity.
+func (s *Server) printSummary() {
+	requests := s.Requests()
+
+	identities := make([]string, 0, len(requests))
+	var total uint64
+	for identity, count := range requests {
+		identities = append(identities, identity)
+		total += count
+	}
+	sort.Strings(identities)
+
+	fmt.Printf("served %d requests from %d clients\n", total, len(identities))
+	for _, identity := range identities {
+		name := identity
+		if name == "" {
+			name = "(anonymous)"
+		}
+		fmt.Printf("  %s: %d\n", name, requests[identity])
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/bbengfort/speedmap/server/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The maximum number of requests on a stream that are handled at once; the
+// stream is not read from while this many requests are in flight.
+const streamConcurrency = 128
+
+// Stream handles the get, put and del requests sent on a pipelined stream
+// concurrently, sending the reply to each as soon as it is handled so that
+// clients can keep many requests in flight without waiting for a round trip
+// per request. Replies are tagged with the id of their request and may be
+// sent in any order. A request that fails (including one that is not allowed
+// by the ACL) is replied to with its status code without ending the stream.
+// The stream ends when the client closes it once all of its requests have
+// been replied to, or with an Unavailable status when the server shuts down.
+func (s *Server) Stream(stream pb.KV_StreamServer) error {
+	ctx := stream.Context()
+	identity, authenticated := ctx.Value(identityKey{}).(string)
+
+	var (
+		wg      sync.WaitGroup
+		limit   = make(chan struct{}, streamConcurrency)
+		replies = make(chan *pb.StreamReply, streamConcurrency)
+		stop    = make(chan struct{})
+		sent    = make(chan error, 1)
+		recvd   = make(chan error, 1)
+	)
+
+	// Only one go routine may send on the stream at a time. Replies are sent
+	// until the replies channel is closed or sending is stopped; once a send
+	// fails the remaining replies are discarded.
+	go func() {
+		var err error
+		for {
+			select {
+			case reply, ok := <-replies:
+				if !ok {
+					sent <- err
+					return
+				}
+
+				if err == nil {
+					err = stream.Send(reply)
+				}
+			case <-stop:
+				sent <- err
+				return
+			}
+		}
+	}()
+
+	// Requests are handled in their own go routines until the client closes
+	// the stream. Handlers give up on replying once the stream has ended.
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvd <- err
+				return
+			}
+
+			select {
+			case limit <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				select {
+				case replies <- s.handleStream(ctx, identity, authenticated, req):
+				case <-ctx.Done():
+				}
+				<-limit
+			}()
+		}
+	}()
+
+	select {
+	case err := <-recvd:
+		// No more requests will be handled once the client has closed the
+		// stream, so the replies to the requests in flight can be flushed
+		wg.Wait()
+		close(replies)
+		if serr := <-sent; err == io.EOF {
+			err = serr
+		}
+		return err
+	case <-s.done:
+		close(stop)
+		<-sent
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+}
+
+// Handles a request received on a stream by passing it to the unary handler
+// of the request once it has been counted and authorized.
+func (s *Server) handleStream(ctx context.Context, identity string, authenticated bool, req *pb.StreamRequest) *pb.StreamReply {
+	var (
+		inner identified
+		err   error
+		set   int
+	)
+
+	if req.Get != nil {
+		inner, set = req.Get, set+1
+	}
+	if req.Put != nil {
+		inner, set = req.Put, set+1
+	}
+	if req.Del != nil {
+		inner, set = req.Del, set+1
+	}
+
+	reply := &pb.StreamReply{Id: req.Id}
+	if set != 1 {
+		err = status.Error(codes.InvalidArgument, "exactly one of get, put or del must be set")
+	} else {
+		if !authenticated {
+			identity = inner.GetIdentity()
+		}
+
+		s.count(identity)
+		if err = s.authorize(identity, inner); err == nil {
+			switch inner := inner.(type) {
+			case *pb.GetRequest:
+				reply.Reply, err = s.Get(ctx, inner)
+			case *pb.PutRequest:
+				reply.Reply, err = s.Put(ctx, inner)
+			case *pb.DelRequest:
+				reply.Reply, err = s.Del(ctx, inner)
+			}
+		}
+	}
+
+	if err != nil {
+		st := status.Convert(err)
+		reply.Code = uint32(st.Code())
+		reply.Reply = &pb.ClientReply{Success: false, Error: st.Message()}
+	}
+	return reply
+}
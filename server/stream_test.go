@@ -0,0 +1,201 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/server"
+	"github.com/bbengfort/speedmap/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A store whose puts block until they are let through by the gate.
+type gatedStore struct {
+	speedmap.Store
+	gate chan struct{}
+}
+
+func (s *gatedStore) Put(key string, value []byte) error {
+	<-s.gate
+	return s.Store.Put(key, value)
+}
+
+var _ = Describe("Stream", func() {
+
+	var (
+		kv     *gatedStore
+		srv    *Server
+		client *Client
+	)
+
+	BeforeEach(func() {
+		shard, err := store.NewShard()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		// Puts are not gated unless the test replaces the gate
+		kv = &gatedStore{Store: shard, gate: make(chan struct{})}
+		close(kv.gate)
+
+		srv = New(kv)
+		client = NewClient("team-a")
+	})
+
+	JustBeforeEach(func() {
+		Ω(client.Connect(listen(srv))).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Ω(client.Close()).Should(Succeed())
+		shutdown(srv)
+	})
+
+	It("should match each reply to its request", func() {
+		pipeline, err := client.Pipeline(16)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		puts := make([]*Call, 0, 500)
+		for i := 0; i < 500; i++ {
+			key := fmt.Sprintf("key%d", i)
+			puts = append(puts, pipeline.PutAsync(key, []byte(key)))
+		}
+
+		for _, call := range puts {
+			rep, err := call.Wait()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(rep.Success).Should(BeTrue())
+		}
+
+		gets := make([]*Call, 0, 500)
+		for i := 0; i < 500; i++ {
+			gets = append(gets, pipeline.GetAsync(fmt.Sprintf("key%d", i)))
+		}
+
+		for i, call := range gets {
+			rep, err := call.Wait()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(rep.Pair.Key).Should(Equal(fmt.Sprintf("key%d", i)))
+			Ω(rep.Pair.Value).Should(Equal([]byte(rep.Pair.Key)))
+		}
+
+		rep, err := pipeline.Del("key0", false)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(rep.Success).Should(BeTrue())
+
+		Ω(pipeline.Close()).Should(Succeed())
+	})
+
+	It("should keep at most the window of requests in flight", func() {
+		kv.gate = make(chan struct{})
+		pipeline, err := client.Pipeline(4)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		calls := make([]*Call, 0, 5)
+		for i := 0; i < 4; i++ {
+			calls = append(calls, pipeline.PutAsync(fmt.Sprintf("key%d", i), []byte("value")))
+		}
+
+		// The fifth request is not sent until a reply makes room in the window
+		sent := make(chan *Call, 1)
+		go func() { sent <- pipeline.PutAsync("key4", []byte("value")) }()
+		Consistently(sent, "100ms").ShouldNot(Receive())
+
+		kv.gate <- struct{}{}
+		var call *Call
+		Eventually(sent).Should(Receive(&call))
+		calls = append(calls, call)
+
+		close(kv.gate)
+		for _, call := range calls {
+			_, err := call.Wait()
+			Ω(err).ShouldNot(HaveOccurred())
+		}
+		Ω(pipeline.Close()).Should(Succeed())
+	})
+
+	It("should reply to the requests in flight when the stream is closed", func() {
+		kv.gate = make(chan struct{})
+		pipeline, err := client.Pipeline(8)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		call := pipeline.PutAsync("foo", []byte("bar"))
+		closed := make(chan error, 1)
+		go func() { closed <- pipeline.Close() }()
+		Consistently(call.Done(), "50ms").ShouldNot(BeClosed())
+
+		close(kv.gate)
+		Eventually(closed).Should(Receive(BeNil()))
+		Ω(call.Done()).Should(BeClosed())
+
+		_, err = pipeline.Get("foo")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should fail the calls in flight when the server shuts down", func() {
+		kv.gate = make(chan struct{})
+		pipeline, err := client.Pipeline(8)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		call := pipeline.PutAsync("foo", []byte("bar"))
+		shutdown := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(shutdown)
+			srv.Shutdown(context.Background())
+		}()
+
+		_, err = call.Wait()
+		Ω(status.Code(err)).Should(Equal(codes.Unavailable))
+
+		close(kv.gate)
+		Eventually(shutdown).Should(BeClosed())
+	})
+
+	Context("with an ACL", func() {
+
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "speedmap-stream")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			acl, err := LoadACL(writeFile(dir, "acl.txt", "team-a get,put a/\n"))
+			Ω(err).ShouldNot(HaveOccurred())
+			srv.SetACL(acl)
+		})
+
+		AfterEach(func() {
+			Ω(os.RemoveAll(dir)).Should(Succeed())
+		})
+
+		It("should deny requests without ending the stream", func() {
+			pipeline, err := client.Pipeline(8)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = pipeline.Put("b/foo", []byte("bar"))
+			Ω(status.Code(err)).Should(Equal(codes.PermissionDenied))
+
+			_, err = pipeline.Del("a/foo", false)
+			Ω(status.Code(err)).Should(Equal(codes.PermissionDenied))
+
+			rep, err := pipeline.Put("a/foo", []byte("bar"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(rep.Success).Should(BeTrue())
+
+			// Each request is authorized with its own identity
+			_, err = client.WithIdentity("team-b").Get("a/foo")
+			Ω(status.Code(err)).Should(Equal(codes.PermissionDenied))
+
+			Ω(pipeline.Close()).Should(Succeed())
+		})
+
+	})
+
+})
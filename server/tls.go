@@ -0,0 +1,204 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Names of the files written by GenerateCerts.
+const (
+	CACertFile     = "ca.pem"
+	CAKeyFile      = "ca.key"
+	ServerCertFile = "server.pem"
+	ServerKeyFile  = "server.key"
+	ClientCertFile = "client.pem"
+	ClientKeyFile  = "client.key"
+)
+
+// CertValidity is how long the certificates created by GenerateCerts are valid.
+const CertValidity = 365 * 24 * time.Hour
+
+// ServerTLS loads the server certificate and key to serve requests over TLS.
+// If a CA file is specified, the server requires mutual TLS: clients must
+// present a certificate signed by the CA.
+func ServerTLS(certFile, keyFile, caFile string) (conf *tls.Config, err error) {
+	var cert tls.Certificate
+	if cert, err = tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return nil, fmt.Errorf("could not load server certificate: %s", err)
+	}
+
+	conf = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile != "" {
+		if conf.ClientCAs, err = loadCertPool(caFile); err != nil {
+			return nil, err
+		}
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return conf, nil
+}
+
+// ClientTLS configures a client to connect to the server over TLS, verifying
+// the certificate of the server with the CA file, or with the system roots if
+// no CA file is specified. If a certificate and key are specified, they are
+// presented to the server for mutual TLS.
+func ClientTLS(caFile, certFile, keyFile string) (conf *tls.Config, err error) {
+	conf = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		if conf.RootCAs, err = loadCertPool(caFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %s", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// Loads the PEM encoded certificates in the file into a certificate pool.
+func loadCertPool(path string) (pool *x509.CertPool, err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(path); err != nil {
+		return nil, fmt.Errorf("could not read CA certificate: %s", err)
+	}
+
+	pool = x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// GenerateCerts creates a self-signed CA along with a server certificate for
+// the specified hosts (names or IP addresses) and a client certificate, both
+// signed by the CA, and writes them and their keys to PEM files in the
+// directory. The certificates are intended for testing and development; the
+// keys are written unencrypted.
+func GenerateCerts(dir string, hosts ...string) (err error) {
+	if len(hosts) == 0 {
+		return errors.New("specify at least one host for the server certificate")
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Create the self-signed certificate authority
+	ca := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "speedmap test CA", Organization: []string{"speedmap"}},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	var caKey *ecdsa.PrivateKey
+	if caKey, err = writeCert(dir, CACertFile, CAKeyFile, ca, nil, nil); err != nil {
+		return err
+	}
+
+	// Create the server certificate for the hosts
+	srv := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: hosts[0], Organization: []string{"speedmap"}},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			srv.IPAddresses = append(srv.IPAddresses, ip)
+		} else {
+			srv.DNSNames = append(srv.DNSNames, host)
+		}
+	}
+
+	if _, err = writeCert(dir, ServerCertFile, ServerKeyFile, srv, ca, caKey); err != nil {
+		return err
+	}
+
+	// Create the client certificate
+	client := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "speedmap client", Organization: []string{"speedmap"}},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	if _, err = writeCert(dir, ClientCertFile, ClientKeyFile, client, ca, caKey); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Generates a key for the certificate template, signs it with the parent (or
+// self-signs it if parent is nil), and writes the certificate and key to the
+// named files in the directory, returning the key.
+func writeCert(dir, certName, keyName string, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (key *ecdsa.PrivateKey, err error) {
+	if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return nil, err
+	}
+
+	if template.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128)); err != nil {
+		return nil, err
+	}
+
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = template.NotBefore.Add(CertValidity)
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	var der []byte
+	if der, err = x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey); err != nil {
+		return nil, err
+	}
+
+	// Replace the template of a CA with the signed certificate to sign others
+	if template.IsCA {
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(der); err != nil {
+			return nil, err
+		}
+		*template = *cert
+	}
+
+	var keyDER []byte
+	if keyDER, err = x509.MarshalECPrivateKey(key); err != nil {
+		return nil, err
+	}
+
+	if err = writePEM(filepath.Join(dir, certName), "CERTIFICATE", der, 0644); err != nil {
+		return nil, err
+	}
+	if err = writePEM(filepath.Join(dir, keyName), "EC PRIVATE KEY", keyDER, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Writes the PEM encoded block to the file with the specified permissions.
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	return ioutil.WriteFile(path, data, perm)
+}
@@ -0,0 +1,208 @@
+package speedmap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// SnapshotVersion is the version of the snapshot format written by Snapshot.
+const SnapshotVersion = 1
+
+// The snapshot format begins with a header of the magic bytes, the version of
+// the format and flags describing how the records are encoded. The records
+// follow the header (compressed with gzip if flagged) and each record is a
+// record marker, the uvarint lengths of the key and the value, the CRC of the
+// key and the value, then the key and the value. The records are terminated by
+// an end marker followed by the uvarint number of records in the snapshot so
+// that a truncated snapshot can be detected.
+// Values are limited to 1 GiB, the maximum record size of the write-ahead log,
+// so that a corrupt length cannot make Restore allocate an arbitrary amount.
+const (
+	snapshotMagic = "SPMS"
+	flagGzip      = 1 << 0
+	markRecord    = 1
+	markEnd       = 0
+	maxValueSize  = 1 << 30
+)
+
+// The CRC table used to checksum snapshot records.
+var snapshotCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// Snapshot writes the contents of the store to the writer, optionally
+// compressing the records with gzip, and returns the number of key/value
+// pairs written. The store must be Iterable; the snapshot reflects what the
+// store visits in Range, which is not necessarily a point-in-time view of a
+// store that is being modified concurrently.
+func Snapshot(store Store, w io.Writer, compress bool) (count uint64, err error) {
+	var flags byte
+	if compress {
+		flags |= flagGzip
+	}
+
+	header := append([]byte(snapshotMagic), SnapshotVersion, flags)
+	if _, err = w.Write(header); err != nil {
+		return 0, err
+	}
+
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+
+	buf := bufio.NewWriter(w)
+	var lens [1 + 2*binary.MaxVarintLen64 + 4]byte
+
+	var werr error
+	err = Range(store, func(key string, value []byte) bool {
+		if len(value) > maxValueSize {
+			werr = fmt.Errorf("value of key '%s' is too large to snapshot (%d > %d bytes)", key, len(value), maxValueSize)
+			return false
+		}
+
+		n := 1
+		lens[0] = markRecord
+		n += binary.PutUvarint(lens[n:], uint64(len(key)))
+		n += binary.PutUvarint(lens[n:], uint64(len(value)))
+
+		crc := crc32.Update(crc32.Checksum([]byte(key), snapshotCRC), snapshotCRC, value)
+		binary.LittleEndian.PutUint32(lens[n:], crc)
+		n += 4
+
+		if _, werr = buf.Write(lens[:n]); werr != nil {
+			return false
+		}
+		if _, werr = buf.WriteString(key); werr != nil {
+			return false
+		}
+		if _, werr = buf.Write(value); werr != nil {
+			return false
+		}
+
+		count++
+		return true
+	})
+
+	if err != nil {
+		return count, err
+	}
+	if werr != nil {
+		return count, werr
+	}
+
+	lens[0] = markEnd
+	n := 1 + binary.PutUvarint(lens[1:], count)
+	if _, err = buf.Write(lens[:n]); err != nil {
+		return count, err
+	}
+
+	if err = buf.Flush(); err != nil {
+		return count, err
+	}
+
+	if gz != nil {
+		if err = gz.Close(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// Restore reads a snapshot written by Snapshot and puts every key/value pair
+// into the store, returning the number of pairs restored. Each record is
+// verified before it is put, and an error wrapping ErrCorrupt is returned if
+// a record fails its checksum or the snapshot is truncated; in either case
+// the records before the corruption will have been restored.
+func Restore(store Store, r io.Reader) (count uint64, err error) {
+	var header [len(snapshotMagic) + 2]byte
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		return 0, fmt.Errorf("%w: could not read snapshot header: %s", ErrCorrupt, err)
+	}
+
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return 0, fmt.Errorf("%w: not a speedmap snapshot", ErrCorrupt)
+	}
+
+	if version := header[len(snapshotMagic)]; version != SnapshotVersion {
+		return 0, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	if header[len(snapshotMagic)+1]&flagGzip != 0 {
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(r); err != nil {
+			return 0, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	buf := bufio.NewReader(r)
+	for {
+		var mark byte
+		if mark, err = buf.ReadByte(); err != nil {
+			return count, truncated(err)
+		}
+
+		if mark == markEnd {
+			var expected uint64
+			if expected, err = binary.ReadUvarint(buf); err != nil {
+				return count, truncated(err)
+			}
+			if expected != count {
+				return count, fmt.Errorf("%w: expected %d records, restored %d", ErrCorrupt, expected, count)
+			}
+			return count, nil
+		}
+
+		if mark != markRecord {
+			return count, fmt.Errorf("%w: unknown record marker %d", ErrCorrupt, mark)
+		}
+
+		var klen, vlen uint64
+		if klen, err = binary.ReadUvarint(buf); err != nil {
+			return count, truncated(err)
+		}
+		if vlen, err = binary.ReadUvarint(buf); err != nil {
+			return count, truncated(err)
+		}
+		if klen > MaxKeySize || vlen > maxValueSize {
+			return count, fmt.Errorf("%w: invalid record lengths", ErrCorrupt)
+		}
+
+		var crc [4]byte
+		if _, err = io.ReadFull(buf, crc[:]); err != nil {
+			return count, truncated(err)
+		}
+
+		// Read the record incrementally so that a truncated snapshot fails
+		// before the full length of a corrupt record is allocated.
+		record := new(bytes.Buffer)
+		if _, err = io.CopyN(record, buf, int64(klen+vlen)); err != nil {
+			return count, truncated(err)
+		}
+		data := record.Bytes()
+
+		if crc32.Checksum(data, snapshotCRC) != binary.LittleEndian.Uint32(crc[:]) {
+			return count, fmt.Errorf("%w: record checksum mismatch", ErrCorrupt)
+		}
+
+		if err = store.Put(string(data[:klen]), data[klen:]); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// Wraps an unexpected end of the snapshot as corruption.
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: snapshot is truncated", ErrCorrupt)
+	}
+	return err
+}
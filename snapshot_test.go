@@ -0,0 +1,121 @@
+package speedmap_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bbengfort/speedmap"
+	"github.com/bbengfort/speedmap/store"
+)
+
+// Creates a store with the specified number of keys.
+func fillStore(keys int) Store {
+	kv, err := store.NewShard()
+	Ω(err).ShouldNot(HaveOccurred())
+
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("%X", i)
+		Ω(kv.Put(key, []byte("value of "+key))).Should(Succeed())
+	}
+	return kv
+}
+
+var _ = Describe("Snapshot", func() {
+
+	for _, compress := range []bool{false, true} {
+		compress := compress
+
+		It(fmt.Sprintf("should snapshot and restore a store with compress %t", compress), func() {
+			buf := new(bytes.Buffer)
+			count, err := Snapshot(fillStore(1000), buf, compress)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(count).Should(BeEquivalentTo(1000))
+
+			restored, err := store.NewBasic()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			count, err = Restore(restored, buf)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(count).Should(BeEquivalentTo(1000))
+
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("%X", i)
+				val, err := restored.Get(key)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(val).Should(Equal([]byte("value of " + key)))
+			}
+		})
+	}
+
+	It("should compress snapshots", func() {
+		plain, compressed := new(bytes.Buffer), new(bytes.Buffer)
+		_, err := Snapshot(fillStore(1000), plain, false)
+		Ω(err).ShouldNot(HaveOccurred())
+		_, err = Snapshot(fillStore(1000), compressed, true)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(compressed.Len()).Should(BeNumerically("<", plain.Len()))
+	})
+
+	It("should require an iterable store", func() {
+		kv, err := store.NewShard()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var iterable interface{} = kv
+		_, ok := iterable.(Iterable)
+		Ω(ok).Should(BeTrue())
+
+		_, err = Snapshot(WithContext(kv), new(bytes.Buffer), false)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should detect corrupt and truncated snapshots", func() {
+		buf := new(bytes.Buffer)
+		_, err := Snapshot(fillStore(100), buf, false)
+		Ω(err).ShouldNot(HaveOccurred())
+		data := buf.Bytes()
+
+		kv, _ := store.NewBasic()
+		_, err = Restore(kv, bytes.NewReader(data[:len(data)-10]))
+		Ω(errors.Is(err, ErrCorrupt)).Should(BeTrue())
+
+		flipped := append([]byte(nil), data...)
+		flipped[len(flipped)/2] ^= 0xff
+		_, err = Restore(kv, bytes.NewReader(flipped))
+		Ω(errors.Is(err, ErrCorrupt)).Should(BeTrue())
+
+		_, err = Restore(kv, bytes.NewReader([]byte("not a snapshot")))
+		Ω(errors.Is(err, ErrCorrupt)).Should(BeTrue())
+	})
+
+	It("should reject corrupt record lengths without allocating them", func() {
+		header := append([]byte("SPMS"), byte(SnapshotVersion), 0)
+		record := func(klen, vlen uint64) []byte {
+			var lens [2 * binary.MaxVarintLen64]byte
+			n := binary.PutUvarint(lens[:], klen)
+			n += binary.PutUvarint(lens[n:], vlen)
+
+			data := append(append([]byte(nil), header...), 1)
+			data = append(data, lens[:n]...)
+			return append(data, 0, 0, 0, 0, 'f', 'o', 'o')
+		}
+
+		kv, _ := store.NewBasic()
+		_, err := Restore(kv, bytes.NewReader(record(3, 1<<31)))
+		Ω(errors.Is(err, ErrCorrupt)).Should(BeTrue())
+
+		// A length within the limit fails when the snapshot ends
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err = Restore(kv, bytes.NewReader(record(3, 1<<30)))
+		Ω(errors.Is(err, ErrCorrupt)).Should(BeTrue())
+		runtime.ReadMemStats(&after)
+		Ω(after.TotalAlloc - before.TotalAlloc).Should(BeNumerically("<", 1<<20))
+	})
+
+})
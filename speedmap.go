@@ -3,6 +3,7 @@ package speedmap
 import (
 	"fmt"
 	"math/rand"
+	"runtime"
 	"time"
 )
 
@@ -38,7 +39,20 @@ type Result struct {
 	Workload    Workload      // The workload of the result
 	Concurrency int           // The number of concurrent clients executed on the store
 	Operations  uint64        // The number of operations successfully executed
+	Aborts      uint64        // The number of operations aborted due to conflicts
+	Hits        uint64        // The number of reads that found the key
+	Misses      uint64        // The number of reads that did not find the key
+	Evictions   uint64        // The number of keys evicted by a bounded store
 	Duration    time.Duration // The length of time the workload run took
+	GCCycles    uint32        // The number of garbage collections during the run
+	GCPause     time.Duration // The total stop-the-world GC pause time during the run
+	MaxGCPause  time.Duration // The longest stop-the-world GC pause during the run
+	ForcedGC    time.Duration // The duration of a full GC forced after the run, if measured
+	HeapObjects uint64        // The number of allocated heap objects after the run
+	P50Latency  time.Duration // The median latency of an operation, if the store is Timed
+	P90Latency  time.Duration // The 90th percentile latency of an operation, if the store is Timed
+	P99Latency  time.Duration // The 99th percentile latency of an operation, if the store is Timed
+	MaxLatency  time.Duration // The longest latency of an operation, if the store is Timed
 }
 
 // Throughput returns the number of operations per second achieved.
@@ -50,16 +64,63 @@ func (r *Result) Throughput() float64 {
 	return float64(r.Operations) / r.Duration.Seconds()
 }
 
+// AbortRate returns the fraction of attempted operations that were aborted.
+func (r *Result) AbortRate() float64 {
+	if r.Aborts == 0 {
+		return 0.0
+	}
+
+	return float64(r.Aborts) / float64(r.Operations+r.Aborts)
+}
+
+// HitRatio returns the fraction of reads that found the key, for workloads
+// that fill the store on a miss as a cache would be used.
+func (r *Result) HitRatio() float64 {
+	if r.Hits == 0 {
+		return 0.0
+	}
+
+	return float64(r.Hits) / float64(r.Hits+r.Misses)
+}
+
+// Records the garbage collection statistics of the run from the memory
+// statistics read before and after it. Only the most recent 256 pauses are
+// available from the runtime, so the maximum pause is computed from those.
+func (r *Result) setMemStats(before, after *runtime.MemStats) {
+	r.GCCycles = after.NumGC - before.NumGC
+	r.GCPause = time.Duration(after.PauseTotalNs - before.PauseTotalNs)
+	r.HeapObjects = after.HeapObjects
+
+	for i := after.NumGC; i > before.NumGC && after.NumGC-i < uint32(len(after.PauseNs)); i-- {
+		if pause := time.Duration(after.PauseNs[(i+255)%256]); pause > r.MaxGCPause {
+			r.MaxGCPause = pause
+		}
+	}
+}
+
 // String returns a CSV value for writing the record to disk:
-// store,workload,concurrency,operations,duration (ns),throughput
+// store,workload,concurrency,operations,duration (ns),throughput,aborts,abort rate,hit ratio,evictions,gc cycles,gc pause (ns),max gc pause (ns),forced gc (ns),heap objects,p50 latency (ns),p90 latency (ns),p99 latency (ns),max latency (ns)
 func (r *Result) String() string {
 	return fmt.Sprintf(
-		"%s,%s,%d,%d,%d,%0.3f\n",
+		"%s,%s,%d,%d,%d,%0.3f,%d,%0.4f,%0.4f,%d,%d,%d,%d,%d,%d,%d,%d,%d,%d\n",
 		r.Store,
 		r.Workload,
 		r.Concurrency,
 		r.Operations,
 		r.Duration,
 		r.Throughput(),
+		r.Aborts,
+		r.AbortRate(),
+		r.HitRatio(),
+		r.Evictions,
+		r.GCCycles,
+		r.GCPause,
+		r.MaxGCPause,
+		r.ForcedGC,
+		r.HeapObjects,
+		r.P50Latency,
+		r.P90Latency,
+		r.P99Latency,
+		r.MaxLatency,
 	)
 }
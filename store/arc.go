@@ -0,0 +1,183 @@
+package store
+
+import "container/list"
+
+// ARC implements the Adaptive Replacement Cache policy of Megiddo and Modha.
+// Resident keys are split between T1, keys that have been accessed once
+// recently, and T2, keys that have been accessed at least twice. Keys evicted
+// from T1 and T2 are remembered (without their values) in the ghost lists B1
+// and B2. A miss on a key in B1 means T1 is too small, and a miss on a key in
+// B2 means T2 is too small, so the target size of T1 adapts between recency
+// and frequency as the access pattern changes.
+type ARC struct {
+	t1, t2, b1, b2 *list.List
+	index          map[string]*list.Element
+	capacity       int    // the expected number of resident keys
+	target         int    // the target size of T1
+	adapted        string // the incoming key the target was adapted for by Victim
+}
+
+// An entry in one of the ARC lists, which identifies the list it is in.
+type arcEntry struct {
+	key  string
+	list *list.List
+}
+
+// NewARC creates an adaptive replacement cache eviction policy.
+func NewARC(capacity int) Policy {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &ARC{
+		t1:       list.New(),
+		t2:       list.New(),
+		b1:       list.New(),
+		b2:       list.New(),
+		index:    make(map[string]*list.Element, capacity*2),
+		capacity: capacity,
+	}
+}
+
+// Add the key to T1, or to T2 if the key was remembered in one of the ghost
+// lists, adapting the target size of T1 if Victim has not already done so.
+func (p *ARC) Add(key string) {
+	if elem, ok := p.index[key]; ok {
+		if ghost := elem.Value.(*arcEntry).list; (ghost == p.b1 || ghost == p.b2) && p.adapted != key {
+			p.adapt(ghost)
+		}
+		p.move(elem, p.t2)
+	} else {
+		p.push(key, p.t1)
+	}
+
+	p.adapted = ""
+	p.trim()
+}
+
+// Hit moves the key to the front of T2 since it has been accessed again.
+func (p *ARC) Hit(key string) {
+	if elem, ok := p.index[key]; ok {
+		if list := elem.Value.(*arcEntry).list; list == p.t1 || list == p.t2 {
+			p.move(elem, p.t2)
+		}
+	}
+}
+
+// Remove the key from the policy, including from the ghost lists.
+func (p *ARC) Remove(key string) {
+	if elem, ok := p.index[key]; ok {
+		elem.Value.(*arcEntry).list.Remove(elem)
+		delete(p.index, key)
+	}
+}
+
+// Victim evicts the least recently used key of T1 if T1 is larger than its
+// target size, otherwise the least recently used key of T2, remembering the
+// evicted key in the corresponding ghost list. The incoming key is never
+// evicted; if it is the only key in the chosen list, the other list is used.
+func (p *ARC) Victim(incoming string) string {
+	var ghost *list.List
+	if elem, ok := p.index[incoming]; ok {
+		if list := elem.Value.(*arcEntry).list; list == p.b1 || list == p.b2 {
+			ghost = list
+			p.adapt(list)
+			p.adapted = incoming
+		}
+	}
+
+	from, to := p.t2, p.b2
+	if t1 := p.t1.Len(); t1 > 0 && (t1 > p.target || (ghost == p.b2 && t1 == p.target) || p.t2.Len() == 0) {
+		from, to = p.t1, p.b1
+	}
+
+	elem := lastExcept(from, incoming)
+	if elem == nil {
+		if from == p.t1 {
+			from, to = p.t2, p.b2
+		} else {
+			from, to = p.t1, p.b1
+		}
+		if elem = lastExcept(from, incoming); elem == nil {
+			return ""
+		}
+	}
+
+	key := elem.Value.(*arcEntry).key
+	p.move(elem, to)
+	p.trim()
+	return key
+}
+
+// String returns the name of the policy.
+func (p *ARC) String() string {
+	return "arc"
+}
+
+// Adapts the target size of T1 after a miss on a key in the ghost list.
+func (p *ARC) adapt(ghost *list.List) {
+	if ghost == p.b1 {
+		delta := 1
+		if p.b1.Len() > 0 && p.b2.Len() > p.b1.Len() {
+			delta = p.b2.Len() / p.b1.Len()
+		}
+		if p.target += delta; p.target > p.capacity {
+			p.target = p.capacity
+		}
+		return
+	}
+
+	delta := 1
+	if p.b2.Len() > 0 && p.b1.Len() > p.b2.Len() {
+		delta = p.b1.Len() / p.b2.Len()
+	}
+	if p.target -= delta; p.target < 0 {
+		p.target = 0
+	}
+}
+
+// Forgets the oldest ghost keys so that T1 and B1 together hold no more than
+// the capacity, and all four lists together hold no more than twice the
+// capacity (or the number of resident keys, if the shard holds more keys than
+// expected because its capacity is bounded by bytes).
+func (p *ARC) trim() {
+	for p.b1.Len() > 0 && p.t1.Len()+p.b1.Len() > p.capacity {
+		p.forget(p.b1)
+	}
+
+	limit := 2 * p.capacity
+	if resident := p.t1.Len() + p.t2.Len(); resident > p.capacity {
+		limit = resident + p.capacity
+	}
+
+	for p.b2.Len() > 0 && len(p.index) > limit {
+		p.forget(p.b2)
+	}
+}
+
+// Removes the least recently used key from the ghost list.
+func (p *ARC) forget(ghost *list.List) {
+	entry := ghost.Remove(ghost.Back()).(*arcEntry)
+	delete(p.index, entry.key)
+}
+
+// Pushes a new key to the front of the list.
+func (p *ARC) push(key string, to *list.List) {
+	p.index[key] = to.PushFront(&arcEntry{key: key, list: to})
+}
+
+// Moves an entry to the front of the specified list.
+func (p *ARC) move(elem *list.Element, to *list.List) {
+	entry := elem.Value.(*arcEntry)
+	entry.list.Remove(elem)
+	p.push(entry.key, to)
+}
+
+// Returns the least recently used entry of the list, skipping the key.
+func lastExcept(from *list.List, skip string) *list.Element {
+	elem := from.Back()
+	if elem != nil && elem.Value.(*arcEntry).key == skip {
+		elem = elem.Prev()
+	}
+	return elem
+}
@@ -0,0 +1,393 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// ArenaShardSize is the initial size in bytes of the arena of each shard.
+const ArenaShardSize = 1 << 20
+
+// Size of the header of each entry in an arena: the 64-bit hash of the key,
+// the 16-bit length of the key, and the 32-bit length of the value.
+const arenaHeader = 8 + 2 + 4
+
+// Arena is a sharded store in the style of bigcache that is designed to keep
+// the garbage collector from scanning its contents. Keys and values are
+// serialized into one large byte slice per shard, and each shard indexes its
+// entries with a map from the 64-bit hash of the key to the offset of the
+// entry in the arena. Neither the arena nor the index contains pointers, so
+// the cost of a GC cycle does not grow with the number of keys in the store.
+//
+// Each arena is a ring buffer: entries are appended at the head and the space
+// of deleted or overwritten entries is reclaimed as the tail passes them.
+// When the ring is full and the tail is live, the shard is compacted by
+// copying its live entries into a new arena, which is grown if it would be
+// more than half full. Keys whose hash collides with another key are indexed
+// by an overflow map of strings, which is expected to be (nearly) empty.
+//
+// Because entries are moved by compaction, Get returns a copy of the value.
+type Arena [ShardCount]*arenaShard
+
+// A thread safe ring buffer arena that implements a portion of the keyspace.
+type arenaShard struct {
+	sync.RWMutex
+	index      map[uint64]uint32 // hash of key to offset of its entry
+	collisions map[string]uint32 // keys whose hash collides with an indexed key
+	buf        []byte            // the ring buffer arena
+	head       int               // offset that the next entry is written at
+	tail       int               // offset of the oldest entry in the ring
+	wrap       int               // offset the head wrapped at, -1 if not wrapped
+	entries    int               // number of entries in the ring, live or dead
+	live       int               // number of bytes used by live entries
+}
+
+// NewArena creates an arena store, allocating the initial arena of each shard.
+func NewArena() (store *Arena, err error) {
+	store = new(Arena)
+	for i := 0; i < ShardCount; i++ {
+		store[i] = &arenaShard{
+			index:      make(map[uint64]uint32),
+			collisions: make(map[string]uint32),
+			buf:        make([]byte, ArenaShardSize),
+			wrap:       -1,
+		}
+	}
+	return store, nil
+}
+
+// Get a copy of the value by finding the shard the key belongs to and read
+// locking it. If the key is not in the shard, returns an error.
+func (s *Arena) Get(key string) (value []byte, err error) {
+	hash := fnv64(key)
+	shard := s[hash%ShardCount]
+	shard.RLock()
+	defer shard.RUnlock()
+
+	off, ok := shard.lookup(key, hash)
+	if !ok {
+		return nil, notFound(key)
+	}
+	return shard.value(off), nil
+}
+
+// Put a value by finding the shard the key belongs to, locking it and
+// appending the entry to the arena. Returns an error if the key exceeds the
+// maximum key size or the arena cannot grow to fit the entry.
+func (s *Arena) Put(key string, value []byte) (err error) {
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
+	hash := fnv64(key)
+	shard := s[hash%ShardCount]
+	shard.Lock()
+	defer shard.Unlock()
+	return shard.put(key, hash, value)
+}
+
+// Delete a key by finding the shard the key belongs to and locking it. The
+// space used by the entry is reclaimed when the tail of the ring passes it.
+func (s *Arena) Delete(key string) (err error) {
+	hash := fnv64(key)
+	shard := s[hash%ShardCount]
+	shard.Lock()
+	shard.remove(key, hash)
+	shard.Unlock()
+	return nil
+}
+
+// GetOrCreate returns a copy of the value stored or stores the supplied
+// default value. If the default value cannot be stored because the arena cannot
+// grow or the key is too large, the default value is returned but created is
+// false.
+func (s *Arena) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	if len(key) > speedmap.MaxKeySize {
+		return value, false
+	}
+
+	hash := fnv64(key)
+	shard := s[hash%ShardCount]
+	shard.Lock()
+	defer shard.Unlock()
+
+	if off, ok := shard.lookup(key, hash); ok {
+		return shard.value(off), false
+	}
+
+	if err := shard.put(key, hash, value); err != nil {
+		return value, false
+	}
+	return value, true
+}
+
+// Range calls fn with a copy of every key/value pair, read locking one shard
+// at a time, stopping if fn returns false.
+func (s *Arena) Range(fn func(key string, value []byte) bool) error {
+	for _, shard := range s {
+		shard.RLock()
+		for _, off := range shard.index {
+			if !fn(shard.key(off), shard.value(off)) {
+				shard.RUnlock()
+				return nil
+			}
+		}
+
+		for key, off := range shard.collisions {
+			if !fn(key, shard.value(off)) {
+				shard.RUnlock()
+				return nil
+			}
+		}
+		shard.RUnlock()
+	}
+	return nil
+}
+
+// String returns the string representation of the arena store.
+func (s *Arena) String() string {
+	return "arena"
+}
+
+// Returns the offset of the entry for the key. Must be called with the lock.
+func (s *arenaShard) lookup(key string, hash uint64) (uint32, bool) {
+	if len(s.collisions) > 0 {
+		if off, ok := s.collisions[key]; ok {
+			return off, true
+		}
+	}
+
+	if off, ok := s.index[hash]; ok && s.matches(off, key) {
+		return off, true
+	}
+	return 0, false
+}
+
+// Appends the entry to the arena and indexes it, removing any previous entry
+// for the key. Must be called with the lock.
+func (s *arenaShard) put(key string, hash uint64, value []byte) error {
+	size := arenaHeader + len(key) + len(value)
+	if uint64(size) > math.MaxUint32/2 {
+		return fmt.Errorf("value of %d bytes is too large for the arena", len(value))
+	}
+
+	s.remove(key, hash)
+
+	off, err := s.reserve(size)
+	if err != nil {
+		return err
+	}
+
+	entry := s.buf[off : off+size]
+	binary.LittleEndian.PutUint64(entry[0:8], hash)
+	binary.LittleEndian.PutUint16(entry[8:10], uint16(len(key)))
+	binary.LittleEndian.PutUint32(entry[10:14], uint32(len(value)))
+	copy(entry[arenaHeader:], key)
+	copy(entry[arenaHeader+len(key):], value)
+
+	if idx, ok := s.index[hash]; ok && !s.matches(idx, key) {
+		s.collisions[key] = uint32(off)
+	} else {
+		s.index[hash] = uint32(off)
+	}
+
+	s.live += size
+	return nil
+}
+
+// Removes the key from the index so that its entry is dead. Must be called
+// with the lock.
+func (s *arenaShard) remove(key string, hash uint64) {
+	if off, ok := s.collisions[key]; ok {
+		s.live -= s.size(int(off))
+		delete(s.collisions, key)
+		return
+	}
+
+	if off, ok := s.index[hash]; ok && s.matches(off, key) {
+		s.live -= s.size(int(off))
+		delete(s.index, hash)
+	}
+}
+
+// Returns the offset in the ring that an entry of the specified size can be
+// written at, advancing the head past it. Dead entries are reclaimed from the
+// tail of the ring to make room, and if that is not enough, the shard is
+// compacted. Must be called with the lock.
+func (s *arenaShard) reserve(size int) (int, error) {
+	for {
+		if off, ok := s.advance(size); ok {
+			return off, nil
+		}
+
+		if s.entries == 0 || s.alive(s.tail) {
+			break
+		}
+		s.pop()
+	}
+
+	if err := s.compact(size); err != nil {
+		return 0, err
+	}
+
+	off, _ := s.advance(size)
+	return off, nil
+}
+
+// Advances the head past an entry of the specified size if there is room
+// for it, wrapping to the start of the arena if there is not enough room at
+// the end. Returns the offset of the entry and false if there is no room.
+func (s *arenaShard) advance(size int) (int, bool) {
+	if s.entries == 0 {
+		s.head, s.tail, s.wrap = 0, 0, -1
+	}
+
+	if s.wrap < 0 {
+		// The live region is [tail, head), free space at the end and start
+		if s.head+size <= len(s.buf) {
+			off := s.head
+			s.head += size
+			s.entries++
+			return off, true
+		}
+
+		if size <= s.tail {
+			s.wrap = s.head
+			s.head = size
+			s.entries++
+			return 0, true
+		}
+		return 0, false
+	}
+
+	// The live region is [tail, wrap) and [0, head), free space is [head, tail)
+	if s.head+size <= s.tail {
+		off := s.head
+		s.head += size
+		s.entries++
+		return off, true
+	}
+	return 0, false
+}
+
+// Reclaims the entry at the tail of the ring. Must be called with the lock.
+func (s *arenaShard) pop() {
+	s.tail += s.size(s.tail)
+	s.entries--
+	if s.tail == s.wrap {
+		s.tail, s.wrap = 0, -1
+	}
+}
+
+// Copies the live entries into a new arena that is large enough to hold them
+// along with an entry of the specified size while being at most half full,
+// updating the index with the new offsets. Must be called with the lock.
+func (s *arenaShard) compact(size int) error {
+	need := s.live + size
+	capacity := len(s.buf)
+	for capacity < need*2 {
+		capacity *= 2
+	}
+
+	if uint64(capacity) > math.MaxUint32 {
+		return fmt.Errorf("arena shard cannot grow beyond %d bytes", uint64(math.MaxUint32))
+	}
+
+	buf := make([]byte, capacity)
+	pos, head, entries := s.tail, 0, 0
+	for i := 0; i < s.entries; i++ {
+		if s.wrap >= 0 && pos == s.wrap {
+			pos = 0
+		}
+
+		n := s.size(pos)
+		if s.alive(pos) {
+			copy(buf[head:], s.buf[pos:pos+n])
+			s.reindex(pos, head)
+			head += n
+			entries++
+		}
+		pos += n
+	}
+
+	s.buf, s.head, s.tail, s.wrap, s.entries = buf, head, 0, -1, entries
+	return nil
+}
+
+// Returns true if the entry at the offset is indexed. Must be called with
+// the lock.
+func (s *arenaShard) alive(off int) bool {
+	if idx, ok := s.index[s.hash(off)]; ok && int(idx) == off {
+		return true
+	}
+
+	if len(s.collisions) > 0 {
+		if idx, ok := s.collisions[s.key(uint32(off))]; ok && int(idx) == off {
+			return true
+		}
+	}
+	return false
+}
+
+// Points the index of the live entry at its new offset. Must be called with
+// the lock and before the old offset is overwritten.
+func (s *arenaShard) reindex(from, to int) {
+	hash := s.hash(from)
+	if off, ok := s.index[hash]; ok && int(off) == from {
+		s.index[hash] = uint32(to)
+		return
+	}
+	s.collisions[s.key(uint32(from))] = uint32(to)
+}
+
+// Returns the hash stored in the header of the entry at the offset.
+func (s *arenaShard) hash(off int) uint64 {
+	return binary.LittleEndian.Uint64(s.buf[off : off+8])
+}
+
+// Returns the total size of the entry at the offset.
+func (s *arenaShard) size(off int) int {
+	klen := int(binary.LittleEndian.Uint16(s.buf[off+8 : off+10]))
+	vlen := int(binary.LittleEndian.Uint32(s.buf[off+10 : off+14]))
+	return arenaHeader + klen + vlen
+}
+
+// Returns the key of the entry at the offset.
+func (s *arenaShard) key(off uint32) string {
+	klen := int(binary.LittleEndian.Uint16(s.buf[off+8 : off+10]))
+	start := int(off) + arenaHeader
+	return string(s.buf[start : start+klen])
+}
+
+// Returns true if the entry at the offset is for the key, without allocating.
+func (s *arenaShard) matches(off uint32, key string) bool {
+	klen := int(binary.LittleEndian.Uint16(s.buf[off+8 : off+10]))
+	start := int(off) + arenaHeader
+	return string(s.buf[start:start+klen]) == key
+}
+
+// Returns a copy of the value of the entry at the offset.
+func (s *arenaShard) value(off uint32) []byte {
+	klen := int(binary.LittleEndian.Uint16(s.buf[off+8 : off+10]))
+	vlen := int(binary.LittleEndian.Uint32(s.buf[off+10 : off+14]))
+	start := int(off) + arenaHeader + klen
+
+	value := make([]byte, vlen)
+	copy(value, s.buf[start:start+vlen])
+	return value
+}
+
+// Computes the 64-bit FNV-1a hash of the specified key.
+func fnv64(key string) uint64 {
+	hash := uint64(14695981039346656037)
+	const prime64 = uint64(1099511628211)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= prime64
+	}
+	return hash
+}
@@ -0,0 +1,103 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/store"
+)
+
+var _ = Describe("Arena", func() {
+
+	var (
+		err   error
+		store speedmap.Store
+	)
+
+	BeforeEach(func() {
+		store, err = NewArena()
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should be a store", func() {
+		Ω(&Arena{}).Should(BeAssignableToTypeOf(store))
+	})
+
+	It("should be able to perform store operations", func() {
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+
+		val, err := store.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		Ω(store.Delete("foo")).Should(Succeed())
+
+		val, err = store.Get("foo")
+		Ω(err).Should(HaveOccurred())
+		Ω(val).Should(BeNil())
+	})
+
+	for _, conformance := range Conformance {
+		check := conformance.Check
+		It("should "+conformance.Behavior, func() {
+			Ω(check(store)).Should(Succeed())
+		})
+	}
+
+	It("should be able to get or create a value", func() {
+		actual, created := store.GetOrCreate("foo", []byte("bar"))
+		Ω(actual).Should(Equal([]byte("bar")))
+		Ω(created).Should(BeTrue())
+
+		actual, created = store.GetOrCreate("foo", []byte("baz"))
+		Ω(actual).Should(Equal([]byte("bar")))
+		Ω(created).Should(BeFalse())
+	})
+
+	It("should return a copy of the value", func() {
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+
+		val, err := store.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		val[0] = 'c'
+
+		val, err = store.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+	})
+
+	It("should reclaim and compact the arena as values change", func() {
+		expected := make(map[string][]byte)
+		for i := 0; i < 200000; i++ {
+			key := fmt.Sprintf("%X", rand.Intn(5000))
+
+			if rand.Float32() < 0.1 {
+				Ω(store.Delete(key)).Should(Succeed())
+				delete(expected, key)
+				continue
+			}
+
+			val := make([]byte, rand.Intn(1024))
+			rand.Read(val)
+			Ω(store.Put(key, val)).Should(Succeed())
+			expected[key] = val
+		}
+
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("%X", i)
+			val, err := store.Get(key)
+			if exp, ok := expected[key]; ok {
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(val).Should(Equal(exp))
+			} else {
+				Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+			}
+		}
+	})
+
+})
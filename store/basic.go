@@ -1,8 +1,9 @@
 package store
 
 import (
-	"fmt"
 	"sync"
+
+	"github.com/bbengfort/speedmap"
 )
 
 // Basic implements a simple key/value data structure that is synchronized
@@ -28,13 +29,18 @@ func (s *Basic) Get(key string) (value []byte, err error) {
 
 	val, ok := s.data[key]
 	if !ok {
-		return nil, fmt.Errorf("no value found for key '%s'", key)
+		return nil, notFound(key)
 	}
 	return val, nil
 }
 
-// Put a value by locking the internal map and storing it. No error returned.
+// Put a value by locking the internal map and storing it. Returns an error
+// only if the key exceeds the maximum key size.
 func (s *Basic) Put(key string, value []byte) (err error) {
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
 	s.Lock()
 	defer s.Unlock()
 
@@ -53,7 +59,12 @@ func (s *Basic) Delete(key string) (err error) {
 }
 
 // GetOrCreate returns the value stored or stores the supplied default value.
+// A key that is too large is not stored, and created is false.
 func (s *Basic) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	if len(key) > speedmap.MaxKeySize {
+		return value, false
+	}
+
 	s.Lock()
 	defer s.Unlock()
 
@@ -68,6 +79,52 @@ func (s *Basic) GetOrCreate(key string, value []byte) (actual []byte, created bo
 	return actual, false
 }
 
+// MultiGet fetches the values of all keys while holding a single read lock.
+// The value of a key that is not in the map is nil.
+func (s *Basic) MultiGet(keys []string) (values [][]byte, err error) {
+	values = make([][]byte, len(keys))
+
+	s.RLock()
+	defer s.RUnlock()
+
+	for i, key := range keys {
+		values[i] = s.data[key]
+	}
+	return values, nil
+}
+
+// MultiPut stores all of the pairs while holding a single write lock. If any
+// key exceeds the maximum key size then none of the pairs are stored.
+func (s *Basic) MultiPut(pairs map[string][]byte) (err error) {
+	for key := range pairs {
+		if len(key) > speedmap.MaxKeySize {
+			return keyTooLarge(key)
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for key, value := range pairs {
+		s.data[key] = value
+	}
+	return nil
+}
+
+// Range calls fn for every key/value pair while read locking the internal
+// map, stopping if fn returns false.
+func (s *Basic) Range(fn func(key string, value []byte) bool) error {
+	s.RLock()
+	defer s.RUnlock()
+
+	for key, val := range s.data {
+		if !fn(key, val) {
+			break
+		}
+	}
+	return nil
+}
+
 // String returns a string representation of the Store
 func (s *Basic) String() string {
 	return "basic"
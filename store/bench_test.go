@@ -2,13 +2,15 @@ package store_test
 
 import (
 	"fmt"
+	"io"
 	"testing"
 
 	"github.com/bbengfort/speedmap"
 	. "github.com/bbengfort/speedmap/store"
 )
 
-// Create a list of stores for benchmarking
+// Create a list of stores for benchmarking; stores that are closers (such as
+// the expiring store and its sweeper) are closed when the benchmark finishes.
 func makeStores(t testing.TB) []speedmap.Store {
 	var (
 		e error
@@ -16,7 +18,7 @@ func makeStores(t testing.TB) []speedmap.Store {
 	)
 
 	// Create the stores array
-	stores := make([]speedmap.Store, 0, 4)
+	stores := make([]speedmap.Store, 0, 9)
 
 	// Add the basic store
 	if s, e = NewBasic(); e != nil {
@@ -42,6 +44,43 @@ func makeStores(t testing.TB) []speedmap.Store {
 	}
 	stores = append(stores, s)
 
+	// Add the arena store
+	if s, e = NewArena(); e != nil {
+		t.Fatalf("could not create arena store: %s", e)
+	}
+	stores = append(stores, s)
+
+	// Add the versioned store
+	if s, e = NewVersioned(); e != nil {
+		t.Fatalf("could not create versioned store: %s", e)
+	}
+	stores = append(stores, s)
+
+	// Add the mvcc store
+	if s, e = NewMVCC(); e != nil {
+		t.Fatalf("could not create mvcc store: %s", e)
+	}
+	stores = append(stores, s)
+
+	// Add the expiring store
+	if s, e = NewExpiring(DefaultSweepInterval); e != nil {
+		t.Fatalf("could not create expiring store: %s", e)
+	}
+	stores = append(stores, s)
+
+	// Add a cache large enough to hold every benchmarked key
+	if s, e = NewCache(ShardCount*DefaultCacheShardCapacity, 0, NewLRU); e != nil {
+		t.Fatalf("could not create cache store: %s", e)
+	}
+	stores = append(stores, s)
+
+	t.Cleanup(func() {
+		for _, s := range stores {
+			if closer, ok := s.(io.Closer); ok {
+				closer.Close()
+			}
+		}
+	})
 	return stores
 }
 
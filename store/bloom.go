@@ -0,0 +1,55 @@
+package store
+
+// The number of bits per key of the bloom filters of tables, which gives a
+// false positive rate of about 1% with the optimal number of hash functions.
+const bloomBitsPerKey = 10
+
+// A bloom filter over the keys of a table: the bits of the filter followed by
+// a byte holding the number of hash functions. The bit positions of a key are
+// derived from the two halves of its 64 bit FNV hash (double hashing).
+type bloom []byte
+
+// Creates a bloom filter from the hashes of the keys.
+func newBloom(hashes []uint64) bloom {
+	nbits := len(hashes) * bloomBitsPerKey
+	if nbits < 64 {
+		nbits = 64
+	}
+
+	nbytes := (nbits + 7) / 8
+	nbits = nbytes * 8
+
+	// k = ln(2) * bits per key, rounded down
+	k := bloomBitsPerKey * 69 / 100
+	filter := make(bloom, nbytes+1)
+	filter[nbytes] = byte(k)
+
+	for _, hash := range hashes {
+		h1, h2 := uint32(hash), uint32(hash>>32)
+		for i := 0; i < k; i++ {
+			bit := (h1 + uint32(i)*h2) % uint32(nbits)
+			filter[bit/8] |= 1 << (bit % 8)
+		}
+	}
+	return filter
+}
+
+// Returns false if the key is definitely not in the filter, true if it may be.
+func (b bloom) contains(key string) bool {
+	if len(b) < 2 {
+		return true
+	}
+
+	nbits := uint32(len(b)-1) * 8
+	k := int(b[len(b)-1])
+	hash := fnv64(key)
+	h1, h2 := uint32(hash), uint32(hash>>32)
+
+	for i := 0; i < k; i++ {
+		bit := (h1 + uint32(i)*h2) % nbits
+		if b[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
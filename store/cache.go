@@ -0,0 +1,238 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// DefaultCacheShardCapacity is the capacity in entries that eviction policies
+// are sized for when a Cache is bounded only by bytes.
+const DefaultCacheShardCapacity = 1024
+
+// Cache is a sharded store (like Shard) whose size is bounded by a maximum
+// number of entries, a maximum number of bytes of keys and values, or both.
+// Each shard is bounded by its fraction of the limits and has its own
+// eviction policy that chooses which key to evict when the shard is full, so
+// that evictions in one shard do not block operations on the others. Because
+// the eviction policies track accesses, reads take the write lock of the
+// shard; the Cache trades some read concurrency for its bounded memory.
+type Cache struct {
+	shards     [ShardCount]*cacheShard
+	policy     string
+	maxEntries int   // maximum entries per shard, zero if unbounded
+	maxBytes   int64 // maximum bytes per shard, zero if unbounded
+	hits       uint64
+	misses     uint64
+	evictions  uint64
+}
+
+// A thread safe map that implements a portion of the cache keyspace.
+type cacheShard struct {
+	sync.Mutex
+	data   map[string][]byte
+	bytes  int64
+	policy Policy
+}
+
+// NewCache creates a cache that holds at most maxEntries keys and maxBytes
+// bytes of keys and values, evicting keys chosen by the policy created by the
+// factory. A limit of zero or less means that the cache is not bounded by it,
+// but at least one of the limits is required.
+func NewCache(maxEntries int, maxBytes int64, policy PolicyFactory) (store *Cache, err error) {
+	if maxEntries <= 0 && maxBytes <= 0 {
+		return nil, fmt.Errorf("cache requires a maximum number of entries or bytes")
+	}
+
+	store = new(Cache)
+	if maxEntries > 0 {
+		store.maxEntries = (maxEntries + ShardCount - 1) / ShardCount
+	}
+	if maxBytes > 0 {
+		store.maxBytes = (maxBytes + ShardCount - 1) / ShardCount
+	}
+
+	capacity := store.maxEntries
+	if capacity == 0 {
+		capacity = DefaultCacheShardCapacity
+	}
+
+	for i := 0; i < ShardCount; i++ {
+		store.shards[i] = &cacheShard{
+			data:   make(map[string][]byte, capacity),
+			policy: policy(capacity),
+		}
+	}
+
+	store.policy = store.shards[0].policy.String()
+	return store, nil
+}
+
+// Get a value by finding the shard the key belongs to and locking it so that
+// the hit can be recorded by the eviction policy. If the key is not in the
+// cache, either because it was never stored or because it was evicted,
+// returns an error.
+func (s *Cache) Get(key string) (value []byte, err error) {
+	shard := s.shards[shardIndex(key)]
+	shard.Lock()
+	val, ok := shard.data[key]
+	if ok {
+		shard.policy.Hit(key)
+	}
+	shard.Unlock()
+
+	if !ok {
+		atomic.AddUint64(&s.misses, 1)
+		return nil, notFound(key)
+	}
+
+	atomic.AddUint64(&s.hits, 1)
+	return val, nil
+}
+
+// Put a value by finding the shard the key belongs to and locking it, evicting
+// keys from the shard as needed to make room for the value.
+func (s *Cache) Put(key string, value []byte) (err error) {
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
+	shard := s.shards[shardIndex(key)]
+	shard.Lock()
+	s.put(shard, key, value)
+	shard.Unlock()
+	return nil
+}
+
+// Delete a key by finding the shard the key belongs to and locking it. No
+// error is returned even if the key isn't in the cache to begin with.
+func (s *Cache) Delete(key string) (err error) {
+	shard := s.shards[shardIndex(key)]
+	shard.Lock()
+	if val, ok := shard.data[key]; ok {
+		delete(shard.data, key)
+		shard.bytes -= entrySize(key, val)
+		shard.policy.Remove(key)
+	}
+	shard.Unlock()
+	return nil
+}
+
+// GetOrCreate returns the value in the cache, recording a hit, or stores the
+// supplied default value, recording a miss. A key that is too large is not
+// stored, and created is false.
+func (s *Cache) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	if len(key) > speedmap.MaxKeySize {
+		return value, false
+	}
+
+	shard := s.shards[shardIndex(key)]
+	shard.Lock()
+	defer shard.Unlock()
+
+	if val, ok := shard.data[key]; ok {
+		shard.policy.Hit(key)
+		atomic.AddUint64(&s.hits, 1)
+		return val, false
+	}
+
+	atomic.AddUint64(&s.misses, 1)
+	s.put(shard, key, value)
+	return value, true
+}
+
+// Range calls fn for every key/value pair, locking one shard at a time,
+// stopping if fn returns false. Visiting a key is not a hit.
+func (s *Cache) Range(fn func(key string, value []byte) bool) error {
+	for _, shard := range s.shards {
+		shard.Lock()
+		for key, val := range shard.data {
+			if !fn(key, val) {
+				shard.Unlock()
+				return nil
+			}
+		}
+		shard.Unlock()
+	}
+	return nil
+}
+
+// Stats returns the hits, misses, and evictions of the cache along with the
+// number of entries and bytes it currently holds.
+func (s *Cache) Stats() speedmap.CacheStats {
+	stats := speedmap.CacheStats{
+		Hits:      atomic.LoadUint64(&s.hits),
+		Misses:    atomic.LoadUint64(&s.misses),
+		Evictions: atomic.LoadUint64(&s.evictions),
+	}
+
+	for _, shard := range s.shards {
+		shard.Lock()
+		stats.Entries += len(shard.data)
+		stats.Bytes += shard.bytes
+		shard.Unlock()
+	}
+	return stats
+}
+
+// String returns a string representation of the cache and its policy.
+func (s *Cache) String() string {
+	return "cache " + s.policy
+}
+
+// Stores the value in the shard, evicting keys before inserting a new key
+// until there is room for it, or after updating a key until the shard is back
+// within its byte budget. A value that is larger than the budget of the shard
+// is stored on its own. Must be called while holding the lock of the shard.
+func (s *Cache) put(shard *cacheShard, key string, value []byte) {
+	size := entrySize(key, value)
+
+	if old, ok := shard.data[key]; ok {
+		shard.data[key] = value
+		shard.bytes += size - entrySize(key, old)
+		shard.policy.Hit(key)
+
+		for s.maxBytes > 0 && shard.bytes > s.maxBytes && len(shard.data) > 1 {
+			if victim := s.evict(shard, key); victim == "" {
+				break
+			}
+		}
+		return
+	}
+
+	for len(shard.data) > 0 && s.full(shard, size) {
+		if victim := s.evict(shard, key); victim == "" {
+			break
+		}
+	}
+
+	shard.data[key] = value
+	shard.bytes += size
+	shard.policy.Add(key)
+}
+
+// Returns true if the shard does not have room for another entry of the size.
+func (s *Cache) full(shard *cacheShard, size int64) bool {
+	if s.maxEntries > 0 && len(shard.data) >= s.maxEntries {
+		return true
+	}
+	return s.maxBytes > 0 && shard.bytes+size > s.maxBytes
+}
+
+// Evicts the victim chosen by the shard's policy and returns its key.
+func (s *Cache) evict(shard *cacheShard, incoming string) string {
+	victim := shard.policy.Victim(incoming)
+	if val, ok := shard.data[victim]; ok {
+		delete(shard.data, victim)
+		shard.bytes -= entrySize(victim, val)
+		atomic.AddUint64(&s.evictions, 1)
+	}
+	return victim
+}
+
+// Returns the number of bytes the cache accounts for the key and value.
+func entrySize(key string, value []byte) int64 {
+	return int64(len(key) + len(value))
+}
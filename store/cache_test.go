@@ -0,0 +1,191 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/store"
+)
+
+var _ = Describe("Cache", func() {
+
+	It("should require a bound", func() {
+		_, err := NewCache(0, 0, NewLRU)
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should look up eviction policies by name", func() {
+		for _, name := range []string{"lru", "clock", "tinylfu", "arc"} {
+			factory, err := GetPolicy(name)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(factory(8).String()).Should(Equal(name))
+		}
+
+		_, err := GetPolicy("random")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	for _, name := range []string{"lru", "clock", "tinylfu", "arc"} {
+		name := name
+
+		Describe(name, func() {
+
+			var (
+				err   error
+				store speedmap.Store
+			)
+
+			BeforeEach(func() {
+				store, err = NewCache(ShardCount*16, 0, Policies[name])
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should be a bounded store", func() {
+				Ω(&Cache{}).Should(BeAssignableToTypeOf(store))
+				Ω(store.String()).Should(Equal("cache " + name))
+
+				_, ok := store.(speedmap.Bounded)
+				Ω(ok).Should(BeTrue())
+			})
+
+			It("should be able to perform store operations", func() {
+				Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+
+				val, err := store.Get("foo")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(val).Should(Equal([]byte("bar")))
+
+				Ω(store.Delete("foo")).Should(Succeed())
+
+				val, err = store.Get("foo")
+				Ω(err).Should(HaveOccurred())
+				Ω(val).Should(BeNil())
+			})
+
+			It("should return standard errors", func() {
+				_, err := store.Get("foo")
+				Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+
+				key := strings.Repeat("a", speedmap.MaxKeySize+1)
+				err = store.Put(key, []byte("bar"))
+				Ω(errors.Is(err, speedmap.ErrKeyTooLarge)).Should(BeTrue())
+			})
+
+			It("should range over its contents", func() {
+				for i := 0; i < 100; i++ {
+					key := fmt.Sprintf("%X", i)
+					Ω(store.Put(key, []byte(key))).Should(Succeed())
+				}
+				Ω(store.Delete("0")).Should(Succeed())
+
+				seen := make(map[string]string)
+				err := store.(speedmap.Iterable).Range(func(key string, value []byte) bool {
+					seen[key] = string(value)
+					return true
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(seen).Should(HaveLen(99))
+				Ω(seen).Should(HaveKeyWithValue("1", "1"))
+				Ω(seen).ShouldNot(HaveKey("0"))
+			})
+
+			It("should be able to get or create a value", func() {
+				actual, created := store.GetOrCreate("foo", []byte("bar"))
+				Ω(created).Should(BeTrue())
+				Ω(actual).Should(Equal([]byte("bar")))
+
+				actual, created = store.GetOrCreate("foo", []byte("baz"))
+				Ω(created).Should(BeFalse())
+				Ω(actual).Should(Equal([]byte("bar")))
+			})
+
+			It("should evict keys to stay within its capacity", func() {
+				for i := 0; i < 10000; i++ {
+					key := fmt.Sprintf("%X", i)
+					Ω(store.Put(key, []byte(key))).Should(Succeed())
+				}
+
+				stats := store.(speedmap.Bounded).Stats()
+				Ω(stats.Entries).Should(BeNumerically("<=", ShardCount*16))
+				Ω(stats.Evictions).Should(BeEquivalentTo(10000 - stats.Entries))
+			})
+
+			It("should retain frequently accessed keys", func() {
+				Ω(store.Put("hot", []byte("value"))).Should(Succeed())
+
+				for i := 0; i < 10000; i++ {
+					_, err := store.Get("hot")
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(store.Put(fmt.Sprintf("%X", i), []byte("cold"))).Should(Succeed())
+				}
+
+				stats := store.(speedmap.Bounded).Stats()
+				Ω(stats.Hits).Should(BeEquivalentTo(10000))
+				Ω(stats.HitRatio()).Should(Equal(1.0))
+			})
+
+			It("should keep an updated key that grows past its byte budget", func() {
+				cache, err := NewCache(0, ShardCount*1024, Policies[name])
+				Ω(err).ShouldNot(HaveOccurred())
+
+				// Make foo the only key in the main space of its shard
+				other := sameShard("foo")
+				Ω(cache.Put("foo", []byte("bar"))).Should(Succeed())
+				Ω(cache.Put(other, []byte("bar"))).Should(Succeed())
+				_, err = cache.Get("foo")
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(cache.Put("foo", make([]byte, 2048))).Should(Succeed())
+				val, err := cache.Get("foo")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(val).Should(HaveLen(2048))
+
+				_, err = cache.Get(other)
+				Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+				Ω(cache.Stats().Evictions).Should(BeEquivalentTo(1))
+			})
+
+		})
+	}
+
+	It("should evict keys to stay within its byte budget", func() {
+		store, err := NewCache(0, ShardCount*1024, NewTinyLFU)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		value := make([]byte, 120)
+		for i := 0; i < 10000; i++ {
+			Ω(store.Put(fmt.Sprintf("%X", i), value)).Should(Succeed())
+		}
+
+		stats := store.Stats()
+		Ω(stats.Bytes).Should(BeNumerically("<=", ShardCount*1024))
+		Ω(stats.Evictions).Should(BeNumerically(">", 0))
+
+		// A value that grows past the budget evicts other keys
+		Ω(store.Put("foo", value)).Should(Succeed())
+		Ω(store.Put("foo", make([]byte, 1000))).Should(Succeed())
+		Ω(store.Stats().Bytes).Should(BeNumerically("<=", ShardCount*1024))
+	})
+
+})
+
+// Returns a key that is stored in the same shard as the specified key.
+func sameShard(key string) string {
+	shard := func(key string) uint32 {
+		hash := fnv.New32()
+		hash.Write([]byte(key))
+		return hash.Sum32() % ShardCount
+	}
+
+	for i := 0; ; i++ {
+		if other := fmt.Sprintf("%X", i); other != key && shard(other) == shard(key) {
+			return other
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+	"github.com/bbengfort/speedmap/wal"
+)
+
+// Durable wraps any store with a write-ahead log so that its contents survive
+// restarts: every Put and Delete is appended to the log before it is applied
+// to the wrapped store, and the log is replayed into the wrapped store when
+// the Durable store is created. Writes to the same key are serialized by one
+// of ShardCount key locks so that the log records the changes to a key in the
+// order they were applied; writes to different keys remain concurrent and are
+// group committed by the log.
+type Durable struct {
+	speedmap.Store
+	log   *wal.Log
+	locks [ShardCount]sync.Mutex
+}
+
+// NewDurable replays the log into the store, then wraps the store so that
+// subsequent changes are appended to the log. The store should be empty or
+// hold the pairs that the log was started from (e.g. a loaded snapshot), since
+// the log only records the changes made through the Durable store.
+func NewDurable(store speedmap.Store, log *wal.Log) (durable *Durable, err error) {
+	err = log.Replay(func(op wal.Op, key string, value []byte) error {
+		if op == wal.OpDelete {
+			return store.Delete(key)
+		}
+		return store.Put(key, value)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &Durable{Store: store, log: log}, nil
+}
+
+// Put appends the value to the log, then puts it to the wrapped store.
+func (s *Durable) Put(key string, value []byte) (err error) {
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
+	lock := &s.locks[shardIndex(key)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err = s.log.Append(wal.OpPut, key, value); err != nil {
+		return err
+	}
+	return s.Store.Put(key, value)
+}
+
+// PutWithTTL appends the value to the log, then puts it to the wrapped store,
+// which must be a speedmap.Expirer. The log does not record the TTL, so a
+// value replayed after a restart no longer expires.
+func (s *Durable) PutWithTTL(key string, value []byte, ttl time.Duration) (err error) {
+	expirer, ok := s.Store.(speedmap.Expirer)
+	if !ok {
+		return fmt.Errorf("the %s store does not support expiration", s.Store)
+	}
+
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
+	lock := &s.locks[shardIndex(key)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err = s.log.Append(wal.OpPut, key, value); err != nil {
+		return err
+	}
+	return expirer.PutWithTTL(key, value, ttl)
+}
+
+// Delete appends the delete to the log, then deletes the key from the
+// wrapped store.
+func (s *Durable) Delete(key string) (err error) {
+	lock := &s.locks[shardIndex(key)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err = s.log.Append(wal.OpDelete, key, nil); err != nil {
+		return err
+	}
+	return s.Store.Delete(key)
+}
+
+// GetOrCreate returns the value stored or appends the default value to the
+// log and then stores it. If the value cannot be appended to the log, the
+// default value is returned but is not stored, and created is false.
+func (s *Durable) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	lock := &s.locks[shardIndex(key)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	if actual, err := s.Store.Get(key); err == nil {
+		return actual, false
+	}
+
+	if err := s.log.Append(wal.OpPut, key, value); err != nil {
+		return value, false
+	}
+	return s.Store.GetOrCreate(key, value)
+}
+
+// Range calls fn for every key/value pair in the wrapped store, returning an
+// error if the wrapped store is not speedmap.Iterable.
+func (s *Durable) Range(fn func(key string, value []byte) bool) error {
+	return speedmap.Range(s.Store, fn)
+}
+
+// Close the write-ahead log, syncing any records that have not been synced.
+func (s *Durable) Close() error {
+	return s.log.Close()
+}
+
+// String returns a representation of the wrapped store and its log.
+func (s *Durable) String() string {
+	return s.Store.String() + " " + s.log.String()
+}
@@ -0,0 +1,177 @@
+package store_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/store"
+	"github.com/bbengfort/speedmap/wal"
+)
+
+var _ = Describe("Durable", func() {
+
+	var (
+		dir   string
+		store *Durable
+	)
+
+	// Opens the log in the directory and wraps a new shard store with it.
+	open := func() *Durable {
+		log, err := wal.Open(dir, wal.Options{Sync: wal.SyncAlways})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		shard, err := NewShard()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		durable, err := NewDurable(shard, log)
+		Ω(err).ShouldNot(HaveOccurred())
+		return durable
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "speedmap-durable")
+		Ω(err).ShouldNot(HaveOccurred())
+		store = open()
+	})
+
+	AfterEach(func() {
+		Ω(store.Close()).Should(Succeed())
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	It("should wrap a store", func() {
+		Ω(store.String()).Should(Equal("shard wal always"))
+	})
+
+	It("should be able to perform store operations", func() {
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+
+		val, err := store.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		Ω(store.Delete("foo")).Should(Succeed())
+
+		val, err = store.Get("foo")
+		Ω(err).Should(HaveOccurred())
+		Ω(val).Should(BeNil())
+	})
+
+	It("should put values with a ttl to an expiring store", func() {
+		Ω(store.PutWithTTL("foo", []byte("bar"), time.Minute)).ShouldNot(Succeed())
+		Ω(store.Close()).Should(Succeed())
+
+		log, err := wal.Open(dir, wal.Options{Sync: wal.SyncAlways})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		expiring, err := NewExpiring(0)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer expiring.Close()
+
+		store, err = NewDurable(expiring, log)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(store.PutWithTTL("foo", []byte("bar"), 20*time.Millisecond)).Should(Succeed())
+		val, err := store.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		time.Sleep(30 * time.Millisecond)
+		_, err = store.Get("foo")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+
+		Ω(store.Close()).Should(Succeed())
+		store = open()
+		val, err = store.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+	})
+
+	It("should return standard errors", func() {
+		_, err := store.Get("foo")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+
+		key := strings.Repeat("a", speedmap.MaxKeySize+1)
+		err = store.Put(key, []byte("bar"))
+		Ω(errors.Is(err, speedmap.ErrKeyTooLarge)).Should(BeTrue())
+
+		err = store.Delete(key)
+		Ω(errors.Is(err, speedmap.ErrKeyTooLarge)).Should(BeTrue())
+
+		_, created := store.GetOrCreate(key, []byte("bar"))
+		Ω(created).Should(BeFalse())
+
+		Ω(store.Close()).Should(Succeed())
+		err = store.Put("foo", []byte("bar"))
+		Ω(errors.Is(err, speedmap.ErrClosed)).Should(BeTrue())
+	})
+
+	It("should recover its contents after a restart", func() {
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+		Ω(store.Put("baz", []byte("qux"))).Should(Succeed())
+		Ω(store.Delete("baz")).Should(Succeed())
+
+		actual, created := store.GetOrCreate("zap", []byte("zing"))
+		Ω(created).Should(BeTrue())
+		Ω(actual).Should(Equal([]byte("zing")))
+
+		Ω(store.Close()).Should(Succeed())
+		store = open()
+
+		val, err := store.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		val, err = store.Get("zap")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("zing")))
+
+		_, err = store.Get("baz")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+	})
+
+	It("should replay the log on top of the pairs it was started from", func() {
+		Ω(store.Put("b", []byte("3"))).Should(Succeed())
+		Ω(store.Delete("a")).Should(Succeed())
+		Ω(store.Close()).Should(Succeed())
+
+		// Pairs loaded before the store is made durable, e.g. from a snapshot
+		for i := 0; i < 2; i++ {
+			log, err := wal.Open(dir, wal.Options{Sync: wal.SyncAlways})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			shard, err := NewShard()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(shard.Put("a", []byte("1"))).Should(Succeed())
+			Ω(shard.Put("b", []byte("2"))).Should(Succeed())
+			Ω(shard.Put("c", []byte("4"))).Should(Succeed())
+
+			store, err = NewDurable(shard, log)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = store.Get("a")
+			Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+			Ω(store.Get("b")).Should(Equal([]byte("3")))
+			Ω(store.Get("c")).Should(Equal([]byte("4")))
+			Ω(store.Close()).Should(Succeed())
+		}
+
+		// The log only holds the writes made through the durable store
+		store = open()
+		pairs := 0
+		Ω(store.Range(func(key string, value []byte) bool {
+			pairs++
+			return true
+		})).Should(Succeed())
+		Ω(pairs).Should(Equal(1))
+	})
+
+})
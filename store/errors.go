@@ -0,0 +1,17 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Wraps speedmap.ErrNotFound with the key that was not found in the store.
+func notFound(key string) error {
+	return fmt.Errorf("%w '%s'", speedmap.ErrNotFound, key)
+}
+
+// Wraps speedmap.ErrKeyTooLarge with the size of the offending key.
+func keyTooLarge(key string) error {
+	return fmt.Errorf("%w (%d > %d bytes)", speedmap.ErrKeyTooLarge, len(key), speedmap.MaxKeySize)
+}
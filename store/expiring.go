@@ -0,0 +1,242 @@
+package store
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// DefaultSweepInterval is how often the Expiring store sweeper runs.
+const DefaultSweepInterval = 100 * time.Millisecond
+
+// Expiring is a sharded store (like Shard) whose values can expire. Expired
+// values are removed lazily when they are read, and by a background sweeper
+// that periodically visits each shard in turn. Each shard keeps a min-heap of
+// expiration times so that the sweeper only touches expired keys rather than
+// scanning the shard, and only holds a single shard lock at a time.
+type Expiring struct {
+	shards [ShardCount]*expiringShard
+	ttl    time.Duration // the TTL of values stored with Put
+	sweep  time.Duration // the sweeper interval, zero if no sweeper
+	done   chan struct{}
+	once   sync.Once
+}
+
+// A thread safe map that implements a portion of the expiring keyspace.
+type expiringShard struct {
+	sync.RWMutex
+	data    map[string]expiring
+	expires expiryHeap // only maintained if the store has a sweeper
+	tracked bool
+}
+
+// A value along with its expiration time in nanoseconds, zero if none.
+type expiring struct {
+	value   []byte
+	expires int64
+}
+
+// NewExpiring creates an expiring store and starts its background sweeper
+// with the specified interval. If the interval is zero or less, no sweeper is
+// started and expired values are only removed lazily when they are read.
+func NewExpiring(sweep time.Duration) (store *Expiring, err error) {
+	store = &Expiring{done: make(chan struct{})}
+	for i := 0; i < ShardCount; i++ {
+		store.shards[i] = &expiringShard{data: make(map[string]expiring), tracked: sweep > 0}
+	}
+
+	if sweep > 0 {
+		store.sweep = sweep
+		go store.sweeper()
+	}
+	return store, nil
+}
+
+// SetDefaultTTL sets the TTL of values stored with Put and GetOrCreate. By
+// default these values never expire. Must be called before using the store.
+func (s *Expiring) SetDefaultTTL(ttl time.Duration) {
+	s.ttl = ttl
+}
+
+// Get a value by finding the shard the key belongs to and read locking it. If
+// the key is not in the shard or the value has expired, returns an error;
+// expired values are deleted if the shard has not been modified in between.
+func (s *Expiring) Get(key string) (value []byte, err error) {
+	shard := s.shards[shardIndex(key)]
+	shard.RLock()
+	val, ok := shard.data[key]
+	shard.RUnlock()
+
+	if !ok {
+		return nil, notFound(key)
+	}
+
+	now := time.Now().UnixNano()
+	if val.expired(now) {
+		shard.Lock()
+		if val, ok = shard.data[key]; ok && val.expired(now) {
+			delete(shard.data, key)
+		}
+		shard.Unlock()
+		return nil, notFound(key)
+	}
+	return val.value, nil
+}
+
+// Put a value with the default TTL of the store.
+func (s *Expiring) Put(key string, value []byte) (err error) {
+	return s.PutWithTTL(key, value, s.ttl)
+}
+
+// PutWithTTL puts a value that expires once the ttl has elapsed, or never if
+// the ttl is zero or less.
+func (s *Expiring) PutWithTTL(key string, value []byte, ttl time.Duration) (err error) {
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
+	shard := s.shards[shardIndex(key)]
+	shard.Lock()
+	shard.put(key, value, deadline(ttl))
+	shard.Unlock()
+	return nil
+}
+
+// Delete a key by finding the shard the key belongs to and locking it. Any
+// pending expiration of the key is ignored by the sweeper.
+func (s *Expiring) Delete(key string) (err error) {
+	shard := s.shards[shardIndex(key)]
+	shard.Lock()
+	delete(shard.data, key)
+	shard.Unlock()
+	return nil
+}
+
+// GetOrCreate returns the value stored if it has not expired, otherwise stores
+// the supplied default value with the default TTL of the store. A key that is
+// too large is not stored, and created is false.
+func (s *Expiring) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	if len(key) > speedmap.MaxKeySize {
+		return value, false
+	}
+
+	shard := s.shards[shardIndex(key)]
+	shard.Lock()
+	defer shard.Unlock()
+
+	if val, ok := shard.data[key]; ok && !val.expired(time.Now().UnixNano()) {
+		return val.value, false
+	}
+
+	shard.put(key, value, deadline(s.ttl))
+	return value, true
+}
+
+// Range calls fn for every key/value pair that has not expired, read locking
+// one shard at a time, stopping if fn returns false.
+func (s *Expiring) Range(fn func(key string, value []byte) bool) error {
+	now := time.Now().UnixNano()
+	for _, shard := range s.shards {
+		shard.RLock()
+		for key, val := range shard.data {
+			if !val.expired(now) && !fn(key, val.value) {
+				shard.RUnlock()
+				return nil
+			}
+		}
+		shard.RUnlock()
+	}
+	return nil
+}
+
+// Close stops the background sweeper; the store can still be used afterward
+// but expired values are only removed lazily when they are read.
+func (s *Expiring) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
+
+// String returns the string representation of the expiring store.
+func (s *Expiring) String() string {
+	if s.sweep > 0 {
+		return "expiring"
+	}
+	return "expiring lazy"
+}
+
+// Runs in its own go routine, sweeping each shard every interval until closed.
+func (s *Expiring) sweeper() {
+	ticker := time.NewTicker(s.sweep)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			for _, shard := range s.shards {
+				shard.sweep(time.Now().UnixNano())
+			}
+		}
+	}
+}
+
+// Stores the value and tracks its expiration. Must be called with the lock.
+func (s *expiringShard) put(key string, value []byte, expires int64) {
+	s.data[key] = expiring{value: value, expires: expires}
+	if expires > 0 && s.tracked {
+		heap.Push(&s.expires, expiry{key: key, expires: expires})
+	}
+}
+
+// Deletes every key whose expiration is before now by popping the expiration
+// heap. A heap entry is stale if the key has since been deleted or stored
+// again with a different expiration, in which case it is simply discarded.
+func (s *expiringShard) sweep(now int64) {
+	s.Lock()
+	defer s.Unlock()
+
+	for len(s.expires) > 0 && s.expires[0].expires <= now {
+		exp := heap.Pop(&s.expires).(expiry)
+		if val, ok := s.data[exp.key]; ok && val.expires == exp.expires {
+			delete(s.data, exp.key)
+		}
+	}
+}
+
+// Returns true if the value has an expiration that is before now.
+func (v expiring) expired(now int64) bool {
+	return v.expires > 0 && v.expires <= now
+}
+
+// Computes the expiration time of a ttl from now, zero if no expiration.
+func deadline(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
+
+// An entry in the expiration heap of a shard.
+type expiry struct {
+	key     string
+	expires int64
+}
+
+// Implements heap.Interface as a min-heap of expiration times.
+type expiryHeap []expiry
+
+func (h expiryHeap) Len() int            { return len(h) }
+func (h expiryHeap) Less(i, j int) bool  { return h[i].expires < h[j].expires }
+func (h expiryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x interface{}) { *h = append(*h, x.(expiry)) }
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,147 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/store"
+)
+
+var _ = Describe("Expiring", func() {
+
+	var (
+		err   error
+		store speedmap.Store
+	)
+
+	BeforeEach(func() {
+		store, err = NewExpiring(10 * time.Millisecond)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(store.(*Expiring).Close()).Should(Succeed())
+	})
+
+	It("should be a store", func() {
+		Ω(&Expiring{}).Should(BeAssignableToTypeOf(store))
+	})
+
+	It("should be able to perform store operations", func() {
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+
+		val, err := store.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		Ω(store.Delete("foo")).Should(Succeed())
+
+		val, err = store.Get("foo")
+		Ω(err).Should(HaveOccurred())
+		Ω(val).Should(BeNil())
+	})
+
+	for _, conformance := range Conformance {
+		check := conformance.Check
+		It("should "+conformance.Behavior, func() {
+			Ω(check(store)).Should(Succeed())
+		})
+	}
+
+	It("should be able to get or create a value", func() {
+		actual, created := store.GetOrCreate("foo", []byte("bar"))
+		Ω(actual).Should(Equal([]byte("bar")))
+		Ω(created).Should(BeTrue())
+
+		actual, created = store.GetOrCreate("foo", []byte("red"))
+		Ω(actual).Should(Equal([]byte("bar")))
+		Ω(created).Should(BeFalse())
+	})
+
+	It("should expire values lazily on read", func() {
+		lazy, err := NewExpiring(0)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(lazy.String()).Should(Equal("expiring lazy"))
+
+		Ω(lazy.PutWithTTL("foo", []byte("bar"), 20*time.Millisecond)).Should(Succeed())
+		Ω(lazy.PutWithTTL("baz", []byte("qux"), 0)).Should(Succeed())
+
+		val, err := lazy.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		time.Sleep(30 * time.Millisecond)
+		_, err = lazy.Get("foo")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+
+		actual, created := lazy.GetOrCreate("foo", []byte("new"))
+		Ω(created).Should(BeTrue())
+		Ω(actual).Should(Equal([]byte("new")))
+
+		_, err = lazy.Get("baz")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should sweep expired values in the background", func() {
+		expiring := store.(*Expiring)
+		expiring.SetDefaultTTL(20 * time.Millisecond)
+
+		for i := 0; i < 100; i++ {
+			Ω(store.Put(fmt.Sprintf("%X", i), []byte("foo"))).Should(Succeed())
+		}
+
+		// Overwriting a key without a ttl prevents it from being swept
+		Ω(expiring.PutWithTTL("0", []byte("bar"), 0)).Should(Succeed())
+
+		Eventually(func() error {
+			_, err := store.Get("63")
+			return err
+		}).Should(HaveOccurred())
+
+		val, err := store.Get("0")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+	})
+
+	Measure("get throughput", func(b Benchmarker) {
+		// Populate the store
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("%X", i)
+			store.Put(key, []byte(key))
+		}
+
+		results, err := Blast(store, 5000, "Get")
+		Ω(err).ShouldNot(HaveOccurred())
+		b.RecordValue("throughput", results.Throughput)
+	}, 10)
+
+	Measure("put throughput", func(b Benchmarker) {
+		results, err := Blast(store, 5000, "Put")
+		Ω(err).ShouldNot(HaveOccurred())
+		b.RecordValue("throughput", results.Throughput)
+	}, 10)
+
+	Measure("delete throughput", func(b Benchmarker) {
+		// Populate the store
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("%X", i)
+			store.Put(key, []byte(key))
+		}
+
+		results, err := Blast(store, 5000, "Delete")
+		Ω(err).ShouldNot(HaveOccurred())
+		b.RecordValue("throughput", results.Throughput)
+	}, 10)
+
+	Measure("get or create throughput", func(b Benchmarker) {
+		results, err := Blast(store, 5000, "GetOrCreate")
+		Ω(err).ShouldNot(HaveOccurred())
+		b.RecordValue("throughput", results.Throughput)
+	}, 10)
+
+})
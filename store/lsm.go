@@ -0,0 +1,849 @@
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bbengfort/speedmap"
+	"github.com/bbengfort/speedmap/wal"
+)
+
+// Defaults used when the corresponding LSM option is zero.
+const (
+	DefaultMemtableSize = 4 * 1024 * 1024
+	DefaultTableSize    = 2 * 1024 * 1024
+)
+
+// Parameters of the leveled compaction of the LSM store: level 0 is compacted
+// into level 1 once it has lsmLevel0Tables tables, and writes stall once it
+// has lsmLevel0Stall tables until compaction catches up. Every other level is
+// compacted into the next once it holds more than lsmLevelRatio times the
+// bytes of the previous level, starting at lsmLevel1Size bytes for level 1.
+const (
+	lsmLevels       = 7
+	lsmLevel0Tables = 4
+	lsmLevel0Stall  = 12
+	lsmLevel1Size   = 10 * 1024 * 1024
+	lsmLevelRatio   = 10
+)
+
+// Names of the files in the directory of the LSM store.
+const (
+	lsmManifest = "MANIFEST"
+	lsmLogExt   = ".log"
+)
+
+// LSMOptions configure the sizes of the memtable and tables of the LSM store
+// and the sync policy of its write-ahead log.
+type LSMOptions struct {
+	MemtableSize int64       // bytes of writes buffered in memory before they are flushed to a table
+	TableSize    int64       // target size in bytes of the tables created by compaction
+	Sync         wal.Options // sync policy of the log of the writes in the memtable
+}
+
+// LSM is a persistent store that is a log-structured merge tree. Writes are
+// appended to a write-ahead log and put into a sorted in-memory memtable;
+// when the memtable is full it is swapped for an empty one and flushed to an
+// immutable table on disk in the background. Tables are organized in levels:
+// tables in level 0 are flushed memtables whose keys may overlap, while the
+// tables of each deeper level hold disjoint ranges of keys and each level
+// holds about ten times more data than the one before it. When a level grows
+// too large its tables are merged into the next level (leveled compaction),
+// discarding overwritten values and, at the deepest level, deleted keys.
+//
+// A Get looks in the memtable, then in the memtable being flushed, then in
+// each level in turn from the newest tables to the oldest; every table has a
+// bloom filter so that tables which cannot hold the key are not read. Keys
+// are kept in order, so the LSM store is a speedmap.Scanner.
+type LSM struct {
+	dir   string
+	opts  LSMOptions
+	locks [ShardCount]sync.Mutex // serialize writes to a key so the log and memtable agree
+	next  uint64                 // the id of the next table or log, accessed atomically
+
+	mu     sync.RWMutex // protects the memtables and levels
+	cond   *sync.Cond   // signalled when the immutable memtable is flushed or level 0 is compacted
+	mem    *memtable    // the memtable receiving writes
+	imm    *memtable    // the full memtable being flushed, if any
+	levels [lsmLevels][]*sstable
+	closed bool
+	name   string
+	err    error // sticky error from the background compaction
+
+	work chan struct{} // wakes up the compactor
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewLSM opens the LSM store in the directory, creating it if necessary and
+// recovering the tables in its manifest and the writes in its logs.
+func NewLSM(dir string, opts LSMOptions) (store *LSM, err error) {
+	if opts.MemtableSize <= 0 {
+		opts.MemtableSize = DefaultMemtableSize
+	}
+	if opts.TableSize <= 0 {
+		opts.TableSize = DefaultTableSize
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	store = &LSM{dir: dir, opts: opts, work: make(chan struct{}, 1), done: make(chan struct{})}
+	store.cond = sync.NewCond(&store.mu)
+
+	if err = store.recover(); err != nil {
+		store.closeTables()
+		return nil, err
+	}
+
+	store.name = "lsm " + store.mem.log.String()
+	store.wg.Add(1)
+	go store.compactor()
+	store.signal()
+	return store, nil
+}
+
+// Get a value by looking for the key in the memtables, then in each level of
+// tables from newest to oldest. If the key is not found, or the newest entry
+// for the key is a tombstone, returns an error.
+func (s *LSM) Get(key string) (value []byte, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, fmt.Errorf("%w: lsm", speedmap.ErrClosed)
+	}
+
+	var (
+		entry lsmEntry
+		ok    bool
+	)
+
+	if entry, ok, err = s.get(key); err != nil {
+		return nil, err
+	}
+
+	if !ok || entry.deleted {
+		return nil, notFound(key)
+	}
+	return entry.value, nil
+}
+
+// Put a value by appending it to the log and putting it into the memtable.
+func (s *LSM) Put(key string, value []byte) (err error) {
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
+	lock := &s.locks[shardIndex(key)]
+	lock.Lock()
+	defer lock.Unlock()
+	return s.write(key, value, false)
+}
+
+// Delete a key by appending a tombstone to the log and the memtable. No error
+// is returned even if the key isn't in the store to begin with.
+func (s *LSM) Delete(key string) (err error) {
+	lock := &s.locks[shardIndex(key)]
+	lock.Lock()
+	defer lock.Unlock()
+	return s.write(key, nil, true)
+}
+
+// GetOrCreate returns the value stored or puts the default value. If the value
+// cannot be put, the default value is returned but is not stored, and created
+// is false.
+func (s *LSM) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	if len(key) > speedmap.MaxKeySize {
+		return value, false
+	}
+
+	lock := &s.locks[shardIndex(key)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	if actual, err := s.Get(key); err == nil {
+		return actual, false
+	}
+
+	if err := s.write(key, value, false); err != nil {
+		return value, false
+	}
+	return value, true
+}
+
+// Scan calls fn in key order for every key/value pair with start <= key < end
+// (or every key after start if end is empty) until fn returns false, merging
+// the memtables and the tables that overlap the range.
+func (s *LSM) Scan(start, end string, fn func(key string, value []byte) bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return fmt.Errorf("%w: lsm", speedmap.ErrClosed)
+	}
+
+	iters := []entryIterator{newSliceIterator(s.mem.entries(start, end))}
+	if s.imm != nil {
+		iters = append(iters, newSliceIterator(s.imm.entries(start, end)))
+	}
+
+	for _, tables := range s.levels {
+		for _, table := range tables {
+			if table.overlaps(start, end) {
+				iters = append(iters, table.iterator(start))
+			}
+		}
+	}
+
+	merge := newMergeIterator(iters...)
+	for merge.next() {
+		entry := merge.current()
+		if end != "" && entry.key >= end {
+			break
+		}
+		if entry.deleted {
+			continue
+		}
+		if !fn(entry.key, entry.value) {
+			break
+		}
+	}
+	return merge.err()
+}
+
+// Range calls fn for every key/value pair in key order until fn returns false.
+func (s *LSM) Range(fn func(key string, value []byte) bool) error {
+	return s.Scan("", "", fn)
+}
+
+// Levels returns the number of tables in each level of the store.
+func (s *LSM) Levels() []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make([]int, lsmLevels)
+	for level, tables := range s.levels {
+		counts[level] = len(tables)
+	}
+	return counts
+}
+
+// Close the store, waiting for a flush or compaction in progress to finish,
+// then closing the logs and tables. Writes in the memtables that have not been
+// flushed are recovered from their logs when the store is reopened.
+func (s *LSM) Close() (err error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	close(s.done)
+	s.wg.Wait()
+
+	err = s.mem.log.Close()
+	if s.imm != nil {
+		if ierr := s.imm.log.Close(); err == nil {
+			err = ierr
+		}
+	}
+
+	if terr := s.closeTables(); err == nil {
+		err = terr
+	}
+	return err
+}
+
+// String returns a representation of the store and the sync policy of its log.
+func (s *LSM) String() string {
+	return s.name
+}
+
+//===========================================================================
+// Reads and writes
+//===========================================================================
+
+// Returns the newest entry for the key. Must hold the read lock.
+func (s *LSM) get(key string) (entry lsmEntry, ok bool, err error) {
+	if entry, ok = s.mem.get(key); ok {
+		return entry, true, nil
+	}
+
+	if s.imm != nil {
+		if entry, ok = s.imm.get(key); ok {
+			return entry, true, nil
+		}
+	}
+
+	// Tables in level 0 may overlap and are ordered from newest to oldest
+	for _, table := range s.levels[0] {
+		if entry, ok, err = table.get(key); ok || err != nil {
+			return entry, ok, err
+		}
+	}
+
+	// Tables in the other levels are disjoint and sorted by their first key
+	for _, tables := range s.levels[1:] {
+		i := sort.Search(len(tables), func(i int) bool { return tables[i].last >= key })
+		if i < len(tables) {
+			if entry, ok, err = tables[i].get(key); ok || err != nil {
+				return entry, ok, err
+			}
+		}
+	}
+	return entry, false, nil
+}
+
+// Appends the write to the log, then puts it into the memtable, swapping the
+// memtable for an empty one if it is full. Must hold the lock of the key.
+func (s *LSM) write(key string, value []byte, deleted bool) (err error) {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return fmt.Errorf("%w: lsm", speedmap.ErrClosed)
+	}
+
+	op := wal.OpPut
+	if deleted {
+		op = wal.OpDelete
+	}
+
+	mem := s.mem
+	if err = mem.log.Append(op, key, value); err != nil {
+		s.mu.RUnlock()
+		return err
+	}
+
+	full := mem.put(key, value, deleted) >= s.opts.MemtableSize
+	s.mu.RUnlock()
+
+	if full {
+		return s.rotate(mem)
+	}
+	return nil
+}
+
+// Makes the full memtable immutable and replaces it with an empty memtable
+// with a new log, waiting for the previous immutable memtable to be flushed
+// and for level 0 to be compacted if it is too large so that writers cannot
+// outpace the compactor.
+func (s *LSM) rotate(full *memtable) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for (s.imm != nil || len(s.levels[0]) >= lsmLevel0Stall) && s.mem == full && !s.closed && s.err == nil {
+		s.cond.Wait()
+	}
+
+	// Another writer has already rotated the memtable
+	if s.mem != full || s.closed {
+		return nil
+	}
+
+	if s.err != nil {
+		return s.err
+	}
+
+	var mem *memtable
+	if mem, err = s.newMemtable(); err != nil {
+		return err
+	}
+
+	s.imm, s.mem = full, mem
+	s.signal()
+	return nil
+}
+
+// Creates an empty memtable with a new log.
+func (s *LSM) newMemtable() (mem *memtable, err error) {
+	dir := s.logPath(atomic.AddUint64(&s.next, 1) - 1)
+
+	var log *wal.Log
+	if log, err = wal.Open(dir, s.opts.Sync); err != nil {
+		return nil, err
+	}
+	return newMemtable(log, dir), nil
+}
+
+//===========================================================================
+// Flushes and compaction
+//===========================================================================
+
+// Wakes up the compactor if it is not already awake.
+func (s *LSM) signal() {
+	select {
+	case s.work <- struct{}{}:
+	default:
+	}
+}
+
+// Runs in its own go routine, flushing immutable memtables and compacting
+// levels that are too large whenever it is signalled, until the store is
+// closed. Errors are reported by subsequent writes that wait on a flush.
+func (s *LSM) compactor() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.work:
+		}
+
+		if err := s.compact(); err != nil {
+			s.mu.Lock()
+			if s.err == nil {
+				s.err = err
+			}
+			s.cond.Broadcast()
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// Flushes the immutable memtable and compacts levels until there is no work
+// left or the store is closed. Only the compactor changes the levels, so it
+// may read them without the lock.
+func (s *LSM) compact() (err error) {
+	for {
+		select {
+		case <-s.done:
+			return nil
+		default:
+		}
+
+		s.mu.RLock()
+		imm := s.imm
+		s.mu.RUnlock()
+
+		if imm != nil {
+			if err = s.flush(imm); err != nil {
+				return err
+			}
+			continue
+		}
+
+		level := s.pickLevel()
+		if level < 0 {
+			return nil
+		}
+
+		if err = s.compactLevel(level); err != nil {
+			return err
+		}
+	}
+}
+
+// Writes the immutable memtable to a new table in level 0, then removes the
+// logs that held its writes.
+func (s *LSM) flush(imm *memtable) (err error) {
+	var outputs []*sstable
+	if entries := imm.entries("", ""); len(entries) > 0 {
+		if outputs, err = s.writeTables(newSliceIterator(entries), false, 0); err != nil {
+			return err
+		}
+	}
+
+	levels := s.levels
+	levels[0] = append(outputs, s.levels[0]...)
+
+	if err = s.saveManifest(levels); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.levels = levels
+	s.imm = nil
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	if err = imm.log.Close(); err != nil {
+		return err
+	}
+
+	for _, dir := range imm.dirs {
+		if err = os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the level that most needs to be compacted into the next level, or
+// -1 if no level needs to be compacted.
+func (s *LSM) pickLevel() int {
+	if len(s.levels[0]) >= lsmLevel0Tables {
+		return 0
+	}
+
+	limit := int64(lsmLevel1Size)
+	for level := 1; level < lsmLevels-1; level++ {
+		var size int64
+		for _, table := range s.levels[level] {
+			size += table.size
+		}
+
+		if size > limit {
+			return level
+		}
+		limit *= lsmLevelRatio
+	}
+	return -1
+}
+
+// Merges tables from the level with the tables they overlap in the next level
+// and replaces them all with the merged tables in the next level. All of the
+// tables in level 0 are compacted at once since they may overlap each other;
+// from any other level the table with the smallest first key is compacted.
+func (s *LSM) compactLevel(level int) (err error) {
+	var inputs []*sstable
+	if level == 0 {
+		inputs = append(inputs, s.levels[0]...)
+	} else {
+		inputs = append(inputs, s.levels[level][0])
+	}
+
+	start, end := inputs[0].first, inputs[0].last
+	for _, table := range inputs[1:] {
+		if table.first < start {
+			start = table.first
+		}
+		if table.last > end {
+			end = table.last
+		}
+	}
+
+	var overlaps []*sstable
+	for _, table := range s.levels[level+1] {
+		if table.overlaps(start, end) {
+			overlaps = append(overlaps, table)
+		}
+	}
+
+	// Inputs are ordered newest first, level 0 is already newest first
+	iters := make([]entryIterator, 0, len(inputs)+len(overlaps))
+	for _, table := range append(inputs, overlaps...) {
+		iters = append(iters, table.iterator(""))
+	}
+
+	var outputs []*sstable
+	if outputs, err = s.writeTables(newMergeIterator(iters...), s.bottom(level+1), level+1); err != nil {
+		return err
+	}
+
+	levels := s.levels
+	levels[level] = without(s.levels[level], inputs)
+	levels[level+1] = append(without(s.levels[level+1], overlaps), outputs...)
+	sort.Slice(levels[level+1], func(i, j int) bool { return levels[level+1][i].first < levels[level+1][j].first })
+
+	if err = s.saveManifest(levels); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.levels = levels
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	// No reader can be using the replaced tables once the levels are swapped
+	for _, table := range append(inputs, overlaps...) {
+		if err = table.remove(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns true if there are no tables below the level, so that tombstones
+// written to the level no longer shadow any older values and can be dropped.
+func (s *LSM) bottom(level int) bool {
+	for _, tables := range s.levels[level+1:] {
+		if len(tables) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Writes the entries of the iterator to new tables of about the table size
+// (any size for level 0), dropping tombstones if requested.
+func (s *LSM) writeTables(iter entryIterator, dropTombstones bool, level int) (tables []*sstable, err error) {
+	var w *tableWriter
+
+	defer func() {
+		if err != nil {
+			if w != nil {
+				w.abort()
+			}
+			for _, table := range tables {
+				table.remove()
+			}
+			tables = nil
+		}
+	}()
+
+	for iter.next() {
+		entry := iter.current()
+		if entry.deleted && dropTombstones {
+			continue
+		}
+
+		if w == nil {
+			id := atomic.AddUint64(&s.next, 1) - 1
+			if w, err = newTableWriter(id, s.tablePath(id)); err != nil {
+				return tables, err
+			}
+		}
+
+		if err = w.add(entry); err != nil {
+			return tables, err
+		}
+
+		if level > 0 && w.size() >= s.opts.TableSize {
+			var table *sstable
+			table, err = w.finish()
+			w = nil
+			if err != nil {
+				return tables, err
+			}
+			tables = append(tables, table)
+		}
+	}
+
+	if err = iter.err(); err != nil {
+		return tables, err
+	}
+
+	if w != nil {
+		var table *sstable
+		table, err = w.finish()
+		w = nil
+		if err != nil {
+			return tables, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// Returns the tables that are not in the removed tables.
+func without(tables, removed []*sstable) []*sstable {
+	kept := make([]*sstable, 0, len(tables))
+	for _, table := range tables {
+		found := false
+		for _, rm := range removed {
+			if table == rm {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			kept = append(kept, table)
+		}
+	}
+	return kept
+}
+
+//===========================================================================
+// Manifest and recovery
+//===========================================================================
+
+// Writes the level and id of every table to a new manifest, which atomically
+// replaces the current manifest. Level 0 is written in order from newest to
+// oldest. Tables that are not in the manifest are removed on recovery.
+func (s *LSM) saveManifest(levels [lsmLevels][]*sstable) (err error) {
+	path := filepath.Join(s.dir, lsmManifest)
+	tmp := path + ".tmp"
+
+	var f *os.File
+	if f, err = os.Create(tmp); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for level, tables := range levels {
+		for _, table := range tables {
+			fmt.Fprintf(w, "%d %d\n", level, table.id)
+		}
+	}
+
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+// Opens the tables in the manifest, removes the files of any tables that are
+// not in it, then replays the logs of the memtables that were not flushed
+// into a new memtable.
+func (s *LSM) recover() (err error) {
+	var manifest map[uint64]int
+	if manifest, err = s.loadManifest(); err != nil {
+		return err
+	}
+
+	var names []string
+	if names, err = filepath.Glob(filepath.Join(s.dir, "*")); err != nil {
+		return err
+	}
+
+	var logs []uint64
+	for _, path := range names {
+		name := filepath.Base(path)
+		ext := filepath.Ext(name)
+
+		id, perr := strconv.ParseUint(strings.TrimSuffix(name, ext), 10, 64)
+		if perr != nil {
+			continue
+		}
+
+		if id >= s.next {
+			s.next = id + 1
+		}
+
+		switch ext {
+		case tableExt:
+			if _, ok := manifest[id]; !ok {
+				if err = os.Remove(path); err != nil {
+					return err
+				}
+			}
+		case lsmLogExt:
+			logs = append(logs, id)
+		}
+	}
+
+	// Open the tables from newest to oldest so level 0 is in the right order
+	ids := make([]uint64, 0, len(manifest))
+	for id := range manifest {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+
+	for _, id := range ids {
+		var table *sstable
+		if table, err = openTable(id, s.tablePath(id)); err != nil {
+			return err
+		}
+		level := manifest[id]
+		s.levels[level] = append(s.levels[level], table)
+	}
+
+	for _, tables := range s.levels[1:] {
+		sort.Slice(tables, func(i, j int) bool { return tables[i].first < tables[j].first })
+	}
+
+	// Replay the logs from oldest to newest into a new memtable, which keeps
+	// the logs until it is flushed
+	if s.mem, err = s.newMemtable(); err != nil {
+		return err
+	}
+
+	sort.Slice(logs, func(i, j int) bool { return logs[i] < logs[j] })
+	for _, id := range logs {
+		dir := s.logPath(id)
+
+		var log *wal.Log
+		if log, err = wal.Open(dir, s.opts.Sync); err != nil {
+			return err
+		}
+
+		err = log.Replay(func(op wal.Op, key string, value []byte) error {
+			s.mem.put(key, value, op == wal.OpDelete)
+			return nil
+		})
+
+		if cerr := log.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		s.mem.dirs = append(s.mem.dirs, dir)
+	}
+	return nil
+}
+
+// Reads the manifest, returning the level of each table by its id.
+func (s *LSM) loadManifest() (tables map[uint64]int, err error) {
+	tables = make(map[uint64]int)
+
+	var f *os.File
+	if f, err = os.Open(filepath.Join(s.dir, lsmManifest)); err != nil {
+		if os.IsNotExist(err) {
+			return tables, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var (
+			level int
+			id    uint64
+		)
+
+		if _, err = fmt.Sscanf(scanner.Text(), "%d %d", &level, &id); err != nil || level < 0 || level >= lsmLevels {
+			return nil, fmt.Errorf("%w: invalid manifest entry %q", speedmap.ErrCorrupt, scanner.Text())
+		}
+		tables[id] = level
+	}
+	return tables, scanner.Err()
+}
+
+// Closes all of the tables in the levels.
+func (s *LSM) closeTables() (err error) {
+	for _, tables := range s.levels {
+		for _, table := range tables {
+			if cerr := table.close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	return err
+}
+
+// Returns the path of the table with the specified id.
+func (s *LSM) tablePath(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", id, tableExt))
+}
+
+// Returns the path of the directory of the log with the specified id.
+func (s *LSM) logPath(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", id, lsmLogExt))
+}
+
+// Syncs the directory so that files created or renamed in it are durable.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
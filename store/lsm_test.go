@@ -0,0 +1,196 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/store"
+	"github.com/bbengfort/speedmap/wal"
+)
+
+var _ = Describe("LSM", func() {
+
+	var (
+		dir   string
+		store *LSM
+	)
+
+	// Opens the store in the directory with small memtables and tables so
+	// that writes are flushed and compacted.
+	open := func() *LSM {
+		lsm, err := NewLSM(dir, LSMOptions{MemtableSize: 8192, TableSize: 4096, Sync: wal.Options{Sync: wal.SyncNever}})
+		Ω(err).ShouldNot(HaveOccurred())
+		return lsm
+	}
+
+	// Returns the number of tables in all of the levels.
+	tables := func() (count int) {
+		for _, n := range store.Levels() {
+			count += n
+		}
+		return count
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "speedmap-lsm")
+		Ω(err).ShouldNot(HaveOccurred())
+		store = open()
+	})
+
+	AfterEach(func() {
+		Ω(store.Close()).Should(Succeed())
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	It("should be a scanner", func() {
+		Ω(store).Should(BeAssignableToTypeOf(&LSM{}))
+		Ω(store.String()).Should(Equal("lsm wal never"))
+
+		var scanner speedmap.Scanner = store
+		Ω(scanner).ShouldNot(BeNil())
+	})
+
+	It("should be able to perform store operations", func() {
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+
+		val, err := store.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		Ω(store.Delete("foo")).Should(Succeed())
+
+		val, err = store.Get("foo")
+		Ω(err).Should(HaveOccurred())
+		Ω(val).Should(BeNil())
+	})
+
+	It("should return standard errors", func() {
+		_, err := store.Get("foo")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+
+		key := strings.Repeat("a", speedmap.MaxKeySize+1)
+		err = store.Put(key, []byte("bar"))
+		Ω(errors.Is(err, speedmap.ErrKeyTooLarge)).Should(BeTrue())
+
+		Ω(store.Close()).Should(Succeed())
+		err = store.Put("foo", []byte("bar"))
+		Ω(errors.Is(err, speedmap.ErrClosed)).Should(BeTrue())
+
+		_, err = store.Get("foo")
+		Ω(errors.Is(err, speedmap.ErrClosed)).Should(BeTrue())
+	})
+
+	It("should be able to get or create a value", func() {
+		actual, created := store.GetOrCreate("foo", []byte("bar"))
+		Ω(actual).Should(Equal([]byte("bar")))
+		Ω(created).Should(BeTrue())
+
+		actual, created = store.GetOrCreate("foo", []byte("red"))
+		Ω(actual).Should(Equal([]byte("bar")))
+		Ω(created).Should(BeFalse())
+	})
+
+	It("should flush and compact writes into tables", func() {
+		for i := 0; i < 4000; i++ {
+			key := fmt.Sprintf("%05d", i%1000)
+			Ω(store.Put(key, []byte(fmt.Sprintf("%s-%d", key, i)))).Should(Succeed())
+		}
+
+		for i := 0; i < 1000; i += 10 {
+			Ω(store.Delete(fmt.Sprintf("%05d", i))).Should(Succeed())
+		}
+
+		Eventually(tables, 5*time.Second).ShouldNot(BeZero())
+		Eventually(func() int { return store.Levels()[0] }, 5*time.Second).Should(BeNumerically("<", 4))
+		Ω(store.Levels()[1]).ShouldNot(BeZero())
+
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("%05d", i)
+			val, err := store.Get(key)
+			if i%10 == 0 {
+				Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue(), key)
+				continue
+			}
+
+			Ω(err).ShouldNot(HaveOccurred(), key)
+			Ω(string(val)).Should(Equal(fmt.Sprintf("%s-%d", key, i+3000)))
+		}
+	})
+
+	It("should scan its keys in order", func() {
+		for i := 99; i >= 0; i-- {
+			key := fmt.Sprintf("%02d", i)
+			Ω(store.Put(key, []byte(key))).Should(Succeed())
+		}
+		Ω(store.Delete("15")).Should(Succeed())
+
+		var keys []string
+		err := speedmap.Scan(store, "10", "20", func(key string, value []byte) bool {
+			Ω(string(value)).Should(Equal(key))
+			keys = append(keys, key)
+			return true
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(keys).Should(Equal([]string{"10", "11", "12", "13", "14", "16", "17", "18", "19"}))
+
+		keys = nil
+		err = store.Range(func(key string, value []byte) bool {
+			keys = append(keys, key)
+			return len(keys) < 3
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(keys).Should(Equal([]string{"00", "01", "02"}))
+	})
+
+	It("should scan keys that have been flushed to tables", func() {
+		value := []byte(strings.Repeat("x", 100))
+		for i := 0; i < 2000; i++ {
+			Ω(store.Put(fmt.Sprintf("%05d", (i*7919)%2000), value)).Should(Succeed())
+		}
+		Eventually(tables, 5*time.Second).ShouldNot(BeZero())
+
+		prev, count := "", 0
+		err := store.Scan("00500", "", func(key string, _ []byte) bool {
+			Ω(key > prev).Should(BeTrue())
+			prev = key
+			count++
+			return true
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(count).Should(Equal(1500))
+	})
+
+	It("should recover its contents after a restart", func() {
+		for i := 0; i < 2000; i++ {
+			key := fmt.Sprintf("%05d", i)
+			Ω(store.Put(key, []byte(key))).Should(Succeed())
+		}
+		Ω(store.Delete("00042")).Should(Succeed())
+		Eventually(tables, 5*time.Second).ShouldNot(BeZero())
+
+		Ω(store.Close()).Should(Succeed())
+		store = open()
+
+		for i := 0; i < 2000; i++ {
+			key := fmt.Sprintf("%05d", i)
+			val, err := store.Get(key)
+			if i == 42 {
+				Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+				continue
+			}
+
+			Ω(err).ShouldNot(HaveOccurred(), key)
+			Ω(val).Should(Equal([]byte(key)))
+		}
+	})
+
+})
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/bbengfort/speedmap/wal"
+)
+
+// The maximum height of the memtable skip list, enough for millions of keys.
+const maxSkipHeight = 16
+
+// An entry in the LSM store: a key and its value, or a tombstone recording
+// that the key was deleted so that it shadows older values of the key.
+type lsmEntry struct {
+	key     string
+	value   []byte
+	deleted bool
+}
+
+// A memtable holds the most recent writes to the LSM store in a skip list
+// ordered by key, along with the write-ahead log that makes them durable.
+// Once the memtable is full it becomes immutable and is flushed to a table,
+// after which the directories of the logs holding its writes are removed.
+type memtable struct {
+	sync.RWMutex
+	head   *skipNode
+	height int
+	count  int
+	size   int64
+	seed   uint64
+	log    *wal.Log // the log the writes to the memtable are appended to
+	dirs   []string // the directories of the logs holding the writes
+}
+
+// A node in the skip list with a pointer to the next node at each level.
+type skipNode struct {
+	lsmEntry
+	next []*skipNode
+}
+
+// Creates an empty memtable that appends writes to the log in the directory.
+func newMemtable(log *wal.Log, dir string) *memtable {
+	return &memtable{
+		head:   &skipNode{next: make([]*skipNode, maxSkipHeight)},
+		height: 1,
+		seed:   0x9E3779B97F4A7C15,
+		log:    log,
+		dirs:   []string{dir},
+	}
+}
+
+// Puts the value (or a tombstone) into the memtable and returns the number of
+// bytes of keys and values it holds.
+func (m *memtable) put(key string, value []byte, deleted bool) int64 {
+	m.Lock()
+	defer m.Unlock()
+
+	var prev [maxSkipHeight]*skipNode
+	node := m.head
+	for level := m.height - 1; level >= 0; level-- {
+		for node.next[level] != nil && node.next[level].key < key {
+			node = node.next[level]
+		}
+		prev[level] = node
+	}
+
+	if next := node.next[0]; next != nil && next.key == key {
+		m.size += int64(len(value) - len(next.value))
+		next.value, next.deleted = value, deleted
+		return m.size
+	}
+
+	height := m.randomHeight()
+	for ; m.height < height; m.height++ {
+		prev[m.height] = m.head
+	}
+
+	node = &skipNode{lsmEntry: lsmEntry{key: key, value: value, deleted: deleted}, next: make([]*skipNode, height)}
+	for level := 0; level < height; level++ {
+		node.next[level] = prev[level].next[level]
+		prev[level].next[level] = node
+	}
+
+	m.count++
+	m.size += entrySize(key, value)
+	return m.size
+}
+
+// Returns the entry for the key, which may be a tombstone, and true if the
+// memtable holds the key.
+func (m *memtable) get(key string) (entry lsmEntry, ok bool) {
+	m.RLock()
+	defer m.RUnlock()
+
+	if node := m.seek(key); node != nil && node.key == key {
+		return node.lsmEntry, true
+	}
+	return entry, false
+}
+
+// Returns a copy of the entries with start <= key < end in key order; an
+// empty end includes all the keys after start.
+func (m *memtable) entries(start, end string) []lsmEntry {
+	m.RLock()
+	defer m.RUnlock()
+
+	entries := make([]lsmEntry, 0, m.count)
+	for node := m.seek(start); node != nil; node = node.next[0] {
+		if end != "" && node.key >= end {
+			break
+		}
+		entries = append(entries, node.lsmEntry)
+	}
+	return entries
+}
+
+// Returns the first node whose key is greater than or equal to the key. Must
+// be called while holding the lock.
+func (m *memtable) seek(key string) *skipNode {
+	node := m.head
+	for level := m.height - 1; level >= 0; level-- {
+		for node.next[level] != nil && node.next[level].key < key {
+			node = node.next[level]
+		}
+	}
+	return node.next[0]
+}
+
+// Returns a random height for a new node, each level with probability 1/4.
+func (m *memtable) randomHeight() int {
+	// xorshift64 is sufficient and avoids the lock of the global rand source
+	m.seed ^= m.seed << 13
+	m.seed ^= m.seed >> 7
+	m.seed ^= m.seed << 17
+
+	height, bits := 1, m.seed
+	for height < maxSkipHeight && bits&3 == 0 {
+		height++
+		bits >>= 2
+	}
+	return height
+}
+
+// An iterator over a slice of entries in key order.
+type sliceIterator struct {
+	entries []lsmEntry
+	pos     int
+}
+
+func (it *sliceIterator) next() bool {
+	it.pos++
+	return it.pos < len(it.entries)
+}
+
+func (it *sliceIterator) current() lsmEntry {
+	return it.entries[it.pos]
+}
+
+func (it *sliceIterator) err() error {
+	return nil
+}
+
+// Creates an iterator over the entries, which must be in key order.
+func newSliceIterator(entries []lsmEntry) *sliceIterator {
+	return &sliceIterator{entries: entries, pos: -1}
+}
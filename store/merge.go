@@ -0,0 +1,98 @@
+package store
+
+import "container/heap"
+
+// An iterator over entries in key order. next advances the iterator to the
+// next entry, returning false when the entries are exhausted or on error.
+type entryIterator interface {
+	next() bool
+	current() lsmEntry
+	err() error
+}
+
+// Merges iterators that are ordered from the newest entries to the oldest
+// into a single iterator in key order. If a key is in more than one of the
+// iterators, only the newest entry for the key is returned.
+type mergeIterator struct {
+	sources mergeHeap
+	entry   lsmEntry
+	fail    error
+}
+
+// A source of the merge and its age, lower is newer.
+type mergeSource struct {
+	iter entryIterator
+	age  int
+}
+
+// Creates a merge of the iterators, ordered from newest to oldest.
+func newMergeIterator(iters ...entryIterator) *mergeIterator {
+	m := &mergeIterator{sources: make(mergeHeap, 0, len(iters))}
+	for age, iter := range iters {
+		if iter.next() {
+			m.sources = append(m.sources, mergeSource{iter: iter, age: age})
+		} else if err := iter.err(); err != nil && m.fail == nil {
+			m.fail = err
+		}
+	}
+
+	heap.Init(&m.sources)
+	return m
+}
+
+func (m *mergeIterator) next() bool {
+	if m.fail != nil || len(m.sources) == 0 {
+		return false
+	}
+
+	m.entry = m.sources[0].iter.current()
+
+	// Advance every source past the key, discarding the older entries
+	for len(m.sources) > 0 && m.sources[0].iter.current().key == m.entry.key {
+		if m.sources[0].iter.next() {
+			heap.Fix(&m.sources, 0)
+			continue
+		}
+
+		if err := m.sources[0].iter.err(); err != nil {
+			m.fail = err
+			return false
+		}
+		heap.Pop(&m.sources)
+	}
+	return true
+}
+
+func (m *mergeIterator) current() lsmEntry {
+	return m.entry
+}
+
+func (m *mergeIterator) err() error {
+	return m.fail
+}
+
+// A min heap of the merge sources by their current key, then by their age.
+type mergeHeap []mergeSource
+
+func (h mergeHeap) Len() int      { return len(h) }
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h mergeHeap) Less(i, j int) bool {
+	ki, kj := h[i].iter.current().key, h[j].iter.current().key
+	if ki == kj {
+		return h[i].age < h[j].age
+	}
+	return ki < kj
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	*h = append(*h, x.(mergeSource))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
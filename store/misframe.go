@@ -1,8 +1,9 @@
 package store
 
 import (
-	"fmt"
 	"sync"
+
+	"github.com/bbengfort/speedmap"
 )
 
 // Misframe extends the Basic synchronized map structure with an optimized
@@ -29,13 +30,18 @@ func (s *Misframe) Get(key string) (value []byte, err error) {
 
 	val, ok := s.data[key]
 	if !ok {
-		return nil, fmt.Errorf("no value found for key '%s'", key)
+		return nil, notFound(key)
 	}
 	return val, nil
 }
 
-// Put a value by locking the internal map and storing it. No error returned.
+// Put a value by locking the internal map and storing it. Returns an error
+// only if the key exceeds the maximum key size.
 func (s *Misframe) Put(key string, value []byte) (err error) {
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
 	s.Lock()
 	defer s.Unlock()
 
@@ -54,7 +60,12 @@ func (s *Misframe) Delete(key string) (err error) {
 }
 
 // GetOrCreate returns the value stored or stores the supplied default value.
+// A key that is too large is not stored, and created is false.
 func (s *Misframe) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	if len(key) > speedmap.MaxKeySize {
+		return value, false
+	}
+
 	var present bool
 
 	s.RLock()
@@ -74,6 +85,52 @@ func (s *Misframe) GetOrCreate(key string, value []byte) (actual []byte, created
 	return actual, false
 }
 
+// MultiGet fetches the values of all keys while holding a single read lock.
+// The value of a key that is not in the map is nil.
+func (s *Misframe) MultiGet(keys []string) (values [][]byte, err error) {
+	values = make([][]byte, len(keys))
+
+	s.RLock()
+	defer s.RUnlock()
+
+	for i, key := range keys {
+		values[i] = s.data[key]
+	}
+	return values, nil
+}
+
+// MultiPut stores all of the pairs while holding a single write lock. If any
+// key exceeds the maximum key size then none of the pairs are stored.
+func (s *Misframe) MultiPut(pairs map[string][]byte) (err error) {
+	for key := range pairs {
+		if len(key) > speedmap.MaxKeySize {
+			return keyTooLarge(key)
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for key, value := range pairs {
+		s.data[key] = value
+	}
+	return nil
+}
+
+// Range calls fn for every key/value pair while read locking the internal
+// map, stopping if fn returns false.
+func (s *Misframe) Range(fn func(key string, value []byte) bool) error {
+	s.RLock()
+	defer s.RUnlock()
+
+	for key, val := range s.data {
+		if !fn(key, val) {
+			break
+		}
+	}
+	return nil
+}
+
 // String returns a string representation of the Store
 func (s *Misframe) String() string {
 	return "misframe"
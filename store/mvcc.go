@@ -0,0 +1,300 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// DefaultRetention is the number of versions (writes to the store) that the
+// MVCC store retains old values for before they are garbage collected.
+const DefaultRetention = 4096
+
+// MVCC is a multi-version key/value store synchronized with a RWMutex. Every
+// write to the store creates a new version of the key identified by the next
+// value of a monotonically increasing sequence number, and deletes create
+// tombstone versions. Older versions remain readable with GetAt or from a
+// Snapshot until they fall behind the retention horizon (the current
+// sequence minus the retention, or the oldest unreleased snapshot if that is
+// older). Versions older than the horizon are garbage collected lazily when
+// the key is written, or for all keys when Compact is called.
+type MVCC struct {
+	sync.RWMutex
+	data      map[string][]*version // versions of each key in ascending order
+	seq       uint64                // the version of the most recent write
+	retention uint64                // number of versions to retain
+	compacted uint64                // versions before this may have been collected
+	pins      map[uint64]int        // versions pinned by unreleased snapshots
+}
+
+// NewMVCC creates an MVCC store with the default retention and initializes
+// the internal map.
+func NewMVCC() (store *MVCC, err error) {
+	store = new(MVCC)
+	store.data = make(map[string][]*version)
+	store.pins = make(map[uint64]int)
+	store.retention = DefaultRetention
+	return store, nil
+}
+
+// SetRetention sets the number of versions to retain old values for.
+func (s *MVCC) SetRetention(retention uint64) {
+	s.Lock()
+	defer s.Unlock()
+	s.retention = retention
+}
+
+// Get the latest value of the key by read locking the internal map. If the
+// key is not in the map or its latest version is a delete, returns an error.
+func (s *MVCC) Get(key string) (value []byte, err error) {
+	value, _, err = s.GetVersion(key)
+	return value, err
+}
+
+// GetVersion returns the latest value of the key along with its version.
+func (s *MVCC) GetVersion(key string) (value []byte, vers uint64, err error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	versions, ok := s.data[key]
+	if !ok {
+		return nil, 0, notFound(key)
+	}
+
+	latest := versions[len(versions)-1]
+	if latest.deleted {
+		return nil, latest.version, notFound(key)
+	}
+	return latest.value, latest.version, nil
+}
+
+// GetAt returns the value of the key as of the specified version, e.g. the
+// value of the most recent write to the key at or before the version. If the
+// version has fallen behind the retention horizon, an error wrapping
+// speedmap.ErrCompacted is returned since older values may have been
+// garbage collected.
+func (s *MVCC) GetAt(key string, vers uint64) (value []byte, err error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.getAt(key, vers)
+}
+
+// Must be called while holding at least the read lock.
+func (s *MVCC) getAt(key string, vers uint64) (value []byte, err error) {
+	if vers < s.compacted {
+		return nil, fmt.Errorf("%w: version %d is older than %d", speedmap.ErrCompacted, vers, s.compacted)
+	}
+
+	versions := s.data[key]
+	idx := sort.Search(len(versions), func(i int) bool { return versions[i].version > vers }) - 1
+	if idx < 0 || versions[idx].deleted {
+		return nil, notFound(key)
+	}
+	return versions[idx].value, nil
+}
+
+// Put a value by locking the internal map and appending a new version of the
+// key, garbage collecting versions of the key past the retention horizon.
+// Returns an error only if the key exceeds the maximum key size.
+func (s *MVCC) Put(key string, value []byte) (err error) {
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.append(key, &version{value: value})
+	return nil
+}
+
+// Delete a key by locking the internal map and appending a tombstone version.
+// No error returned even if the key isn't in the map to begin with. Deleting a
+// key that is not in the map or is already deleted does not add a version.
+func (s *MVCC) Delete(key string) (err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if versions, ok := s.data[key]; !ok || versions[len(versions)-1].deleted {
+		return nil
+	}
+
+	s.append(key, &version{deleted: true})
+	return nil
+}
+
+// GetOrCreate returns the latest value stored or stores the supplied default
+// value as a new version of the key. A key that is too large is not stored,
+// and created is false.
+func (s *MVCC) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	if len(key) > speedmap.MaxKeySize {
+		return value, false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if versions, ok := s.data[key]; ok {
+		if latest := versions[len(versions)-1]; !latest.deleted {
+			return latest.value, false
+		}
+	}
+
+	s.append(key, &version{value: value})
+	return value, true
+}
+
+// CompareAndSwap appends the value as a new version of the key only if the
+// latest version of the key is vers. Returns an error if the key is not in
+// the map or its latest version is a delete.
+func (s *MVCC) CompareAndSwap(key string, vers uint64, value []byte) (swapped bool, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	versions, ok := s.data[key]
+	if !ok || versions[len(versions)-1].deleted {
+		return false, notFound(key)
+	}
+
+	if versions[len(versions)-1].version != vers {
+		return false, nil
+	}
+
+	s.append(key, &version{value: value})
+	return true, nil
+}
+
+// Snapshot returns a consistent, read-only view of the store as of the most
+// recent write. The versions visible to the snapshot are not garbage
+// collected until the snapshot is released.
+func (s *MVCC) Snapshot() *Snapshot {
+	s.Lock()
+	defer s.Unlock()
+
+	s.pins[s.seq]++
+	return &Snapshot{store: s, version: s.seq}
+}
+
+// Compact garbage collects the versions of every key past the retention
+// horizon, removing keys entirely if their only remaining version is a delete.
+func (s *MVCC) Compact() {
+	s.Lock()
+	defer s.Unlock()
+
+	horizon := s.horizon()
+	for key := range s.data {
+		s.collect(key, horizon)
+	}
+}
+
+// Range calls fn with the latest value of every key that has not been deleted
+// while read locking the store, stopping if fn returns false.
+func (s *MVCC) Range(fn func(key string, value []byte) bool) error {
+	s.RLock()
+	defer s.RUnlock()
+
+	for key, versions := range s.data {
+		if latest := versions[len(versions)-1]; !latest.deleted && !fn(key, latest.value) {
+			break
+		}
+	}
+	return nil
+}
+
+// String returns a string representation of the Store
+func (s *MVCC) String() string {
+	return "mvcc"
+}
+
+// Assigns the next sequence number to the version and appends it to the key's
+// versions, then garbage collects the key. Must be called with the lock held.
+func (s *MVCC) append(key string, val *version) {
+	s.seq++
+	val.version = s.seq
+	s.data[key] = append(s.data[key], val)
+	s.collect(key, s.horizon())
+}
+
+// Computes the oldest version that must remain readable, which is the older
+// of the retention horizon and the oldest pinned snapshot. Must be called with
+// the lock held.
+func (s *MVCC) horizon() uint64 {
+	var horizon uint64
+	if s.seq > s.retention {
+		horizon = s.seq - s.retention
+	}
+
+	for pin := range s.pins {
+		if pin < horizon {
+			horizon = pin
+		}
+	}
+	return horizon
+}
+
+// Removes all versions of the key that are not visible at or after the
+// horizon, e.g. all versions older than the latest version at the horizon.
+// Must be called with the lock held.
+func (s *MVCC) collect(key string, horizon uint64) {
+	versions := s.data[key]
+	idx := sort.Search(len(versions), func(i int) bool { return versions[i].version > horizon }) - 1
+	if idx < 0 {
+		return
+	}
+
+	if horizon > s.compacted {
+		s.compacted = horizon
+	}
+
+	if idx == len(versions)-1 && versions[idx].deleted {
+		delete(s.data, key)
+		return
+	}
+
+	if idx > 0 {
+		// Copy the retained versions so that the old ones can be freed.
+		s.data[key] = append([]*version(nil), versions[idx:]...)
+	}
+}
+
+// Snapshot is a read-only view of an MVCC store as of a specific version.
+// Snapshots should be released when they are no longer needed so that the
+// versions they pin can be garbage collected.
+type Snapshot struct {
+	store    *MVCC
+	version  uint64
+	released bool
+}
+
+// Version returns the version of the store that the snapshot reads.
+func (s *Snapshot) Version() uint64 {
+	return s.version
+}
+
+// Get the value of the key as of the snapshot version.
+func (s *Snapshot) Get(key string) (value []byte, err error) {
+	s.store.RLock()
+	defer s.store.RUnlock()
+
+	if s.released {
+		return nil, fmt.Errorf("%w: snapshot at version %d released", speedmap.ErrClosed, s.version)
+	}
+	return s.store.getAt(key, s.version)
+}
+
+// Release the snapshot so that the versions it pins can be garbage collected.
+func (s *Snapshot) Release() {
+	s.store.Lock()
+	defer s.store.Unlock()
+
+	if s.released {
+		return
+	}
+
+	s.released = true
+	if s.store.pins[s.version]--; s.store.pins[s.version] <= 0 {
+		delete(s.store.pins, s.version)
+	}
+}
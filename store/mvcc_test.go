@@ -0,0 +1,223 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/store"
+)
+
+var _ = Describe("MVCC", func() {
+
+	var (
+		err   error
+		store speedmap.Store
+	)
+
+	BeforeEach(func() {
+		store, err = NewMVCC()
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should be a store", func() {
+		Ω(&MVCC{}).Should(BeAssignableToTypeOf(store))
+	})
+
+	It("should be able to perform store operations", func() {
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+
+		val, err := store.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		Ω(store.Delete("foo")).Should(Succeed())
+
+		val, err = store.Get("foo")
+		Ω(err).Should(HaveOccurred())
+		Ω(val).Should(BeNil())
+	})
+
+	for _, conformance := range Conformance {
+		check := conformance.Check
+		It("should "+conformance.Behavior, func() {
+			Ω(check(store)).Should(Succeed())
+		})
+	}
+
+	It("should be able to get or create a value", func() {
+		actual, created := store.GetOrCreate("foo", []byte("bar"))
+		Ω(actual).Should(Equal([]byte("bar")))
+		Ω(created).Should(BeTrue())
+
+		actual, created = store.GetOrCreate("foo", []byte("red"))
+		Ω(actual).Should(Equal([]byte("bar")))
+		Ω(created).Should(BeFalse())
+	})
+
+	It("should compare and swap values by version", func() {
+		swapper := store.(speedmap.Swapper)
+		_, err := swapper.CompareAndSwap("foo", 0, []byte("bar"))
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+		_, vers, err := store.(speedmap.Versioner).GetVersion("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		swapped, err := swapper.CompareAndSwap("foo", vers, []byte("baz"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(swapped).Should(BeTrue())
+
+		swapped, err = swapper.CompareAndSwap("foo", vers, []byte("red"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(swapped).Should(BeFalse())
+		Ω(store.Get("foo")).Should(Equal([]byte("baz")))
+
+		Ω(store.Delete("foo")).Should(Succeed())
+		_, err = swapper.CompareAndSwap("foo", vers, []byte("red"))
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+	})
+
+	It("should read values at previous versions", func() {
+		mvcc := store.(*MVCC)
+		Ω(store.Put("foo", []byte("a"))).Should(Succeed())
+		_, v1, err := mvcc.GetVersion("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(store.Put("foo", []byte("b"))).Should(Succeed())
+		Ω(store.Delete("foo")).Should(Succeed())
+		_, v3, err := mvcc.GetVersion("foo")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+
+		_, err = mvcc.GetAt("foo", v1-1)
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+
+		val, err := mvcc.GetAt("foo", v1)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("a")))
+
+		val, err = mvcc.GetAt("foo", v1+1)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("b")))
+
+		_, err = mvcc.GetAt("foo", v3)
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+	})
+
+	It("should not add versions when deleting a deleted key", func() {
+		mvcc := store.(*MVCC)
+		version := func() uint64 {
+			snap := mvcc.Snapshot()
+			defer snap.Release()
+			return snap.Version()
+		}
+
+		Ω(store.Delete("foo")).Should(Succeed())
+		Ω(version()).Should(BeZero())
+
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+		Ω(store.Delete("foo")).Should(Succeed())
+		seq := version()
+
+		Ω(store.Delete("foo")).Should(Succeed())
+		Ω(store.Delete("foo")).Should(Succeed())
+		Ω(version()).Should(Equal(seq))
+	})
+
+	It("should provide consistent snapshots", func() {
+		mvcc := store.(*MVCC)
+		Ω(store.Put("foo", []byte("a"))).Should(Succeed())
+		Ω(store.Put("bar", []byte("a"))).Should(Succeed())
+
+		snap := mvcc.Snapshot()
+		Ω(store.Put("foo", []byte("b"))).Should(Succeed())
+		Ω(store.Delete("bar")).Should(Succeed())
+		Ω(store.Put("baz", []byte("b"))).Should(Succeed())
+
+		val, err := snap.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("a")))
+
+		val, err = snap.Get("bar")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("a")))
+
+		_, err = snap.Get("baz")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+
+		snap.Release()
+		_, err = snap.Get("foo")
+		Ω(errors.Is(err, speedmap.ErrClosed)).Should(BeTrue())
+	})
+
+	It("should garbage collect versions past the retention horizon", func() {
+		mvcc := store.(*MVCC)
+		mvcc.SetRetention(2)
+
+		Ω(store.Put("foo", []byte("a"))).Should(Succeed())
+		_, v1, _ := mvcc.GetVersion("foo")
+		snap := mvcc.Snapshot()
+
+		for i := 0; i < 10; i++ {
+			Ω(store.Put("foo", []byte("b"))).Should(Succeed())
+		}
+
+		// The snapshot pins the first version
+		val, err := snap.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("a")))
+
+		snap.Release()
+		Ω(store.Delete("foo")).Should(Succeed())
+		for i := 0; i < 10; i++ {
+			Ω(store.Put("bar", []byte("b"))).Should(Succeed())
+		}
+		mvcc.Compact()
+
+		_, err = mvcc.GetAt("foo", v1)
+		Ω(errors.Is(err, speedmap.ErrCompacted)).Should(BeTrue())
+
+		_, _, err = mvcc.GetVersion("foo")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+	})
+
+	Measure("get throughput", func(b Benchmarker) {
+		// Populate the store
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("%X", i)
+			store.Put(key, []byte(key))
+		}
+
+		results, err := Blast(store, 5000, "Get")
+		Ω(err).ShouldNot(HaveOccurred())
+		b.RecordValue("throughput", results.Throughput)
+	}, 10)
+
+	Measure("put throughput", func(b Benchmarker) {
+		results, err := Blast(store, 5000, "Put")
+		Ω(err).ShouldNot(HaveOccurred())
+		b.RecordValue("throughput", results.Throughput)
+	}, 10)
+
+	Measure("delete throughput", func(b Benchmarker) {
+		// Populate the store
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("%X", i)
+			store.Put(key, []byte(key))
+		}
+
+		results, err := Blast(store, 5000, "Delete")
+		Ω(err).ShouldNot(HaveOccurred())
+		b.RecordValue("throughput", results.Throughput)
+	}, 10)
+
+	Measure("get or create throughput", func(b Benchmarker) {
+		results, err := Blast(store, 5000, "GetOrCreate")
+		Ω(err).ShouldNot(HaveOccurred())
+		b.RecordValue("throughput", results.Throughput)
+	}, 10)
+
+})
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+)
+
+// Policy decides which key to evict from a shard of a Cache when it is full.
+// A policy tracks the keys that are resident in the shard: Add is called when
+// a key is inserted, Hit when a resident key is read or updated, and Remove
+// when a key is deleted. Victim is called when the shard must make room for
+// the incoming key (which may be resident if its value grew), and must return
+// a resident key other than the incoming key and stop tracking it, or an empty
+// string if there is no such key. Policies are not thread-safe; the
+// Cache calls them while holding the lock of the shard.
+type Policy interface {
+	Add(key string)
+	Hit(key string)
+	Remove(key string)
+	Victim(incoming string) (key string)
+	String() string
+}
+
+// PolicyFactory creates a policy for a shard whose capacity (in entries) is
+// expected to be approximately the specified capacity.
+type PolicyFactory func(capacity int) Policy
+
+// Policies are the eviction policy factories available to a Cache by name.
+var Policies = map[string]PolicyFactory{
+	"lru":     NewLRU,
+	"clock":   NewClock,
+	"tinylfu": NewTinyLFU,
+	"arc":     NewARC,
+}
+
+// GetPolicy returns the named eviction policy factory.
+func GetPolicy(name string) (PolicyFactory, error) {
+	if factory, ok := Policies[name]; ok {
+		return factory, nil
+	}
+
+	names := make([]string, 0, len(Policies))
+	for name := range Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown eviction policy '%s', use one of %v", name, names)
+}
+
+//===========================================================================
+// Least Recently Used
+//===========================================================================
+
+// LRU evicts the least recently used key, maintaining the recency order of
+// keys in a doubly linked list.
+type LRU struct {
+	order *list.List
+	index map[string]*list.Element
+}
+
+// NewLRU creates a least recently used eviction policy.
+func NewLRU(capacity int) Policy {
+	return &LRU{order: list.New(), index: make(map[string]*list.Element, capacity)}
+}
+
+// Add the key as the most recently used key.
+func (p *LRU) Add(key string) {
+	p.index[key] = p.order.PushFront(key)
+}
+
+// Hit marks the key as the most recently used key.
+func (p *LRU) Hit(key string) {
+	if elem, ok := p.index[key]; ok {
+		p.order.MoveToFront(elem)
+	}
+}
+
+// Remove the key from the recency order.
+func (p *LRU) Remove(key string) {
+	if elem, ok := p.index[key]; ok {
+		p.order.Remove(elem)
+		delete(p.index, key)
+	}
+}
+
+// Victim returns the least recently used key other than the incoming key.
+func (p *LRU) Victim(incoming string) string {
+	elem := p.order.Back()
+	if elem != nil && elem.Value.(string) == incoming {
+		elem = elem.Prev()
+	}
+
+	if elem == nil {
+		return ""
+	}
+
+	key := p.order.Remove(elem).(string)
+	delete(p.index, key)
+	return key
+}
+
+// String returns the name of the policy.
+func (p *LRU) String() string {
+	return "lru"
+}
+
+//===========================================================================
+// CLOCK
+//===========================================================================
+
+// Clock approximates LRU with the CLOCK algorithm: keys are kept in a circular
+// buffer with a reference bit that is set on every hit. To find a victim, a
+// hand sweeps the buffer clearing reference bits until it finds a key whose
+// bit is already clear. Hits only set a bit, so they are cheaper than LRU.
+type Clock struct {
+	slots []clockSlot
+	index map[string]int
+	free  []int
+	hand  int
+}
+
+// A slot in the clock buffer.
+type clockSlot struct {
+	key  string
+	ref  bool
+	used bool
+}
+
+// NewClock creates a CLOCK eviction policy.
+func NewClock(capacity int) Policy {
+	return &Clock{
+		slots: make([]clockSlot, 0, capacity),
+		index: make(map[string]int, capacity),
+	}
+}
+
+// Add the key to a free slot in the buffer.
+func (p *Clock) Add(key string) {
+	slot := clockSlot{key: key, used: true}
+	if n := len(p.free); n > 0 {
+		idx := p.free[n-1]
+		p.free = p.free[:n-1]
+		p.slots[idx] = slot
+		p.index[key] = idx
+		return
+	}
+
+	p.slots = append(p.slots, slot)
+	p.index[key] = len(p.slots) - 1
+}
+
+// Hit sets the reference bit of the key.
+func (p *Clock) Hit(key string) {
+	if idx, ok := p.index[key]; ok {
+		p.slots[idx].ref = true
+	}
+}
+
+// Remove the key from its slot in the buffer.
+func (p *Clock) Remove(key string) {
+	if idx, ok := p.index[key]; ok {
+		p.release(idx)
+	}
+}
+
+// Victim sweeps the hand around the buffer, returning the first key without
+// a reference bit and clearing the bits of the keys it passes. The incoming
+// key is passed over without clearing its bit.
+func (p *Clock) Victim(incoming string) string {
+	if _, ok := p.index[incoming]; len(p.index) == 0 || (ok && len(p.index) == 1) {
+		return ""
+	}
+
+	for {
+		if p.hand >= len(p.slots) {
+			p.hand = 0
+		}
+
+		slot := &p.slots[p.hand]
+		idx := p.hand
+		p.hand++
+
+		if !slot.used || slot.key == incoming {
+			continue
+		}
+
+		if slot.ref {
+			slot.ref = false
+			continue
+		}
+
+		key := slot.key
+		p.release(idx)
+		return key
+	}
+}
+
+// String returns the name of the policy.
+func (p *Clock) String() string {
+	return "clock"
+}
+
+// Marks the slot as free so that it can be reused.
+func (p *Clock) release(idx int) {
+	delete(p.index, p.slots[idx].key)
+	p.slots[idx] = clockSlot{}
+	p.free = append(p.free, idx)
+}
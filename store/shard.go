@@ -1,8 +1,9 @@
 package store
 
 import (
-	"fmt"
 	"sync"
+
+	"github.com/bbengfort/speedmap"
 )
 
 // ShardCount specifies the number of shards the store contains.
@@ -34,7 +35,7 @@ func NewShard() (store Shard, err error) {
 
 // GetShard returns the shard the key is assigned to.
 func (s Shard) GetShard(key string) *shard {
-	return s[uint(fnv32(key))%uint(ShardCount)]
+	return s[shardIndex(key)]
 }
 
 // Get a value by finding the shard the key belongs to, fetching and locking
@@ -46,7 +47,7 @@ func (s Shard) Get(key string) (value []byte, err error) {
 	shard.RUnlock()
 
 	if !ok {
-		return nil, fmt.Errorf("no value found for key '%s'", key)
+		return nil, notFound(key)
 	}
 	return val, nil
 }
@@ -55,6 +56,10 @@ func (s Shard) Get(key string) (value []byte, err error) {
 // the shard and assigning the value to the key. Unlike the Basic store,
 // does not use defer unlock.
 func (s Shard) Put(key string, value []byte) (err error) {
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
 	shard := s.GetShard(key)
 	shard.Lock()
 	shard.data[key] = value
@@ -75,7 +80,12 @@ func (s Shard) Delete(key string) (err error) {
 // GetOrCreate returns the value stored or stores the default value by finding
 // the shard the key belongs to, fetching and locking it then checking if the
 // key exists, setting if necessary. Unlike the Basic store, does not use defer unlock.
+// A key that is too large is not stored, and created is false.
 func (s Shard) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	if len(key) > speedmap.MaxKeySize {
+		return value, false
+	}
+
 	shard := s.GetShard(key)
 	shard.Lock()
 
@@ -92,11 +102,87 @@ func (s Shard) GetOrCreate(key string, value []byte) (actual []byte, created boo
 	return actual, false
 }
 
+// MultiGet fetches the values of all keys by grouping the keys by the shard
+// they belong to, so that each shard is read locked only once. The value of
+// a key that is not in the store is nil.
+func (s Shard) MultiGet(keys []string) (values [][]byte, err error) {
+	var groups [ShardCount][]int
+	for i, key := range keys {
+		idx := shardIndex(key)
+		groups[idx] = append(groups[idx], i)
+	}
+
+	values = make([][]byte, len(keys))
+	for idx, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+
+		shard := s[idx]
+		shard.RLock()
+		for _, i := range group {
+			values[i] = shard.data[keys[i]]
+		}
+		shard.RUnlock()
+	}
+	return values, nil
+}
+
+// MultiPut stores all of the pairs by grouping the keys by the shard they
+// belong to, so that each shard is locked only once. If any key exceeds the
+// maximum key size then none of the pairs are stored.
+func (s Shard) MultiPut(pairs map[string][]byte) (err error) {
+	var groups [ShardCount][]string
+	for key := range pairs {
+		if len(key) > speedmap.MaxKeySize {
+			return keyTooLarge(key)
+		}
+
+		idx := shardIndex(key)
+		groups[idx] = append(groups[idx], key)
+	}
+
+	for idx, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+
+		shard := s[idx]
+		shard.Lock()
+		for _, key := range group {
+			shard.data[key] = pairs[key]
+		}
+		shard.Unlock()
+	}
+	return nil
+}
+
+// Range calls fn for every key/value pair, read locking one shard at a time,
+// stopping if fn returns false.
+func (s Shard) Range(fn func(key string, value []byte) bool) error {
+	for _, shard := range s {
+		shard.RLock()
+		for key, val := range shard.data {
+			if !fn(key, val) {
+				shard.RUnlock()
+				return nil
+			}
+		}
+		shard.RUnlock()
+	}
+	return nil
+}
+
 // String returns the string representation of the sharded store.
 func (s Shard) String() string {
 	return "shard"
 }
 
+// Computes the index of the shard the key is assigned to.
+func shardIndex(key string) uint {
+	return uint(fnv32(key)) % uint(ShardCount)
+}
+
 // Computes the uint32 fingerprint of the specified key.
 func fnv32(key string) uint32 {
 	hash := uint32(2166136261)
@@ -24,6 +24,9 @@ var _ = Describe("Shard", func() {
 
 	It("should be a store", func() {
 		Ω(make(Shard, 0)).Should(BeAssignableToTypeOf(store))
+
+		_, ok := store.(speedmap.Batcher)
+		Ω(ok).Should(BeTrue())
 	})
 
 	It("should be able to perform store operations", func() {
@@ -40,6 +43,13 @@ var _ = Describe("Shard", func() {
 		Ω(val).Should(BeNil())
 	})
 
+	for _, conformance := range Conformance {
+		check := conformance.Check
+		It("should "+conformance.Behavior, func() {
+			Ω(check(store)).Should(Succeed())
+		})
+	}
+
 	It("should be able to get or create a value", func() {
 		actual, created := store.GetOrCreate("foo", []byte("bar"))
 		Ω(actual).Should(Equal([]byte("bar")))
@@ -0,0 +1,483 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// A table is an immutable file of entries sorted by key. The entries are
+// written in blocks of about tableBlockSize bytes, each followed by its CRC,
+// so that a read only has to load and verify the block that holds the key.
+// The blocks are followed by an index of the first key of each block and the
+// last key of the table, then by a bloom filter of the keys, then by a fixed
+// size footer that locates the index and the filter. Each entry in a block is
+// the uvarint length of the key, the uvarint length of the value plus one (or
+// zero for a tombstone), the key, and the value.
+const (
+	tableBlockSize  = 4096
+	tableFooterSize = 6 * 8
+	tableMagic      = 0x73706d7373746162 // "spmsstab"
+	tableExt        = ".sst"
+)
+
+// The CRC table used to checksum the blocks of tables.
+var tableCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// An open table and the metadata needed to read it, which is kept in memory.
+type sstable struct {
+	id     uint64
+	path   string
+	file   *os.File
+	index  []blockHandle
+	filter bloom
+	first  string
+	last   string
+	size   int64
+	count  uint64
+}
+
+// The location of a block in a table and the first key in the block.
+type blockHandle struct {
+	first  string
+	offset int64
+	length int64 // including the CRC
+}
+
+// Opens the table at the path and loads its index and bloom filter.
+func openTable(id uint64, path string) (table *sstable, err error) {
+	var file *os.File
+	if file, err = os.Open(path); err != nil {
+		return nil, err
+	}
+
+	table = &sstable{id: id, path: path, file: file}
+	if err = table.load(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("table %d: %w", id, err)
+	}
+	return table, nil
+}
+
+// Reads the footer, index and filter of the table.
+func (t *sstable) load() (err error) {
+	var info os.FileInfo
+	if info, err = t.file.Stat(); err != nil {
+		return err
+	}
+
+	t.size = info.Size()
+	if t.size < tableFooterSize {
+		return fmt.Errorf("%w: table is truncated", speedmap.ErrCorrupt)
+	}
+
+	footer := make([]byte, tableFooterSize)
+	if _, err = t.file.ReadAt(footer, t.size-tableFooterSize); err != nil {
+		return err
+	}
+
+	if binary.LittleEndian.Uint64(footer[40:]) != tableMagic {
+		return fmt.Errorf("%w: not a table", speedmap.ErrCorrupt)
+	}
+
+	indexOffset, indexLength := int64(binary.LittleEndian.Uint64(footer[0:])), int64(binary.LittleEndian.Uint64(footer[8:]))
+	filterOffset, filterLength := int64(binary.LittleEndian.Uint64(footer[16:])), int64(binary.LittleEndian.Uint64(footer[24:]))
+	t.count = binary.LittleEndian.Uint64(footer[32:])
+
+	var index []byte
+	if index, err = t.readBlock(indexOffset, indexLength); err != nil {
+		return err
+	}
+	if err = t.parseIndex(index); err != nil {
+		return err
+	}
+
+	if t.filter, err = t.readBlock(filterOffset, filterLength); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Parses the index: the number of blocks, the handle of each block, and the
+// last key of the table.
+func (t *sstable) parseIndex(data []byte) error {
+	corrupt := fmt.Errorf("%w: invalid table index", speedmap.ErrCorrupt)
+
+	nblocks, n := binary.Uvarint(data)
+	if n <= 0 || nblocks > uint64(len(data)) {
+		return corrupt
+	}
+	data = data[n:]
+
+	t.index = make([]blockHandle, nblocks)
+	for i := range t.index {
+		var key []byte
+		if key, data = readBytes(data); key == nil {
+			return corrupt
+		}
+
+		offset, n := binary.Uvarint(data)
+		if n <= 0 {
+			return corrupt
+		}
+		data = data[n:]
+
+		length, n := binary.Uvarint(data)
+		if n <= 0 {
+			return corrupt
+		}
+		data = data[n:]
+
+		t.index[i] = blockHandle{first: string(key), offset: int64(offset), length: int64(length)}
+	}
+
+	last, _ := readBytes(data)
+	if last == nil || len(t.index) == 0 {
+		return corrupt
+	}
+
+	t.first, t.last = t.index[0].first, string(last)
+	return nil
+}
+
+// Returns the entry for the key, which may be a tombstone, and true if the
+// table holds the key.
+func (t *sstable) get(key string) (entry lsmEntry, ok bool, err error) {
+	if key < t.first || key > t.last || !t.filter.contains(key) {
+		return entry, false, nil
+	}
+
+	// Find the last block whose first key is not greater than the key
+	i := sort.Search(len(t.index), func(i int) bool { return t.index[i].first > key }) - 1
+	if i < 0 {
+		return entry, false, nil
+	}
+
+	var block []byte
+	if block, err = t.readBlock(t.index[i].offset, t.index[i].length); err != nil {
+		return entry, false, err
+	}
+
+	for len(block) > 0 {
+		if entry, block, err = readEntry(block); err != nil {
+			return entry, false, err
+		}
+
+		if entry.key == key {
+			return entry, true, nil
+		}
+		if entry.key > key {
+			break
+		}
+	}
+	return lsmEntry{}, false, nil
+}
+
+// Returns true if the table holds keys in the range [start, end].
+func (t *sstable) overlaps(start, end string) bool {
+	return t.last >= start && (end == "" || t.first <= end)
+}
+
+// Reads the block at the offset and verifies its CRC, returning the block
+// without the CRC.
+func (t *sstable) readBlock(offset, length int64) (block []byte, err error) {
+	if length < 4 || offset < 0 || offset+length > t.size {
+		return nil, fmt.Errorf("%w: invalid block in table %d", speedmap.ErrCorrupt, t.id)
+	}
+
+	block = make([]byte, length)
+	if _, err = t.file.ReadAt(block, offset); err != nil {
+		return nil, err
+	}
+
+	data, crc := block[:length-4], binary.LittleEndian.Uint32(block[length-4:])
+	if crc32.Checksum(data, tableCRC) != crc {
+		return nil, fmt.Errorf("%w: block checksum mismatch in table %d", speedmap.ErrCorrupt, t.id)
+	}
+	return data, nil
+}
+
+// Closes the file of the table.
+func (t *sstable) close() error {
+	return t.file.Close()
+}
+
+// Closes the table and removes its file.
+func (t *sstable) remove() error {
+	t.close()
+	return os.Remove(t.path)
+}
+
+// An iterator over the entries of a table, reading one block at a time.
+type tableIterator struct {
+	table *sstable
+	block int
+	data  []byte
+	entry lsmEntry
+	fail  error
+}
+
+// Creates an iterator positioned before the first entry with key >= start.
+func (t *sstable) iterator(start string) *tableIterator {
+	it := &tableIterator{table: t}
+
+	// Start with the last block whose first key is not greater than start
+	it.block = sort.Search(len(t.index), func(i int) bool { return t.index[i].first > start }) - 1
+	if it.block < 0 {
+		it.block = 0
+	}
+
+	if it.fail = it.load(); it.fail != nil {
+		return it
+	}
+
+	// Skip the entries of the block that are before start
+	for len(it.data) > 0 {
+		var entry lsmEntry
+		rest := it.data
+		if entry, rest, it.fail = readEntry(rest); it.fail != nil || entry.key >= start {
+			return it
+		}
+		it.data = rest
+	}
+	return it
+}
+
+func (it *tableIterator) next() bool {
+	if it.fail != nil {
+		return false
+	}
+
+	for len(it.data) == 0 {
+		it.block++
+		if it.block >= len(it.table.index) {
+			return false
+		}
+		if it.fail = it.load(); it.fail != nil {
+			return false
+		}
+	}
+
+	it.entry, it.data, it.fail = readEntry(it.data)
+	return it.fail == nil
+}
+
+func (it *tableIterator) current() lsmEntry {
+	return it.entry
+}
+
+func (it *tableIterator) err() error {
+	return it.fail
+}
+
+// Loads the current block of the iterator.
+func (it *tableIterator) load() (err error) {
+	if it.block >= len(it.table.index) {
+		it.data = nil
+		return nil
+	}
+
+	handle := it.table.index[it.block]
+	it.data, err = it.table.readBlock(handle.offset, handle.length)
+	return err
+}
+
+// Writes entries, which must be added in key order, to a new table.
+type tableWriter struct {
+	id     uint64
+	path   string
+	file   *os.File
+	buf    *bufio.Writer
+	block  []byte
+	first  string
+	last   string
+	offset int64
+	index  []blockHandle
+	hashes []uint64
+}
+
+// Creates the file for a new table at the path.
+func newTableWriter(id uint64, path string) (w *tableWriter, err error) {
+	w = &tableWriter{id: id, path: path}
+	if w.file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); err != nil {
+		return nil, err
+	}
+
+	w.buf = bufio.NewWriterSize(w.file, 64*1024)
+	w.block = make([]byte, 0, tableBlockSize+1024)
+	return w, nil
+}
+
+// Adds the entry to the table.
+func (w *tableWriter) add(entry lsmEntry) (err error) {
+	if len(w.block) == 0 {
+		w.first = entry.key
+	}
+
+	w.block = binary.AppendUvarint(w.block, uint64(len(entry.key)))
+	if entry.deleted {
+		w.block = binary.AppendUvarint(w.block, 0)
+	} else {
+		w.block = binary.AppendUvarint(w.block, uint64(len(entry.value))+1)
+	}
+	w.block = append(w.block, entry.key...)
+	w.block = append(w.block, entry.value...)
+
+	w.last = entry.key
+	w.hashes = append(w.hashes, fnv64(entry.key))
+
+	if len(w.block) >= tableBlockSize {
+		return w.flushBlock()
+	}
+	return nil
+}
+
+// Returns the approximate size of the table written so far.
+func (w *tableWriter) size() int64 {
+	return w.offset + int64(len(w.block))
+}
+
+// Finishes the table by writing the last block, the index, the filter and the
+// footer, syncs it, and opens it for reading.
+func (w *tableWriter) finish() (table *sstable, err error) {
+	if err = w.flushBlock(); err != nil {
+		w.abort()
+		return nil, err
+	}
+
+	index := binary.AppendUvarint(nil, uint64(len(w.index)))
+	for _, handle := range w.index {
+		index = appendBytes(index, handle.first)
+		index = binary.AppendUvarint(index, uint64(handle.offset))
+		index = binary.AppendUvarint(index, uint64(handle.length))
+	}
+	index = appendBytes(index, w.last)
+
+	indexOffset, indexLength := w.offset, int64(len(index)+4)
+	if err = w.write(index); err != nil {
+		w.abort()
+		return nil, err
+	}
+
+	filter := newBloom(w.hashes)
+	filterOffset, filterLength := w.offset, int64(len(filter)+4)
+	if err = w.write(filter); err != nil {
+		w.abort()
+		return nil, err
+	}
+
+	footer := make([]byte, tableFooterSize)
+	binary.LittleEndian.PutUint64(footer[0:], uint64(indexOffset))
+	binary.LittleEndian.PutUint64(footer[8:], uint64(indexLength))
+	binary.LittleEndian.PutUint64(footer[16:], uint64(filterOffset))
+	binary.LittleEndian.PutUint64(footer[24:], uint64(filterLength))
+	binary.LittleEndian.PutUint64(footer[32:], uint64(len(w.hashes)))
+	binary.LittleEndian.PutUint64(footer[40:], tableMagic)
+
+	if _, err = w.buf.Write(footer); err == nil {
+		if err = w.buf.Flush(); err == nil {
+			err = w.file.Sync()
+		}
+	}
+
+	if err != nil {
+		w.abort()
+		return nil, err
+	}
+
+	if err = w.file.Close(); err != nil {
+		os.Remove(w.path)
+		return nil, err
+	}
+	return openTable(w.id, w.path)
+}
+
+// Closes and removes the unfinished table.
+func (w *tableWriter) abort() {
+	w.file.Close()
+	os.Remove(w.path)
+}
+
+// Writes the current block and adds it to the index.
+func (w *tableWriter) flushBlock() error {
+	if len(w.block) == 0 {
+		return nil
+	}
+
+	handle := blockHandle{first: w.first, offset: w.offset, length: int64(len(w.block) + 4)}
+	if err := w.write(w.block); err != nil {
+		return err
+	}
+
+	w.index = append(w.index, handle)
+	w.block = w.block[:0]
+	return nil
+}
+
+// Writes the data followed by its CRC.
+func (w *tableWriter) write(data []byte) (err error) {
+	var crc [4]byte
+	binary.LittleEndian.PutUint32(crc[:], crc32.Checksum(data, tableCRC))
+
+	if _, err = w.buf.Write(data); err != nil {
+		return err
+	}
+	if _, err = w.buf.Write(crc[:]); err != nil {
+		return err
+	}
+
+	w.offset += int64(len(data) + len(crc))
+	return nil
+}
+
+// Reads an entry from the start of the block and returns the rest of the block.
+func readEntry(block []byte) (entry lsmEntry, rest []byte, err error) {
+	klen, n := binary.Uvarint(block)
+	if n <= 0 {
+		return entry, nil, fmt.Errorf("%w: invalid table entry", speedmap.ErrCorrupt)
+	}
+	block = block[n:]
+
+	vlen, n := binary.Uvarint(block)
+	if n <= 0 {
+		return entry, nil, fmt.Errorf("%w: invalid table entry", speedmap.ErrCorrupt)
+	}
+	block = block[n:]
+
+	size := klen
+	if vlen > 0 {
+		size += vlen - 1
+	}
+	if size > uint64(len(block)) {
+		return entry, nil, fmt.Errorf("%w: invalid table entry", speedmap.ErrCorrupt)
+	}
+
+	entry.key = string(block[:klen])
+	if vlen == 0 {
+		entry.deleted = true
+	} else {
+		entry.value = block[klen:size:size]
+	}
+	return entry, block[size:], nil
+}
+
+// Appends the uvarint length of the string followed by the string.
+func appendBytes(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// Reads a uvarint length prefixed byte slice, returning nil if it is invalid.
+func readBytes(data []byte) (value, rest []byte) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || length > uint64(len(data)-n) {
+		return nil, data
+	}
+	data = data[n:]
+	return data[:length:length], data[length:]
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// StoreFactory creates an empty in-memory store.
+type StoreFactory func() (speedmap.Store, error)
+
+// Stores are the factories of the in-memory stores by name, e.g. to select the
+// store that a Swappable is swapped to.
+var Stores = map[string]StoreFactory{
+	"basic":     func() (speedmap.Store, error) { return NewBasic() },
+	"misframe":  func() (speedmap.Store, error) { return NewMisframe() },
+	"sync":      func() (speedmap.Store, error) { return NewSyncMap() },
+	"shard":     func() (speedmap.Store, error) { return NewShard() },
+	"arena":     func() (speedmap.Store, error) { return NewArena() },
+	"versioned": func() (speedmap.Store, error) { return NewVersioned() },
+	"mvcc":      func() (speedmap.Store, error) { return NewMVCC() },
+	"expiring":  func() (speedmap.Store, error) { return NewExpiring(DefaultSweepInterval) },
+}
+
+// GetStore returns the named store factory.
+func GetStore(name string) (StoreFactory, error) {
+	if factory, ok := Stores[name]; ok {
+		return factory, nil
+	}
+
+	names := make([]string, 0, len(Stores))
+	for name := range Stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown store '%s', use one of %v", name, names)
+}
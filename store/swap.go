@@ -0,0 +1,328 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Swappable wraps a store so that it can be replaced by a store of another
+// implementation while it is in use, e.g. to compare stores under the same
+// running workload. Swap migrates the pairs of the current store to the next
+// store without blocking reads or writes: while the pairs are copied, reads
+// are served by the current store and writes are applied to both stores, and
+// once every pair has been copied the next store replaces the current one.
+// Writes to the same key are serialized by one of ShardCount key locks during
+// a migration so that a pair that is copied never replaces a later write.
+type Swappable struct {
+	mu       sync.RWMutex        // held exclusively to start and complete a swap
+	current  speedmap.Store      // the store that serves requests
+	next     speedmap.Store      // the store being migrated to, nil if not swapping
+	written  map[string]struct{} // the keys written during a migration, which are not copied
+	wmu      sync.Mutex          // protects written
+	locks    [ShardCount]sync.Mutex
+	swapping sync.Mutex // allows only one swap at a time
+}
+
+// ErrNotSwappable is returned by NewSwappable and Swap for stores that support
+// versions, transactions, or watches, which Swappable does not forward.
+var ErrNotSwappable = errors.New("store cannot be swapped without losing its versions, transactions or watches")
+
+// NewSwappable wraps the store so that it can be swapped. Stores that support
+// versions, transactions, or watches cannot be wrapped without losing those
+// capabilities and are refused with ErrNotSwappable.
+func NewSwappable(store speedmap.Store) (swappable *Swappable, err error) {
+	if err = canSwap(store); err != nil {
+		return nil, err
+	}
+	return &Swappable{current: store}, nil
+}
+
+// Get the value of the key from the current store.
+func (s *Swappable) Get(key string) (value []byte, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.Get(key)
+}
+
+// Put the value of the key in the current store, and in the next store if a
+// swap is in progress.
+func (s *Swappable) Put(key string, value []byte) (err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.next == nil {
+		return s.current.Put(key, value)
+	}
+
+	lock := s.lock(key)
+	defer lock.Unlock()
+
+	if err = s.current.Put(key, value); err != nil {
+		return err
+	}
+
+	s.write(key)
+	return s.next.Put(key, value)
+}
+
+// Delete the key from the current store, and from the next store if a swap is
+// in progress.
+func (s *Swappable) Delete(key string) (err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.next == nil {
+		return s.current.Delete(key)
+	}
+
+	lock := s.lock(key)
+	defer lock.Unlock()
+
+	if err = s.current.Delete(key); err != nil {
+		return err
+	}
+
+	// The key may not have been copied to the next store yet
+	s.write(key)
+	if err = s.next.Delete(key); errors.Is(err, speedmap.ErrNotFound) {
+		err = nil
+	}
+	return err
+}
+
+// GetOrCreate the key in the current store, putting the value in the next
+// store if it is created while a swap is in progress.
+func (s *Swappable) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.next == nil {
+		return s.current.GetOrCreate(key, value)
+	}
+
+	lock := s.lock(key)
+	defer lock.Unlock()
+
+	if actual, created = s.current.GetOrCreate(key, value); created {
+		s.write(key)
+		s.next.Put(key, actual)
+	}
+	return actual, created
+}
+
+// PutWithTTL puts the value of the key in the current store, which must be a
+// speedmap.Expirer, and in the next store if a swap is in progress, without a
+// TTL if the next store is not an Expirer. Pairs copied by a swap do not keep
+// their TTL.
+func (s *Swappable) PutWithTTL(key string, value []byte, ttl time.Duration) (err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expirer, ok := s.current.(speedmap.Expirer)
+	if !ok {
+		return fmt.Errorf("the %s store does not support expiration", s.current)
+	}
+
+	if s.next == nil {
+		return expirer.PutWithTTL(key, value, ttl)
+	}
+
+	lock := s.lock(key)
+	defer lock.Unlock()
+
+	if err = expirer.PutWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+
+	s.write(key)
+	if next, ok := s.next.(speedmap.Expirer); ok {
+		return next.PutWithTTL(key, value, ttl)
+	}
+	return s.next.Put(key, value)
+}
+
+// MultiGet the values of the keys from the current store.
+func (s *Swappable) MultiGet(keys []string) (values [][]byte, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return speedmap.MultiGet(s.current, keys)
+}
+
+// MultiPut the pairs in the current store, and in the next store if a swap is
+// in progress.
+func (s *Swappable) MultiPut(pairs map[string][]byte) (err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.next == nil {
+		return speedmap.MultiPut(s.current, pairs)
+	}
+
+	// Lock the keys in order so that batches cannot deadlock
+	locked := make(map[uint]bool)
+	for key := range pairs {
+		locked[shardIndex(key)] = true
+	}
+
+	indices := make([]int, 0, len(locked))
+	for i := range locked {
+		indices = append(indices, int(i))
+	}
+	sort.Ints(indices)
+
+	for _, i := range indices {
+		s.locks[i].Lock()
+		defer s.locks[i].Unlock()
+	}
+
+	if err = speedmap.MultiPut(s.current, pairs); err != nil {
+		return err
+	}
+
+	for key := range pairs {
+		s.write(key)
+	}
+	return speedmap.MultiPut(s.next, pairs)
+}
+
+// Range over the pairs of the current store, which must be speedmap.Iterable.
+func (s *Swappable) Range(fn func(key string, value []byte) bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return speedmap.Range(s.current, fn)
+}
+
+// Swap migrates the pairs of the current store, which must be Iterable, to
+// the next store and replaces the current store with it, returning the
+// number of pairs copied. The current store is closed once it is replaced if
+// it is an io.Closer. If the next store cannot be swapped in (see NewSwappable)
+// or the pairs cannot be copied the swap is abandoned, the current store is
+// kept, and the next store is closed if it is an io.Closer.
+func (s *Swappable) Swap(next speedmap.Store) (copied uint64, err error) {
+	if err = canSwap(next); err != nil {
+		if closer, ok := next.(io.Closer); ok {
+			closer.Close()
+		}
+		return 0, err
+	}
+
+	s.swapping.Lock()
+	defer s.swapping.Unlock()
+
+	s.mu.Lock()
+	current := s.current
+	s.next, s.written = next, make(map[string]struct{})
+	s.mu.Unlock()
+
+	if copied, err = s.migrate(current, next); err != nil {
+		s.mu.Lock()
+		s.next, s.written = nil, nil
+		s.mu.Unlock()
+
+		if closer, ok := next.(io.Closer); ok {
+			closer.Close()
+		}
+		return 0, err
+	}
+
+	s.mu.Lock()
+	s.current, s.next, s.written = next, nil, nil
+	s.mu.Unlock()
+
+	if closer, ok := current.(io.Closer); ok {
+		if err = closer.Close(); err != nil {
+			return copied, err
+		}
+	}
+	return copied, nil
+}
+
+// Current returns the store that serves requests.
+func (s *Swappable) Current() speedmap.Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Close the current store if it is an io.Closer, e.g. to flush durable state.
+func (s *Swappable) Close() error {
+	if closer, ok := s.Current().(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// String returns the name of the current store.
+func (s *Swappable) String() string {
+	return s.Current().String() + " swappable"
+}
+
+// Returns ErrNotSwappable if the store supports versions, transactions, or
+// watches, which would be hidden by Swappable.
+func canSwap(store speedmap.Store) error {
+	switch speedmap.Unwrap(store).(type) {
+	case speedmap.Versioner, speedmap.Swapper, speedmap.Transactional, speedmap.Watchable:
+		return fmt.Errorf("the %s %w", store, ErrNotSwappable)
+	}
+	return nil
+}
+
+// Copies the pairs of the current store to the next store, except for keys
+// that have been written since the swap started, since the next store already
+// has their latest value. The pairs are collected first since the store may
+// be locked during Range.
+func (s *Swappable) migrate(current, next speedmap.Store) (copied uint64, err error) {
+	keys := make([]string, 0)
+	values := make([][]byte, 0)
+	if err = speedmap.Range(current, func(key string, value []byte) bool {
+		keys = append(keys, key)
+		values = append(values, value)
+		return true
+	}); err != nil {
+		return 0, err
+	}
+
+	for i, key := range keys {
+		if err = s.copy(next, key, values[i]); err != nil {
+			return copied, err
+		}
+		copied++
+	}
+	return copied, nil
+}
+
+// Copies the pair to the next store unless the key has been written since the
+// swap started.
+func (s *Swappable) copy(next speedmap.Store, key string, value []byte) error {
+	lock := s.lock(key)
+	defer lock.Unlock()
+
+	s.wmu.Lock()
+	_, written := s.written[key]
+	s.wmu.Unlock()
+
+	if written {
+		return nil
+	}
+	return next.Put(key, value)
+}
+
+// Locks the key during a swap, returning the lock to unlock.
+func (s *Swappable) lock(key string) *sync.Mutex {
+	lock := &s.locks[shardIndex(key)]
+	lock.Lock()
+	return lock
+}
+
+// Records that the key was written during a swap.
+func (s *Swappable) write(key string) {
+	s.wmu.Lock()
+	s.written[key] = struct{}{}
+	s.wmu.Unlock()
+}
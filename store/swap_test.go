@@ -0,0 +1,194 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/store"
+)
+
+var _ = Describe("Swappable", func() {
+
+	var (
+		err   error
+		store *Swappable
+	)
+
+	BeforeEach(func() {
+		var basic *Basic
+		basic, err = NewBasic()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		store, err = NewSwappable(basic)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should be an iterable batch store", func() {
+		var kv speedmap.Store = store
+		_, ok := kv.(speedmap.Iterable)
+		Ω(ok).Should(BeTrue())
+
+		_, ok = kv.(speedmap.Batcher)
+		Ω(ok).Should(BeTrue())
+		Ω(store.String()).Should(Equal("basic swappable"))
+	})
+
+	It("should migrate the pairs to the next store", func() {
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("%X", i)
+			Ω(store.Put(key, []byte(key))).Should(Succeed())
+		}
+
+		next, err := NewSyncMap()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		copied, err := store.Swap(next)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(copied).Should(Equal(uint64(100)))
+		Ω(store.Current()).Should(BeIdenticalTo(next))
+		Ω(store.String()).Should(Equal("sync map swappable"))
+
+		Ω(next.Get("4F")).Should(Equal([]byte("4F")))
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+		Ω(next.Get("foo")).Should(Equal([]byte("bar")))
+	})
+
+	It("should not lose writes made during a swap", func() {
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("%X", i)
+			Ω(store.Put(key, []byte("before"))).Should(Succeed())
+		}
+
+		next, err := NewShard()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		// Overwrite or delete every key while the store is swapped
+		var wg sync.WaitGroup
+		for t := 0; t < 4; t++ {
+			wg.Add(1)
+			go func(t int) {
+				defer wg.Done()
+				for i := t; i < 1000; i += 4 {
+					key := fmt.Sprintf("%X", i)
+					if i%2 == 0 {
+						store.Delete(key)
+					} else {
+						store.MultiPut(map[string][]byte{key: []byte("after")})
+					}
+				}
+			}(t)
+		}
+
+		_, err = store.Swap(next)
+		Ω(err).ShouldNot(HaveOccurred())
+		wg.Wait()
+
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("%X", i)
+			value, err := next.Get(key)
+			if i%2 == 0 {
+				Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+			} else {
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(value).Should(Equal([]byte("after")))
+			}
+		}
+	})
+
+	It("should keep the current store if it cannot be iterated", func() {
+		current := store.Current()
+		store, err = NewSwappable(noRange{current})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		basic, err := NewBasic()
+		Ω(err).ShouldNot(HaveOccurred())
+		next := &closeCounter{Store: basic}
+
+		_, err = store.Swap(next)
+		Ω(err).Should(HaveOccurred())
+		Ω(next.closed).Should(Equal(1))
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+		Ω(current.Get("foo")).Should(Equal([]byte("bar")))
+	})
+
+	It("should refuse stores whose capabilities it would hide", func() {
+		for _, name := range []string{"versioned", "mvcc"} {
+			factory, err := GetStore(name)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			kv, err := factory()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = NewSwappable(kv)
+			Ω(err).Should(HaveOccurred())
+		}
+
+		shard, err := NewShard()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		watched, err := NewWatched(shard)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = NewSwappable(watched)
+		Ω(errors.Is(err, ErrNotSwappable)).Should(BeTrue())
+	})
+
+	It("should refuse to swap to stores whose capabilities it would hide", func() {
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+
+		mvcc, err := NewMVCC()
+		Ω(err).ShouldNot(HaveOccurred())
+		next := &closeCounterMVCC{MVCC: mvcc}
+
+		_, err = store.Swap(next)
+		Ω(errors.Is(err, ErrNotSwappable)).Should(BeTrue())
+		Ω(next.closed).Should(Equal(1))
+		Ω(store.String()).Should(Equal("basic swappable"))
+		Ω(store.Get("foo")).Should(Equal([]byte("bar")))
+	})
+
+	It("should look up stores by name", func() {
+		factory, err := GetStore("shard")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		kv, err := factory()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(kv).Should(BeAssignableToTypeOf(Shard{}))
+
+		_, err = GetStore("btree")
+		Ω(err).Should(MatchError("unknown store 'btree', use one of [arena basic expiring misframe mvcc shard sync versioned]"))
+	})
+})
+
+// Hides the optional interfaces of a store.
+type noRange struct {
+	speedmap.Store
+}
+
+// Counts the number of times that a store is closed.
+type closeCounter struct {
+	speedmap.Store
+	closed int
+}
+
+func (s *closeCounter) Close() error {
+	s.closed++
+	return nil
+}
+
+// Counts the number of times that an MVCC store is closed, keeping its
+// optional interfaces.
+type closeCounterMVCC struct {
+	*MVCC
+	closed int
+}
+
+func (s *closeCounterMVCC) Close() error {
+	s.closed++
+	return nil
+}
@@ -3,6 +3,8 @@ package store
 import (
 	"fmt"
 	"sync"
+
+	"github.com/bbengfort/speedmap"
 )
 
 // SyncMap is just a wrapper to sync.Map to provide the specified interface.
@@ -22,19 +24,24 @@ func NewSyncMap() (store *SyncMap, err error) {
 func (s *SyncMap) Get(key string) (value []byte, err error) {
 	data, ok := s.data.Load(key)
 	if !ok {
-		return nil, fmt.Errorf("no value found for key '%s'", key)
+		return nil, notFound(key)
 	}
 
 	value, ok = data.([]byte)
 	if !ok {
-		return nil, fmt.Errorf("could not cast value to bytes")
+		return nil, fmt.Errorf("%w for key '%s'", speedmap.ErrValueType, key)
 	}
 
 	return value, nil
 }
 
-// Put is an alias for sync.Map.Store. Does not return an error.
+// Put is an alias for sync.Map.Store. Returns an error only if the key
+// exceeds the maximum key size.
 func (s *SyncMap) Put(key string, value []byte) (err error) {
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
 	s.data.Store(key, value)
 	return nil
 }
@@ -45,12 +52,31 @@ func (s *SyncMap) Delete(key string) (err error) {
 	return nil
 }
 
-// GetOrCreate is an alias for sync.Map.LoadOrStore.
+// GetOrCreate is an alias for sync.Map.LoadOrStore, except that a key that is
+// too large is not stored, and created is false.
 func (s *SyncMap) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	if len(key) > speedmap.MaxKeySize {
+		return value, false
+	}
+
 	data, loaded := s.data.LoadOrStore(key, value)
 	return data.([]byte), !loaded
 }
 
+// Range is an alias for sync.Map.Range, stopping with an error if a value
+// cannot be cast to bytes.
+func (s *SyncMap) Range(fn func(key string, value []byte) bool) (err error) {
+	s.data.Range(func(key, data interface{}) bool {
+		value, ok := data.([]byte)
+		if !ok {
+			err = fmt.Errorf("%w for key '%s'", speedmap.ErrValueType, key)
+			return false
+		}
+		return fn(key.(string), value)
+	})
+	return err
+}
+
 // String returns a string representation of the Store
 func (s *SyncMap) String() string {
 	return "sync map"
@@ -40,6 +40,13 @@ var _ = Describe("Sync", func() {
 		Ω(val).Should(BeNil())
 	})
 
+	for _, conformance := range Conformance {
+		check := conformance.Check
+		It("should "+conformance.Behavior, func() {
+			Ω(check(store)).Should(Succeed())
+		})
+	}
+
 	It("should be able to get or create a value", func() {
 		actual, created := store.GetOrCreate("foo", []byte("bar"))
 		Ω(actual).Should(Equal([]byte("bar")))
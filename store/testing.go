@@ -1,7 +1,9 @@
 package store
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -52,3 +54,112 @@ type BlastResult struct {
 	Duration   time.Duration
 	Throughput float64
 }
+
+// Conformance is a table of the checks that the in-memory stores share, each
+// named for the behavior it checks so that the tests of a store can run them
+// all. A check returns an error describing the first way in which the store
+// does not conform.
+var Conformance = []struct {
+	Behavior string
+	Check    func(store speedmap.Store) error
+}{
+	{"return standard errors", checkErrors},
+	{"get and put batches", checkBatches},
+	{"range over its contents", checkRange},
+}
+
+// Checks that missing keys and keys that are too large return the standard
+// errors, and that GetOrCreate does not store a key that is too large.
+func checkErrors(store speedmap.Store) error {
+	if _, err := store.Get("foo"); !errors.Is(err, speedmap.ErrNotFound) {
+		return fmt.Errorf("get of a missing key returned %v", err)
+	}
+
+	key := strings.Repeat("a", speedmap.MaxKeySize+1)
+	if err := store.Put(key, []byte("bar")); !errors.Is(err, speedmap.ErrKeyTooLarge) {
+		return fmt.Errorf("put of a key that is too large returned %v", err)
+	}
+
+	if _, created := store.GetOrCreate(key, []byte("bar")); created {
+		return errors.New("get or create stored a key that is too large")
+	}
+
+	if _, err := store.Get(key); !errors.Is(err, speedmap.ErrNotFound) {
+		return fmt.Errorf("get of a key that is too large returned %v", err)
+	}
+	return nil
+}
+
+// Checks that a batch of pairs can be put and then got in the order of the
+// keys, with a nil value for a missing key.
+func checkBatches(store speedmap.Store) error {
+	pairs := make(map[string][]byte)
+	keys := make([]string, 0, 101)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("%X", i)
+		pairs[key] = []byte(key)
+		keys = append(keys, key)
+	}
+	keys = append(keys, "missing")
+
+	if err := speedmap.MultiPut(store, pairs); err != nil {
+		return err
+	}
+
+	values, err := speedmap.MultiGet(store, keys)
+	if err != nil {
+		return err
+	}
+
+	if len(values) != len(keys) {
+		return fmt.Errorf("got %d values for %d keys", len(values), len(keys))
+	}
+
+	for i, key := range keys[:100] {
+		if string(values[i]) != key {
+			return fmt.Errorf("got %q for key %q", values[i], key)
+		}
+	}
+
+	if values[100] != nil {
+		return fmt.Errorf("got %q for a missing key", values[100])
+	}
+	return nil
+}
+
+// Checks that Range visits every pair in the store but not deleted keys.
+func checkRange(store speedmap.Store) error {
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("%X", i)
+		if err := store.Put(key, []byte(key)); err != nil {
+			return err
+		}
+	}
+
+	if err := store.Delete("0"); err != nil {
+		return err
+	}
+
+	seen := make(map[string]string)
+	if err := speedmap.Range(store, func(key string, value []byte) bool {
+		seen[key] = string(value)
+		return true
+	}); err != nil {
+		return err
+	}
+
+	if len(seen) != 99 {
+		return fmt.Errorf("ranged over %d pairs instead of 99", len(seen))
+	}
+
+	if _, ok := seen["0"]; ok {
+		return errors.New("ranged over a deleted key")
+	}
+
+	for key, value := range seen {
+		if key != value {
+			return fmt.Errorf("ranged over %q with value %q", key, value)
+		}
+	}
+	return nil
+}
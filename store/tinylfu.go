@@ -0,0 +1,245 @@
+package store
+
+import "container/list"
+
+// TinyLFU implements the W-TinyLFU eviction policy: new keys enter a small LRU
+// window (1% of the keys), and keys that overflow the window are moved into a
+// segmented LRU main space made up of a probation segment and a protected
+// segment (80% of the main space) for keys that have been hit in probation.
+// When a victim is needed, the key most recently admitted to probation
+// competes with the least recently used key in probation and the one with the
+// lower estimated access frequency is evicted. Frequencies are estimated with
+// a count-min sketch of 4-bit counters that are periodically halved, so that
+// the policy adapts to changes in popularity.
+type TinyLFU struct {
+	window    *list.List
+	probation *list.List
+	protected *list.List
+	index     map[string]*list.Element
+	sketch    *sketch
+}
+
+// Identifies which segment of the policy an entry is in.
+type segment uint8
+
+const (
+	windowSegment segment = iota
+	probationSegment
+	protectedSegment
+)
+
+// An entry in one of the TinyLFU segments.
+type tinyEntry struct {
+	key     string
+	segment segment
+}
+
+// NewTinyLFU creates a W-TinyLFU eviction policy.
+func NewTinyLFU(capacity int) Policy {
+	return &TinyLFU{
+		window:    list.New(),
+		probation: list.New(),
+		protected: list.New(),
+		index:     make(map[string]*list.Element, capacity),
+		sketch:    newSketch(capacity),
+	}
+}
+
+// Add the key to the window, moving keys that overflow the window to the
+// probation segment of the main space.
+func (p *TinyLFU) Add(key string) {
+	p.sketch.increment(key)
+	p.index[key] = p.window.PushFront(&tinyEntry{key: key, segment: windowSegment})
+
+	limit := len(p.index) / 100
+	if limit < 1 {
+		limit = 1
+	}
+
+	for p.window.Len() > limit {
+		entry := p.window.Remove(p.window.Back()).(*tinyEntry)
+		entry.segment = probationSegment
+		p.index[entry.key] = p.probation.PushFront(entry)
+	}
+}
+
+// Hit records the access in the sketch and updates the recency of the key,
+// promoting keys hit in probation to the protected segment.
+func (p *TinyLFU) Hit(key string) {
+	p.sketch.increment(key)
+
+	elem, ok := p.index[key]
+	if !ok {
+		return
+	}
+
+	entry := elem.Value.(*tinyEntry)
+	switch entry.segment {
+	case windowSegment:
+		p.window.MoveToFront(elem)
+	case protectedSegment:
+		p.protected.MoveToFront(elem)
+	case probationSegment:
+		p.probation.Remove(elem)
+		entry.segment = protectedSegment
+		p.index[key] = p.protected.PushFront(entry)
+
+		// Demote the least recently used protected keys back to probation
+		limit := (p.probation.Len() + p.protected.Len()) * 8 / 10
+		for p.protected.Len() > limit && p.protected.Len() > 1 {
+			demoted := p.protected.Remove(p.protected.Back()).(*tinyEntry)
+			demoted.segment = probationSegment
+			p.index[demoted.key] = p.probation.PushFront(demoted)
+		}
+	}
+}
+
+// Remove the key from whichever segment it is in.
+func (p *TinyLFU) Remove(key string) {
+	if elem, ok := p.index[key]; ok {
+		p.segmentList(elem.Value.(*tinyEntry).segment).Remove(elem)
+		delete(p.index, key)
+	}
+}
+
+// Victim holds an admission contest between the most recently admitted key in
+// probation and the least recently used key in probation, evicting the one
+// that is accessed less frequently. If probation is empty, the least recently
+// used protected key is evicted, or if the main space is empty, the least
+// recently used key in the window. The incoming key is never evicted.
+func (p *TinyLFU) Victim(incoming string) string {
+	var elem *list.Element
+	candidate, victim := p.newest(p.probation, incoming), p.oldest(p.probation, incoming)
+	switch {
+	case candidate != nil && candidate != victim:
+		elem = candidate
+		if p.sketch.estimate(candidate.Value.(*tinyEntry).key) > p.sketch.estimate(victim.Value.(*tinyEntry).key) {
+			elem = victim
+		}
+	case victim != nil:
+		elem = victim
+	default:
+		if elem = p.oldest(p.protected, incoming); elem == nil {
+			if elem = p.oldest(p.window, incoming); elem == nil {
+				return ""
+			}
+		}
+	}
+
+	entry := elem.Value.(*tinyEntry)
+	p.segmentList(entry.segment).Remove(elem)
+	delete(p.index, entry.key)
+	return entry.key
+}
+
+// String returns the name of the policy.
+func (p *TinyLFU) String() string {
+	return "tinylfu"
+}
+
+// Returns the least recently used element of the segment, skipping the key.
+func (p *TinyLFU) oldest(segment *list.List, skip string) *list.Element {
+	elem := segment.Back()
+	if elem != nil && elem.Value.(*tinyEntry).key == skip {
+		elem = elem.Prev()
+	}
+	return elem
+}
+
+// Returns the most recently used element of the segment, skipping the key.
+func (p *TinyLFU) newest(segment *list.List, skip string) *list.Element {
+	elem := segment.Front()
+	if elem != nil && elem.Value.(*tinyEntry).key == skip {
+		elem = elem.Next()
+	}
+	return elem
+}
+
+// Returns the list that implements the segment.
+func (p *TinyLFU) segmentList(seg segment) *list.List {
+	switch seg {
+	case windowSegment:
+		return p.window
+	case probationSegment:
+		return p.probation
+	default:
+		return p.protected
+	}
+}
+
+//===========================================================================
+// Count-Min Sketch
+//===========================================================================
+
+// Depth of the count-min sketch and the maximum value of a counter.
+const (
+	sketchDepth = 4
+	sketchMax   = 15
+)
+
+// A count-min sketch with saturating 4-bit counters (stored in bytes for
+// simplicity) that halves all counters after a sample of increments so that
+// the frequency estimates decay over time.
+type sketch struct {
+	rows    [sketchDepth][]uint8
+	mask    uint32
+	samples int
+	limit   int
+}
+
+// Creates a sketch sized for the expected number of keys.
+func newSketch(capacity int) *sketch {
+	width := 64
+	for width < capacity*2 {
+		width <<= 1
+	}
+
+	s := &sketch{mask: uint32(width - 1), limit: width * 10}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// Increments the counters of the key, aging the sketch after every sample.
+func (s *sketch) increment(key string) {
+	h1, h2 := sketchHashes(key)
+	for i := range s.rows {
+		idx := (h1 + uint32(i)*h2) & s.mask
+		if s.rows[i][idx] < sketchMax {
+			s.rows[i][idx]++
+		}
+	}
+
+	if s.samples++; s.samples >= s.limit {
+		s.age()
+	}
+}
+
+// Returns the minimum of the counters of the key.
+func (s *sketch) estimate(key string) uint8 {
+	h1, h2 := sketchHashes(key)
+	min := uint8(sketchMax)
+	for i := range s.rows {
+		if c := s.rows[i][(h1+uint32(i)*h2)&s.mask]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// Halves every counter in the sketch.
+func (s *sketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.samples /= 2
+}
+
+// Computes two hashes of the key for double hashing the sketch rows.
+func sketchHashes(key string) (uint32, uint32) {
+	h := fnv32(key)
+	return h, (h>>17 | h<<15) | 1
+}
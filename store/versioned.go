@@ -0,0 +1,260 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Versioned is a key/value store synchronized with a RWMutex that associates
+// a version with every key. Every write (including deletes) assigns the key
+// the next value of a monotonically increasing sequence, which allows the
+// store to support multi-key transactions with optimistic concurrency
+// control: a transaction records the version of every key it reads and only
+// commits if none of those versions have changed.
+//
+// Deleted keys are kept in the map as tombstones so that a transaction that
+// read a missing key can detect if it was created and deleted again. Deleting
+// a key that is not in the store (or is already deleted) is a no-op, so that
+// tombstones are only created for keys that were stored.
+type Versioned struct {
+	sync.RWMutex
+	data map[string]*version
+	seq  uint64
+}
+
+// A value stored along with the sequence number of the write that created it.
+type version struct {
+	value   []byte
+	version uint64
+	deleted bool
+}
+
+// NewVersioned creates a Versioned store and initializes the internal map.
+func NewVersioned() (store *Versioned, err error) {
+	store = new(Versioned)
+	store.data = make(map[string]*version)
+	return store, nil
+}
+
+// Get a value by read locking the internal map and fetching it. If the key
+// is not in the map or has been deleted, returns an error.
+func (s *Versioned) Get(key string) (value []byte, err error) {
+	value, _, err = s.GetVersion(key)
+	return value, err
+}
+
+// GetVersion returns the value of the key along with the version of the write
+// that stored it. If the key is not in the map, the version of the delete
+// that removed it is returned with the error, or zero if it was never stored.
+func (s *Versioned) GetVersion(key string) (value []byte, vers uint64, err error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	val, ok := s.data[key]
+	if !ok {
+		return nil, 0, notFound(key)
+	}
+
+	if val.deleted {
+		return nil, val.version, notFound(key)
+	}
+	return val.value, val.version, nil
+}
+
+// Put a value by locking the internal map and storing it with a new version.
+// Returns an error only if the key exceeds the maximum key size.
+func (s *Versioned) Put(key string, value []byte) (err error) {
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.seq++
+	s.data[key] = &version{value: value, version: s.seq}
+	return nil
+}
+
+// Delete a key by locking the internal map and replacing it with a tombstone.
+// No error returned even if the key isn't in the map to begin with.
+func (s *Versioned) Delete(key string) (err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if val, ok := s.data[key]; !ok || val.deleted {
+		return nil
+	}
+
+	s.seq++
+	s.data[key] = &version{version: s.seq, deleted: true}
+	return nil
+}
+
+// GetOrCreate returns the value stored or stores the supplied default value.
+// A key that is too large is not stored, and created is false.
+func (s *Versioned) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	if len(key) > speedmap.MaxKeySize {
+		return value, false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if val, ok := s.data[key]; ok && !val.deleted {
+		return val.value, false
+	}
+
+	s.seq++
+	s.data[key] = &version{value: value, version: s.seq}
+	return value, true
+}
+
+// CompareAndSwap stores the value with a new version only if the current
+// version of the key is vers. Returns an error if the key is not in the map.
+func (s *Versioned) CompareAndSwap(key string, vers uint64, value []byte) (swapped bool, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	val, ok := s.data[key]
+	if !ok || val.deleted {
+		return false, notFound(key)
+	}
+
+	if val.version != vers {
+		return false, nil
+	}
+
+	s.seq++
+	s.data[key] = &version{value: value, version: s.seq}
+	return true, nil
+}
+
+// Begin a new transaction on the store.
+func (s *Versioned) Begin() speedmap.Txn {
+	return &transaction{
+		store:  s,
+		reads:  make(map[string]uint64),
+		writes: make(map[string]*version),
+	}
+}
+
+// Range calls fn for every key/value pair that has not been deleted while
+// read locking the internal map, stopping if fn returns false.
+func (s *Versioned) Range(fn func(key string, value []byte) bool) error {
+	s.RLock()
+	defer s.RUnlock()
+
+	for key, val := range s.data {
+		if !val.deleted && !fn(key, val.value) {
+			break
+		}
+	}
+	return nil
+}
+
+// String returns a string representation of the Store
+func (s *Versioned) String() string {
+	return "versioned"
+}
+
+// A transaction buffers writes and records the versions of the keys it reads
+// so that they can be validated against the Versioned store on commit.
+type transaction struct {
+	store  *Versioned
+	reads  map[string]uint64
+	writes map[string]*version
+	done   bool
+}
+
+// Get returns a value written earlier in the transaction, otherwise reads the
+// value from the store, recording the version that was read.
+func (t *transaction) Get(key string) (value []byte, err error) {
+	if t.done {
+		return nil, speedmap.ErrTxnDone
+	}
+
+	if val, ok := t.writes[key]; ok {
+		if val.deleted {
+			return nil, notFound(key)
+		}
+		return val.value, nil
+	}
+
+	value, vers, err := t.store.GetVersion(key)
+	if _, ok := t.reads[key]; !ok {
+		t.reads[key] = vers
+	}
+	return value, err
+}
+
+// Put buffers the write of the value to the key until commit.
+func (t *transaction) Put(key string, value []byte) (err error) {
+	if t.done {
+		return speedmap.ErrTxnDone
+	}
+
+	if len(key) > speedmap.MaxKeySize {
+		return keyTooLarge(key)
+	}
+
+	t.writes[key] = &version{value: value}
+	return nil
+}
+
+// Delete buffers the deletion of the key until commit.
+func (t *transaction) Delete(key string) (err error) {
+	if t.done {
+		return speedmap.ErrTxnDone
+	}
+
+	t.writes[key] = &version{deleted: true}
+	return nil
+}
+
+// Commit locks the store and validates that every key read by the
+// transaction still has the version that was read, then applies all of the
+// buffered writes with a single new version before unlocking the store.
+// Buffered deletes of keys that are not in the store are skipped.
+func (t *transaction) Commit() (err error) {
+	if t.done {
+		return speedmap.ErrTxnDone
+	}
+	t.done = true
+
+	s := t.store
+	s.Lock()
+	defer s.Unlock()
+
+	for key, vers := range t.reads {
+		var current uint64
+		if val, ok := s.data[key]; ok {
+			current = val.version
+		}
+
+		if current != vers {
+			return fmt.Errorf("%w on key '%s' (read version %d, current version %d)", speedmap.ErrConflict, key, vers, current)
+		}
+	}
+
+	if len(t.writes) == 0 {
+		return nil
+	}
+
+	s.seq++
+	for key, val := range t.writes {
+		if cur, ok := s.data[key]; val.deleted && (!ok || cur.deleted) {
+			continue
+		}
+		val.version = s.seq
+		s.data[key] = val
+	}
+	return nil
+}
+
+// Rollback discards the transaction without applying any writes.
+func (t *transaction) Rollback() {
+	t.done = true
+}
@@ -0,0 +1,226 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/store"
+)
+
+var _ = Describe("Versioned", func() {
+
+	var (
+		err   error
+		store speedmap.Store
+	)
+
+	BeforeEach(func() {
+		store, err = NewVersioned()
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should be a store", func() {
+		Ω(&Versioned{}).Should(BeAssignableToTypeOf(store))
+	})
+
+	It("should be able to perform store operations", func() {
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+
+		val, err := store.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("bar")))
+
+		Ω(store.Delete("foo")).Should(Succeed())
+
+		val, err = store.Get("foo")
+		Ω(err).Should(HaveOccurred())
+		Ω(val).Should(BeNil())
+	})
+
+	for _, conformance := range Conformance {
+		check := conformance.Check
+		It("should "+conformance.Behavior, func() {
+			Ω(check(store)).Should(Succeed())
+		})
+	}
+
+	It("should be able to get or create a value", func() {
+		actual, created := store.GetOrCreate("foo", []byte("bar"))
+		Ω(actual).Should(Equal([]byte("bar")))
+		Ω(created).Should(BeTrue())
+
+		actual, created = store.GetOrCreate("foo", []byte("red"))
+		Ω(actual).Should(Equal([]byte("bar")))
+		Ω(created).Should(BeFalse())
+	})
+
+	It("should compare and swap values by version", func() {
+		swapper := store.(speedmap.Swapper)
+		_, err := swapper.CompareAndSwap("foo", 0, []byte("bar"))
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+		_, vers, err := store.(speedmap.Versioner).GetVersion("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		swapped, err := swapper.CompareAndSwap("foo", vers, []byte("baz"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(swapped).Should(BeTrue())
+
+		swapped, err = swapper.CompareAndSwap("foo", vers, []byte("red"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(swapped).Should(BeFalse())
+		Ω(store.Get("foo")).Should(Equal([]byte("baz")))
+
+		Ω(store.Delete("foo")).Should(Succeed())
+		_, err = swapper.CompareAndSwap("foo", vers, []byte("red"))
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+	})
+
+	It("should increment versions on every write", func() {
+		versioned := store.(*Versioned)
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+
+		_, v1, err := versioned.GetVersion("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(store.Delete("foo")).Should(Succeed())
+		_, v2, err := versioned.GetVersion("foo")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+		Ω(v2).Should(BeNumerically(">", v1))
+
+		actual, created := store.GetOrCreate("foo", []byte("red"))
+		Ω(actual).Should(Equal([]byte("red")))
+		Ω(created).Should(BeTrue())
+		_, v3, err := versioned.GetVersion("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(v3).Should(BeNumerically(">", v2))
+	})
+
+	It("should not create tombstones for missing keys", func() {
+		versioned := store.(*Versioned)
+		Ω(store.Delete("foo")).Should(Succeed())
+		_, vers, err := versioned.GetVersion("foo")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+		Ω(vers).Should(BeZero())
+
+		txn := versioned.Begin()
+		Ω(txn.Delete("foo")).Should(Succeed())
+		Ω(txn.Put("bar", []byte("baz"))).Should(Succeed())
+		Ω(txn.Commit()).Should(Succeed())
+		_, vers, _ = versioned.GetVersion("foo")
+		Ω(vers).Should(BeZero())
+
+		Ω(store.Delete("bar")).Should(Succeed())
+		_, v1, _ := versioned.GetVersion("bar")
+		Ω(v1).ShouldNot(BeZero())
+		Ω(store.Delete("bar")).Should(Succeed())
+		_, v2, _ := versioned.GetVersion("bar")
+		Ω(v2).Should(Equal(v1))
+	})
+
+	It("should commit transactions atomically", func() {
+		Ω(store.Put("a", []byte("1"))).Should(Succeed())
+
+		txn := store.(speedmap.Transactional).Begin()
+		val, err := txn.Get("a")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("1")))
+
+		Ω(txn.Put("a", []byte("2"))).Should(Succeed())
+		Ω(txn.Put("b", []byte("3"))).Should(Succeed())
+		Ω(txn.Delete("c")).Should(Succeed())
+
+		// Writes are visible in the transaction but not in the store
+		val, err = txn.Get("a")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("2")))
+		_, err = store.Get("b")
+		Ω(err).Should(HaveOccurred())
+
+		Ω(txn.Commit()).Should(Succeed())
+		Ω(errors.Is(txn.Commit(), speedmap.ErrTxnDone)).Should(BeTrue())
+
+		val, err = store.Get("a")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("2")))
+		val, err = store.Get("b")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("3")))
+	})
+
+	It("should abort transactions whose reads conflict", func() {
+		t1 := store.(speedmap.Transactional).Begin()
+		t2 := store.(speedmap.Transactional).Begin()
+
+		// Both transactions read the missing key and then write it
+		_, err := t1.Get("foo")
+		Ω(err).Should(HaveOccurred())
+		_, err = t2.Get("foo")
+		Ω(err).Should(HaveOccurred())
+
+		Ω(t1.Put("foo", []byte("t1"))).Should(Succeed())
+		Ω(t2.Put("foo", []byte("t2"))).Should(Succeed())
+		Ω(t2.Put("bar", []byte("t2"))).Should(Succeed())
+
+		Ω(t1.Commit()).Should(Succeed())
+		Ω(errors.Is(t2.Commit(), speedmap.ErrConflict)).Should(BeTrue())
+
+		val, err := store.Get("foo")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(val).Should(Equal([]byte("t1")))
+		_, err = store.Get("bar")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should not apply rolled back transactions", func() {
+		txn := store.(speedmap.Transactional).Begin()
+		Ω(txn.Put("foo", []byte("bar"))).Should(Succeed())
+		txn.Rollback()
+
+		Ω(errors.Is(txn.Commit(), speedmap.ErrTxnDone)).Should(BeTrue())
+		_, err := store.Get("foo")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	Measure("get throughput", func(b Benchmarker) {
+		// Populate the store
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("%X", i)
+			store.Put(key, []byte(key))
+		}
+
+		results, err := Blast(store, 5000, "Get")
+		Ω(err).ShouldNot(HaveOccurred())
+		b.RecordValue("throughput", results.Throughput)
+	}, 10)
+
+	Measure("put throughput", func(b Benchmarker) {
+		results, err := Blast(store, 5000, "Put")
+		Ω(err).ShouldNot(HaveOccurred())
+		b.RecordValue("throughput", results.Throughput)
+	}, 10)
+
+	Measure("delete throughput", func(b Benchmarker) {
+		// Populate the store
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("%X", i)
+			store.Put(key, []byte(key))
+		}
+
+		results, err := Blast(store, 5000, "Delete")
+		Ω(err).ShouldNot(HaveOccurred())
+		b.RecordValue("throughput", results.Throughput)
+	}, 10)
+
+	Measure("get or create throughput", func(b Benchmarker) {
+		results, err := Blast(store, 5000, "GetOrCreate")
+		Ω(err).ShouldNot(HaveOccurred())
+		b.RecordValue("throughput", results.Throughput)
+	}, 10)
+
+})
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Watched wraps any store to make it Watchable, publishing an event to every
+// matching watcher after each successful Put, Delete, or GetOrCreate that
+// creates a value. Writes to the same key are serialized by one of ShardCount
+// key locks so that watchers observe the changes to a key in the order they
+// were applied to the store; writes to different keys remain concurrent.
+//
+// Events are delivered to each watcher through a buffered channel without
+// blocking the writer. A watcher whose buffer fills up is closed with
+// speedmap.ErrSlowConsumer and must watch again to resume.
+type Watched struct {
+	speedmap.Store
+	locks    [ShardCount]sync.Mutex
+	mu       sync.RWMutex
+	seq      uint64
+	watchers map[*watcher]struct{}
+}
+
+// NewWatched wraps the specified store so that its changes can be watched.
+func NewWatched(store speedmap.Store) (watched *Watched, err error) {
+	watched = &Watched{Store: store}
+	watched.watchers = make(map[*watcher]struct{})
+	return watched, nil
+}
+
+// Put the value to the wrapped store and notify watchers of the key.
+func (s *Watched) Put(key string, value []byte) (err error) {
+	lock := &s.locks[shardIndex(key)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err = s.Store.Put(key, value); err != nil {
+		return err
+	}
+
+	s.publish(speedmap.EventPut, key, value)
+	return nil
+}
+
+// PutWithTTL puts the value to the wrapped store, which must be a
+// speedmap.Expirer, and notifies watchers of the key. No event is published
+// when the value expires.
+func (s *Watched) PutWithTTL(key string, value []byte, ttl time.Duration) (err error) {
+	expirer, ok := s.Store.(speedmap.Expirer)
+	if !ok {
+		return fmt.Errorf("the %s store does not support expiration", s.Store)
+	}
+
+	lock := &s.locks[shardIndex(key)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err = expirer.PutWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+
+	s.publish(speedmap.EventPut, key, value)
+	return nil
+}
+
+// Delete the key from the wrapped store and notify watchers of the key if
+// the key was in the store to begin with.
+func (s *Watched) Delete(key string) (err error) {
+	lock := &s.locks[shardIndex(key)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	_, missing := s.Store.Get(key)
+	if err = s.Store.Delete(key); err != nil {
+		return err
+	}
+
+	if missing == nil {
+		s.publish(speedmap.EventDelete, key, nil)
+	}
+	return nil
+}
+
+// GetOrCreate calls GetOrCreate on the wrapped store and notifies watchers of
+// the key if the default value was stored.
+func (s *Watched) GetOrCreate(key string, value []byte) (actual []byte, created bool) {
+	lock := &s.locks[shardIndex(key)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	if actual, created = s.Store.GetOrCreate(key, value); created {
+		s.publish(speedmap.EventPut, key, actual)
+	}
+	return actual, created
+}
+
+// Range calls fn for every key/value pair in the wrapped store, returning an
+// error if the wrapped store is not speedmap.Iterable.
+func (s *Watched) Range(fn func(key string, value []byte) bool) error {
+	return speedmap.Range(s.Store, fn)
+}
+
+// Watch returns a watcher that receives the changes to the key, or to every
+// key with the specified prefix if prefix is true.
+func (s *Watched) Watch(key string, prefix bool) (speedmap.Watcher, error) {
+	w := &watcher{
+		parent: s,
+		key:    key,
+		prefix: prefix,
+		events: make(chan *speedmap.Event, speedmap.WatchBuffer),
+	}
+
+	s.mu.Lock()
+	s.watchers[w] = struct{}{}
+	s.mu.Unlock()
+	return w, nil
+}
+
+// Close the wrapped store if it is an io.Closer, e.g. to flush durable state.
+func (s *Watched) Close() error {
+	if closer, ok := s.Store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// String returns a string representation of the wrapped store.
+func (s *Watched) String() string {
+	return s.Store.String() + " watched"
+}
+
+// Sends the event to every watcher that matches the key. Must be called while
+// holding the lock of the key so that events for the key are ordered.
+func (s *Watched) publish(etype speedmap.EventType, key string, value []byte) {
+	event := &speedmap.Event{Type: etype, Key: key, Value: value}
+	event.Version = atomic.AddUint64(&s.seq, 1)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for w := range s.watchers {
+		if w.matches(key) {
+			w.send(event)
+		}
+	}
+}
+
+// Removes the watcher from the store. Must not be called while holding mu.
+func (s *Watched) remove(w *watcher) {
+	s.mu.Lock()
+	delete(s.watchers, w)
+	s.mu.Unlock()
+}
+
+// Implements speedmap.Watcher for the Watched store.
+type watcher struct {
+	sync.Mutex
+	parent *Watched
+	key    string
+	prefix bool
+	events chan *speedmap.Event
+	closed bool
+	err    error
+}
+
+// Events returns the channel that change events are delivered on.
+func (w *watcher) Events() <-chan *speedmap.Event {
+	return w.events
+}
+
+// Err returns the reason the watcher was closed by the store, if any.
+func (w *watcher) Err() error {
+	w.Lock()
+	defer w.Unlock()
+	return w.err
+}
+
+// Close the watcher and stop receiving events.
+func (w *watcher) Close() {
+	w.parent.remove(w)
+
+	w.Lock()
+	defer w.Unlock()
+	if !w.closed {
+		w.closed = true
+		close(w.events)
+	}
+}
+
+// Returns true if the watcher is interested in changes to the key.
+func (w *watcher) matches(key string) bool {
+	if w.prefix {
+		return strings.HasPrefix(key, w.key)
+	}
+	return key == w.key
+}
+
+// Delivers the event without blocking, closing the watcher if it is full.
+func (w *watcher) send(event *speedmap.Event) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.events <- event:
+	default:
+		w.closed = true
+		w.err = speedmap.ErrSlowConsumer
+		close(w.events)
+		go w.parent.remove(w)
+	}
+}
@@ -0,0 +1,130 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/store"
+)
+
+var _ = Describe("Watched", func() {
+
+	var (
+		err   error
+		store *Watched
+	)
+
+	BeforeEach(func() {
+		var shard Shard
+		shard, err = NewShard()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		store, err = NewWatched(shard)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should be a watchable store", func() {
+		var kv speedmap.Store = store
+		_, ok := kv.(speedmap.Watchable)
+		Ω(ok).Should(BeTrue())
+	})
+
+	It("should notify watchers of changes to a key", func() {
+		watcher, err := store.Watch("foo", false)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer watcher.Close()
+
+		Ω(store.Put("foo", []byte("bar"))).Should(Succeed())
+		Ω(store.Put("food", []byte("bar"))).Should(Succeed())
+		Ω(store.Delete("foo")).Should(Succeed())
+		Ω(store.Delete("foo")).Should(Succeed())
+		store.GetOrCreate("foo", []byte("baz"))
+		store.GetOrCreate("foo", []byte("zap"))
+
+		var event *speedmap.Event
+		Eventually(watcher.Events()).Should(Receive(&event))
+		Ω(event.Type).Should(Equal(speedmap.EventPut))
+		Ω(event.Key).Should(Equal("foo"))
+		Ω(event.Value).Should(Equal([]byte("bar")))
+		version := event.Version
+
+		Eventually(watcher.Events()).Should(Receive(&event))
+		Ω(event.Type).Should(Equal(speedmap.EventDelete))
+		Ω(event.Version).Should(BeNumerically(">", version))
+
+		Eventually(watcher.Events()).Should(Receive(&event))
+		Ω(event.Type).Should(Equal(speedmap.EventPut))
+		Ω(event.Value).Should(Equal([]byte("baz")))
+
+		Consistently(watcher.Events()).ShouldNot(Receive())
+	})
+
+	It("should notify watchers of changes to a prefix", func() {
+		watcher, err := store.Watch("conf/", true)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(store.Put("conf/a", []byte("1"))).Should(Succeed())
+		Ω(store.Put("other", []byte("2"))).Should(Succeed())
+		Ω(store.Put("conf/b", []byte("3"))).Should(Succeed())
+
+		var event *speedmap.Event
+		Eventually(watcher.Events()).Should(Receive(&event))
+		Ω(event.Key).Should(Equal("conf/a"))
+		Eventually(watcher.Events()).Should(Receive(&event))
+		Ω(event.Key).Should(Equal("conf/b"))
+
+		watcher.Close()
+		Eventually(watcher.Events()).Should(BeClosed())
+		Ω(watcher.Err()).ShouldNot(HaveOccurred())
+	})
+
+	It("should put values with a ttl to an expiring store", func() {
+		var kv speedmap.Store = store
+		_, ok := kv.(speedmap.Expirer)
+		Ω(ok).Should(BeTrue())
+		Ω(store.PutWithTTL("foo", []byte("bar"), time.Minute)).ShouldNot(Succeed())
+
+		expiring, err := NewExpiring(0)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer expiring.Close()
+
+		store, err = NewWatched(expiring)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		watcher, err := store.Watch("foo", false)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer watcher.Close()
+
+		Ω(store.PutWithTTL("foo", []byte("bar"), 20*time.Millisecond)).Should(Succeed())
+
+		var event *speedmap.Event
+		Eventually(watcher.Events()).Should(Receive(&event))
+		Ω(event.Type).Should(Equal(speedmap.EventPut))
+		Ω(event.Value).Should(Equal([]byte("bar")))
+
+		time.Sleep(30 * time.Millisecond)
+		_, err = store.Get("foo")
+		Ω(errors.Is(err, speedmap.ErrNotFound)).Should(BeTrue())
+	})
+
+	It("should close slow consumers without blocking writers", func() {
+		watcher, err := store.Watch("", true)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		for i := 0; i < speedmap.WatchBuffer*2; i++ {
+			Ω(store.Put(fmt.Sprintf("%X", i), []byte("foo"))).Should(Succeed())
+		}
+
+		for i := 0; i < speedmap.WatchBuffer; i++ {
+			Eventually(watcher.Events()).Should(Receive())
+		}
+		Eventually(watcher.Events()).Should(BeClosed())
+		Ω(errors.Is(watcher.Err(), speedmap.ErrSlowConsumer)).Should(BeTrue())
+	})
+
+})
@@ -0,0 +1,10 @@
+package speedmap
+
+import "time"
+
+// Expirer is an optional interface for stores that can expire values. A value
+// put with a TTL is no longer returned by the store once the TTL has elapsed;
+// a TTL of zero or less means that the value never expires.
+type Expirer interface {
+	PutWithTTL(key string, value []byte, ttl time.Duration) (err error)
+}
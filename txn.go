@@ -0,0 +1,23 @@
+package speedmap
+
+// Transactional is an optional interface for stores that support atomic
+// multi-key transactions. Transactions are isolated using optimistic
+// concurrency control: reads and writes are buffered in the transaction and
+// only validated and applied when the transaction is committed.
+type Transactional interface {
+	Begin() Txn
+}
+
+// Txn is a transaction created by a Transactional store. Reads observe the
+// writes made earlier in the same transaction. Commit returns an error
+// wrapping ErrConflict if any key read by the transaction was modified by
+// another transaction after it was read, in which case none of the writes
+// are applied and the transaction may be retried from the beginning. A Txn
+// is not safe for concurrent use by multiple go routines.
+type Txn interface {
+	Get(key string) (value []byte, err error)
+	Put(key string, value []byte) (err error)
+	Delete(key string) (err error)
+	Commit() (err error)
+	Rollback()
+}
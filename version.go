@@ -0,0 +1,17 @@
+package speedmap
+
+// Versioner is an optional interface for stores that associate a version with
+// every value, where the version is a sequence number that increases with
+// every write to the store.
+type Versioner interface {
+	GetVersion(key string) (value []byte, version uint64, err error)
+}
+
+// Swapper is an optional interface for versioned stores that can replace the
+// value of a key only if it has not been written since a version was read,
+// e.g. to implement optimistic updates of a single key without a transaction.
+// CompareAndSwap returns false if the version of the key is not vers, and an
+// error wrapping ErrNotFound if the key is not in the store.
+type Swapper interface {
+	CompareAndSwap(key string, vers uint64, value []byte) (swapped bool, err error)
+}
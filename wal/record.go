@@ -0,0 +1,105 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Op identifies the store operation that a log record describes.
+type Op uint8
+
+// The operations that are recorded in the log.
+const (
+	OpPut Op = iota + 1
+	OpDelete
+)
+
+// String returns a human readable representation of the operation.
+func (o Op) String() string {
+	switch o {
+	case OpPut:
+		return "put"
+	case OpDelete:
+		return "delete"
+	default:
+		return fmt.Sprintf("op(%d)", uint8(o))
+	}
+}
+
+// Each record is framed by a header holding the CRC of the record body
+// followed by the length of the body. The body is the operation, the length
+// of the key, the key, and (the remainder of the body) the value.
+const (
+	headerSize    = 4 + 4
+	bodyHeader    = 1 + 2
+	maxRecordSize = 1 << 30
+)
+
+// ErrRecordTooLarge is returned by Append for records whose body is larger than
+// the maximum record size of 1 GiB.
+var ErrRecordTooLarge = errors.New("record exceeds maximum record size")
+
+// The CRC table used to checksum record bodies.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Appends the encoded record to the buffer and returns the extended buffer.
+func appendRecord(buf []byte, op Op, key string, value []byte) []byte {
+	size := bodyHeader + len(key) + len(value)
+
+	start := len(buf)
+	buf = append(buf, make([]byte, headerSize+size)...)
+
+	body := buf[start+headerSize:]
+	body[0] = byte(op)
+	binary.LittleEndian.PutUint16(body[1:3], uint16(len(key)))
+	copy(body[bodyHeader:], key)
+	copy(body[bodyHeader+len(key):], value)
+
+	binary.LittleEndian.PutUint32(buf[start:start+4], crc32.Checksum(body, crcTable))
+	binary.LittleEndian.PutUint32(buf[start+4:start+8], uint32(size))
+	return buf
+}
+
+// Reads the next record from the reader, returning the number of bytes read.
+// Returns io.EOF if there are no more records, or an error wrapping
+// speedmap.ErrCorrupt if the record is truncated or fails its checksum.
+func readRecord(r io.Reader) (op Op, key string, value []byte, n int, err error) {
+	var header [headerSize]byte
+	if n, err = io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return 0, "", nil, 0, io.EOF
+		}
+		return 0, "", nil, n, fmt.Errorf("%w: truncated record header", speedmap.ErrCorrupt)
+	}
+
+	size := int(binary.LittleEndian.Uint32(header[4:8]))
+	if size < bodyHeader || size > maxRecordSize {
+		return 0, "", nil, n, fmt.Errorf("%w: invalid record length %d", speedmap.ErrCorrupt, size)
+	}
+
+	body := make([]byte, size)
+	m, err := io.ReadFull(r, body)
+	n += m
+	if err != nil {
+		return 0, "", nil, n, fmt.Errorf("%w: truncated record body", speedmap.ErrCorrupt)
+	}
+
+	if crc32.Checksum(body, crcTable) != binary.LittleEndian.Uint32(header[0:4]) {
+		return 0, "", nil, n, fmt.Errorf("%w: record checksum mismatch", speedmap.ErrCorrupt)
+	}
+
+	klen := int(binary.LittleEndian.Uint16(body[1:3]))
+	if bodyHeader+klen > size {
+		return 0, "", nil, n, fmt.Errorf("%w: invalid key length %d", speedmap.ErrCorrupt, klen)
+	}
+
+	op = Op(body[0])
+	key = string(body[bodyHeader : bodyHeader+klen])
+	value = body[bodyHeader+klen:]
+	return op, key, value, n, nil
+}
@@ -0,0 +1,416 @@
+/*
+Package wal implements a segmented write-ahead log of store operations that
+can be replayed to recover the state of a store after a restart.
+*/
+package wal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Defaults used when the corresponding option is zero.
+const (
+	DefaultSyncInterval = 10 * time.Millisecond
+	DefaultSegmentSize  = 64 * 1024 * 1024
+)
+
+// Extension of the log segment files.
+const segmentExt = ".wal"
+
+// SyncPolicy determines when appended records are flushed to stable storage.
+type SyncPolicy uint8
+
+// The sync policies supported by the log.
+const (
+	SyncAlways   SyncPolicy = iota // sync before acknowledging every append
+	SyncInterval                   // sync in the background every interval
+	SyncNever                      // leave flushing to the operating system
+)
+
+// Options configure the durability and segmentation of the log.
+type Options struct {
+	Sync        SyncPolicy    // when to flush appended records to disk
+	Interval    time.Duration // how often to sync with SyncInterval
+	SegmentSize int64         // size in bytes after which a new segment is started
+}
+
+// ParseSync parses a sync policy: "always", "never", or a duration such as
+// "10ms" to sync on that interval.
+func ParseSync(s string) (opts Options, err error) {
+	switch strings.ToLower(s) {
+	case "always":
+		opts.Sync = SyncAlways
+	case "never":
+		opts.Sync = SyncNever
+	default:
+		if opts.Interval, err = time.ParseDuration(s); err != nil || opts.Interval <= 0 {
+			return opts, fmt.Errorf("could not parse sync policy '%s', use always, never or an interval", s)
+		}
+		opts.Sync = SyncInterval
+	}
+	return opts, nil
+}
+
+// Log is a segmented write-ahead log. Records are appended to the current
+// segment file until it exceeds the segment size, at which point a new
+// segment is started. Each record is checksummed so that a record torn by a
+// crash can be detected and discarded when the log is replayed.
+//
+// Concurrent appends are group committed: records appended while a write is
+// in progress are buffered, and the next write flushes (and syncs, with
+// SyncAlways) all of them at once, so that the cost of a sync is shared by
+// all of the writers waiting on it.
+type Log struct {
+	dir  string
+	opts Options
+
+	mu       sync.Mutex // protects the pending group commit
+	cond     *sync.Cond // signalled when a group has been written
+	buf      []byte     // records waiting to be written
+	spare    []byte     // buffer to reuse for the next group
+	pending  *group     // the group the buffered records belong to
+	flushing bool       // whether a leader is writing groups
+	closed   bool
+
+	fmu   sync.Mutex // protects the segment file
+	file  *os.File
+	index uint64 // the index of the current segment
+	size  int64  // the size of the current segment
+	dirty bool   // written since the last sync
+	err   error  // sticky error from a background sync
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// A group of records that are committed together along with the error of
+// writing the group, if any, once it has been written.
+type group struct {
+	written bool
+	err     error
+}
+
+// Open the log in the specified directory, creating it if necessary. New
+// records are appended to the last segment in the directory. Replay should be
+// called to recover the records in the log before any records are appended.
+func Open(dir string, opts Options) (log *Log, err error) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultSyncInterval
+	}
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	log = &Log{dir: dir, opts: opts, pending: newGroup(), done: make(chan struct{})}
+	log.cond = sync.NewCond(&log.mu)
+
+	var segments []uint64
+	if segments, err = log.segments(); err != nil {
+		return nil, err
+	}
+
+	index := uint64(1)
+	if len(segments) > 0 {
+		index = segments[len(segments)-1]
+	}
+
+	if err = log.openSegment(index); err != nil {
+		return nil, err
+	}
+
+	if opts.Sync == SyncInterval {
+		log.wg.Add(1)
+		go log.syncer()
+	}
+	return log, nil
+}
+
+// Replay calls the function with every record in the log in the order they
+// were appended. If the last record of the last segment is torn or corrupt,
+// as happens when the process crashes during a write, the segment is
+// truncated to remove it along with anything after it. Corruption in any
+// other segment returns an error wrapping speedmap.ErrCorrupt.
+func (l *Log) Replay(fn func(op Op, key string, value []byte) error) (err error) {
+	var segments []uint64
+	if segments, err = l.segments(); err != nil {
+		return err
+	}
+
+	for i, index := range segments {
+		var offset int64
+		if offset, err = l.replaySegment(index, fn); err != nil {
+			if !errors.Is(err, speedmap.ErrCorrupt) || i < len(segments)-1 {
+				return fmt.Errorf("segment %d: %w", index, err)
+			}
+
+			// Discard the torn record at the end of the log
+			l.fmu.Lock()
+			if index == l.index {
+				if err = l.file.Truncate(offset); err == nil {
+					l.size = offset
+				}
+			}
+			l.fmu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// Append a record to the log, returning once it has been written according
+// to the sync policy of the log. If another append is being written, the
+// record is buffered and written with the next group by the first of the
+// appenders waiting on that group to become the leader. Records with keys
+// larger than speedmap.MaxKeySize or that are larger than the maximum record
+// size are rejected, since they could not be replayed.
+func (l *Log) Append(op Op, key string, value []byte) error {
+	if len(key) > speedmap.MaxKeySize {
+		return fmt.Errorf("%w (%d > %d bytes)", speedmap.ErrKeyTooLarge, len(key), speedmap.MaxKeySize)
+	}
+
+	if size := bodyHeader + len(key) + len(value); size > maxRecordSize {
+		return fmt.Errorf("%w (%d > %d bytes)", ErrRecordTooLarge, size, maxRecordSize)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return fmt.Errorf("%w: write-ahead log", speedmap.ErrClosed)
+	}
+
+	l.buf = appendRecord(l.buf, op, key, value)
+	g := l.pending
+
+	for !g.written {
+		if l.flushing {
+			l.cond.Wait()
+			continue
+		}
+
+		l.flushing = true
+		l.flush()
+	}
+	return g.err
+}
+
+// Sync flushes all written records to stable storage.
+func (l *Log) Sync() error {
+	l.fmu.Lock()
+	defer l.fmu.Unlock()
+	return l.sync()
+}
+
+// Close the log, waiting for pending records to be written and syncing them.
+func (l *Log) Close() (err error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+
+	l.closed = true
+	for l.flushing || len(l.buf) > 0 {
+		l.cond.Wait()
+	}
+	l.mu.Unlock()
+
+	close(l.done)
+	l.wg.Wait()
+
+	l.fmu.Lock()
+	defer l.fmu.Unlock()
+	if err = l.sync(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// String returns a representation of the sync policy of the log.
+func (l *Log) String() string {
+	switch l.opts.Sync {
+	case SyncAlways:
+		return "wal always"
+	case SyncNever:
+		return "wal never"
+	default:
+		return "wal " + l.opts.Interval.String()
+	}
+}
+
+// Writes the pending group to the segment file and wakes up the appenders
+// waiting on it. Must be called by the leader while holding mu, which is
+// released while writing so that the next group can be buffered.
+func (l *Log) flush() {
+	buf, g := l.buf, l.pending
+	l.buf, l.spare = l.spare[:0], nil
+	l.pending = newGroup()
+	l.mu.Unlock()
+
+	err := l.write(buf)
+
+	l.mu.Lock()
+	l.spare = buf
+	g.err, g.written = err, true
+	l.flushing = false
+	l.cond.Broadcast()
+}
+
+// Writes the records to the current segment, starting a new segment first if
+// the current one is full, and syncs them if required by the sync policy.
+func (l *Log) write(records []byte) (err error) {
+	l.fmu.Lock()
+	defer l.fmu.Unlock()
+
+	if l.err != nil {
+		return l.err
+	}
+
+	if l.size >= l.opts.SegmentSize {
+		if err = l.sync(); err != nil {
+			return err
+		}
+		if err = l.file.Close(); err != nil {
+			return err
+		}
+		if err = l.openSegment(l.index + 1); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(records)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	l.dirty = true
+	if l.opts.Sync == SyncAlways {
+		return l.sync()
+	}
+	return nil
+}
+
+// Syncs the current segment if it has been written to. Must hold fmu.
+func (l *Log) sync() error {
+	if !l.dirty {
+		return nil
+	}
+
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.dirty = false
+	return nil
+}
+
+// Runs in its own go routine, syncing the log every interval until closed.
+// Errors are reported by subsequent appends, since the records that were not
+// synced may have been lost.
+func (l *Log) syncer() {
+	defer l.wg.Done()
+	ticker := time.NewTicker(l.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			l.fmu.Lock()
+			if err := l.sync(); err != nil && l.err == nil {
+				l.err = err
+			}
+			l.fmu.Unlock()
+		}
+	}
+}
+
+// Opens the segment with the specified index for appending. Must hold fmu or
+// be called before the log is used.
+func (l *Log) openSegment(index uint64) (err error) {
+	var file *os.File
+	if file, err = os.OpenFile(l.segmentPath(index), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		return err
+	}
+
+	var info os.FileInfo
+	if info, err = file.Stat(); err != nil {
+		file.Close()
+		return err
+	}
+
+	l.file, l.index, l.size = file, index, info.Size()
+	return nil
+}
+
+// Reads every record in the segment, returning the offset of the end of the
+// last valid record.
+func (l *Log) replaySegment(index uint64, fn func(op Op, key string, value []byte) error) (offset int64, err error) {
+	var file *os.File
+	if file, err = os.Open(l.segmentPath(index)); err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		op, key, value, n, err := readRecord(reader)
+		if err == io.EOF {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+
+		if err = fn(op, key, value); err != nil {
+			return offset, err
+		}
+		offset += int64(n)
+	}
+}
+
+// Returns the indices of the segments in the log directory in order.
+func (l *Log) segments() (indices []uint64, err error) {
+	var paths []string
+	if paths, err = filepath.Glob(filepath.Join(l.dir, "*"+segmentExt)); err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), segmentExt)
+		if index, err := strconv.ParseUint(name, 10, 64); err == nil {
+			indices = append(indices, index)
+		}
+	}
+
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	return indices, nil
+}
+
+// Returns the path of the segment with the specified index.
+func (l *Log) segmentPath(index uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%020d%s", index, segmentExt))
+}
+
+// Creates a new, uncommitted group.
+func newGroup() *group {
+	return &group{}
+}
@@ -0,0 +1,230 @@
+package wal_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	. "github.com/bbengfort/speedmap/wal"
+)
+
+// A record read from the log during replay.
+type record struct {
+	op    Op
+	key   string
+	value string
+}
+
+// Replays the log and returns all of its records.
+func replay(log *Log) ([]record, error) {
+	records := make([]record, 0)
+	err := log.Replay(func(op Op, key string, value []byte) error {
+		records = append(records, record{op, key, string(value)})
+		return nil
+	})
+	return records, err
+}
+
+var _ = Describe("WAL", func() {
+
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "speedmap-wal")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	It("should parse sync policies", func() {
+		opts, err := ParseSync("always")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(opts.Sync).Should(Equal(SyncAlways))
+
+		opts, err = ParseSync("never")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(opts.Sync).Should(Equal(SyncNever))
+
+		opts, err = ParseSync("50ms")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(opts.Sync).Should(Equal(SyncInterval))
+		Ω(opts.Interval).Should(Equal(50 * time.Millisecond))
+
+		_, err = ParseSync("sometimes")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	for _, policy := range []string{"always", "10ms", "never"} {
+		policy := policy
+
+		It(fmt.Sprintf("should replay appended records with sync %s", policy), func() {
+			opts, err := ParseSync(policy)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			log, err := Open(dir, opts)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(log.String()).Should(Equal("wal " + policy))
+
+			Ω(log.Append(OpPut, "foo", []byte("bar"))).Should(Succeed())
+			Ω(log.Append(OpPut, "baz", []byte("qux"))).Should(Succeed())
+			Ω(log.Append(OpDelete, "foo", nil)).Should(Succeed())
+			Ω(log.Close()).Should(Succeed())
+
+			err = log.Append(OpPut, "foo", []byte("bar"))
+			Ω(errors.Is(err, speedmap.ErrClosed)).Should(BeTrue())
+
+			log, err = Open(dir, opts)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer log.Close()
+
+			records, err := replay(log)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(records).Should(Equal([]record{
+				{OpPut, "foo", "bar"}, {OpPut, "baz", "qux"}, {OpDelete, "foo", ""},
+			}))
+		})
+	}
+
+	It("should reject records that could not be replayed", func() {
+		log, err := Open(dir, Options{Sync: SyncAlways})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		err = log.Append(OpDelete, strings.Repeat("a", speedmap.MaxKeySize+1), nil)
+		Ω(errors.Is(err, speedmap.ErrKeyTooLarge)).Should(BeTrue())
+
+		err = log.Append(OpPut, "foo", make([]byte, 1<<30))
+		Ω(errors.Is(err, ErrRecordTooLarge)).Should(BeTrue())
+
+		// Later appends are not lost when the log is replayed
+		Ω(log.Append(OpPut, "foo", []byte("bar"))).Should(Succeed())
+		Ω(log.Close()).Should(Succeed())
+
+		log, err = Open(dir, Options{Sync: SyncAlways})
+		Ω(err).ShouldNot(HaveOccurred())
+		defer log.Close()
+
+		records, err := replay(log)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(records).Should(Equal([]record{{OpPut, "foo", "bar"}}))
+	})
+
+	It("should group commit concurrent appends", func() {
+		log, err := Open(dir, Options{Sync: SyncAlways})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		group := &sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			group.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer group.Done()
+				for j := 0; j < 100; j++ {
+					Ω(log.Append(OpPut, fmt.Sprintf("%d-%d", i, j), []byte("value"))).Should(Succeed())
+				}
+			}(i)
+		}
+		group.Wait()
+		Ω(log.Close()).Should(Succeed())
+
+		log, err = Open(dir, Options{})
+		Ω(err).ShouldNot(HaveOccurred())
+		defer log.Close()
+
+		records, err := replay(log)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(records).Should(HaveLen(800))
+	})
+
+	It("should roll over to new segments", func() {
+		log, err := Open(dir, Options{Sync: SyncNever, SegmentSize: 1024})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		for i := 0; i < 1000; i++ {
+			Ω(log.Append(OpPut, fmt.Sprintf("%04d", i), []byte("value"))).Should(Succeed())
+		}
+		Ω(log.Close()).Should(Succeed())
+
+		segments, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(len(segments)).Should(BeNumerically(">", 10))
+
+		log, err = Open(dir, Options{Sync: SyncNever, SegmentSize: 1024})
+		Ω(err).ShouldNot(HaveOccurred())
+		defer log.Close()
+
+		records, err := replay(log)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(records).Should(HaveLen(1000))
+		for i, rec := range records {
+			Ω(rec.key).Should(Equal(fmt.Sprintf("%04d", i)))
+		}
+	})
+
+	It("should discard a torn record at the end of the log", func() {
+		log, err := Open(dir, Options{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(log.Append(OpPut, "foo", []byte("bar"))).Should(Succeed())
+		Ω(log.Close()).Should(Succeed())
+
+		// Simulate a crash in the middle of writing a record
+		segments, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+		Ω(segments).Should(HaveLen(1))
+		f, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0644)
+		Ω(err).ShouldNot(HaveOccurred())
+		f.Write([]byte{0x01, 0x02, 0x03, 0x04, 0x20, 0x00})
+		f.Close()
+
+		log, err = Open(dir, Options{})
+		Ω(err).ShouldNot(HaveOccurred())
+		records, err := replay(log)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(records).Should(Equal([]record{{OpPut, "foo", "bar"}}))
+
+		Ω(log.Append(OpPut, "baz", []byte("qux"))).Should(Succeed())
+		Ω(log.Close()).Should(Succeed())
+
+		log, err = Open(dir, Options{})
+		Ω(err).ShouldNot(HaveOccurred())
+		defer log.Close()
+
+		records, err = replay(log)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(records).Should(Equal([]record{{OpPut, "foo", "bar"}, {OpPut, "baz", "qux"}}))
+	})
+
+	It("should detect corruption before the end of the log", func() {
+		log, err := Open(dir, Options{SegmentSize: 64})
+		Ω(err).ShouldNot(HaveOccurred())
+		for i := 0; i < 10; i++ {
+			Ω(log.Append(OpPut, fmt.Sprintf("%04d", i), []byte("some value"))).Should(Succeed())
+		}
+		Ω(log.Close()).Should(Succeed())
+
+		// Flip a byte in the first segment
+		segments, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+		data, err := ioutil.ReadFile(segments[0])
+		Ω(err).ShouldNot(HaveOccurred())
+		data[len(data)-1] ^= 0xff
+		Ω(ioutil.WriteFile(segments[0], data, 0644)).Should(Succeed())
+
+		log, err = Open(dir, Options{SegmentSize: 64})
+		Ω(err).ShouldNot(HaveOccurred())
+		defer log.Close()
+
+		_, err = replay(log)
+		Ω(errors.Is(err, speedmap.ErrCorrupt)).Should(BeTrue())
+	})
+
+})
@@ -0,0 +1,52 @@
+package speedmap
+
+// WatchBuffer is the number of events buffered for each watcher. If a watcher
+// falls this far behind the changes to the store it is closed, so that a slow
+// consumer can never block writes to the store.
+const WatchBuffer = 256
+
+// EventType describes the kind of change to a key in a watch event.
+type EventType uint8
+
+// Event types delivered to watchers.
+const (
+	EventPut EventType = iota + 1
+	EventDelete
+)
+
+// String returns a human readable representation of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventPut:
+		return "put"
+	case EventDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a change to a key in the store. The version is the sequence
+// number of the change in the store's change feed; it increases with every
+// change, so a watcher will always see increasing versions for a single key.
+type Event struct {
+	Type    EventType
+	Key     string
+	Value   []byte
+	Version uint64
+}
+
+// Watchable is an optional interface for stores that can notify subscribers
+// of changes to a key or, if prefix is true, to all keys with the prefix.
+type Watchable interface {
+	Watch(key string, prefix bool) (watcher Watcher, err error)
+}
+
+// Watcher delivers change events from a Watchable store. The events channel
+// is closed when the watcher is closed, either by calling Close or because
+// the watcher fell behind, in which case Err returns ErrSlowConsumer.
+type Watcher interface {
+	Events() <-chan *Event
+	Err() error
+	Close()
+}
@@ -16,9 +16,23 @@ func NewConflict(prob, readratio float32) *Conflict {
 		prob:      prob,
 		keys:      MaxKeys,
 		size:      DataSize,
+		batch:     1,
 	}
 }
 
+// NewBatchConflict creates a conflict workload where each client operation
+// gets or puts the specified number of keys at once using MultiGet or
+// MultiPut. Each key in the batch conflicts with the specified probability.
+func NewBatchConflict(prob, readratio float32, batch int) *Conflict {
+	if batch < 1 {
+		batch = 1
+	}
+
+	conflict := NewConflict(prob, readratio)
+	conflict.batch = batch
+	return conflict
+}
+
 // Conflict allocates a key range to each thread, along with a probability
 // that the thread will access a key being accessed by a different thread.
 // A 0% probability means that the clients will access a disjoint key set.
@@ -27,6 +41,7 @@ type Conflict struct {
 	prob      float32 // probability of conflict
 	keys      int64   // the number of keys (each key identified by number)
 	size      int     // the size of the value to write
+	batch     int     // the number of keys accessed per operation
 }
 
 // Run the conflict workload for the specified number of clients.
@@ -38,7 +53,11 @@ func (c *Conflict) Run(store speedmap.Store, clients int) (*speedmap.Result, err
 
 	start := time.Now()
 	for i := 1; i <= clients; i++ {
-		go c.client(i, store, group)
+		if c.batch > 1 {
+			go c.batchClient(i, store, group)
+		} else {
+			go c.client(i, store, group)
+		}
 	}
 	group.Wait()
 	result.Duration = time.Since(start)
@@ -106,17 +125,61 @@ func (c *Conflict) complexClient(i int, store speedmap.Store, group *sync.WaitGr
 	group.Done()
 }
 
+// Runs the ith client in a go routine like client, but accesses the store in
+// batches of keys rather than one key at a time. Each key in a batch counts
+// as a single operation so that throughput is comparable to non-batched runs.
+func (c *Conflict) batchClient(i int, store speedmap.Store, group *sync.WaitGroup) {
+	r := int64(i)
+	keys := make([]string, 0, c.batch)
+
+	for o := 0; o < OpsPerThread; o += c.batch {
+		n := c.batch
+		if o+n > OpsPerThread {
+			n = OpsPerThread - o
+		}
+
+		keys = keys[:0]
+		for k := 0; k < n; k++ {
+			if rand.Float32() < c.prob {
+				// We have a conflict select any key in the shared key group
+				keys = append(keys, RandomKey(0, c.keys))
+			} else {
+				// Select a key in the clients own keyspace
+				keys = append(keys, RandomKey(r, c.keys))
+			}
+		}
+
+		if rand.Float32() <= c.readratio {
+			// Get a batch of keys
+			speedmap.MultiGet(store, keys)
+		} else {
+			// Put a batch of keys
+			pairs := make(map[string][]byte, n)
+			for k, key := range keys {
+				pairs[key] = []byte(fmt.Sprintf("%X-%X", r, o+k))
+			}
+			speedmap.MultiPut(store, pairs)
+		}
+	}
+	group.Done()
+}
+
 // String returns a representation of the conflict workload
 func (c *Conflict) String() string {
 	prob := c.prob * 100
 
-	if c.readratio == 1.0 {
-		return fmt.Sprintf("%0.0f%% conflict read-only", prob)
+	var desc string
+	switch c.readratio {
+	case 1.0:
+		desc = fmt.Sprintf("%0.0f%% conflict read-only", prob)
+	case 0.0:
+		desc = fmt.Sprintf("%0.0f%% conflict write-only", prob)
+	default:
+		desc = fmt.Sprintf("%0.0f%% conflict %0.0f%% reads", prob, c.readratio*100)
 	}
 
-	if c.readratio == 0.0 {
-		return fmt.Sprintf("%0.0f%% conflict write-only", prob)
+	if c.batch > 1 {
+		return fmt.Sprintf("%s batch %d", desc, c.batch)
 	}
-
-	return fmt.Sprintf("%0.0f%% conflict %0.0f%% reads", prob, c.readratio*100)
+	return desc
 }
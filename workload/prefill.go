@@ -0,0 +1,24 @@
+package workload
+
+import (
+	"fmt"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// Prefill puts the specified number of keys into the store before a workload
+// is run, so that the workload is measured against a large store. The keys
+// are prefixed so that they are disjoint from the keys used by the workloads.
+func Prefill(store speedmap.Store, keys int) (err error) {
+	val, err := GenerateRandomBytes(DataSize)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < keys; i++ {
+		if err = store.Put(fmt.Sprintf("prefill-%X", i), val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
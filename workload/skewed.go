@@ -0,0 +1,113 @@
+package workload
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// SkewedKeys is the number of keys accessed by the skewed workload, which is
+// larger than MaxKeys so that bounded stores cannot hold every key.
+const SkewedKeys = MaxKeys * 10
+
+// NewSkewed workload with the specified Zipf exponent, which must be greater
+// than 1; the larger the exponent, the more the accesses favor popular keys.
+func NewSkewed(skew float64, readratio float32) *Skewed {
+	if skew <= 1.0 {
+		skew = 1.01
+	}
+
+	return &Skewed{
+		readratio: readratio,
+		skew:      skew,
+		keys:      SkewedKeys,
+		size:      DataSize,
+	}
+}
+
+// Skewed is a cache workload where all clients share a key space whose keys
+// are accessed according to a Zipf distribution, so that a few keys are very
+// popular and most keys are rarely accessed. Reads that miss fill the store
+// with a Put, as an application would fill a cache from a backing store, and
+// the hits and misses of reads are reported in the result. If the store is
+// speedmap.Bounded, the evictions during the run are reported as well.
+type Skewed struct {
+	readratio float32 // ratio of reads to writes
+	skew      float64 // the exponent of the Zipf distribution
+	keys      uint64  // the number of keys (each key identified by number)
+	size      int     // the size of the value to write
+}
+
+// Run the skewed workload for the specified number of clients.
+func (w *Skewed) Run(store speedmap.Store, clients int) (*speedmap.Result, error) {
+	result := &speedmap.Result{Store: store, Workload: w, Concurrency: clients}
+
+	bounded, isBounded := speedmap.Unwrap(store).(speedmap.Bounded)
+	var before speedmap.CacheStats
+	if isBounded {
+		before = bounded.Stats()
+	}
+
+	group := &sync.WaitGroup{}
+	group.Add(clients)
+
+	start := time.Now()
+	for i := 1; i <= clients; i++ {
+		go w.client(i, store, group, result)
+	}
+	group.Wait()
+	result.Duration = time.Since(start)
+	result.Operations = uint64(clients) * uint64(OpsPerThread)
+
+	if isBounded {
+		result.Evictions = bounded.Stats().Evictions - before.Evictions
+	}
+	return result, nil
+}
+
+// Runs the ith client in a go routine with its own source of randomness since
+// the Zipf generator is not safe for concurrent use, counting the hits and
+// misses of its reads in the result.
+func (w *Skewed) client(i int, store speedmap.Store, group *sync.WaitGroup, result *speedmap.Result) {
+	defer group.Done()
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(i)))
+	zipf := rand.NewZipf(rng, w.skew, 1, w.keys-1)
+	val, _ := GenerateRandomBytes(w.size)
+
+	var hits, misses uint64
+	for o := 0; o < OpsPerThread; o++ {
+		key := fmt.Sprintf("%X", zipf.Uint64())
+
+		if rng.Float32() <= w.readratio {
+			// Get a key, filling the store on a miss
+			if _, err := store.Get(key); err == nil {
+				hits++
+				continue
+			}
+			misses++
+		}
+
+		// Put a key
+		store.Put(key, val)
+	}
+
+	atomic.AddUint64(&result.Hits, hits)
+	atomic.AddUint64(&result.Misses, misses)
+}
+
+// String returns a representation of the skewed workload
+func (w *Skewed) String() string {
+	switch w.readratio {
+	case 1.0:
+		return fmt.Sprintf("zipf %0.2f read-only", w.skew)
+	case 0.0:
+		return fmt.Sprintf("zipf %0.2f write-only", w.skew)
+	default:
+		return fmt.Sprintf("zipf %0.2f %0.0f%% reads", w.skew, w.readratio*100)
+	}
+}
@@ -0,0 +1,36 @@
+package workload_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap/store"
+	. "github.com/bbengfort/speedmap/workload"
+)
+
+var _ = Describe("Skewed", func() {
+
+	It("should report the hit ratio of an unbounded store", func() {
+		basic, err := store.NewBasic()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		result, err := NewSkewed(1.2, 1.0).Run(basic, 4)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result.Operations).Should(BeEquivalentTo(4 * OpsPerThread))
+		Ω(result.Hits + result.Misses).Should(BeEquivalentTo(4 * OpsPerThread))
+		Ω(result.HitRatio()).Should(BeNumerically(">", 0.5))
+		Ω(result.Evictions).Should(BeZero())
+	})
+
+	It("should report the evictions of a bounded store", func() {
+		cache, err := store.NewCache(256, 0, store.NewTinyLFU)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		result, err := NewSkewed(1.01, 0.9).Run(cache, 4)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result.Evictions).Should(BeNumerically(">", 0))
+		Ω(result.HitRatio()).Should(BeNumerically(">", 0.0))
+		Ω(result.HitRatio()).Should(BeNumerically("<", 1.0))
+	})
+
+})
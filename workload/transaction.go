@@ -0,0 +1,112 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bbengfort/speedmap"
+)
+
+// TxnSize is the number of keys accessed by each transaction.
+const TxnSize = 4
+
+// NewTransaction workload with the specified conflict probability.
+func NewTransaction(prob, readratio float32) *Transaction {
+	return &Transaction{
+		readratio: readratio,
+		prob:      prob,
+		keys:      MaxKeys,
+		size:      TxnSize,
+	}
+}
+
+// Transaction is the transactional variant of the Conflict workload: each
+// client executes transactions that access several keys in its own key range
+// or, with the conflict probability, in the shared range. Reads are recorded
+// in the transaction and writes are read-modify-writes of the key, so that
+// concurrent transactions on the same key conflict and one of them aborts.
+// Aborted transactions are not retried, instead they are reported in the
+// result so that the abort rate can be compared as the conflict probability
+// increases. The store must implement speedmap.Transactional.
+type Transaction struct {
+	readratio float32 // ratio of reads to writes
+	prob      float32 // probability of conflict
+	keys      int64   // the number of keys (each key identified by number)
+	size      int     // the number of keys accessed per transaction
+}
+
+// Run the transaction workload for the specified number of clients.
+func (t *Transaction) Run(store speedmap.Store, clients int) (*speedmap.Result, error) {
+	txns, ok := speedmap.Unwrap(store).(speedmap.Transactional)
+	if !ok {
+		return nil, fmt.Errorf("%s store does not support transactions", store)
+	}
+
+	result := &speedmap.Result{Store: store, Workload: t, Concurrency: clients}
+
+	group := &sync.WaitGroup{}
+	group.Add(clients)
+
+	start := time.Now()
+	for i := 1; i <= clients; i++ {
+		go t.client(i, txns, group, &result.Aborts)
+	}
+	group.Wait()
+	result.Duration = time.Since(start)
+	result.Operations = uint64(clients)*uint64(OpsPerThread) - result.Aborts
+
+	return result, nil
+}
+
+// Runs the ith client in a go routine, executing OpsPerThread transactions
+// and counting the number that were aborted. Note that i must be 1-index to
+// ensure that keyspace 0 is the conflict space.
+func (t *Transaction) client(i int, store speedmap.Transactional, group *sync.WaitGroup, aborts *uint64) {
+	r := int64(i)
+
+	for o := 0; o < OpsPerThread; o++ {
+		txn := store.Begin()
+		val := []byte(fmt.Sprintf("%X-%X", r, o))
+
+		for k := 0; k < t.size; k++ {
+			var key string
+			if rand.Float32() < t.prob {
+				// We have a conflict select any key in the shared key group
+				key = RandomKey(0, t.keys)
+			} else {
+				// Select a key in the clients own keyspace
+				key = RandomKey(r, t.keys)
+			}
+
+			// Every access reads the key, writes also update it
+			txn.Get(key)
+			if rand.Float32() > t.readratio {
+				txn.Put(key, val)
+			}
+		}
+
+		if err := txn.Commit(); errors.Is(err, speedmap.ErrConflict) {
+			atomic.AddUint64(aborts, 1)
+		}
+	}
+	group.Done()
+}
+
+// String returns a representation of the transaction workload
+func (t *Transaction) String() string {
+	prob := t.prob * 100
+
+	if t.readratio == 1.0 {
+		return fmt.Sprintf("%0.0f%% conflict read-only transactions", prob)
+	}
+
+	if t.readratio == 0.0 {
+		return fmt.Sprintf("%0.0f%% conflict write-only transactions", prob)
+	}
+
+	return fmt.Sprintf("%0.0f%% conflict %0.0f%% reads transactions", prob, t.readratio*100)
+}
@@ -0,0 +1,52 @@
+package workload_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bbengfort/speedmap"
+	"github.com/bbengfort/speedmap/store"
+	. "github.com/bbengfort/speedmap/workload"
+)
+
+var _ = Describe("Transaction", func() {
+
+	It("should require a transactional store", func() {
+		basic, err := store.NewBasic()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = NewTransaction(0.5, 0.5).Run(basic, 2)
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should run on a transactional store adapted to contexts", func() {
+		vers, err := store.NewVersioned()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		result, err := NewTransaction(0.0, 0.5).Run(speedmap.WithContext(vers), 2)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result.Operations).Should(BeEquivalentTo(2 * OpsPerThread))
+	})
+
+	It("should not abort transactions without conflicts", func() {
+		vers, err := store.NewVersioned()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		result, err := NewTransaction(0.0, 0.5).Run(vers, 4)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result.Aborts).Should(BeZero())
+		Ω(result.Operations).Should(BeEquivalentTo(4 * OpsPerThread))
+	})
+
+	It("should count aborted transactions with conflicts", func() {
+		vers, err := store.NewVersioned()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		result, err := NewTransaction(1.0, 0.0).Run(vers, 4)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result.Operations + result.Aborts).Should(BeEquivalentTo(4 * OpsPerThread))
+		Ω(result.Aborts).Should(BeNumerically(">", 0))
+		Ω(result.AbortRate()).Should(BeNumerically("<", 1.0))
+	})
+
+})